@@ -36,8 +36,10 @@ router.get('/', async (req, res) => {
 
         res.json({
             success: true,
-            count: result.length,
-            data: result
+            count: result.count,
+            data: result.classes,
+            truncated: result.truncated,
+            bookmark: result.bookmark
         });
 
     } catch (error) {
@@ -149,7 +149,9 @@ router.post('/init', async (req, res) => {
 router.get('/classes', async (req, res) => {
     try {
         const result = await runScript('query', 'ClassContract:GetAllClasses');
-        res.json({ success: true, data: Array.isArray(result) ? result : [] });
+        const classes = result && Array.isArray(result.classes) ? result.classes : [];
+        // truncated: liste incomplete, la suite via GetAllClassesPaginated(bookmark)
+        res.json({ success: true, data: classes, truncated: Boolean(result && result.truncated), bookmark: (result && result.bookmark) || '' });
     } catch (error) {
         const { status, error: msg } = classifyError(error);
         res.status(status).json({ success: false, error: msg });
@@ -240,7 +242,8 @@ router.post('/materials', async (req, res) => {
 router.get('/exams', async (req, res) => {
     try {
         const result = await runScript('query', 'AcademicContract:GetAllExams');
-        res.json({ success: true, data: Array.isArray(result) ? result : [] });
+        const exams = result && Array.isArray(result.exams) ? result.exams : [];
+        res.json({ success: true, data: exams, truncated: Boolean(result && result.truncated) });
     } catch (error) {
         const { status, error: msg } = classifyError(error);
         res.status(status).json({ success: false, error: msg });
@@ -270,7 +273,8 @@ router.post('/exams', async (req, res) => {
 router.get('/grades', async (req, res) => {
     try {
         const result = await runScript('query', 'AcademicContract:GetAllGrades');
-        res.json({ success: true, data: Array.isArray(result) ? result : [] });
+        const grades = result && Array.isArray(result.grades) ? result.grades : [];
+        res.json({ success: true, data: grades, truncated: Boolean(result && result.truncated) });
     } catch (error) {
         const { status, error: msg } = classifyError(error);
         res.status(status).json({ success: false, error: msg });
@@ -167,12 +167,13 @@ router.get('/:materialId/file', authenticate, async (req, res) => {
  *   moduleId,
  *   title,
  *   type,      // "COURS" ou "TP"
- *   ipfsHash   // Hash IPFS du fichier uploadé
+ *   ipfsHash,  // Hash IPFS du fichier uploadé
+ *   size       // Taille du fichier en octets (requise si la classe a un quota)
  * }
  */
 router.post('/', authenticate, authorize(['teacher']), async (req, res) => {
     try {
-        const { materialId, classId, moduleId, title, type, ipfsHash } = req.body;
+        const { materialId, classId, moduleId, title, type, ipfsHash, size } = req.body;
         const { userId, orgMSP } = req.user;
 
         // Validation
@@ -203,7 +204,8 @@ router.post('/', authenticate, authorize(['teacher']), async (req, res) => {
             moduleId,
             title,
             type,
-            ipfsHash
+            ipfsHash,
+            size !== undefined ? String(size) : ''
         );
 
         res.status(201).json({
@@ -245,6 +247,13 @@ router.post('/', authenticate, authorize(['teacher']), async (req, res) => {
             });
         }
 
+        if (error.message.includes('Storage quota exceeded')) {
+            return res.status(413).json({
+                success: false,
+                error: error.message
+            });
+        }
+
         res.status(500).json({
             success: false,
             error: error.message
@@ -419,7 +428,8 @@ router.post('/upload', authenticate, authorize(['teacher']), upload.single('file
             moduleId,
             title,
             type,
-            ipfsResult.cid
+            ipfsResult.cid,
+            String(req.file.size)
         );
 
         console.log(`   On-chain registration complete: ${materialId}`);
@@ -472,6 +482,13 @@ router.post('/upload', authenticate, authorize(['teacher']), upload.single('file
             });
         }
 
+        if (error.message.includes('Storage quota exceeded')) {
+            return res.status(413).json({
+                success: false,
+                error: error.message
+            });
+        }
+
         res.status(500).json({
             success: false,
             error: error.message
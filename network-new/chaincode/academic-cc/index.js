@@ -6,6 +6,17 @@
  * - lib/material.js: Gestion des supports de cours (IPFS)
  * - lib/exam.js: Gestion des examens et corrections
  * - lib/grade.js: Gestion des notes (avec CouchDB queries)
+ * - lib/appeal.js: Contestations de notes
+ * - lib/config.js: Configuration système (délais, limites)
+ * - lib/audit.js: Journal d'audit des actions sensibles
+ * - lib/role.js: Registre des rôles (admin, teacher)
+ * - lib/search.js: Recherche textuelle multi-assets
+ * - lib/notification.js: Notifications par destinataire
+ * - lib/pin.js: Suivi de l'épinglage des contenus IPFS
+ * - lib/records.js: Lecture typée des enregistrements (docType)
+ * - lib/gradechain.js: Chaîne de hachage des notes par examen
+ * - lib/ids.js: Identifiants déterministes des enregistrements créés automatiquement
+ * - lib/time.js: Normalisation des dates en UTC
  * - index.js: Point d'entrée et contrat principal (legacy)
  *
  * Organizations: SchoolOrg (SchoolMSP) + StudentsOrg (StudentsMSP)
@@ -18,6 +29,17 @@ const ClassContract = require('./lib/class');
 const MaterialContract = require('./lib/material');
 const ExamContract = require('./lib/exam');
 const GradeContract = require('./lib/grade');
+const AppealContract = require('./lib/appeal');
+const ConfigContract = require('./lib/config');
+const AuditContract = require('./lib/audit');
+const RoleContract = require('./lib/role');
+const SearchContract = require('./lib/search');
+const NotificationContract = require('./lib/notification');
+const PinContract = require('./lib/pin');
+const { parseRecord, serializeRecord, MAX_QUERY_RESULTS } = require('./lib/records');
+const { getExamMaxScore, getGradingScheme, DEFAULT_MAX_SCORE } = require('./lib/exam');
+const { isTeachingStaff } = require('./lib/role');
+const { normalizeDate } = require('./lib/time');
 const { Contract } = require('fabric-contract-api');
 
 /**
@@ -38,6 +60,18 @@ class AcademicContract extends Contract {
         return new Date(seconds * 1000).toISOString();
     }
 
+    /**
+     * Examen tel qu'exposé par les getters historiques: ils ne vérifient ni l'inscription ni
+     * le délai de mise à disposition, la correction est donc toujours masquée
+     * (ExamContract:GetCorrectionFile)
+     */
+    _maskCorrection(exam) {
+        if (exam.correctionFileHash) {
+            exam.correctionFileHash = null;
+        }
+        return exam;
+    }
+
     // ==================== INITIALIZATION ====================
 
     async InitLedger(ctx) {
@@ -63,9 +97,8 @@ class AcademicContract extends Contract {
     async UploadMaterial(ctx, materialId, classId, title, materialType, ipfsHash, uploadedBy) {
         console.info('============= START : Upload Material ===========');
 
-        // Vérifier que l'appelant est SchoolOrg
-        const mspID = ctx.clientIdentity.getMSPID();
-        if (mspID !== 'SchoolMSP') {
+        // Vérifier que l'appelant fait partie de l'équipe pédagogique (rôle non révoqué)
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members can upload materials');
         }
 
@@ -86,7 +119,7 @@ class AcademicContract extends Contract {
             uploadedAt: this._getTxTimestamp(ctx),
         };
 
-        await ctx.stub.putState(materialId, Buffer.from(JSON.stringify(material)));
+        await ctx.stub.putState(materialId, serializeRecord(material));
 
         ctx.stub.setEvent('MaterialUploaded', Buffer.from(JSON.stringify({
             materialId: materialId,
@@ -122,12 +155,11 @@ class AcademicContract extends Contract {
 
     // ==================== EXAMS ====================
 
-    async CreateExam(ctx, examId, classId, title, examDate, description) {
+    async CreateExam(ctx, examId, classId, title, examDate, description, maxScore) {
         console.info('============= START : Create Exam ===========');
 
-        // Seulement SchoolOrg peut créer des examens
-        const mspID = ctx.clientIdentity.getMSPID();
-        if (mspID !== 'SchoolMSP') {
+        // Seule l'équipe pédagogique de SchoolOrg peut créer des examens
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members can create exams');
         }
 
@@ -137,17 +169,27 @@ class AcademicContract extends Contract {
             throw new Error(`Class ${classId} does not exist`);
         }
 
+        const maxScoreNum = maxScore ? parseFloat(maxScore) : DEFAULT_MAX_SCORE;
+        if (isNaN(maxScoreNum) || maxScoreNum <= 0) {
+            throw new Error('Invalid maxScore: must be a strictly positive number');
+        }
+
+        // Même format de date qu'ExamContract: stockée en UTC, décalage d'origine conservé
+        const normalizedDate = normalizeDate(examDate, 'examDate');
+
         const exam = {
             docType: 'exam',
             examId: examId,
             classId: classId,
             title: title,
-            examDate: examDate,
+            examDate: normalizedDate.utc,
+            examDateOffset: normalizedDate.offset, // Décalage horaire saisi (affichage)
             description: description || '',
+            maxScore: maxScoreNum, // Note maximale commune à toutes les notes de l'examen
             createdAt: this._getTxTimestamp(ctx),
         };
 
-        await ctx.stub.putState(examId, Buffer.from(JSON.stringify(exam)));
+        await ctx.stub.putState(examId, serializeRecord(exam));
 
         ctx.stub.setEvent('ExamCreated', Buffer.from(JSON.stringify({
             examId: examId,
@@ -163,11 +205,14 @@ class AcademicContract extends Contract {
         if (!examAsBytes || examAsBytes.length === 0) {
             throw new Error(`Exam ${examId} does not exist`);
         }
-        return examAsBytes.toString();
+        const exam = parseRecord(examAsBytes, examId, 'exam');
+        return JSON.stringify(this._maskCorrection(exam));
     }
 
     async GetAllExams(ctx) {
+        // Au plus MAX_QUERY_RESULTS résultats: au-delà, truncated=true
         const allResults = [];
+        let truncated = false;
         const iterator = await ctx.stub.getStateByRange('', '');
         let result = await iterator.next();
 
@@ -177,7 +222,11 @@ class AcademicContract extends Contract {
             try {
                 record = JSON.parse(strValue);
                 if (record.docType === 'exam') {
-                    allResults.push(record);
+                    if (allResults.length === MAX_QUERY_RESULTS) {
+                        truncated = true;
+                        break;
+                    }
+                    allResults.push(this._maskCorrection(record));
                 }
             } catch (err) {
                 console.log(err);
@@ -185,7 +234,7 @@ class AcademicContract extends Contract {
             result = await iterator.next();
         }
         await iterator.close();
-        return JSON.stringify(allResults);
+        return JSON.stringify({ exams: allResults, count: allResults.length, truncated: truncated });
     }
 
     async GetClassExams(ctx, classId) {
@@ -199,7 +248,7 @@ class AcademicContract extends Contract {
             try {
                 record = JSON.parse(strValue);
                 if (record.docType === 'exam' && record.classId === classId) {
-                    allResults.push(record);
+                    allResults.push(this._maskCorrection(record));
                 }
             } catch (err) {
                 console.log(err);
@@ -215,31 +264,26 @@ class AcademicContract extends Contract {
     async SubmitGrade(ctx, gradeId, examId, studentId, score, maxScore, comments) {
         console.info('============= START : Submit Grade ===========');
 
-        // Seulement SchoolOrg peut soumettre des notes
-        const mspID = ctx.clientIdentity.getMSPID();
-        if (mspID !== 'SchoolMSP') {
+        // Seule l'équipe pédagogique de SchoolOrg peut soumettre des notes
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members can submit grades');
         }
 
-        // Vérifier que l'examen existe
-        const examAsBytes = await ctx.stub.getState(examId);
-        if (!examAsBytes || examAsBytes.length === 0) {
-            throw new Error(`Exam ${examId} does not exist`);
+        // Toutes les notes d'un examen partagent la note maximale de l'examen
+        const gradeContract = new GradeContract();
+        const exam = await gradeContract._getExam(ctx, examId);
+        if (getGradingScheme(exam) === 'passfail') {
+            throw new Error(`Exam ${examId} is graded pass/fail: use GradeContract:SubmitGrade with "pass" or "fail"`);
+        }
+        const examMaxScore = getExamMaxScore(exam);
+        const maxScoreNum = maxScore !== undefined && maxScore !== '' ? parseFloat(maxScore) : examMaxScore;
+        if (maxScoreNum !== examMaxScore) {
+            throw new Error(`Invalid maxScore: ${maxScore} does not match the maxScore ${examMaxScore} of exam ${examId}`);
         }
 
-        const grade = {
-            docType: 'grade',
-            gradeId: gradeId,
-            examId: examId,
-            studentId: studentId,
-            score: parseFloat(score),
-            maxScore: parseFloat(maxScore),
-            comments: comments || '',
-            isPublished: false,
-            submittedAt: this._getTxTimestamp(ctx),
-        };
-
-        await ctx.stub.putState(gradeId, Buffer.from(JSON.stringify(grade)));
+        // Même création que GradeContract:SubmitGrade (inscription, note unique ou figée,
+        // saisie ouverte, verrou de relecture, chaîne de hachage de l'examen)
+        const grade = await gradeContract._createGrade(ctx, gradeId, examId, studentId, score, comments, 'scored', false);
 
         ctx.stub.setEvent('GradeSubmitted', Buffer.from(JSON.stringify({
             gradeId: gradeId,
@@ -252,9 +296,8 @@ class AcademicContract extends Contract {
     }
 
     async PublishGrade(ctx, gradeId) {
-        // Seulement SchoolOrg peut publier des notes
-        const mspID = ctx.clientIdentity.getMSPID();
-        if (mspID !== 'SchoolMSP') {
+        // Seule l'équipe pédagogique de SchoolOrg peut publier des notes
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members can publish grades');
         }
 
@@ -263,11 +306,26 @@ class AcademicContract extends Contract {
             throw new Error(`Grade ${gradeId} does not exist`);
         }
 
-        const grade = JSON.parse(gradeAsBytes.toString());
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+
+        // Mêmes contrôles de publication que GradeContract (annulation, note provisoire,
+        // approbation de la classe, embargo, verrou de relecture)
+        if (grade.voided) {
+            throw new Error(`Grade ${gradeId} is voided and cannot be published`);
+        }
+        if (grade.provisional === true) {
+            throw new Error(`Grade ${gradeId} is provisional: confirm it first (GradeContract:ConfirmGrade)`);
+        }
+        const gradeContract = new GradeContract();
+        const exam = await gradeContract._getExam(ctx, grade.examId);
+        await gradeContract._checkReleaseApproval(ctx, exam);
+        await gradeContract._checkReleaseWindow(ctx, exam, gradeId);
+        await gradeContract._checkReviewLock(ctx, grade.examId);
+
         grade.isPublished = true;
         grade.publishedAt = this._getTxTimestamp(ctx);
 
-        await ctx.stub.putState(gradeId, Buffer.from(JSON.stringify(grade)));
+        await ctx.stub.putState(gradeId, serializeRecord(grade));
 
         ctx.stub.setEvent('GradePublished', Buffer.from(JSON.stringify({
             gradeId: gradeId,
@@ -283,7 +341,7 @@ class AcademicContract extends Contract {
             throw new Error(`Grade ${gradeId} does not exist`);
         }
 
-        const grade = JSON.parse(gradeAsBytes.toString());
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
 
         // Si c'est un étudiant, il ne peut voir que ses propres notes publiées
         const mspID = ctx.clientIdentity.getMSPID();
@@ -311,7 +369,9 @@ class AcademicContract extends Contract {
             throw new Error('Access Denied: Only SchoolOrg members can view all grades');
         }
 
+        // Au plus MAX_QUERY_RESULTS résultats: au-delà, truncated=true
         const allResults = [];
+        let truncated = false;
         const iterator = await ctx.stub.getStateByRange('', '');
         let result = await iterator.next();
 
@@ -321,6 +381,10 @@ class AcademicContract extends Contract {
             try {
                 record = JSON.parse(strValue);
                 if (record.docType === 'grade') {
+                    if (allResults.length === MAX_QUERY_RESULTS) {
+                        truncated = true;
+                        break;
+                    }
                     allResults.push(record);
                 }
             } catch (err) {
@@ -329,7 +393,7 @@ class AcademicContract extends Contract {
             result = await iterator.next();
         }
         await iterator.close();
-        return JSON.stringify(allResults);
+        return JSON.stringify({ grades: allResults, count: allResults.length, truncated: truncated });
     }
 
     async GetStudentGrades(ctx, studentId) {
@@ -400,5 +464,9 @@ class AcademicContract extends Contract {
     }
 }
 
-// Exporter les cinq contrats
-module.exports.contracts = [AcademicContract, ClassContract, MaterialContract, ExamContract, GradeContract];
+// Exporter les contrats
+module.exports.contracts = [
+    AcademicContract, ClassContract, MaterialContract, ExamContract, GradeContract,
+    AppealContract, ConfigContract, AuditContract, RoleContract, SearchContract,
+    NotificationContract, PinContract,
+];
@@ -0,0 +1,474 @@
+/*
+ * Grade Appeal Smart Contract
+ *
+ * Cycle de vie: pending -> accepted | rejected (teacher)
+ *               rejected -> escalated (étudiant) -> accepted | rejected (department-head)
+ *
+ * Contrôle d'accès:
+ * - Dépôt: l'étudiant concerné uniquement, dans le délai après publication
+ * - Décision: teachers de la classe ou admin
+ * - Escalade: l'étudiant concerné, dans le délai après le rejet
+ * - Décision sur escalade: department-head uniquement
+ * - Consultation: Teachers + étudiant concerné
+ * - Statistiques par classe: teachers de la classe ou admin
+ * - Délais configurables via ConfigContract (appealWindowDays, appealEscalationDays)
+ */
+
+'use strict';
+
+const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord } = require('./records');
+const { getSystemConfig } = require('./config');
+const { getCallerRole, isTeachingStaff } = require('./role');
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+// Décisions possibles sur une contestation (teacher ou department-head)
+const APPEAL_DECISIONS = ['accepted', 'rejected'];
+
+// Statuts d'une contestation, dans l'ordre du cycle de vie (GetAppealStatistics)
+const APPEAL_STATUSES = ['pending', 'accepted', 'rejected', 'escalated'];
+
+class AppealContract extends Contract {
+
+    // ==================== CONTRÔLES D'ACCÈS ====================
+
+    /**
+     * Vérifie si l'appelant appartient à SchoolOrg (teachers/admin)
+     */
+    _isSchoolMember(ctx) {
+        const mspID = ctx.clientIdentity.getMSPID();
+        return mspID === 'SchoolMSP';
+    }
+
+    /**
+     * Vérifie si l'appelant appartient à StudentsOrg
+     */
+    _isStudentMember(ctx) {
+        const mspID = ctx.clientIdentity.getMSPID();
+        return mspID === 'StudentsMSP';
+    }
+
+    /**
+     * Récupère l'ID de l'utilisateur appelant
+     * Format: x509::/CN=User1@school.academic.edu/...
+     */
+    _getCallerIdentity(ctx) {
+        const userID = ctx.clientIdentity.getID();
+        // Extraire le CN (Common Name) de l'identité X.509
+        const match = userID.match(/CN=([^,/]+)/);
+        return match ? match[1] : userID;
+    }
+
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
+    // ==================== FONCTIONS MÉTIER ====================
+
+    /**
+     * 1. Contester une note
+     *
+     * Accessible par: L'étudiant concerné uniquement
+     * RÈGLE TEMPORELLE: Uniquement dans les N jours suivant la publication
+     * (N = appealWindowDays de la configuration système)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} appealId - ID unique de la contestation (ex: "appeal-grade1")
+     * @param {string} gradeId - ID de la note contestée
+     * @param {string} reason - Motif de la contestation
+     * @returns {string} JSON de la contestation
+     */
+    async FileGradeAppeal(ctx, appealId, gradeId, reason) {
+        console.info('============= START : FileGradeAppeal ===========');
+
+        if (!this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: Only students can file grade appeals');
+        }
+
+        if (!reason || !reason.trim()) {
+            throw new Error('Missing reason: an appeal must explain what is contested');
+        }
+
+        const exists = await ctx.stub.getState(appealId);
+        if (exists && exists.length > 0) {
+            throw new Error(`Appeal ${appealId} already exists`);
+        }
+
+        const gradeAsBytes = await ctx.stub.getState(gradeId);
+        if (!gradeAsBytes || gradeAsBytes.length === 0) {
+            throw new Error(`Grade ${gradeId} does not exist`);
+        }
+
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+
+        const caller = this._getCallerIdentity(ctx);
+        if (grade.studentId !== caller) {
+            throw new Error('Access Denied: You can only appeal your own grades');
+        }
+
+        if (!grade.publishedAt) {
+            throw new Error(`Grade ${gradeId} has not been published yet`);
+        }
+
+        // RÈGLE TEMPORELLE: délai calculé depuis la publication, comparé au timestamp de la transaction
+        const config = await getSystemConfig(ctx);
+        const filedAt = this._getTxTimestamp(ctx);
+        const deadline = new Date(new Date(grade.publishedAt).getTime() + config.appealWindowDays * DAY_MS);
+
+        if (new Date(filedAt) > deadline) {
+            throw new Error(`Appeal deadline passed: appeals for grade ${gradeId} closed on ${deadline.toISOString()} (${config.appealWindowDays} days after publication)`);
+        }
+
+        const appeal = {
+            docType: 'appeal',
+            id: appealId,
+            gradeId: gradeId,
+            examId: grade.examId,
+            classId: grade.classId,
+            studentId: caller,
+            reason: reason,
+            status: 'pending',
+            filedAt: filedAt,
+            deadline: deadline.toISOString(),
+        };
+
+        await ctx.stub.putState(appealId, serializeRecord(appeal));
+
+        ctx.stub.setEvent('GradeAppealFiled', Buffer.from(JSON.stringify({
+            appealId: appealId,
+            gradeId: gradeId,
+            classId: grade.classId,
+            studentId: caller,
+        })));
+
+        console.info(`✅ Appeal filed: ${appealId} on grade ${gradeId} by ${caller}`);
+        console.info('============= END : FileGradeAppeal ===========');
+
+        return JSON.stringify(appeal);
+    }
+
+    /**
+     * 2. Obtenir une contestation
+     *
+     * Accessible par: Teachers + étudiant concerné
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} appealId - ID de la contestation
+     * @returns {string} JSON de la contestation
+     */
+    async GetAppeal(ctx, appealId) {
+        const appealAsBytes = await ctx.stub.getState(appealId);
+        if (!appealAsBytes || appealAsBytes.length === 0) {
+            throw new Error(`Appeal ${appealId} does not exist`);
+        }
+
+        const appeal = parseRecord(appealAsBytes, appealId, 'appeal');
+
+        if (!this._isSchoolMember(ctx) && appeal.studentId !== this._getCallerIdentity(ctx)) {
+            throw new Error('Access Denied: You can only view your own appeals');
+        }
+
+        return JSON.stringify(appeal);
+    }
+
+    /**
+     * 3. Statuer sur une contestation
+     *
+     * Accessible par: Teachers / co-teachers de la classe + admins
+     * Uniquement une contestation en attente (pending)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} appealId - ID de la contestation
+     * @param {string} decision - "accepted" ou "rejected"
+     * @param {string} response - Réponse motivée à l'étudiant
+     * @returns {string} JSON de la contestation
+     */
+    async ResolveAppeal(ctx, appealId, decision, response) {
+        console.info('============= START : ResolveAppeal ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only teachers can resolve grade appeals');
+        }
+
+        const appeal = await this._getAppeal(ctx, appealId);
+        await this._checkClassTeacher(ctx, appeal.classId, 'resolve this appeal');
+
+        if (appeal.status !== 'pending') {
+            throw new Error(`Appeal ${appealId} is ${appeal.status}: only pending appeals can be resolved`);
+        }
+        this._checkDecision(decision, response);
+
+        const caller = this._getCallerIdentity(ctx);
+        appeal.status = decision;
+        appeal.response = response;
+        appeal.resolvedBy = caller;
+        appeal.resolvedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(appealId, serializeRecord(appeal));
+
+        ctx.stub.setEvent('GradeAppealResolved', Buffer.from(JSON.stringify({
+            appealId: appealId,
+            gradeId: appeal.gradeId,
+            studentId: appeal.studentId,
+            decision: decision,
+            resolvedBy: caller,
+        })));
+
+        console.info(`✅ Appeal ${appealId} ${decision} by ${caller}`);
+        console.info('============= END : ResolveAppeal ===========');
+
+        return JSON.stringify(appeal);
+    }
+
+    /**
+     * 4. Escalader une contestation rejetée auprès du department-head
+     *
+     * Accessible par: L'étudiant concerné uniquement
+     * RÈGLE TEMPORELLE: uniquement dans les N jours suivant le rejet
+     * (N = appealEscalationDays de la configuration système)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} appealId - ID de la contestation
+     * @returns {string} JSON de la contestation
+     */
+    async EscalateAppeal(ctx, appealId) {
+        console.info('============= START : EscalateAppeal ===========');
+
+        if (!this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: Only students can escalate grade appeals');
+        }
+
+        const appeal = await this._getAppeal(ctx, appealId);
+
+        const caller = this._getCallerIdentity(ctx);
+        if (appeal.studentId !== caller) {
+            throw new Error('Access Denied: You can only escalate your own appeals');
+        }
+        if (appeal.status !== 'rejected' || appeal.escalatedAt) {
+            throw new Error(`Appeal ${appealId} is ${appeal.status}: only an appeal rejected by the teacher can be escalated`);
+        }
+
+        // RÈGLE TEMPORELLE: délai calculé depuis le rejet, comparé au timestamp de la transaction
+        const config = await getSystemConfig(ctx);
+        const escalatedAt = this._getTxTimestamp(ctx);
+        const deadline = new Date(new Date(appeal.resolvedAt).getTime() + config.appealEscalationDays * DAY_MS);
+
+        if (new Date(escalatedAt) > deadline) {
+            throw new Error(`Escalation deadline passed: appeal ${appealId} could be escalated until ${deadline.toISOString()} (${config.appealEscalationDays} days after rejection)`);
+        }
+
+        appeal.status = 'escalated';
+        appeal.escalatedAt = escalatedAt;
+        appeal.escalationDeadline = deadline.toISOString();
+
+        await ctx.stub.putState(appealId, serializeRecord(appeal));
+
+        ctx.stub.setEvent('GradeAppealEscalated', Buffer.from(JSON.stringify({
+            appealId: appealId,
+            gradeId: appeal.gradeId,
+            classId: appeal.classId,
+            studentId: caller,
+        })));
+
+        console.info(`✅ Appeal ${appealId} escalated by ${caller}`);
+        console.info('============= END : EscalateAppeal ===========');
+
+        return JSON.stringify(appeal);
+    }
+
+    /**
+     * 5. Statuer sur une contestation escaladée (décision définitive)
+     *
+     * Accessible par: department-head uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} appealId - ID de la contestation
+     * @param {string} decision - "accepted" ou "rejected"
+     * @param {string} response - Réponse motivée à l'étudiant
+     * @returns {string} JSON de la contestation
+     */
+    async ResolveEscalatedAppeal(ctx, appealId, decision, response) {
+        console.info('============= START : ResolveEscalatedAppeal ===========');
+
+        if (await getCallerRole(ctx) !== 'department-head') {
+            throw new Error('Access Denied: Only a department head can resolve escalated appeals');
+        }
+
+        const appeal = await this._getAppeal(ctx, appealId);
+        if (appeal.status !== 'escalated') {
+            throw new Error(`Appeal ${appealId} is ${appeal.status}: only escalated appeals can be resolved by a department head`);
+        }
+        this._checkDecision(decision, response);
+
+        const caller = this._getCallerIdentity(ctx);
+        appeal.status = decision;
+        appeal.escalationResponse = response;
+        appeal.escalationResolvedBy = caller;
+        appeal.escalationResolvedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(appealId, serializeRecord(appeal));
+
+        ctx.stub.setEvent('GradeAppealEscalationResolved', Buffer.from(JSON.stringify({
+            appealId: appealId,
+            gradeId: appeal.gradeId,
+            studentId: appeal.studentId,
+            decision: decision,
+            resolvedBy: caller,
+        })));
+
+        console.info(`✅ Escalated appeal ${appealId} ${decision} by ${caller}`);
+        console.info('============= END : ResolveEscalatedAppeal ===========');
+
+        return JSON.stringify(appeal);
+    }
+
+    /**
+     * 6. Statistiques des contestations d'une classe
+     *
+     * Accessible par: Teachers / co-teachers de la classe + admins
+     * Contestations comptées par statut actuel (une contestation escaladée puis tranchée
+     * compte dans accepted/rejected et dans escalatedTotal). Taux de contestation:
+     * notes publiées (non annulées) ayant au moins une contestation / notes publiées.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @returns {string} JSON { classId, total, byStatus, escalatedTotal, gradeCount, appealedGradeCount, appealRatePercent }
+     */
+    async GetAppealStatistics(ctx, classId) {
+        console.info('============= START : GetAppealStatistics ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only teachers can view appeal statistics');
+        }
+
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${classId} does not exist`);
+        }
+        await this._checkClassTeacher(ctx, classId, 'view its appeal statistics');
+
+        const appeals = await this._queryRecords(ctx, { docType: 'appeal', classId: classId });
+        const grades = (await this._queryRecords(ctx, { docType: 'grade', classId: classId }))
+            .filter((grade) => grade.isPublished !== false && !grade.voided);
+
+        const byStatus = {};
+        for (const status of APPEAL_STATUSES) {
+            byStatus[status] = 0;
+        }
+        for (const appeal of appeals) {
+            byStatus[appeal.status] = (byStatus[appeal.status] || 0) + 1;
+        }
+
+        const appealedGradeIds = new Set(appeals.map((appeal) => appeal.gradeId));
+        const appealedGradeCount = grades.filter((grade) => appealedGradeIds.has(grade.id)).length;
+        const appealRatePercent = grades.length > 0
+            ? Math.round((appealedGradeCount / grades.length) * 10000) / 100
+            : null;
+
+        console.info(`✅ Appeal statistics of ${classId}: ${appeals.length} appeals on ${grades.length} published grades`);
+        console.info('============= END : GetAppealStatistics ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            total: appeals.length,
+            byStatus: byStatus,
+            escalatedTotal: appeals.filter((appeal) => appeal.escalatedAt).length,
+            gradeCount: grades.length,
+            appealedGradeCount: appealedGradeCount,
+            appealRatePercent: appealRatePercent,
+        });
+    }
+
+    // ==================== FONCTIONS UTILITAIRES ====================
+
+    /**
+     * Récupère une contestation et vérifie son type
+     * @private
+     */
+    async _getAppeal(ctx, appealId) {
+        const appealAsBytes = await ctx.stub.getState(appealId);
+        if (!appealAsBytes || appealAsBytes.length === 0) {
+            throw new Error(`Appeal ${appealId} does not exist`);
+        }
+        return parseRecord(appealAsBytes, appealId, 'appeal');
+    }
+
+    /**
+     * Vérifie que l'appelant est teacher / co-teacher de la classe, ou admin
+     * @private
+     * @throws {Error} Sinon
+     */
+    async _checkClassTeacher(ctx, classId, action) {
+        const caller = this._getCallerIdentity(ctx);
+        const classAsBytes = classId ? await ctx.stub.getState(classId) : null;
+        if (classAsBytes && classAsBytes.length > 0) {
+            const classData = parseRecord(classAsBytes, classId, 'class');
+            const isTeacher = (classData.teacher || classData.createdBy) === caller ||
+                (classData.staff || []).some((member) => member.identityId === caller && member.role === 'co-teacher');
+            if (isTeacher) {
+                return;
+            }
+        }
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error(`Access Denied: Only the teachers of class ${classId} or an admin can ${action}`);
+        }
+    }
+
+    /**
+     * Exécute un sélecteur d'égalité simple
+     * CouchDB si disponible, sinon parcours complet du ledger filtré sur les mêmes champs
+     * @private
+     */
+    async _queryRecords(ctx, selector) {
+        const queryString = JSON.stringify({ selector: selector });
+        const allResults = [];
+        let iterator;
+
+        try {
+            iterator = await ctx.stub.getQueryResult(queryString);
+        } catch (err) {
+            // Fallback si CouchDB non disponible: parcours complet, filtré ci-dessous
+            console.warn('CouchDB query failed, using fallback method:', err);
+            iterator = await ctx.stub.getStateByRange('', '');
+        }
+
+        let result = await iterator.next();
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if (Object.keys(selector).every((key) => record[key] === selector[key])) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
+    }
+
+    /**
+     * Valide une décision et sa réponse motivée
+     * @private
+     */
+    _checkDecision(decision, response) {
+        if (!APPEAL_DECISIONS.includes(decision)) {
+            throw new Error(`Invalid decision: ${decision} (supported: ${APPEAL_DECISIONS.join(', ')})`);
+        }
+        if (!response || !response.trim()) {
+            throw new Error('Missing response: a decision must be explained to the student');
+        }
+    }
+}
+
+module.exports = AppealContract;
@@ -0,0 +1,189 @@
+/*
+ * Audit Trail Smart Contract
+ *
+ * Journal des actions sensibles (dérogations, suppressions, corrections)
+ * Chaque entrée enregistre qui, quand, quoi et pourquoi.
+ *
+ * Contrôle d'accès:
+ * - Écriture: uniquement via les autres contrats (writeAuditEntry)
+ * - Consultation: SchoolMSP uniquement (teachers/admin)
+ * - Liste des clés brutes du ledger (dépannage): admins uniquement
+ */
+
+'use strict';
+
+const { Contract } = require('fabric-contract-api');
+const { serializeRecord } = require('./records');
+const { getCallerRole } = require('./role');
+
+// Nombre maximal de clés renvoyées par ListKeysByPrefix
+const MAX_LISTED_KEYS = 500;
+
+// Caractères interdits dans un préfixe: contrôles (dont \u0000, espace des clés composites)
+// et \uffff, utilisé comme borne de fin de plage
+const FORBIDDEN_PREFIX_CHARS = /[\u0000-\u001f\uffff]/;
+
+/**
+ * Récupère l'ID de l'utilisateur appelant (CN du certificat X.509)
+ */
+function getCallerIdentity(ctx) {
+    const userID = ctx.clientIdentity.getID();
+    const match = userID.match(/CN=([^,/]+)/);
+    return match ? match[1] : userID;
+}
+
+/**
+ * Écrit une entrée d'audit dans le ledger
+ *
+ * La clé dérive de l'ID de transaction: déterministe sur tous les peers
+ *
+ * @param {Context} ctx - Le contexte de transaction
+ * @param {string} action - Action auditée (ex: "GradePublishOverride")
+ * @param {string} targetId - Asset concerné (note, examen, classe...)
+ * @param {string} reason - Justification fournie par l'appelant
+ * @param {Object} [details] - Informations complémentaires
+ * @returns {Promise<Object>} Entrée d'audit créée
+ */
+async function writeAuditEntry(ctx, action, targetId, reason, details) {
+    const timestamp = ctx.stub.getTxTimestamp();
+    const seconds = timestamp.seconds.low || timestamp.seconds;
+
+    const entry = {
+        docType: 'audit',
+        id: `AUDIT_${ctx.stub.getTxID()}_${action}_${targetId}`,
+        action: action,
+        targetId: targetId,
+        actor: getCallerIdentity(ctx),
+        mspID: ctx.clientIdentity.getMSPID(),
+        reason: reason,
+        details: details || {},
+        txId: ctx.stub.getTxID(),
+        timestamp: new Date(seconds * 1000).toISOString(),
+    };
+
+    await ctx.stub.putState(entry.id, serializeRecord(entry));
+    return entry;
+}
+
+class AuditContract extends Contract {
+
+    // ==================== CONTRÔLES D'ACCÈS ====================
+
+    /**
+     * Vérifie si l'appelant appartient à SchoolOrg (teachers/admin)
+     */
+    _isSchoolMember(ctx) {
+        const mspID = ctx.clientIdentity.getMSPID();
+        return mspID === 'SchoolMSP';
+    }
+
+    // ==================== FONCTIONS MÉTIER ====================
+
+    /**
+     * Obtenir le journal d'audit d'un asset, trié chronologiquement
+     *
+     * Accessible par: SchoolOrg uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} targetId - ID de l'asset (note, examen, classe...)
+     * @returns {string} JSON array des entrées d'audit
+     */
+    async GetAuditTrail(ctx, targetId) {
+        console.info('============= START : GetAuditTrail ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view the audit trail');
+        }
+
+        const allResults = [];
+        const iterator = await ctx.stub.getStateByRange('AUDIT_', 'AUDIT_\uffff');
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            let record;
+
+            try {
+                record = JSON.parse(strValue);
+
+                if (record.docType === 'audit' && record.targetId === targetId) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+
+        allResults.sort((a, b) => a.timestamp.localeCompare(b.timestamp));
+
+        console.info(`✅ Retrieved ${allResults.length} audit entries for ${targetId}`);
+        console.info('============= END : GetAuditTrail ===========');
+
+        return JSON.stringify(allResults);
+    }
+
+    /**
+     * Lister les clés du ledger commençant par un préfixe (dépannage)
+     *
+     * Accessible par: admins uniquement
+     * Seules les clés sont renvoyées, jamais les valeurs. Au-delà de limit, truncated=true.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} prefix - Préfixe des clés (ex: "AUDIT_")
+     * @param {string} limit - Nombre maximal de clés (MAX_LISTED_KEYS au plus)
+     * @returns {string} JSON { prefix, limit, keys, count, truncated }
+     */
+    async ListKeysByPrefix(ctx, prefix, limit) {
+        console.info('============= START : ListKeysByPrefix ===========');
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can list ledger keys');
+        }
+
+        if (typeof prefix !== 'string' || prefix === '') {
+            throw new Error('Invalid prefix: must be a non-empty string');
+        }
+        if (FORBIDDEN_PREFIX_CHARS.test(prefix)) {
+            throw new Error('Invalid prefix: control characters and \\uffff are not allowed');
+        }
+
+        const limitNum = Number(limit);
+        if (!Number.isInteger(limitNum) || limitNum <= 0 || limitNum > MAX_LISTED_KEYS) {
+            throw new Error(`Invalid limit: must be an integer between 1 and ${MAX_LISTED_KEYS}`);
+        }
+
+        const keys = [];
+        let truncated = false;
+        const iterator = await ctx.stub.getStateByRange(prefix, prefix + '\uffff');
+        let result = await iterator.next();
+
+        while (!result.done) {
+            if (keys.length === limitNum) {
+                truncated = true;
+                break;
+            }
+            keys.push(result.value.key);
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+
+        console.info(`✅ Listed ${keys.length} keys with prefix ${prefix}${truncated ? ' (truncated)' : ''}`);
+        console.info('============= END : ListKeysByPrefix ===========');
+
+        return JSON.stringify({
+            prefix: prefix,
+            limit: limitNum,
+            keys: keys,
+            count: keys.length,
+            truncated: truncated,
+        });
+    }
+}
+
+module.exports = AuditContract;
+module.exports.writeAuditEntry = writeAuditEntry;
@@ -0,0 +1,25 @@
+/*
+ * Annulation des parcours longs du ledger
+ *
+ * fabric-shim ne transmet pas la déconnexion du client au chaincode: le
+ * contexte de transaction peut porter un signal d'annulation (ctx.signal,
+ * interface AbortSignal) fourni par l'hôte du chaincode (createContext d'un
+ * contrat, chaincode-as-a-service derrière une passerelle...). Les parcours
+ * le consultent avant chaque lecture et s'arrêtent avec un résultat partiel
+ * et la clé de reprise, au lieu de lire le ledger jusqu'au bout pour rien.
+ * Sans signal, un parcours n'est jamais interrompu.
+ */
+
+'use strict';
+
+/**
+ * Indique si la requête du contexte a été annulée
+ *
+ * @param {Context} ctx - Le contexte de transaction
+ * @returns {boolean} true si ctx.signal est annulé
+ */
+function isCancelled(ctx) {
+    return Boolean(ctx.signal && ctx.signal.aborted);
+}
+
+module.exports = { isCancelled };
@@ -9,6 +9,117 @@
 'use strict';
 
 const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord, MAX_QUERY_RESULTS } = require('./records');
+const { getCallerRole, isTeachingStaff } = require('./role');
+const { getSystemConfig } = require('./config');
+const { normalizeDate } = require('./time');
+const { createNotification } = require('./notification');
+const { writeAuditEntry } = require('./audit');
+const { appendGradeLink } = require('./gradechain');
+const { computeLatePenalty } = require('./exam');
+const { generateDeterministicID } = require('./ids');
+const { isCancelled } = require('./cancel');
+
+// Champs de configuration copiés par CloneClass en plus des champs de base
+const CLONED_CONFIG_FIELDS = ['prerequisites', 'gradingScale', 'maxTotalBytes', 'withdrawalPolicy', 'releaseApprovalRequired', 'gradingWindowDays',
+    'strictGradingDeadline', 'seatCapacities', 'maxWaitlist', 'department', 'level', 'tags', 'creditHours'];
+
+// Types de places: "lecture" (pool par défaut, capacité maxStudents) et pools distincts (seatCapacities)
+const SEAT_TYPES = ['lecture', 'lab'];
+const DEFAULT_SEAT_TYPE = 'lecture';
+
+// Rôles de l'équipe pédagogique d'une classe (en plus du teacher responsable)
+const STAFF_ROLES = ['co-teacher', 'ta'];
+
+// Modes d'EnrollStudentsBatch: atomic (tout ou rien, par défaut) ou besteffort (inscrit les éligibles)
+const BATCH_ENROLL_MODES = ['atomic', 'besteffort'];
+
+// Nombre maximal de sections classées par préférence (AutoAssignSection)
+const MAX_SECTION_PREFERENCES = 10;
+
+// Durée maximale d'une réservation de place (HoldSeat), en secondes
+const MAX_HOLD_TTL_SECONDS = 24 * 60 * 60;
+
+// Étiquettes d'une inscription (cohort, program...): nombre maximal et valeur des non-étiquetés
+const MAX_ENROLLMENT_TAGS = 10;
+const UNTAGGED = 'untagged';
+
+// Classement du catalogue: étiquettes libres par classe (nombre maximal) et critères de GetCatalogFiltered
+const MAX_CLASS_TAGS = 10;
+const CATALOG_FILTER_FIELDS = ['department', 'level', 'semester', 'tags'];
+
+// Crédits (heures) d'une classe, pondération de la moyenne GPA; appliqué aux classes créées sans crédits
+const DEFAULT_CREDIT_HOURS = 3;
+
+// Enregistrements rattachés à une classe (classId), déplacés par MergeClasses
+const CLASS_RECORD_TYPES = ['material', 'exam', 'grade', 'submission', 'appeal', 'incident'];
+
+// Nombre d'enregistrements (tous types) lus par page de ledger pour la liste publique des classes
+const CLASS_SCAN_PAGE_SIZE = 5000;
+
+/**
+ * Clé de l'enregistrement d'inscription d'un étudiant (dérivée de la classe et de l'étudiant)
+ */
+function enrollmentKey(classId, studentId) {
+    return generateDeterministicID('ENR', classId, studentId);
+}
+
+/**
+ * Ancienne clé d'inscription (ENR_<classId>_<studentId>), relue pour les inscriptions existantes
+ */
+function legacyEnrollmentKey(classId, studentId) {
+    return `ENR_${classId}_${studentId}`;
+}
+
+/**
+ * Clé du résultat figé d'un étudiant dans une classe (FinalizeClassOutcome)
+ */
+function outcomeKey(classId, studentId) {
+    return generateDeterministicID('OUTCOME', classId, studentId);
+}
+
+/**
+ * Ancienne clé du résultat figé (OUTCOME_<classId>_<studentId>), relue pour les résultats existants
+ */
+function legacyOutcomeKey(classId, studentId) {
+    return `OUTCOME_${classId}_${studentId}`;
+}
+
+/**
+ * Lit un enregistrement sous sa clé déterministe en priorité, sinon sous son ancienne clé (null si absent)
+ */
+async function getKeyedRecord(ctx, keys, docType) {
+    for (const key of keys) {
+        const recordAsBytes = await ctx.stub.getState(key);
+        if (recordAsBytes && recordAsBytes.length > 0) {
+            return parseRecord(recordAsBytes, key, docType);
+        }
+    }
+    return null;
+}
+
+/**
+ * Récupère l'enregistrement d'inscription d'un étudiant (null si absent)
+ */
+async function getEnrollmentRecord(ctx, classId, studentId) {
+    return getKeyedRecord(ctx, [enrollmentKey(classId, studentId), legacyEnrollmentKey(classId, studentId)], 'enrollment');
+}
+
+/**
+ * Récupère le résultat figé d'un étudiant dans une classe (null si absent)
+ */
+async function getOutcomeRecord(ctx, classId, studentId) {
+    return getKeyedRecord(ctx, [outcomeKey(classId, studentId), legacyOutcomeKey(classId, studentId)], 'classOutcome');
+}
+
+/**
+ * Crédits d'une classe (DEFAULT_CREDIT_HOURS pour les classes antérieures au champ)
+ */
+function getCreditHours(classData) {
+    return typeof classData.creditHours === 'number' && classData.creditHours > 0
+        ? classData.creditHours
+        : DEFAULT_CREDIT_HOURS;
+}
 
 class ClassContract extends Contract {
 
@@ -69,13 +180,15 @@ class ClassContract extends Contract {
      * @param {string} classId - Identifiant unique de la classe (ex: "CYBER101")
      * @param {string} name - Nom de la classe (ex: "Cybersécurité")
      * @param {string} description - Description du cours
+     * @param {string} [maxStudents] - Capacité maximale (optionnel: 0 ou absente = defaultMaxStudents de la configuration)
+     * @param {string} [semester] - Semestre (optionnel, ex: "2026-S1")
      * @returns {string} classId
      */
-    async CreateClass(ctx, classId, name, description) {
+    async CreateClass(ctx, classId, name, description, maxStudents, semester) {
         console.info('============= START : CreateClass ===========');
 
         // CONTRÔLE D'ACCÈS: Seulement SchoolOrg peut créer des classes
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members (teachers) can create classes');
         }
 
@@ -85,6 +198,19 @@ class ClassContract extends Contract {
             throw new Error(`Class ${classId} already exists`);
         }
 
+        // Valider la capacité (0 ou absente: capacité par défaut de la configuration)
+        let maxStudentsNum = maxStudents !== undefined && maxStudents !== '' ? Number(maxStudents) : 0;
+        if (!Number.isInteger(maxStudentsNum) || maxStudentsNum < 0) {
+            throw new Error('Invalid maxStudents: must be a positive integer');
+        }
+        if (maxStudentsNum === 0) {
+            maxStudentsNum = (await getSystemConfig(ctx)).defaultMaxStudents;
+        } else {
+            await this._checkMaxStudents(ctx, maxStudentsNum);
+        }
+
+        const maxWaitlist = (await getSystemConfig(ctx)).defaultMaxWaitlist;
+
         // Récupérer l'identité du créateur
         const createdBy = this._getCallerIdentity(ctx);
 
@@ -97,15 +223,37 @@ class ClassContract extends Contract {
             id: classId,
             name: name,
             description: description,
+            semester: semester || null,
+            department: null, // Département (ex: "CS"), classement du catalogue
+            level: null, // Niveau (ex: 100, 200), classement du catalogue
+            tags: [], // Étiquettes libres du catalogue (ex: "security")
+            creditHours: DEFAULT_CREDIT_HOURS, // Crédits, pondération de la moyenne GPA
+            teacher: createdBy, // Teacher responsable (par défaut le créateur)
+            staff: [], // Équipe pédagogique: [{ identityId, role }] (co-teacher, ta)
             modules: [], // Liste des modules du cours
             enrolledStudents: [], // Liste des étudiants inscrits
+            waitlist: [], // Liste d'attente (ordre d'arrivée)
+            maxWaitlist: maxWaitlist, // Places en liste d'attente, 0 = pas de liste d'attente
+            watchers: [], // Étudiants notifiés à l'ouverture d'une place (WatchClass)
+            holds: [], // Places réservées: [{ studentId, heldBy, heldAt, expiresAt }]
+            maxStudents: maxStudentsNum,
+            seatCapacities: {}, // Pools de places hors "lecture": { lab: 20 }, 0 = illimitée
+            seatCounts: {}, // Inscriptions actives par pool hors "lecture"
+            enrollmentMode: 'hard', // hard: refus si pleine, soft: sur-inscription signalée
+            enrolledCount: 0, // Compteur d'inscriptions actives (synchronisé avec enrolledStudents)
+            maxTotalBytes: 0, // Quota de stockage des supports (octets), 0 = illimité
+            withdrawalPolicy: 'keep', // keep: copies et notes conservées au retrait, void: annulées
+            prerequisites: [], // IDs des classes requises (graphe sans cycle)
+            releaseApprovalRequired: false, // true: publication des notes après approbation (ApproveGradeRelease)
+            gradingWindowDays: 0, // Délai de saisie des notes après examDate (jours), 0 = sans échéance
+            strictGradingDeadline: true, // true: saisie refusée après l'échéance, false: acceptée et signalée en retard
             createdBy: createdBy,
             createdAt: txTimestamp,
             updatedAt: txTimestamp,
         };
 
         // Stocker dans le ledger
-        await ctx.stub.putState(classId, Buffer.from(JSON.stringify(classData)));
+        await ctx.stub.putState(classId, serializeRecord(classData));
 
         // Émettre un événement
         ctx.stub.setEvent('ClassCreated', Buffer.from(JSON.stringify({
@@ -127,49 +275,175 @@ class ClassContract extends Contract {
      * Règle métier: "Description et organisation accessibles à tous"
      *
      * Retourne uniquement: id, name, description (sans modules ni enrolledStudents)
+     * Au plus MAX_QUERY_RESULTS classes: au-delà, ou si la requête est annulée (cancelled),
+     * truncated=true et bookmark permet de poursuivre avec GetAllClassesPaginated
+     * (au lieu d'un échec opaque)
      *
      * @param {Context} ctx - Le contexte de transaction
-     * @returns {string} JSON array des classes (format public)
+     * @returns {string} JSON { classes, count, truncated, bookmark, cancelled }
      */
     async GetAllClasses(ctx) {
         console.info('============= START : GetAllClasses (PUBLIC) ===========');
 
         // PAS DE CONTRÔLE D'ACCÈS - Accessible à tous
 
-        const allResults = [];
+        const page = await this._getPublicClassesPage(ctx, '', MAX_QUERY_RESULTS);
 
-        // Récupérer tous les états du ledger
-        const iterator = await ctx.stub.getStateByRange('', '');
-        let result = await iterator.next();
+        if (page.bookmark) {
+            console.warn(`GetAllClasses ${page.cancelled ? 'cancelled' : 'truncated'} after ${page.classes.length} classes: use GetAllClassesPaginated`);
+        }
+        console.info(`✅ Retrieved ${page.classes.length} classes (public view)`);
+        console.info('============= END : GetAllClasses ===========');
 
-        while (!result.done) {
-            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
-            let record;
+        return JSON.stringify({
+            classes: page.classes,
+            count: page.classes.length,
+            truncated: page.bookmark !== '',
+            bookmark: page.bookmark,
+            cancelled: page.cancelled,
+        });
+    }
 
-            try {
-                record = JSON.parse(strValue);
+    /**
+     * Obtenir une page de la liste des classes (informations publiques)
+     *
+     * Accessible par: TOUS (public) - Pas de contrôle d'accès
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} pageSize - Nombre de classes par page (MAX_QUERY_RESULTS au plus)
+     * @param {string} [bookmark] - Bookmark renvoyé par l'appel précédent ("" pour la première page)
+     * @returns {string} JSON { classes, count, bookmark, cancelled } (bookmark "" sur la dernière page;
+     * une page annulée peut compter moins de pageSize classes sans être la dernière)
+     */
+    async GetAllClassesPaginated(ctx, pageSize, bookmark) {
+        console.info('============= START : GetAllClassesPaginated (PUBLIC) ===========');
 
-                // Filtrer uniquement les classes
-                if (record.docType === 'class') {
-                    // Retourner UNIQUEMENT les informations publiques
+        const size = Number(pageSize);
+        if (!Number.isInteger(size) || size <= 0 || size > MAX_QUERY_RESULTS) {
+            throw new Error(`Invalid pageSize: must be an integer between 1 and ${MAX_QUERY_RESULTS}`);
+        }
+
+        const page = await this._getPublicClassesPage(ctx, bookmark || '', size);
+
+        console.info(`✅ Retrieved ${page.classes.length} classes (public view), next bookmark: ${page.bookmark || 'none'}`);
+        console.info('============= END : GetAllClassesPaginated ===========');
+
+        return JSON.stringify({
+            classes: page.classes,
+            count: page.classes.length,
+            bookmark: page.bookmark,
+            cancelled: page.cancelled,
+        });
+    }
+
+    /**
+     * Rechercher les classes par nom
+     *
+     * Accessible par: TOUS (public) - même vue publique que GetAllClasses
+     * Les noms ne sont pas uniques: retourne toutes les correspondances
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} name - Nom exact de la classe (ex: "Cybersécurité")
+     * @returns {string} JSON array des classes correspondantes (format public)
+     */
+    async GetClassByName(ctx, name) {
+        console.info('============= START : GetClassByName (PUBLIC) ===========');
+
+        if (!name) {
+            throw new Error('Missing name: a class name is required');
+        }
+
+        const queryString = JSON.stringify({
+            selector: {
+                docType: 'class',
+                name: name
+            }
+        });
+
+        const allResults = [];
+
+        try {
+            // Utiliser CouchDB rich query pour optimisation
+            const iterator = await ctx.stub.getQueryResult(queryString);
+            let result = await iterator.next();
+
+            while (!result.done) {
+                const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+
+                try {
+                    const record = JSON.parse(strValue);
                     allResults.push({
                         id: record.id,
                         name: record.name,
                         description: record.description,
-                        // modules et enrolledStudents sont EXCLUS (informations privées)
                     });
+                } catch (err) {
+                    console.log('Error parsing class record:', err);
                 }
-            } catch (err) {
-                console.log('Error parsing record:', err);
+
+                result = await iterator.next();
             }
 
-            result = await iterator.next();
+            await iterator.close();
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            return await this._getClassByNameFallback(ctx, name);
         }
 
-        await iterator.close();
+        console.info(`✅ Found ${allResults.length} classes named "${name}"`);
+        console.info('============= END : GetClassByName ===========');
 
-        console.info(`✅ Retrieved ${allResults.length} classes (public view)`);
-        console.info('============= END : GetAllClasses ===========');
+        return JSON.stringify(allResults);
+    }
+
+    /**
+     * Filtrer le catalogue des classes (informations publiques)
+     *
+     * Accessible par: TOUS (public) - même vue publique que GetAllClasses
+     * Tous les critères fournis doivent être satisfaits (ET): department, level, semester
+     * (égalité) et tags (étiquette ou liste d'étiquettes, toutes requises).
+     * Les classes archivées (fusionnées) sont exclues.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} filterJSON - Critères (ex: '{"department":"CS","level":100}')
+     * @returns {string} JSON array [{ id, name, description, semester, department, level, tags }]
+     */
+    async GetCatalogFiltered(ctx, filterJSON) {
+        console.info('============= START : GetCatalogFiltered (PUBLIC) ===========');
+
+        const filter = this._parseCatalogFilter(filterJSON);
+
+        const selector = Object.assign({ docType: 'class' }, filter);
+        if (filter.tags) {
+            selector.tags = { $all: filter.tags };
+        }
+
+        let classes;
+        try {
+            classes = await this._collectClasses(await ctx.stub.getQueryResult(JSON.stringify({ selector: selector })), () => true);
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            classes = await this._collectClasses(await ctx.stub.getStateByRange('', ''), () => true);
+        }
+
+        // Le filtre est réappliqué dans tous les cas: la correspondance fait foi côté chaincode
+        const allResults = classes
+            .filter((record) => record.docType === 'class' && !record.archived && this._matchesCatalogFilter(record, filter))
+            .map((record) => ({
+                id: record.id,
+                name: record.name,
+                description: record.description,
+                semester: record.semester || null,
+                department: record.department || null,
+                level: record.level || null,
+                tags: record.tags || [],
+            }))
+            .sort((a, b) => a.id.localeCompare(b.id));
+
+        console.info(`✅ Catalog filter ${JSON.stringify(filter)}: ${allResults.length} classes`);
+        console.info('============= END : GetCatalogFiltered ===========');
 
         return JSON.stringify(allResults);
     }
@@ -202,12 +476,7 @@ class ClassContract extends Contract {
             throw new Error(`Class ${classId} does not exist`);
         }
 
-        const classData = JSON.parse(classAsBytes.toString());
-
-        // Vérifier que c'est bien une classe
-        if (classData.docType !== 'class') {
-            throw new Error(`${classId} is not a class`);
-        }
+        const classData = parseRecord(classAsBytes, classId, 'class');
 
         // Log de l'accès pour audit
         console.info(`✅ Class details accessed: ${classId} by ${caller} (${mspID})`);
@@ -218,8 +487,32 @@ class ClassContract extends Contract {
             id: classData.id,
             name: classData.name,
             description: classData.description,
+            semester: classData.semester || null,
+            department: classData.department || null,
+            level: classData.level || null,
+            tags: classData.tags || [],
+            creditHours: getCreditHours(classData),
+            teacher: classData.teacher || classData.createdBy,
+            staff: classData.staff || [],
             modules: classData.modules,
             enrolledStudents: classData.enrolledStudents,
+            waitlist: classData.waitlist || [],
+            maxWaitlist: await this._getMaxWaitlist(ctx, classData),
+            watchers: classData.watchers || [],
+            holds: classData.holds || [],
+            maxStudents: classData.maxStudents,
+            enrolledCount: this._getEnrolledCount(classData),
+            seatCapacities: classData.seatCapacities || {},
+            seatCounts: classData.seatCounts || {},
+            enrollmentMode: this._getEnrollmentMode(classData),
+            maxTotalBytes: classData.maxTotalBytes || 0,
+            withdrawalPolicy: classData.withdrawalPolicy || 'keep',
+            prerequisites: classData.prerequisites || [],
+            releaseApprovalRequired: classData.releaseApprovalRequired === true,
+            gradingWindowDays: classData.gradingWindowDays || 0,
+            strictGradingDeadline: classData.strictGradingDeadline !== false,
+            archived: classData.archived === true,
+            mergedInto: classData.mergedInto || null,
             createdBy: classData.createdBy,
             createdAt: classData.createdAt,
             updatedAt: classData.updatedAt,
@@ -233,142 +526,3250 @@ class ClassContract extends Contract {
      * - SchoolOrg (teachers/admin) - Peut inscrire n'importe quel étudiant
      * - L'étudiant lui-même - Peut uniquement s'inscrire lui-même
      *
+     * Idempotent: si la même inscription active existe déjà (réessai réseau),
+     * succès avec alreadyEnrolled=true, sans nouvelle écriture
+     *
+     * Type de place: "lecture" par défaut (capacité maxStudents); les autres types
+     * (ex: "lab") sont pris dans leur propre pool (SetSeatCapacity)
+     *
      * @param {Context} ctx - Le contexte de transaction
      * @param {string} classId - Identifiant de la classe
      * @param {string} studentId - Identifiant de l'étudiant (ex: "student1@students.academic.edu")
+     * @param {string} [seatType] - Type de place ("lecture" par défaut, "lab")
      * @returns {string} Message de confirmation
      */
-    async EnrollStudent(ctx, classId, studentId) {
+    async EnrollStudent(ctx, classId, studentId, seatType) {
         console.info('============= START : EnrollStudent ===========');
 
-        const caller = this._getCallerIdentity(ctx);
-        const mspID = ctx.clientIdentity.getMSPID();
+        if (seatType && !SEAT_TYPES.includes(seatType)) {
+            throw new Error(`Invalid seatType: ${seatType} (supported: ${SEAT_TYPES.join(', ')})`);
+        }
 
-        // CONTRÔLE D'ACCÈS COMPLEXE:
-        // 1. Si SchoolOrg: Peut inscrire n'importe qui
-        // 2. Si StudentsOrg: Peut uniquement s'inscrire lui-même
+        // "lecture" reste implicite: même inscription qu'un appel sans seatType
+        const extraFields = seatType && seatType !== DEFAULT_SEAT_TYPE ? { seatType: seatType } : {};
+        const result = await this._enrollStudent(ctx, classId, studentId, extraFields);
 
-        const isSchool = this._isSchoolMember(ctx);
-        const isStudent = this._isStudentMember(ctx);
+        console.info('============= END : EnrollStudent ===========');
+        return JSON.stringify(result);
+    }
 
-        if (!isSchool && !isStudent) {
-            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
+    /**
+     * 4 bis. Inscrire un étudiant avec un responsable (tuteur légal ou sponsor)
+     *
+     * Mêmes règles qu'EnrollStudent; le responsable est enregistré sur l'inscription
+     * et figure dans l'export de la liste de classe (ExportClassRoster)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @param {string} sponsorId - Identifiant du tuteur ou du sponsor
+     * @returns {string} Message de confirmation
+     */
+    async EnrollStudentWithSponsor(ctx, classId, studentId, sponsorId) {
+        console.info('============= START : EnrollStudentWithSponsor ===========');
+
+        if (!sponsorId || !sponsorId.trim()) {
+            throw new Error('Invalid sponsorId: must be a non-empty identifier');
         }
 
-        // Si c'est un étudiant, il ne peut inscrire que lui-même
-        if (isStudent && caller !== studentId) {
-            throw new Error(`Access Denied: Students can only enroll themselves. You are ${caller}, trying to enroll ${studentId}`);
+        const result = await this._enrollStudent(ctx, classId, studentId, { sponsorId: sponsorId });
+
+        console.info('============= END : EnrollStudentWithSponsor ===========');
+        return JSON.stringify(result);
+    }
+
+    /**
+     * 4 ter. Inscrire un étudiant avec une date d'effet passée (dossiers papier)
+     *
+     * Accessible par: Admins uniquement
+     * Mêmes règles qu'EnrollStudent; enrolledAt prend la date d'effet, antérieure
+     * au timestamp de la transaction. Motif obligatoire, tracé dans le journal d'audit.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @param {string} effectiveDate - Date d'effet (ISO 8601 avec fuseau)
+     * @param {string} reason - Motif de l'antidatage
+     * @returns {string} Message de confirmation
+     */
+    async EnrollStudentBackdated(ctx, classId, studentId, effectiveDate, reason) {
+        console.info('============= START : EnrollStudentBackdated ===========');
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can backdate enrollments');
         }
 
-        // Vérifier que la classe existe
-        const classAsBytes = await ctx.stub.getState(classId);
-        if (!classAsBytes || classAsBytes.length === 0) {
-            throw new Error(`Class ${classId} does not exist`);
+        if (!reason || !reason.trim()) {
+            throw new Error('A reason is required to backdate an enrollment');
         }
 
-        const classData = JSON.parse(classAsBytes.toString());
+        const enrolledAt = normalizeDate(effectiveDate, 'effectiveDate').utc;
+        const txTimestamp = this._getTxTimestamp(ctx);
+        if (new Date(enrolledAt) >= new Date(txTimestamp)) {
+            throw new Error(`Invalid effectiveDate: ${enrolledAt} must be before the transaction time ${txTimestamp}`);
+        }
+
+        const result = await this._enrollStudent(ctx, classId, studentId, {
+            enrolledAt: enrolledAt,
+            backdated: true,
+            backdateReason: reason,
+        });
 
-        if (classData.docType !== 'class') {
-            throw new Error(`${classId} is not a class`);
+        if (!result.alreadyEnrolled) {
+            await writeAuditEntry(ctx, 'EnrollmentBackdated', classId, reason, {
+                studentId: studentId,
+                effectiveDate: enrolledAt,
+                recordedAt: txTimestamp,
+            });
         }
 
-        // Vérifier si l'étudiant est déjà inscrit
+        console.info('============= END : EnrollStudentBackdated ===========');
+        return JSON.stringify(result);
+    }
+
+    /**
+     * 4 quater. Rejoindre la liste d'attente d'une classe pleine
+     *
+     * Accessible par: mêmes règles qu'EnrollStudent (SchoolOrg, ou l'étudiant lui-même)
+     * Refusé si la classe a encore des places, si la classe n'a pas de liste d'attente
+     * (maxWaitlist = 0), si la liste d'attente est pleine (maxWaitlist atteint) ou si l'étudiant
+     * a atteint le plafond d'inscriptions simultanées.
+     * Les places libérées sont attribuées dans l'ordre d'arrivée (_promoteWaitlist): retraits
+     * (WithdrawStudent, WithdrawStudentsBatch), transfert vers une autre classe (TransferEnrollment)
+     * et hausse de capacité (PatchClass, SetSeatCapacity).
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @returns {string} JSON { success, classId, studentId, position, maxWaitlist }
+     */
+    async JoinWaitlist(ctx, classId, studentId) {
+        console.info('============= START : JoinWaitlist ===========');
+
+        const caller = this._getCallerIdentity(ctx);
+        const isStudent = this._isStudentMember(ctx);
+        if (!(await isTeachingStaff(ctx)) && !isStudent) {
+            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
+        }
+        if (isStudent && caller !== studentId) {
+            throw new Error(`Access Denied: Students can only join a waitlist themselves. You are ${caller}, trying to add ${studentId}`);
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
         if (classData.enrolledStudents.includes(studentId)) {
             throw new Error(`Student ${studentId} is already enrolled in class ${classId}`);
         }
+        if ((classData.waitlist || []).includes(studentId)) {
+            throw new Error(`Student ${studentId} is already on the waitlist of class ${classId}`);
+        }
 
-        // Ajouter l'étudiant à la liste des inscrits
-        classData.enrolledStudents.push(studentId);
-        classData.updatedAt = this._getTxTimestamp(ctx);
+        this._checkNotArchived(classData);
 
-        // Sauvegarder la classe mise à jour
-        await ctx.stub.putState(classId, Buffer.from(JSON.stringify(classData)));
+        const windowError = this._checkEnrollmentWindow(ctx, classData);
+        if (windowError) {
+            throw new Error(windowError);
+        }
 
-        // Émettre un événement
-        ctx.stub.setEvent('StudentEnrolled', Buffer.from(JSON.stringify({
+        if (this._hasCapacity(classData, 1, studentId)) {
+            throw new Error(`Class ${classId} still has available seats: use EnrollStudent`);
+        }
+
+        // Un étudiant au plafond d'inscriptions simultanées ne pourrait pas être promu
+        await this._checkConcurrentEnrollments(ctx, studentId);
+
+        await this._addWaitlistEntry(ctx, classData, studentId, {});
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        const maxWaitlist = await this._getMaxWaitlist(ctx, classData);
+        const position = classData.waitlist.length;
+
+        ctx.stub.setEvent('StudentWaitlisted', Buffer.from(JSON.stringify({
             classId: classId,
             studentId: studentId,
-            enrolledBy: caller,
-            mspID: mspID,
+            position: position,
+            waitlistedBy: caller,
         })));
 
-        const message = `Student ${studentId} successfully enrolled in class ${classId}`;
-        console.info(`✅ ${message} by ${caller} (${mspID})`);
-        console.info('============= END : EnrollStudent ===========');
+        console.info(`✅ Student ${studentId} joined the waitlist of ${classId} (position ${position}/${maxWaitlist}) by ${caller}`);
+        console.info('============= END : JoinWaitlist ===========');
 
         return JSON.stringify({
             success: true,
-            message: message,
             classId: classId,
             studentId: studentId,
-            enrolledBy: caller,
+            position: position,
+            maxWaitlist: maxWaitlist,
         });
     }
 
-    // ==================== FONCTIONS UTILITAIRES ====================
-
-    /**
-     * Vérifie si une classe existe
-     * @private
-     */
-    async _classExists(ctx, classId) {
-        const classAsBytes = await ctx.stub.getState(classId);
-        return classAsBytes && classAsBytes.length > 0;
-    }
-
     /**
-     * Ajouter des modules à une classe (bonus)
-     * Accessible uniquement par SchoolOrg
+     * 4 quinquies. Être notifié de l'ouverture d'une place dans une classe pleine
+     *
+     * Accessible par: mêmes règles qu'EnrollStudent (SchoolOrg, ou l'étudiant lui-même)
+     * Refusé si la classe a encore des places. Quand une place se libère (WithdrawStudent)
+     * ou que la capacité augmente (PatchClass, SetSeatCapacity), elle revient d'abord à la liste
+     * d'attente; si la liste d'attente est vide, chaque étudiant surveillant la classe reçoit
+     * une notification "SeatOpened". La surveillance prend fin à l'inscription.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @returns {string} JSON { success, classId, studentId, watcherCount }
      */
-    async AddModuleToClass(ctx, classId, moduleName) {
-        console.info('============= START : AddModuleToClass ===========');
+    async WatchClass(ctx, classId, studentId) {
+        console.info('============= START : WatchClass ===========');
 
-        if (!this._isSchoolMember(ctx)) {
-            throw new Error('Access Denied: Only SchoolOrg members can add modules');
+        const caller = this._getCallerIdentity(ctx);
+        const isStudent = this._isStudentMember(ctx);
+        if (!(await isTeachingStaff(ctx)) && !isStudent) {
+            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
+        }
+        if (isStudent && caller !== studentId) {
+            throw new Error(`Access Denied: Students can only watch a class themselves. You are ${caller}, trying to add ${studentId}`);
         }
 
-        const classAsBytes = await ctx.stub.getState(classId);
-        if (!classAsBytes || classAsBytes.length === 0) {
-            throw new Error(`Class ${classId} does not exist`);
+        const classData = await this._getClass(ctx, classId);
+
+        if (classData.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is already enrolled in class ${classId}`);
+        }
+        if ((classData.watchers || []).includes(studentId)) {
+            throw new Error(`Student ${studentId} is already watching class ${classId}`);
         }
 
-        const classData = JSON.parse(classAsBytes.toString());
+        this._checkNotArchived(classData);
 
-        if (classData.modules.includes(moduleName)) {
-            throw new Error(`Module ${moduleName} already exists in class ${classId}`);
+        if (this._hasCapacity(classData, 1, studentId)) {
+            throw new Error(`Class ${classId} still has available seats: use EnrollStudent`);
         }
 
-        classData.modules.push(moduleName);
+        classData.watchers = (classData.watchers || []).concat(studentId);
         classData.updatedAt = this._getTxTimestamp(ctx);
+        await ctx.stub.putState(classId, serializeRecord(classData));
 
-        await ctx.stub.putState(classId, Buffer.from(JSON.stringify(classData)));
+        ctx.stub.setEvent('ClassWatched', Buffer.from(JSON.stringify({
+            classId: classId,
+            studentId: studentId,
+            watchedBy: caller,
+        })));
 
-        console.info(`✅ Module ${moduleName} added to class ${classId}`);
-        console.info('============= END : AddModuleToClass ===========');
+        console.info(`✅ Student ${studentId} is watching class ${classId} (${classData.watchers.length} watchers) by ${caller}`);
+        console.info('============= END : WatchClass ===========');
 
-        return JSON.stringify({ success: true, classId: classId, module: moduleName });
+        return JSON.stringify({
+            success: true,
+            classId: classId,
+            studentId: studentId,
+            watcherCount: classData.watchers.length,
+        });
     }
 
     /**
-     * Obtenir les étudiants inscrits à une classe
-     * Accessible par SchoolOrg uniquement
+     * 4 sexies. Inscrire un étudiant dans la première section disponible d'un cours
+     *
+     * Accessible par: mêmes règles qu'EnrollStudent (SchoolOrg, ou l'étudiant lui-même)
+     * Les sections (classes) sont essayées dans l'ordre des préférences: l'étudiant est inscrit
+     * dans la première section ouverte qui a une place et dont il remplit les conditions
+     * d'EnrollStudent (prérequis, plafond d'inscriptions simultanées...). Si toutes sont pleines,
+     * il est placé en liste d'attente de la première section pleine qui en accepte une (maxWaitlist).
+     * Les sections archivées ou hors période d'inscription sont ignorées; chaque section écartée
+     * est listée dans skipped avec son motif.
+     * courseId n'est qu'un libellé: les classes n'ont pas de champ cours, il n'est donc pas
+     * comparé aux sections. Il est enregistré, avec le rang de la section retenue, sur l'inscription (ENR_).
+     * Si l'étudiant est déjà inscrit (ou en attente) dans l'une des sections, elle est renvoyée.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} courseId - Libellé du cours commun aux sections (ex: "CYBER101"), non vérifié
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @param {string} preferencesJSON - Tableau JSON des sections par préférence (ex: '["CYBER101-A","CYBER101-B"]')
+     * @returns {string} JSON { success, courseId, studentId, classId, status, preferenceRank, skipped }
      */
-    async GetEnrolledStudents(ctx, classId) {
-        console.info('============= START : GetEnrolledStudents ===========');
+    async AutoAssignSection(ctx, courseId, studentId, preferencesJSON) {
+        console.info('============= START : AutoAssignSection ===========');
 
-        if (!this._isSchoolMember(ctx)) {
-            throw new Error('Access Denied: Only SchoolOrg members can view enrolled students');
+        const caller = this._getCallerIdentity(ctx);
+        const isStudent = this._isStudentMember(ctx);
+        if (!(await isTeachingStaff(ctx)) && !isStudent) {
+            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
         }
-
-        const classAsBytes = await ctx.stub.getState(classId);
-        if (!classAsBytes || classAsBytes.length === 0) {
-            throw new Error(`Class ${classId} does not exist`);
+        if (isStudent && caller !== studentId) {
+            throw new Error(`Access Denied: Students can only enroll themselves. You are ${caller}, trying to enroll ${studentId}`);
         }
 
-        const classData = JSON.parse(classAsBytes.toString());
+        if (!courseId) {
+            throw new Error('courseId is required');
+        }
 
-        console.info(`✅ Retrieved ${classData.enrolledStudents.length} enrolled students`);
-        console.info('============= END : GetEnrolledStudents ===========');
+        let preferences;
+        try {
+            preferences = JSON.parse(preferencesJSON);
+        } catch (err) {
+            throw new Error('Invalid preferencesJSON: must be a JSON array of class IDs');
+        }
+        if (!Array.isArray(preferences) || preferences.length === 0) {
+            throw new Error('Invalid preferencesJSON: must be a non-empty JSON array of class IDs');
+        }
+        if (preferences.some((classId) => typeof classId !== 'string' || !classId)) {
+            throw new Error('Invalid preferencesJSON: every entry must be a non-empty string');
+        }
+        if (new Set(preferences).size !== preferences.length) {
+            throw new Error('Invalid preferencesJSON: duplicate class IDs');
+        }
+        if (preferences.length > MAX_SECTION_PREFERENCES) {
+            throw new Error(`Too many section preferences (max ${MAX_SECTION_PREFERENCES})`);
+        }
+
+        const sections = [];
+        for (const classId of preferences) {
+            sections.push(await this._getClass(ctx, classId));
+        }
+
+        // Déjà affecté à l'une des sections (réessai): aucune écriture
+        for (let i = 0; i < sections.length; i++) {
+            const classData = sections[i];
+            let status = null;
+            if (classData.enrolledStudents.includes(studentId)) {
+                status = 'active';
+            } else if ((classData.waitlist || []).includes(studentId)) {
+                status = 'waitlisted';
+            }
+            if (status) {
+                console.info(`✅ Student ${studentId} is already assigned to section ${classData.id} of ${courseId} (${status})`);
+                console.info('============= END : AutoAssignSection ===========');
+
+                return JSON.stringify({
+                    success: true,
+                    alreadyAssigned: true,
+                    courseId: courseId,
+                    studentId: studentId,
+                    classId: classData.id,
+                    status: status,
+                    preferenceRank: i + 1,
+                    skipped: [],
+                });
+            }
+        }
+
+        // Sections ouvertes, dans l'ordre des préférences
+        const skipped = [];
+        const open = [];
+        for (let i = 0; i < sections.length; i++) {
+            const classData = sections[i];
+            if (classData.archived) {
+                skipped.push({ classId: classData.id, reason: 'archived' });
+                continue;
+            }
+            const windowError = this._checkEnrollmentWindow(ctx, classData);
+            if (windowError) {
+                skipped.push({ classId: classData.id, reason: windowError });
+                continue;
+            }
+            open.push({ classData: classData, rank: i + 1 });
+        }
+
+        // 1. Première section avec une place dont l'étudiant remplit les conditions
+        const full = [];
+        let available = null;
+        let result = null;
+        for (const section of open) {
+            if (!this._hasCapacity(section.classData, 1, studentId)) {
+                full.push(section);
+                continue;
+            }
+            try {
+                result = await this._enrollStudent(ctx, section.classData.id, studentId, {
+                    courseId: courseId,
+                    preferenceRank: section.rank,
+                });
+            } catch (err) {
+                // _enrollStudent vérifie tout avant d'écrire: la section suivante peut être essayée
+                skipped.push({ classId: section.classData.id, reason: err.message });
+                continue;
+            }
+            available = section;
+            break;
+        }
+        if (available) {
+            for (const section of full) {
+                skipped.push({ classId: section.classData.id, reason: 'full' });
+            }
+
+            console.info(`✅ Student ${studentId} assigned to section ${available.classData.id} of ${courseId} (preference ${available.rank})`);
+            console.info('============= END : AutoAssignSection ===========');
+
+            return JSON.stringify({
+                success: true,
+                alreadyAssigned: false,
+                courseId: courseId,
+                studentId: studentId,
+                classId: available.classData.id,
+                status: 'active',
+                preferenceRank: available.rank,
+                overCapacity: result.overCapacity,
+                skipped: skipped,
+            });
+        }
+
+        // 2. Aucune inscription possible: liste d'attente de la première section pleine qui l'accepte
+        // (un étudiant au plafond d'inscriptions simultanées ne pourrait pas être promu)
+        if (full.length > 0) {
+            await this._checkConcurrentEnrollments(ctx, studentId);
+        }
+        for (const section of full) {
+            const classData = section.classData;
+            const maxWaitlist = await this._getMaxWaitlist(ctx, classData);
+            if ((classData.waitlist || []).length >= maxWaitlist) {
+                skipped.push({ classId: classData.id, reason: maxWaitlist === 0 ? 'full, no waitlist' : 'full, waitlist full' });
+                continue;
+            }
+
+            await this._addWaitlistEntry(ctx, classData, studentId, {
+                courseId: courseId,
+                preferenceRank: section.rank,
+            });
+            await ctx.stub.putState(classData.id, serializeRecord(classData));
+
+            const position = classData.waitlist.length;
+
+            ctx.stub.setEvent('StudentWaitlisted', Buffer.from(JSON.stringify({
+                classId: classData.id,
+                studentId: studentId,
+                position: position,
+                waitlistedBy: caller,
+                courseId: courseId,
+                preferenceRank: section.rank,
+            })));
+
+            console.info(`✅ Student ${studentId} waitlisted in section ${classData.id} of ${courseId} (position ${position}/${maxWaitlist}) by ${caller}`);
+            console.info('============= END : AutoAssignSection ===========');
+
+            return JSON.stringify({
+                success: true,
+                alreadyAssigned: false,
+                courseId: courseId,
+                studentId: studentId,
+                classId: classData.id,
+                status: 'waitlisted',
+                preferenceRank: section.rank,
+                position: position,
+                skipped: skipped,
+            });
+        }
+
+        throw new Error(`No section of course ${courseId} can take student ${studentId}: ${skipped.map((s) => `${s.classId} (${s.reason})`).join(', ')}`);
+    }
+
+    /**
+     * Inscription commune à EnrollStudent et EnrollStudentWithSponsor
+     * extraFields est enregistré sur l'inscription (ENR_)
+     * @private
+     */
+    async _enrollStudent(ctx, classId, studentId, extraFields) {
+        const caller = this._getCallerIdentity(ctx);
+        const mspID = ctx.clientIdentity.getMSPID();
+
+        // CONTRÔLE D'ACCÈS COMPLEXE:
+        // 1. Si SchoolOrg: Peut inscrire n'importe qui
+        // 2. Si StudentsOrg: Peut uniquement s'inscrire lui-même
+
+        const isSchool = await isTeachingStaff(ctx);
+        const isStudent = this._isStudentMember(ctx);
+
+        if (!isSchool && !isStudent) {
+            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
+        }
+
+        // Si c'est un étudiant, il ne peut inscrire que lui-même
+        if (isStudent && caller !== studentId) {
+            throw new Error(`Access Denied: Students can only enroll themselves. You are ${caller}, trying to enroll ${studentId}`);
+        }
+
+        // Vérifier que la classe existe
+        const classData = await this._getClass(ctx, classId);
+
+        // Vérifier si l'étudiant est déjà inscrit
+        // Même inscription active (réessai dont la réponse a été perdue): succès sans écriture
+        if (classData.enrolledStudents.includes(studentId)) {
+            const enrollment = await this._getEnrollment(ctx, classId, studentId);
+            const sameEnrollment = Object.keys(extraFields)
+                .every((field) => (enrollment ? enrollment[field] : undefined) === extraFields[field]);
+            if (!sameEnrollment) {
+                throw new Error(`Student ${studentId} is already enrolled in class ${classId}`);
+            }
+
+            const message = `Student ${studentId} is already enrolled in class ${classId}`;
+            console.info(`✅ ${message} (idempotent retry by ${caller})`);
+
+            return Object.assign({
+                success: true,
+                alreadyEnrolled: true,
+                message: message,
+                classId: classId,
+                studentId: studentId,
+                enrolledBy: caller,
+                overCapacity: Boolean(enrollment && enrollment.overCapacity),
+            }, extraFields);
+        }
+
+        // Conditions d'inscription (mêmes contrôles que CheckEnrollmentEligibility):
+        // classe non archivée, période d'inscription, prérequis, plafond d'inscriptions simultanées,
+        // capacité du pool du type de place demandé
+        // Mode "soft": la sur-inscription est acceptée mais signalée pour validation par le teacher
+        // La place réservée par l'étudiant (HoldSeat) est confirmée par l'inscription
+        const seatType = extraFields.seatType || DEFAULT_SEAT_TYPE;
+        this._checkSeatType(classData, seatType);
+        const failedGate = (await this._getEnrollmentGates(ctx, classData, studentId, seatType)).find((gate) => !gate.passed);
+        if (failedGate) {
+            throw new Error(failedGate.reason);
+        }
+        const overCapacity = !this._hasCapacity(classData, 1, studentId, seatType);
+
+        // Ajouter l'étudiant (liste des inscrits + compteur + enregistrement d'inscription)
+        const enrollmentFields = Object.assign({}, extraFields, overCapacity ? { overCapacity: true } : {});
+        await this._addActiveEnrollment(ctx, classData, studentId, enrollmentFields);
+
+        // Franchissement du seuil d'alerte (une seule alerte par franchissement)
+        const nearCapacity = await this._updateNearCapacityFlag(ctx, classData);
+
+        // Pas de relecture avant l'écriture: dans une transaction, getState renvoie la valeur
+        // du world state au début de la simulation. La lecture de la classe entre dans le read-set:
+        // si une inscription concurrente modifie la classe avant le commit, le peer invalide
+        // celle-ci (MVCC_READ_CONFLICT) et le client doit la resoumettre.
+
+        // Sauvegarder la classe mise à jour
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        // Émettre un événement (Fabric ne conserve qu'un événement par transaction:
+        // une sur-inscription ou un franchissement de seuil remplace StudentEnrolled pour alerter le teacher)
+        let eventName = 'StudentEnrolled';
+        if (overCapacity) {
+            eventName = 'EnrollmentOverCapacity';
+        } else if (nearCapacity) {
+            eventName = 'ClassNearCapacity';
+        }
+        ctx.stub.setEvent(eventName, Buffer.from(JSON.stringify(Object.assign({
+            classId: classId,
+            studentId: studentId,
+            enrolledBy: caller,
+            mspID: mspID,
+            enrolledCount: this._getEnrolledCount(classData),
+            maxStudents: classData.maxStudents,
+        }, extraFields))));
+
+        const message = `Student ${studentId} successfully enrolled in class ${classId}`;
+        console.info(`✅ ${message} by ${caller} (${mspID})`);
+
+        return Object.assign({
+            success: true,
+            alreadyEnrolled: false,
+            message: message,
+            classId: classId,
+            studentId: studentId,
+            enrolledBy: caller,
+            overCapacity: overCapacity,
+        }, extraFields);
+    }
+
+    /**
+     * 5. Désinscrire un étudiant d'une classe
+     *
+     * Accessible par:
+     * - SchoolOrg (teachers/admin) - Peut désinscrire n'importe quel étudiant
+     * - L'étudiant lui-même - Peut uniquement se désinscrire lui-même
+     *
+     * Politique de retrait de la classe (withdrawalPolicy):
+     * - keep: copies et notes de l'étudiant conservées
+     * - void: copies et notes non publiées marquées annulées (jamais supprimées)
+     * La place libérée est attribuée à la liste d'attente (ordre d'arrivée).
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @returns {string} Message de confirmation
+     */
+    async WithdrawStudent(ctx, classId, studentId) {
+        console.info('============= START : WithdrawStudent ===========');
+
+        const caller = this._getCallerIdentity(ctx);
+
+        if (!(await isTeachingStaff(ctx)) && !this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
+        }
+
+        if (this._isStudentMember(ctx) && caller !== studentId) {
+            throw new Error(`Access Denied: Students can only withdraw themselves. You are ${caller}, trying to withdraw ${studentId}`);
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        if (!classData.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is not enrolled in class ${classId}`);
+        }
+
+        const wasFull = !this._hasCapacity(classData, 1, '');
+        await this._removeActiveEnrollment(ctx, classData, studentId, 'withdrawn');
+        const promoted = await this._promoteWaitlist(ctx, classData);
+        const notifiedWatchers = await this._notifySeatOpened(ctx, classData, wasFull);
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        const withdrawalPolicy = classData.withdrawalPolicy || 'keep';
+        const voided = withdrawalPolicy === 'void'
+            ? await this._voidStudentWork(ctx, classId, studentId, caller)
+            : [];
+
+        ctx.stub.setEvent('StudentWithdrawn', Buffer.from(JSON.stringify({
+            classId: classId,
+            studentId: studentId,
+            withdrawalPolicy: withdrawalPolicy,
+            voided: voided,
+            withdrawnBy: caller,
+            promoted: promoted,
+            notifiedWatchers: notifiedWatchers,
+        })));
+
+        const message = `Student ${studentId} successfully withdrawn from class ${classId}`;
+        console.info(`✅ ${message} by ${caller}`);
+        console.info('============= END : WithdrawStudent ===========');
+
+        return JSON.stringify({
+            success: true,
+            message: message,
+            classId: classId,
+            studentId: studentId,
+            withdrawalPolicy: withdrawalPolicy,
+            voided: voided,
+            withdrawnBy: caller,
+            promoted: promoted,
+            notifiedWatchers: notifiedWatchers,
+        });
+    }
+
+    /**
+     * 6. Transférer l'inscription d'un étudiant vers une autre classe
+     *
+     * Accessible par: SchoolOrg uniquement (teachers/admin)
+     * La désinscription de la classe source n'a lieu que si le placement cible est possible:
+     * inscription active, ou liste d'attente si la destination est pleine et waitlistIfFull="true"
+     * (dans la limite de maxWaitlist de la destination)
+     * La place libérée dans la classe source est attribuée à sa liste d'attente (ordre d'arrivée).
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} fromClassId - Classe source
+     * @param {string} toClassId - Classe destination
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @param {string} [waitlistIfFull] - "true" pour placer l'étudiant en liste d'attente si la destination est pleine
+     * @returns {string} Message de confirmation
+     */
+    async TransferEnrollment(ctx, fromClassId, toClassId, studentId, waitlistIfFull) {
+        console.info('============= START : TransferEnrollment ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can transfer enrollments');
+        }
+
+        if (fromClassId === toClassId) {
+            throw new Error('Invalid transfer: source and destination classes are the same');
+        }
+
+        const fromClass = await this._getClass(ctx, fromClassId);
+        const toClass = await this._getClass(ctx, toClassId);
+
+        if (!fromClass.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is not enrolled in class ${fromClassId}`);
+        }
+        if (toClass.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is already enrolled in class ${toClassId}`);
+        }
+        if ((toClass.waitlist || []).includes(studentId)) {
+            throw new Error(`Student ${studentId} is already on the waitlist of class ${toClassId}`);
+        }
+        this._checkNotArchived(toClass);
+
+        // Destination pleine: liste d'attente si demandé, sinon refus (source inchangée)
+        const waitlisted = !this._hasCapacity(toClass, 1, studentId);
+        if (waitlisted && waitlistIfFull !== 'true' && waitlistIfFull !== true) {
+            this._checkCapacity(toClass, 1, studentId);
+        }
+
+        await this._removeActiveEnrollment(ctx, fromClass, studentId, 'transferred', { transferredTo: toClassId });
+        if (waitlisted) {
+            await this._addWaitlistEntry(ctx, toClass, studentId, { transferredFrom: fromClassId });
+        } else {
+            await this._addActiveEnrollment(ctx, toClass, studentId);
+        }
+        const promoted = await this._promoteWaitlist(ctx, fromClass);
+
+        await ctx.stub.putState(fromClassId, serializeRecord(fromClass));
+        await ctx.stub.putState(toClassId, serializeRecord(toClass));
+
+        const caller = this._getCallerIdentity(ctx);
+
+        ctx.stub.setEvent('EnrollmentTransferred', Buffer.from(JSON.stringify({
+            fromClassId: fromClassId,
+            toClassId: toClassId,
+            studentId: studentId,
+            status: waitlisted ? 'waitlisted' : 'active',
+            transferredBy: caller,
+            promoted: promoted,
+        })));
+
+        const message = waitlisted
+            ? `Student ${studentId} transferred from ${fromClassId} to the waitlist of ${toClassId}`
+            : `Student ${studentId} transferred from ${fromClassId} to ${toClassId}`;
+        console.info(`✅ ${message} by ${caller}`);
+        console.info('============= END : TransferEnrollment ===========');
+
+        return JSON.stringify({
+            success: true,
+            message: message,
+            fromClassId: fromClassId,
+            toClassId: toClassId,
+            studentId: studentId,
+            status: waitlisted ? 'waitlisted' : 'active',
+            promoted: promoted,
+        });
+    }
+
+    /**
+     * 7. Inscrire plusieurs étudiants en une transaction
+     *
+     * Accessible par: SchoolOrg uniquement (teachers/admin)
+     * Chaque étudiant est soumis aux conditions d'EnrollStudent (_getEnrollmentGates: classe non
+     * archivée, période d'inscription, prérequis, plafond d'inscriptions simultanées); la capacité
+     * est stricte quel que soit le mode d'inscription de la classe
+     * - atomic (par défaut): tout ou rien, la transaction échoue si un seul étudiant ne peut pas être inscrit
+     * - besteffort: les étudiants éligibles sont inscrits dans l'ordre du lot tant qu'il reste
+     *   des places, les autres sont listés dans failed avec leur motif
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentIdsJSON - Tableau JSON des étudiants (ex: '["alice","bob"]')
+     * @param {string} [mode] - atomic ou besteffort ("" = atomic)
+     * @returns {string} JSON { success, classId, mode, enrolled, count, failed }
+     */
+    async EnrollStudentsBatch(ctx, classId, studentIdsJSON, mode) {
+        console.info('============= START : EnrollStudentsBatch ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can batch enroll students');
+        }
+
+        let studentIds;
+        try {
+            studentIds = JSON.parse(studentIdsJSON);
+        } catch (err) {
+            throw new Error('Invalid studentIdsJSON: must be a JSON array of student IDs');
+        }
+        if (!Array.isArray(studentIds) || studentIds.length === 0) {
+            throw new Error('Invalid studentIdsJSON: must be a non-empty JSON array of student IDs');
+        }
+        if (studentIds.some((studentId) => typeof studentId !== 'string' || !studentId)) {
+            throw new Error('Invalid studentIdsJSON: every entry must be a non-empty string');
+        }
+
+        const batchMode = mode || 'atomic';
+        if (!BATCH_ENROLL_MODES.includes(batchMode)) {
+            throw new Error(`Invalid mode: ${mode} (supported: ${BATCH_ENROLL_MODES.join(', ')})`);
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        const enrolled = [];
+        const failed = [];
+        const seen = new Set();
+
+        if (batchMode === 'atomic') {
+            for (const studentId of studentIds) {
+                if (seen.has(studentId)) {
+                    throw new Error(`Duplicate student ${studentId} in batch`);
+                }
+                const error = await this._getBatchEnrollmentError(ctx, classData, studentId);
+                if (error) {
+                    throw new Error(error);
+                }
+                seen.add(studentId);
+            }
+
+            this._checkCapacity(classData, studentIds.length);
+
+            for (const studentId of studentIds) {
+                await this._addActiveEnrollment(ctx, classData, studentId);
+                enrolled.push(studentId);
+            }
+        } else {
+            // Les conditions sont évaluées sur la classe en mémoire: la capacité porte sur le cumul des inscrits du lot
+            for (const studentId of studentIds) {
+                let error = null;
+                if (seen.has(studentId)) {
+                    error = `Duplicate student ${studentId} in batch`;
+                } else {
+                    error = await this._getBatchEnrollmentError(ctx, classData, studentId)
+                        || this._getCapacityError(classData, 1);
+                }
+                seen.add(studentId);
+
+                if (error) {
+                    failed.push({ studentId: studentId, error: error });
+                    continue;
+                }
+                await this._addActiveEnrollment(ctx, classData, studentId);
+                enrolled.push(studentId);
+            }
+        }
+
+        if (enrolled.length > 0) {
+            await ctx.stub.putState(classId, serializeRecord(classData));
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+
+        ctx.stub.setEvent('StudentsBatchEnrolled', Buffer.from(JSON.stringify({
+            classId: classId,
+            mode: batchMode,
+            studentIds: enrolled,
+            failed: failed.map((failure) => failure.studentId),
+            enrolledBy: caller,
+        })));
+
+        console.info(`✅ ${enrolled.length} students enrolled in class ${classId} by ${caller} (${batchMode}, ${failed.length} failed)`);
+        console.info('============= END : EnrollStudentsBatch ===========');
+
+        return JSON.stringify({
+            success: true,
+            classId: classId,
+            mode: batchMode,
+            enrolled: enrolled,
+            count: enrolled.length,
+            failed: failed,
+        });
+    }
+
+    /**
+     * 7 bis. Désinscrire plusieurs étudiants en une transaction (section annulée, cohorte retirée)
+     *
+     * Accessible par: Teacher responsable de la classe + admins
+     * Le motif est enregistré sur chaque inscription retirée; la politique de retrait
+     * de la classe s'applique. Les places libérées sont attribuées à la liste d'attente
+     * (ordre d'arrivée). Un étudiant non inscrit n'interrompt pas le lot: son résultat l'indique.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentIdsJSON - Tableau JSON des étudiants (ex: '["alice","bob"]')
+     * @param {string} reason - Motif du retrait
+     * @returns {string} JSON { classId, results, withdrawnCount, promoted }
+     */
+    async WithdrawStudentsBatch(ctx, classId, studentIdsJSON, reason) {
+        console.info('============= START : WithdrawStudentsBatch ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData, 'enrollments');
+
+        let studentIds;
+        try {
+            studentIds = JSON.parse(studentIdsJSON);
+        } catch (err) {
+            throw new Error('Invalid studentIdsJSON: must be a JSON array of student IDs');
+        }
+        if (!Array.isArray(studentIds) || studentIds.length === 0) {
+            throw new Error('Invalid studentIdsJSON: must be a non-empty JSON array of student IDs');
+        }
+        if (studentIds.some((studentId) => typeof studentId !== 'string' || !studentId)) {
+            throw new Error('Invalid studentIdsJSON: every entry must be a non-empty string');
+        }
+        if (!reason || !reason.trim()) {
+            throw new Error('A reason is required to withdraw students in batch');
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const withdrawalPolicy = classData.withdrawalPolicy || 'keep';
+        const results = [];
+        const seen = new Set();
+
+        for (const studentId of studentIds) {
+            if (seen.has(studentId)) {
+                results.push({ studentId: studentId, status: 'skipped', error: `Duplicate student ${studentId} in batch` });
+                continue;
+            }
+            seen.add(studentId);
+
+            if (!classData.enrolledStudents.includes(studentId)) {
+                results.push({ studentId: studentId, status: 'skipped', error: `Student ${studentId} is not enrolled in class ${classId}` });
+                continue;
+            }
+
+            await this._removeActiveEnrollment(ctx, classData, studentId, 'withdrawn', { withdrawalReason: reason });
+            const voided = withdrawalPolicy === 'void'
+                ? await this._voidStudentWork(ctx, classId, studentId, caller)
+                : [];
+            results.push({ studentId: studentId, status: 'withdrawn', voided: voided });
+        }
+
+        const promoted = await this._promoteWaitlist(ctx, classData);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        const withdrawn = results.filter((result) => result.status === 'withdrawn').map((result) => result.studentId);
+
+        ctx.stub.setEvent('StudentsBatchWithdrawn', Buffer.from(JSON.stringify({
+            classId: classId,
+            studentIds: withdrawn,
+            promoted: promoted,
+            reason: reason,
+            withdrawnBy: caller,
+        })));
+
+        console.info(`✅ ${withdrawn.length} students withdrawn from class ${classId} by ${caller}, ${promoted.length} promoted from waitlist`);
+        console.info('============= END : WithdrawStudentsBatch ===========');
+
+        return JSON.stringify({
+            success: true,
+            classId: classId,
+            results: results,
+            withdrawnCount: withdrawn.length,
+            promoted: promoted,
+        });
+    }
+
+    /**
+     * 8. Cloner une classe pour un nouveau semestre
+     *
+     * Accessible par: Teacher responsable de la classe source ou admin
+     * Copie la configuration (nom, description, teacher, capacité, modules, prérequis,
+     * barème) sans les inscriptions ni les notes
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} sourceClassId - Classe à cloner
+     * @param {string} newClassId - Identifiant de la nouvelle classe
+     * @param {string} newSemester - Semestre de la nouvelle classe
+     * @returns {string} JSON de la nouvelle classe
+     */
+    async CloneClass(ctx, sourceClassId, newClassId, newSemester) {
+        console.info('============= START : CloneClass ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can clone classes');
+        }
+
+        if (!newSemester) {
+            throw new Error('Missing newSemester: the cloned class needs a semester');
+        }
+
+        const source = await this._getClass(ctx, sourceClassId);
+        await this._checkClassOwner(ctx, source, 'clones');
+
+        if (await this._classExists(ctx, newClassId)) {
+            throw new Error(`Class ${newClassId} already exists`);
+        }
+
+        // Capacité de la source, ou capacité par défaut de la configuration si elle n'en a pas
+        const maxStudents = source.maxStudents || (await getSystemConfig(ctx)).defaultMaxStudents;
+        await this._checkMaxStudents(ctx, maxStudents);
+
+        const createdBy = this._getCallerIdentity(ctx);
+        const txTimestamp = this._getTxTimestamp(ctx);
+
+        const classData = {
+            docType: 'class',
+            id: newClassId,
+            name: source.name,
+            description: source.description,
+            semester: newSemester,
+            teacher: source.teacher || source.createdBy,
+            staff: [], // L'équipe pédagogique est propre à chaque semestre
+            modules: source.modules.slice(),
+            enrolledStudents: [], // Les inscriptions ne sont pas copiées
+            waitlist: [],
+            holds: [],
+            maxStudents: maxStudents,
+            enrollmentMode: this._getEnrollmentMode(source),
+            enrolledCount: 0,
+            clonedFrom: sourceClassId,
+            createdBy: createdBy,
+            createdAt: txTimestamp,
+            updatedAt: txTimestamp,
+        };
+
+        // Configuration optionnelle ajoutée par d'autres fonctionnalités
+        for (const field of CLONED_CONFIG_FIELDS) {
+            if (source[field] !== undefined) {
+                classData[field] = JSON.parse(JSON.stringify(source[field]));
+            }
+        }
+
+        await ctx.stub.putState(newClassId, serializeRecord(classData));
+
+        ctx.stub.setEvent('ClassCloned', Buffer.from(JSON.stringify({
+            sourceClassId: sourceClassId,
+            classId: newClassId,
+            semester: newSemester,
+            createdBy: createdBy,
+        })));
+
+        console.info(`✅ Class ${sourceClassId} cloned into ${newClassId} (${newSemester}) by ${createdBy}`);
+        console.info('============= END : CloneClass ===========');
+
+        return JSON.stringify(classData);
+    }
+
+    /**
+     * 9. Vérifier l'éligibilité d'un étudiant à l'inscription (simulation)
+     *
+     * Accessible par:
+     * - SchoolOrg (teachers/admin) - Pour n'importe quel étudiant
+     * - L'étudiant lui-même
+     *
+     * Évalue chaque condition d'EnrollStudent sans rien modifier et retourne
+     * toutes les raisons de refus (pas seulement la première); les conditions
+     * sont celles qu'applique l'inscription (_getEnrollmentGates)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @returns {string} JSON { eligible, gates: [{ gate, passed, reason }] }
+     */
+    async CheckEnrollmentEligibility(ctx, classId, studentId) {
+        console.info('============= START : CheckEnrollmentEligibility ===========');
+
+        const caller = this._getCallerIdentity(ctx);
+
+        if (!this._isSchoolMember(ctx) && !this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
+        }
+
+        if (this._isStudentMember(ctx) && caller !== studentId) {
+            throw new Error('Access Denied: Students can only check their own eligibility');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const gates = await this._getEnrollmentGates(ctx, classData, studentId, DEFAULT_SEAT_TYPE);
+
+        // Conditions non gérées on-chain à ce jour: signalées comme non évaluées
+        for (const gate of ['scheduleConflict', 'payment', 'academicStanding']) {
+            gates.push({
+                gate: gate,
+                passed: true,
+                evaluated: false,
+                reason: 'Not tracked on-chain: not evaluated',
+            });
+        }
+
+        const eligible = gates.every((gate) => gate.passed);
+
+        console.info(`✅ Eligibility of ${studentId} for ${classId}: ${eligible}`);
+        console.info('============= END : CheckEnrollmentEligibility ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            studentId: studentId,
+            eligible: eligible,
+            gates: gates,
+        });
+    }
+
+    /**
+     * 10. Ajouter un membre à l'équipe pédagogique d'une classe
+     *
+     * Accessible par: Teacher responsable de la classe + admins
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} identityId - Identité du membre (ex: "ta1@school.academic.edu")
+     * @param {string} role - Rôle dans la classe: "co-teacher" ou "ta"
+     * @returns {string} JSON de l'équipe pédagogique
+     */
+    async AddClassStaff(ctx, classId, identityId, role) {
+        console.info('============= START : AddClassStaff ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData);
+
+        if (!STAFF_ROLES.includes(role)) {
+            throw new Error(`Invalid staff role: must be one of ${STAFF_ROLES.join(', ')}`);
+        }
+        if (!identityId || !identityId.trim()) {
+            throw new Error('Invalid identityId: must be a non-empty identity');
+        }
+        if (identityId === this._getClassTeacher(classData)) {
+            throw new Error(`${identityId} is already the teacher of class ${classId}`);
+        }
+
+        const staff = classData.staff || [];
+        if (staff.some((member) => member.identityId === identityId)) {
+            throw new Error(`${identityId} is already staff of class ${classId}`);
+        }
+
+        staff.push({ identityId: identityId, role: role });
+        classData.staff = staff;
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        ctx.stub.setEvent('ClassStaffAdded', Buffer.from(JSON.stringify({
+            classId: classId,
+            identityId: identityId,
+            role: role,
+            addedBy: this._getCallerIdentity(ctx),
+        })));
+
+        console.info(`✅ ${identityId} added as ${role} to class ${classId}`);
+        console.info('============= END : AddClassStaff ===========');
+
+        return JSON.stringify({ classId: classId, staff: staff });
+    }
+
+    /**
+     * 11. Retirer un membre de l'équipe pédagogique d'une classe
+     *
+     * Accessible par: Teacher responsable de la classe + admins
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} identityId - Identité du membre à retirer
+     * @returns {string} JSON de l'équipe pédagogique
+     */
+    async RemoveClassStaff(ctx, classId, identityId) {
+        console.info('============= START : RemoveClassStaff ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData);
+
+        const staff = classData.staff || [];
+        if (!staff.some((member) => member.identityId === identityId)) {
+            throw new Error(`${identityId} is not staff of class ${classId}`);
+        }
+
+        classData.staff = staff.filter((member) => member.identityId !== identityId);
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        ctx.stub.setEvent('ClassStaffRemoved', Buffer.from(JSON.stringify({
+            classId: classId,
+            identityId: identityId,
+            removedBy: this._getCallerIdentity(ctx),
+        })));
+
+        console.info(`✅ ${identityId} removed from staff of class ${classId}`);
+        console.info('============= END : RemoveClassStaff ===========');
+
+        return JSON.stringify({ classId: classId, staff: classData.staff });
+    }
+
+    /**
+     * 12. Obtenir les classes de l'appelant (teacher responsable ou équipe pédagogique)
+     *
+     * Accessible par: SchoolOrg uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @returns {string} JSON array [{ id, name, description, semester, role }] (vide si aucune)
+     */
+    async GetMyClasses(ctx) {
+        console.info('============= START : GetMyClasses ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can list their classes');
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const queryString = JSON.stringify({
+            selector: {
+                docType: 'class',
+                $or: [
+                    { teacher: caller },
+                    { teacher: { $exists: false }, createdBy: caller },
+                    { staff: { $elemMatch: { identityId: caller } } },
+                ],
+            },
+        });
+
+        let classes;
+        try {
+            // Utiliser CouchDB rich query pour optimisation
+            classes = await this._collectClasses(await ctx.stub.getQueryResult(queryString), () => true);
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            classes = await this._collectClasses(await ctx.stub.getStateByRange('', ''),
+                (record) => record.docType === 'class' && this._getStaffRole(record, caller) !== null);
+        }
+
+        const allResults = classes.map((classData) => ({
+            id: classData.id,
+            name: classData.name,
+            description: classData.description,
+            semester: classData.semester || null,
+            role: this._getStaffRole(classData, caller),
+        }));
+
+        console.info(`✅ Found ${allResults.length} classes for ${caller}`);
+        console.info('============= END : GetMyClasses ===========');
+
+        return JSON.stringify(allResults);
+    }
+
+    /**
+     * 13. Réserver une place pendant la finalisation de l'inscription
+     *
+     * Accessible par:
+     * - SchoolOrg (teachers/admin) - Pour n'importe quel étudiant
+     * - L'étudiant lui-même - Uniquement pour lui-même
+     *
+     * La place compte dans la capacité jusqu'à sa confirmation (EnrollStudent)
+     * ou sa libération après expiration (ReleaseExpiredHolds)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} studentId - Identifiant de l'étudiant
+     * @param {string} ttlSeconds - Durée de la réservation en secondes (24h maximum)
+     * @returns {string} JSON de la réservation
+     */
+    async HoldSeat(ctx, classId, studentId, ttlSeconds) {
+        console.info('============= START : HoldSeat ===========');
+
+        const caller = this._getCallerIdentity(ctx);
+
+        if (!(await isTeachingStaff(ctx)) && !this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: You must be a member of SchoolOrg or StudentsOrg');
+        }
+
+        if (this._isStudentMember(ctx) && caller !== studentId) {
+            throw new Error(`Access Denied: Students can only hold a seat for themselves. You are ${caller}, trying to hold for ${studentId}`);
+        }
+
+        const ttl = Number(ttlSeconds);
+        if (ttlSeconds === '' || !Number.isInteger(ttl) || ttl <= 0 || ttl > MAX_HOLD_TTL_SECONDS) {
+            throw new Error(`Invalid ttlSeconds: must be a positive integer up to ${MAX_HOLD_TTL_SECONDS}`);
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        if (classData.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is already enrolled in class ${classId}`);
+        }
+        if ((classData.holds || []).some((hold) => hold.studentId === studentId)) {
+            throw new Error(`Student ${studentId} already holds a seat in class ${classId}`);
+        }
+
+        const windowError = this._checkEnrollmentWindow(ctx, classData);
+        if (windowError) {
+            throw new Error(windowError);
+        }
+
+        // Une réservation exige une place libre, quel que soit le mode de capacité
+        this._checkCapacity(classData, 1, studentId);
+
+        const txTimestamp = this._getTxTimestamp(ctx);
+        const hold = {
+            studentId: studentId,
+            heldBy: caller,
+            heldAt: txTimestamp,
+            expiresAt: new Date(new Date(txTimestamp).getTime() + ttl * 1000).toISOString(),
+        };
+
+        classData.holds = (classData.holds || []).concat(hold);
+        classData.updatedAt = txTimestamp;
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        ctx.stub.setEvent('SeatHeld', Buffer.from(JSON.stringify(Object.assign({ classId: classId }, hold))));
+
+        console.info(`✅ Seat held in ${classId} for ${studentId} until ${hold.expiresAt}`);
+        console.info('============= END : HoldSeat ===========');
+
+        return JSON.stringify(Object.assign({ classId: classId }, hold));
+    }
+
+    /**
+     * 14. Libérer les réservations expirées d'une classe
+     *
+     * Accessible par: Tous les participants authentifiés (nettoyage)
+     * Expiration évaluée au timestamp de la transaction
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @returns {string} JSON { classId, released, remainingHolds }
+     */
+    async ReleaseExpiredHolds(ctx, classId) {
+        console.info('============= START : ReleaseExpiredHolds ===========');
+
+        if (!this._isAuthenticated(ctx)) {
+            throw new Error('Access Denied: You must be authenticated to release expired holds');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const now = new Date(this._getTxTimestamp(ctx));
+
+        const holds = classData.holds || [];
+        const expired = holds.filter((hold) => new Date(hold.expiresAt) <= now);
+
+        if (expired.length > 0) {
+            classData.holds = holds.filter((hold) => new Date(hold.expiresAt) > now);
+            classData.updatedAt = this._getTxTimestamp(ctx);
+
+            await ctx.stub.putState(classId, serializeRecord(classData));
+
+            ctx.stub.setEvent('SeatHoldsReleased', Buffer.from(JSON.stringify({
+                classId: classId,
+                studentIds: expired.map((hold) => hold.studentId),
+            })));
+        }
+
+        console.info(`✅ ${expired.length} expired holds released in ${classId}`);
+        console.info('============= END : ReleaseExpiredHolds ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            released: expired.map((hold) => hold.studentId),
+            remainingHolds: holds.length - expired.length,
+        });
+    }
+
+    /**
+     * 15. Changer le teacher responsable d'une classe
+     *
+     * Accessible par: Teacher responsable de la classe + admins
+     * Les étudiants inscrits (actifs uniquement) reçoivent une notification
+     * avec l'ancien et le nouveau teacher
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} newTeacher - Identité du nouveau teacher (ex: "teacher2@school.academic.edu")
+     * @returns {string} JSON { classId, previousTeacher, newTeacher, notified }
+     */
+    async ReassignTeacher(ctx, classId, newTeacher) {
+        console.info('============= START : ReassignTeacher ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData, 'teacher');
+
+        if (!newTeacher || !newTeacher.trim()) {
+            throw new Error('Invalid newTeacher: must be a non-empty identity');
+        }
+
+        const previousTeacher = this._getClassTeacher(classData);
+        if (newTeacher === previousTeacher) {
+            throw new Error(`${newTeacher} is already the teacher of class ${classId}`);
+        }
+
+        const txTimestamp = this._getTxTimestamp(ctx);
+
+        classData.teacher = newTeacher;
+        // Le nouveau responsable quitte l'équipe pédagogique s'il en faisait partie
+        classData.staff = (classData.staff || []).filter((member) => member.identityId !== newTeacher);
+        classData.updatedAt = txTimestamp;
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        // enrolledStudents ne contient que les inscriptions actives
+        for (const studentId of classData.enrolledStudents) {
+            await createNotification(ctx, studentId, 'ClassTeacherChanged', {
+                classId: classId,
+                className: classData.name,
+                previousTeacher: previousTeacher,
+                newTeacher: newTeacher,
+            });
+        }
+
+        const changedBy = this._getCallerIdentity(ctx);
+        ctx.stub.setEvent('ClassTeacherChanged', Buffer.from(JSON.stringify({
+            classId: classId,
+            previousTeacher: previousTeacher,
+            newTeacher: newTeacher,
+            changedBy: changedBy,
+            notifiedCount: classData.enrolledStudents.length,
+        })));
+
+        console.info(`✅ Class ${classId} reassigned from ${previousTeacher} to ${newTeacher} by ${changedBy}`);
+        console.info('============= END : ReassignTeacher ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            previousTeacher: previousTeacher,
+            newTeacher: newTeacher,
+            notified: classData.enrolledStudents,
+        });
+    }
+
+    /**
+     * 16. Modifier partiellement une classe
+     *
+     * Accessible par: Teacher responsable de la classe + admins
+     * Seuls les champs présents dans patchJSON sont modifiés (un champ absent est conservé,
+     * une valeur 0 ou "" est appliquée): pas de lecture-modification-écriture côté client
+     *
+     * Champs modifiables: name, description, semester (null pour le retirer),
+     * maxStudents (entre 1 et maxStudentsLimit de la configuration),
+     * maxWaitlist (0 = pas de liste d'attente, jamais sous la liste d'attente actuelle),
+     * department et level (classement du catalogue, null pour les retirer),
+     * tags (liste d'étiquettes distinctes, MAX_CLASS_TAGS au plus),
+     * creditHours (nombre strictement positif, pondération de la moyenne GPA)
+     * Les places ajoutées par une hausse de maxStudents sont attribuées à la liste d'attente.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} patchJSON - Champs à modifier (ex: '{"description":"...","maxStudents":40}')
+     * @returns {string} JSON de la classe mise à jour
+     */
+    async PatchClass(ctx, classId, patchJSON) {
+        console.info('============= START : PatchClass ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData, 'details');
+
+        let patch;
+        try {
+            patch = JSON.parse(patchJSON);
+        } catch (err) {
+            throw new Error('Invalid patchJSON: must be a JSON object');
+        }
+        if (!patch || typeof patch !== 'object' || Array.isArray(patch)) {
+            throw new Error('Invalid patchJSON: must be a JSON object');
+        }
+
+        const editable = ['name', 'description', 'semester', 'maxStudents', 'maxWaitlist', 'department', 'level', 'tags', 'creditHours'];
+        const wasFull = !this._hasCapacity(classData, 1, '');
+        const fields = Object.keys(patch);
+        if (fields.length === 0) {
+            throw new Error('Invalid patchJSON: at least one field is required');
+        }
+        for (const key of fields) {
+            if (!editable.includes(key)) {
+                throw new Error(`Invalid field: ${key} cannot be patched (allowed: ${editable.join(', ')})`);
+            }
+        }
+
+        if ('name' in patch) {
+            if (typeof patch.name !== 'string' || !patch.name.trim()) {
+                throw new Error('Invalid name: must be a non-empty string');
+            }
+            classData.name = patch.name;
+        }
+
+        if ('description' in patch) {
+            if (typeof patch.description !== 'string') {
+                throw new Error('Invalid description: must be a string');
+            }
+            classData.description = patch.description;
+        }
+
+        if ('semester' in patch) {
+            if (patch.semester !== null && (typeof patch.semester !== 'string' || !patch.semester.trim())) {
+                throw new Error('Invalid semester: must be a non-empty string or null');
+            }
+            classData.semester = patch.semester;
+        }
+
+        if ('maxStudents' in patch) {
+            await this._checkMaxStudents(ctx, patch.maxStudents);
+            const enrolledCount = this._getSeatCount(classData, DEFAULT_SEAT_TYPE);
+            if (patch.maxStudents < enrolledCount) {
+                throw new Error(`Invalid maxStudents: ${patch.maxStudents} is below the ${enrolledCount} active enrollments of class ${classId}`);
+            }
+            classData.maxStudents = patch.maxStudents;
+        }
+
+        if ('maxWaitlist' in patch) {
+            if (!Number.isInteger(patch.maxWaitlist) || patch.maxWaitlist < 0) {
+                throw new Error('Invalid maxWaitlist: must be a positive integer (0 = no waitlist)');
+            }
+            const waitlistCount = (classData.waitlist || []).length;
+            if (patch.maxWaitlist < waitlistCount) {
+                throw new Error(`Invalid maxWaitlist: ${patch.maxWaitlist} is below the ${waitlistCount} students on the waitlist of class ${classId}`);
+            }
+            classData.maxWaitlist = patch.maxWaitlist;
+        }
+
+        if ('department' in patch) {
+            if (patch.department !== null && (typeof patch.department !== 'string' || !patch.department.trim())) {
+                throw new Error('Invalid department: must be a non-empty string or null');
+            }
+            classData.department = patch.department;
+        }
+
+        if ('level' in patch) {
+            if (patch.level !== null && (!Number.isInteger(patch.level) || patch.level <= 0)) {
+                throw new Error('Invalid level: must be a positive integer (ex: 100) or null');
+            }
+            classData.level = patch.level;
+        }
+
+        if ('tags' in patch) {
+            classData.tags = this._parseClassTags(patch.tags);
+        }
+
+        if ('creditHours' in patch) {
+            if (typeof patch.creditHours !== 'number' || !Number.isFinite(patch.creditHours) || patch.creditHours <= 0) {
+                throw new Error('Invalid creditHours: must be a strictly positive number');
+            }
+            classData.creditHours = patch.creditHours;
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        classData.updatedAt = this._getTxTimestamp(ctx);
+        // Hausse de maxStudents: les nouvelles places vont d'abord à la liste d'attente
+        const promoted = await this._promoteWaitlist(ctx, classData);
+        const notifiedWatchers = await this._notifySeatOpened(ctx, classData, wasFull);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        ctx.stub.setEvent('ClassUpdated', Buffer.from(JSON.stringify({
+            classId: classId,
+            fields: fields,
+            updatedBy: caller,
+            promoted: promoted,
+            notifiedWatchers: notifiedWatchers,
+        })));
+
+        console.info(`✅ Class ${classId} patched (${fields.join(', ')}) by ${caller}`);
+        console.info('============= END : PatchClass ===========');
+
+        return JSON.stringify(classData);
+    }
+
+    /**
+     * 17. Fusionner deux sections (la source est absorbée par la cible)
+     *
+     * Accessible par: Admins uniquement
+     *
+     * - Inscriptions actives de la source: places "lecture" libres de la cible, le surplus
+     *   rejoint la liste d'attente de la cible; la liste d'attente de la source suit
+     * - Refus (rien n'est modifié) si la cible ne peut pas accueillir tout le monde:
+     *   places libres + places de liste d'attente (maxWaitlist, 0 = pas de liste d'attente)
+     * - Refus également si la source a des notes finalisées (FinalizeGrade): elles ne peuvent
+     *   pas être re-chaînées sans UnfinalizeGrade préalable
+     * - Supports, examens, copies, notes, contestations et incidents sont rattachés à la cible,
+     *   les notes sont re-chaînées (VerifyExamGradeChain); les modules de la source sont ajoutés
+     * - La source est archivée (archived, mergedInto) et n'accepte plus d'inscriptions
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} sourceClassId - Section absorbée
+     * @param {string} targetClassId - Section conservée
+     * @returns {string} JSON { sourceClassId, targetClassId, enrolled, waitlisted, moved }
+     */
+    async MergeClasses(ctx, sourceClassId, targetClassId) {
+        console.info('============= START : MergeClasses ===========');
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can merge classes');
+        }
+
+        if (sourceClassId === targetClassId) {
+            throw new Error('Invalid merge: source and target classes are the same');
+        }
+
+        const source = await this._getClass(ctx, sourceClassId);
+        const target = await this._getClass(ctx, targetClassId);
+        this._checkNotArchived(source);
+        this._checkNotArchived(target);
+
+        // Une note finalisée ne change plus (même son classId): la dé-finaliser d'abord (UnfinalizeGrade)
+        const classRecords = await this._getClassRecords(ctx, sourceClassId);
+        const finalizedGrades = classRecords
+            .filter(({ record }) => record.docType === 'grade' && record.finalized)
+            .map(({ key }) => key)
+            .sort();
+        if (finalizedGrades.length > 0) {
+            throw new Error(`Cannot merge ${sourceClassId} into ${targetClassId}: grades ${finalizedGrades.join(', ')} are finalized (UnfinalizeGrade them first)`);
+        }
+
+        // Étudiants à déplacer (ceux déjà inscrits ou en attente dans la cible y restent)
+        const targetWaitlist = target.waitlist || [];
+        const incoming = source.enrolledStudents
+            .filter((studentId) => !target.enrolledStudents.includes(studentId) && !targetWaitlist.includes(studentId));
+        const incomingWaitlist = (source.waitlist || [])
+            .filter((studentId) => !target.enrolledStudents.includes(studentId) && !targetWaitlist.includes(studentId));
+
+        // Capacité: places "lecture" libres de la cible, puis sa liste d'attente
+        const capacity = this._getSeatCapacity(target, DEFAULT_SEAT_TYPE);
+        const freeSeats = Math.max(capacity - this._getSeatCount(target, DEFAULT_SEAT_TYPE) - this._getHeldSeats(target), 0);
+        const overflow = Math.max(incoming.length - freeSeats, 0) + incomingWaitlist.length;
+        if (overflow > 0) {
+            const maxWaitlist = await this._getMaxWaitlist(ctx, target);
+            const freeWaitlist = Math.max(maxWaitlist - targetWaitlist.length, 0);
+            if (overflow > freeWaitlist) {
+                throw new Error(`Cannot merge ${sourceClassId} into ${targetClassId}: ${incoming.length} incoming students for ${freeSeats} free seats, ` +
+                    (maxWaitlist === 0 ? 'and the target does not allow a waitlist' : `and only ${freeWaitlist} free waitlist places for ${overflow} (maxWaitlist: ${maxWaitlist})`));
+            }
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const txTimestamp = this._getTxTimestamp(ctx);
+        const enrolled = [];
+        const waitlisted = [];
+
+        for (const studentId of source.enrolledStudents.slice()) {
+            await this._removeActiveEnrollment(ctx, source, studentId, 'transferred', { transferredTo: targetClassId, mergedInto: targetClassId });
+            if (!incoming.includes(studentId)) {
+                continue;
+            }
+            if (this._hasCapacity(target, 1, studentId)) {
+                await this._addActiveEnrollment(ctx, target, studentId, { mergedFrom: sourceClassId });
+                enrolled.push(studentId);
+            } else {
+                await this._addWaitlistEntry(ctx, target, studentId, { mergedFrom: sourceClassId });
+                waitlisted.push(studentId);
+            }
+        }
+
+        for (const studentId of source.waitlist || []) {
+            const enrollment = await this._getEnrollment(ctx, sourceClassId, studentId);
+            if (enrollment) {
+                enrollment.status = 'transferred';
+                enrollment.withdrawnAt = txTimestamp;
+                enrollment.transferredTo = targetClassId;
+                enrollment.mergedInto = targetClassId;
+                await ctx.stub.putState(enrollment.id, serializeRecord(enrollment));
+            }
+            if (incomingWaitlist.includes(studentId)) {
+                await this._addWaitlistEntry(ctx, target, studentId, { mergedFrom: sourceClassId });
+                waitlisted.push(studentId);
+            }
+        }
+
+        // Supports, examens, copies, notes... rattachés à la cible
+        const moved = {};
+        for (const { key, record } of classRecords) {
+            record.classId = targetClassId;
+            if (record.docType === 'grade') {
+                // classId fait partie du contenu chaîné: nouveau maillon
+                await appendGradeLink(ctx, record, 'update');
+            }
+            await ctx.stub.putState(key, serializeRecord(record));
+            moved[record.docType] = (moved[record.docType] || 0) + 1;
+        }
+
+        target.modules = target.modules.concat((source.modules || []).filter((module) => !target.modules.includes(module)));
+        target.updatedAt = txTimestamp;
+
+        source.waitlist = [];
+        source.holds = [];
+        source.archived = true;
+        source.archivedAt = txTimestamp;
+        source.mergedInto = targetClassId;
+        source.updatedAt = txTimestamp;
+
+        await ctx.stub.putState(sourceClassId, serializeRecord(source));
+        await ctx.stub.putState(targetClassId, serializeRecord(target));
+
+        ctx.stub.setEvent('ClassesMerged', Buffer.from(JSON.stringify({
+            sourceClassId: sourceClassId,
+            targetClassId: targetClassId,
+            enrolled: enrolled.length,
+            waitlisted: waitlisted.length,
+            mergedBy: caller,
+        })));
+
+        console.info(`✅ Class ${sourceClassId} merged into ${targetClassId} by ${caller}: ${enrolled.length} enrolled, ${waitlisted.length} waitlisted`);
+        console.info('============= END : MergeClasses ===========');
+
+        return JSON.stringify({
+            success: true,
+            sourceClassId: sourceClassId,
+            targetClassId: targetClassId,
+            enrolled: enrolled,
+            waitlisted: waitlisted,
+            moved: moved,
+        });
+    }
+
+    // ==================== FONCTIONS FALLBACK (sans CouchDB) ====================
+
+    /**
+     * Fallback pour GetClassByName si CouchDB non disponible
+     */
+    async _getClassByNameFallback(ctx, name) {
+        const allResults = [];
+        const iterator = await ctx.stub.getStateByRange('', '');
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            let record;
+
+            try {
+                record = JSON.parse(strValue);
+
+                if (record.docType === 'class' && record.name === name) {
+                    allResults.push({
+                        id: record.id,
+                        name: record.name,
+                        description: record.description,
+                    });
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return JSON.stringify(allResults);
+    }
+
+    // ==================== FONCTIONS UTILITAIRES ====================
+
+    /**
+     * Vérifie si une classe existe
+     * @private
+     */
+    async _classExists(ctx, classId) {
+        const classAsBytes = await ctx.stub.getState(classId);
+        return classAsBytes && classAsBytes.length > 0;
+    }
+
+    /**
+     * Récupère une classe et vérifie son type
+     * @private
+     * @throws {Error} Si la classe n'existe pas
+     */
+    async _getClass(ctx, classId) {
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${classId} does not exist`);
+        }
+
+        const classData = parseRecord(classAsBytes, classId, 'class');
+
+        return classData;
+    }
+
+    /**
+     * Teacher responsable d'une classe (classes antérieures: le créateur)
+     * @private
+     */
+    _getClassTeacher(classData) {
+        return classData.teacher || classData.createdBy;
+    }
+
+    /**
+     * Rôle d'une identité dans une classe: teacher, co-teacher, ta ou null
+     * @private
+     */
+    _getStaffRole(classData, identityId) {
+        if (this._getClassTeacher(classData) === identityId) {
+            return 'teacher';
+        }
+        const member = (classData.staff || []).find((m) => m.identityId === identityId);
+        return member ? member.role : null;
+    }
+
+    /**
+     * Vérifie que l'appelant est le teacher responsable de la classe ou un admin
+     * subject: objet géré, pour le message d'erreur (staff par défaut)
+     * @private
+     * @throws {Error} Sinon
+     */
+    async _checkClassOwner(ctx, classData, subject) {
+        const managed = subject || 'staff';
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error(`Access Denied: Only SchoolOrg members can manage class ${managed}`);
+        }
+        if (this._getClassTeacher(classData) === this._getCallerIdentity(ctx)) {
+            return;
+        }
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error(`Access Denied: Only the teacher of class ${classData.id} or an admin can manage its ${managed}`);
+        }
+    }
+
+    /**
+     * Graphe des prérequis: ID de classe -> IDs des classes requises
+     * @private
+     */
+    async _getPrerequisiteGraph(ctx) {
+        let classes;
+        try {
+            const queryString = JSON.stringify({ selector: { docType: 'class' } });
+            classes = await this._collectClasses(await ctx.stub.getQueryResult(queryString), () => true);
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            classes = await this._collectClasses(await ctx.stub.getStateByRange('', ''),
+                (record) => record.docType === 'class');
+        }
+
+        const graph = new Map();
+        for (const classData of classes) {
+            graph.set(classData.id, Array.isArray(classData.prerequisites) ? classData.prerequisites : []);
+        }
+        return graph;
+    }
+
+    /**
+     * Chemin de prérequis de "from" vers "to" (parcours en profondeur), null si aucun
+     * @private
+     */
+    _findPrerequisitePath(graph, from, to, visited) {
+        if (from === to) {
+            return [to];
+        }
+        const seen = visited || new Set();
+        if (seen.has(from)) {
+            return null;
+        }
+        seen.add(from);
+
+        for (const next of graph.get(from) || []) {
+            const path = this._findPrerequisitePath(graph, next, to, seen);
+            if (path) {
+                return [from].concat(path);
+            }
+        }
+        return null;
+    }
+
+    /**
+     * Cycles du graphe des prérequis (un cycle par arc retour, ex: ["A", "B", "A"])
+     * @private
+     */
+    _findPrerequisiteCycles(graph) {
+        const state = new Map(); // absent: non visité, 1: en cours, 2: terminé
+        const stack = [];
+        const cycles = [];
+
+        const visit = (classId) => {
+            state.set(classId, 1);
+            stack.push(classId);
+            for (const next of graph.get(classId) || []) {
+                if (state.get(next) === 1) {
+                    cycles.push(stack.slice(stack.indexOf(next)).concat(next));
+                } else if (!state.has(next)) {
+                    visit(next);
+                }
+            }
+            stack.pop();
+            state.set(classId, 2);
+        };
+
+        for (const classId of Array.from(graph.keys()).sort()) {
+            if (!state.has(classId)) {
+                visit(classId);
+            }
+        }
+        return cycles;
+    }
+
+    /**
+     * Page de classes (vue publique) à partir de la clé startKey
+     * bookmark: clé à laquelle reprendre la lecture, "" si la liste est complète
+     *
+     * Les clés des classes n'ont pas de préfixe commun: le ledger est lu par pages de
+     * CLASS_SCAN_PAGE_SIZE enregistrements (getStateByRangeWithPagination, requête en
+     * lecture seule) jusqu'à limit classes ou la fin du ledger. Le bookmark n'est renseigné
+     * que si une classe reste à lire, ou si la requête est annulée (isCancelled, vérifié
+     * avant chaque enregistrement): la lecture s'arrête alors sur un résultat partiel.
+     * @private
+     * @returns {Promise<Object>} { classes, bookmark, cancelled }
+     */
+    async _getPublicClassesPage(ctx, startKey, limit) {
+        const classes = [];
+        let pageStart = startKey;
+
+        do {
+            const { iterator, metadata } = await ctx.stub.getStateByRangeWithPagination(pageStart, '', CLASS_SCAN_PAGE_SIZE, '');
+            let result = await iterator.next();
+
+            while (!result.done) {
+                if (isCancelled(ctx)) {
+                    await iterator.close();
+                    return { classes: classes, bookmark: result.value.key, cancelled: true };
+                }
+
+                const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+                let record;
+
+                try {
+                    record = JSON.parse(strValue);
+                } catch (err) {
+                    console.log('Error parsing record:', err);
+                }
+
+                // Filtrer uniquement les classes
+                if (record && record.docType === 'class') {
+                    if (classes.length === limit) {
+                        await iterator.close();
+                        return { classes: classes, bookmark: result.value.key, cancelled: false };
+                    }
+                    // Retourner UNIQUEMENT les informations publiques
+                    // (modules et enrolledStudents sont EXCLUS)
+                    classes.push({
+                        id: record.id,
+                        name: record.name,
+                        description: record.description,
+                    });
+                }
+
+                result = await iterator.next();
+            }
+
+            await iterator.close();
+            // Page lue entièrement: la suivante reprend après le dernier enregistrement lu
+            pageStart = metadata.bookmark || '';
+        } while (pageStart !== '');
+
+        return { classes: classes, bookmark: '', cancelled: false };
+    }
+
+    /**
+     * Parcourt un itérateur et retourne les classes acceptées par le filtre
+     * @private
+     */
+    async _collectClasses(iterator, filter) {
+        const allResults = [];
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if (filter(record)) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
+    }
+
+    /**
+     * Clé du recensement des inscrits d'une classe à une date (UTC)
+     * @private
+     */
+    _censusKey(classId, censusDate) {
+        return `CENSUS_${classId}_${censusDate}`;
+    }
+
+    /**
+     * Clé de l'enregistrement d'inscription d'un étudiant
+     * @private
+     */
+    _enrollmentKey(classId, studentId) {
+        return enrollmentKey(classId, studentId);
+    }
+
+    /**
+     * Récupère l'enregistrement d'inscription (null si absent)
+     * Clé déterministe en priorité, sinon ancienne clé
+     * @private
+     */
+    async _getEnrollment(ctx, classId, studentId) {
+        return getEnrollmentRecord(ctx, classId, studentId);
+    }
+
+    /**
+     * Écrit un enregistrement d'inscription
+     * Un enregistrement créé sous la clé déterministe remplace celui de l'ancienne clé:
+     * une seule inscription par étudiant et par classe
+     * @private
+     */
+    async _putEnrollment(ctx, enrollment) {
+        await ctx.stub.putState(enrollment.id, serializeRecord(enrollment));
+
+        const legacyKey = legacyEnrollmentKey(enrollment.classId, enrollment.studentId);
+        if (enrollment.id !== legacyKey) {
+            const legacyAsBytes = await ctx.stub.getState(legacyKey);
+            if (legacyAsBytes && legacyAsBytes.length > 0) {
+                await ctx.stub.deleteState(legacyKey);
+            }
+        }
+    }
+
+    /**
+     * Nombre d'inscriptions actives d'une classe
+     * Les classes créées avant le compteur utilisent la taille de la liste
+     * @private
+     */
+    _getEnrolledCount(classData) {
+        return typeof classData.enrolledCount === 'number'
+            ? classData.enrolledCount
+            : classData.enrolledStudents.length;
+    }
+
+    /**
+     * Recompte les inscriptions actives: enregistrements ENR_ actifs,
+     * plus les inscrits antérieurs aux enregistrements
+     * @private
+     */
+    async _countActiveEnrollments(ctx, classData) {
+        const enrollments = await this._getClassEnrollments(ctx, classData.id);
+        const active = new Set(enrollments
+            .filter((enrollment) => enrollment.status === 'active')
+            .map((enrollment) => enrollment.studentId));
+        const recorded = new Set(enrollments.map((enrollment) => enrollment.studentId));
+
+        // Inscriptions antérieures aux enregistrements ENR_: seule la liste fait foi
+        for (const studentId of classData.enrolledStudents) {
+            if (!recorded.has(studentId)) {
+                active.add(studentId);
+            }
+        }
+
+        return active.size;
+    }
+
+    /**
+     * Valide une capacité de classe: entier entre 1 et maxStudentsLimit (configuration)
+     * @private
+     * @throws {Error} Si la capacité est hors bornes
+     */
+    async _checkMaxStudents(ctx, maxStudents) {
+        if (!Number.isInteger(maxStudents) || maxStudents <= 0) {
+            throw new Error('Invalid maxStudents: must be a positive integer');
+        }
+
+        const { maxStudentsLimit } = await getSystemConfig(ctx);
+        if (maxStudents > maxStudentsLimit) {
+            throw new Error(`Invalid maxStudents: ${maxStudents} exceeds the system limit of ${maxStudentsLimit}`);
+        }
+    }
+
+    /**
+     * Vérifie qu'il reste assez de places pour N nouvelles inscriptions
+     * @private
+     * @throws {Error} Si la classe est pleine
+     */
+    _checkCapacity(classData, seatsNeeded, studentId, seatType) {
+        const error = this._getCapacityError(classData, seatsNeeded, studentId, seatType);
+        if (error) {
+            throw new Error(error);
+        }
+    }
+
+    /**
+     * Raison du refus faute de places pour N nouvelles inscriptions
+     * @private
+     * @returns {string|null} Raison du refus, null s'il reste assez de places
+     */
+    _getCapacityError(classData, seatsNeeded, studentId, seatType) {
+        if (this._hasCapacity(classData, seatsNeeded, studentId, seatType)) {
+            return null;
+        }
+        const type = seatType || DEFAULT_SEAT_TYPE;
+        if (type !== DEFAULT_SEAT_TYPE) {
+            return `Class ${classData.id} has no ${type} seats left (${this._getSeatCount(classData, type)}/${this._getSeatCapacity(classData, type)} students)`;
+        }
+        const enrolledCount = this._getSeatCount(classData, DEFAULT_SEAT_TYPE);
+        const heldSeats = this._getHeldSeats(classData, studentId);
+        const held = heldSeats > 0 ? `, ${heldSeats} seats held` : '';
+        return `Class ${classData.id} is full (${enrolledCount}/${classData.maxStudents} students${held})`;
+    }
+
+    /**
+     * Conditions d'inscription d'un étudiant à une classe, dans l'ordre des contrôles
+     * Source unique de l'inscription (_enrollStudent, EnrollStudentsBatch, AutoAssignSection)
+     * et de la simulation (CheckEnrollmentEligibility)
+     * Mode "soft": une classe pleine reste acceptée (sur-inscription signalée)
+     * @private
+     * @returns {Promise<Object[]>} [{ gate, passed, reason }]
+     */
+    async _getEnrollmentGates(ctx, classData, studentId, seatType) {
+        const type = seatType || DEFAULT_SEAT_TYPE;
+        const gates = [];
+
+        const alreadyEnrolled = classData.enrolledStudents.includes(studentId);
+        gates.push({
+            gate: 'enrollmentStatus',
+            passed: !alreadyEnrolled,
+            reason: alreadyEnrolled
+                ? `Student ${studentId} is already enrolled in class ${classData.id}`
+                : 'Not yet enrolled',
+        });
+
+        gates.push({
+            gate: 'archived',
+            passed: !classData.archived,
+            reason: classData.archived
+                ? `Class ${classData.id} is archived (merged into ${classData.mergedInto})`
+                : 'Class is not archived',
+        });
+
+        const windowError = this._checkEnrollmentWindow(ctx, classData);
+        gates.push({
+            gate: 'enrollmentWindow',
+            passed: !windowError,
+            reason: windowError || 'Enrollment is open',
+        });
+
+        const missing = await this._getMissingPrerequisites(ctx, classData, studentId);
+        gates.push({
+            gate: 'prerequisites',
+            passed: missing.length === 0,
+            reason: missing.length > 0
+                ? `Student ${studentId} has not completed the prerequisites of class ${classData.id}: ${missing.join(', ')}`
+                : 'Prerequisites completed',
+        });
+
+        const concurrentError = await this._getConcurrentEnrollmentsError(ctx, studentId);
+        gates.push({
+            gate: 'concurrentEnrollments',
+            passed: !concurrentError,
+            reason: concurrentError || 'Below the concurrent enrollment limit',
+        });
+
+        const capacityError = this._getCapacityError(classData, 1, studentId, type);
+        const softMode = this._getEnrollmentMode(classData) === 'soft';
+        const pool = type === DEFAULT_SEAT_TYPE ? '' : `${type} `;
+        let capacityReason = `${this._getSeatCount(classData, type)}/${this._getSeatCapacity(classData, type) || 'unlimited'} ${pool}seats taken`;
+        if (capacityError) {
+            capacityReason = softMode
+                ? `Class is full; over-capacity enrollment requires instructor review (${capacityError})`
+                : capacityError;
+        }
+        gates.push({
+            gate: 'capacity',
+            passed: !capacityError || softMode,
+            reason: capacityReason,
+        });
+
+        return gates;
+    }
+
+    /**
+     * Première condition d'inscription non remplie par un étudiant d'un lot (EnrollStudentsBatch)
+     * La capacité est exclue: elle porte sur l'ensemble du lot et est vérifiée à part
+     * @private
+     * @returns {Promise<string|null>} Raison du refus, null si l'étudiant peut être inscrit
+     */
+    async _getBatchEnrollmentError(ctx, classData, studentId) {
+        const gates = await this._getEnrollmentGates(ctx, classData, studentId, DEFAULT_SEAT_TYPE);
+        const failedGate = gates.find((gate) => gate.gate !== 'capacity' && !gate.passed);
+        return failedGate ? failedGate.reason : null;
+    }
+
+    /**
+     * Prérequis d'une classe que l'étudiant n'a pas validés
+     * Un prérequis est validé par un résultat figé "completed" (FinalizeClassOutcome)
+     * @private
+     * @returns {Promise<string[]>} IDs des classes requises non validées
+     */
+    async _getMissingPrerequisites(ctx, classData, studentId) {
+        const missing = [];
+        for (const prerequisiteId of classData.prerequisites || []) {
+            const outcome = await getOutcomeRecord(ctx, prerequisiteId, studentId);
+            if (!outcome || outcome.status !== 'completed') {
+                missing.push(prerequisiteId);
+            }
+        }
+        return missing;
+    }
+
+    /**
+     * Indique s'il reste assez de places pour N nouvelles inscriptions dans un pool
+     * Les réservations (HoldSeat) portent sur des places "lecture"
+     * @private
+     */
+    _hasCapacity(classData, seatsNeeded, studentId, seatType) {
+        const type = seatType || DEFAULT_SEAT_TYPE;
+        const capacity = this._getSeatCapacity(classData, type);
+        if (type !== DEFAULT_SEAT_TYPE && capacity === 0) {
+            return true;
+        }
+        const heldSeats = type === DEFAULT_SEAT_TYPE ? this._getHeldSeats(classData, studentId) : 0;
+        return this._getSeatCount(classData, type) + heldSeats + seatsNeeded <= capacity;
+    }
+
+    /**
+     * Valide les étiquettes du catalogue d'une classe: chaînes non vides et distinctes
+     * @private
+     * @throws {Error} Si la liste est invalide
+     */
+    _parseClassTags(tags) {
+        if (!Array.isArray(tags) || tags.some((tag) => typeof tag !== 'string' || !tag.trim())) {
+            throw new Error('Invalid tags: must be an array of non-empty strings');
+        }
+        if (new Set(tags).size !== tags.length) {
+            throw new Error('Invalid tags: tags must be distinct');
+        }
+        if (tags.length > MAX_CLASS_TAGS) {
+            throw new Error(`Invalid tags: at most ${MAX_CLASS_TAGS} tags per class`);
+        }
+        return tags;
+    }
+
+    /**
+     * Valide les critères de GetCatalogFiltered (clés parmi CATALOG_FILTER_FIELDS)
+     * tags est normalisé en liste d'étiquettes
+     * @private
+     * @throws {Error} Si un critère est inconnu ou mal typé
+     */
+    _parseCatalogFilter(filterJSON) {
+        let filter;
+        try {
+            filter = JSON.parse(filterJSON);
+        } catch (err) {
+            throw new Error('Invalid filterJSON: must be a JSON object');
+        }
+        if (!filter || typeof filter !== 'object' || Array.isArray(filter)) {
+            throw new Error('Invalid filterJSON: must be a JSON object');
+        }
+
+        const fields = Object.keys(filter);
+        if (fields.length === 0) {
+            throw new Error(`Invalid filterJSON: at least one criterion is required (${CATALOG_FILTER_FIELDS.join(', ')})`);
+        }
+        for (const key of fields) {
+            if (!CATALOG_FILTER_FIELDS.includes(key)) {
+                throw new Error(`Invalid filter field: ${key} (supported: ${CATALOG_FILTER_FIELDS.join(', ')})`);
+            }
+        }
+
+        for (const key of ['department', 'semester']) {
+            if (key in filter && (typeof filter[key] !== 'string' || !filter[key].trim())) {
+                throw new Error(`Invalid ${key} filter: must be a non-empty string`);
+            }
+        }
+        if ('level' in filter && (!Number.isInteger(filter.level) || filter.level <= 0)) {
+            throw new Error('Invalid level filter: must be a positive integer');
+        }
+        if ('tags' in filter) {
+            const tags = typeof filter.tags === 'string' ? [filter.tags] : filter.tags;
+            if (!Array.isArray(tags) || tags.length === 0 || tags.some((tag) => typeof tag !== 'string' || !tag.trim())) {
+                throw new Error('Invalid tags filter: must be a tag or a non-empty array of tags');
+            }
+            filter.tags = tags;
+        }
+
+        return filter;
+    }
+
+    /**
+     * Indique si une classe satisfait tous les critères du catalogue
+     * @private
+     */
+    _matchesCatalogFilter(classData, filter) {
+        return Object.keys(filter).every((key) => {
+            if (key === 'tags') {
+                return filter.tags.every((tag) => (classData.tags || []).includes(tag));
+            }
+            return classData[key] === filter[key];
+        });
+    }
+
+    /**
+     * Valide un barème des mentions: seuils distincts entre 0 et 100, dont un à 0
+     * Retourné trié par seuil décroissant (ordre de recherche des mentions)
+     * @private
+     * @throws {Error} Si le barème est invalide
+     */
+    _parseGradingScale(scaleJSON) {
+        let scale;
+        try {
+            scale = JSON.parse(scaleJSON);
+        } catch (err) {
+            throw new Error('Invalid scaleJSON: must be a JSON array of { minPercent, letter, points }');
+        }
+        if (!Array.isArray(scale) || scale.length === 0) {
+            throw new Error('Invalid scaleJSON: must be a non-empty JSON array of { minPercent, letter, points }');
+        }
+
+        const rows = scale.map((row) => {
+            if (!row || typeof row !== 'object' || typeof row.minPercent !== 'number' || row.minPercent < 0 || row.minPercent > 100) {
+                throw new Error('Invalid grading scale row: minPercent must be a number between 0 and 100');
+            }
+            if (typeof row.letter !== 'string' || !row.letter.trim()) {
+                throw new Error('Invalid grading scale row: letter must be a non-empty string');
+            }
+            if (typeof row.points !== 'number' || !isFinite(row.points) || row.points < 0) {
+                throw new Error(`Invalid grading scale row ${row.letter}: points must be a non-negative number`);
+            }
+            return { minPercent: row.minPercent, letter: row.letter, points: row.points };
+        });
+
+        const thresholds = new Set(rows.map((row) => row.minPercent));
+        if (thresholds.size !== rows.length) {
+            throw new Error('Invalid grading scale: minPercent thresholds must be distinct');
+        }
+        if (!thresholds.has(0)) {
+            throw new Error('Invalid grading scale: a row with minPercent 0 is required');
+        }
+
+        return rows.sort((a, b) => b.minPercent - a.minPercent);
+    }
+
+    /**
+     * Vérifie que la classe propose le type de place demandé
+     * @private
+     * @throws {Error} Si le pool n'existe pas pour cette classe
+     */
+    _checkSeatType(classData, seatType) {
+        if (seatType !== DEFAULT_SEAT_TYPE && !(seatType in (classData.seatCapacities || {}))) {
+            throw new Error(`Class ${classData.id} has no ${seatType} seats (see SetSeatCapacity)`);
+        }
+    }
+
+    /**
+     * Capacité d'un pool de places; "lecture" correspond à maxStudents,
+     * les autres pools valent 0 quand ils sont illimités (SetSeatCapacity)
+     * @private
+     */
+    _getSeatCapacity(classData, seatType) {
+        if (seatType === DEFAULT_SEAT_TYPE) {
+            return classData.maxStudents;
+        }
+        return (classData.seatCapacities || {})[seatType] || 0;
+    }
+
+    /**
+     * Inscriptions actives d'un pool de places
+     * "lecture": toutes les inscriptions actives hors autres pools (classes antérieures aux pools)
+     * @private
+     */
+    _getSeatCount(classData, seatType) {
+        const seatCounts = classData.seatCounts || {};
+        if (seatType !== DEFAULT_SEAT_TYPE) {
+            return seatCounts[seatType] || 0;
+        }
+        const otherSeats = Object.keys(seatCounts).reduce((sum, type) => sum + seatCounts[type], 0);
+        return Math.max(this._getEnrolledCount(classData) - otherSeats, 0);
+    }
+
+    /**
+     * Nombre de places réservées (HoldSeat), hors réservation de studentId
+     * Une réservation expirée compte jusqu'à sa libération (ReleaseExpiredHolds)
+     * @private
+     */
+    _getHeldSeats(classData, studentId) {
+        return (classData.holds || []).filter((hold) => hold.studentId !== studentId).length;
+    }
+
+    /**
+     * Vérifie le plafond d'inscriptions actives simultanées d'un étudiant
+     * (maxConcurrentActiveEnrollments de la configuration, 0 = illimité), tous semestres confondus
+     * @private
+     * @throws {Error} Si l'étudiant a atteint le plafond
+     */
+    async _checkConcurrentEnrollments(ctx, studentId) {
+        const error = await this._getConcurrentEnrollmentsError(ctx, studentId);
+        if (error) {
+            throw new Error(error);
+        }
+    }
+
+    /**
+     * Plafond d'inscriptions actives simultanées d'un étudiant
+     * Les inscriptions ajoutées ou retirées plus tôt dans la transaction (transfert, fusion)
+     * sont prises en compte: les requêtes ne voient pas les écritures de la transaction
+     * @private
+     * @returns {Promise<string|null>} Raison du refus, null si le plafond n'est pas atteint
+     */
+    async _getConcurrentEnrollmentsError(ctx, studentId) {
+        const { maxConcurrentActiveEnrollments } = await getSystemConfig(ctx);
+        if (!maxConcurrentActiveEnrollments) {
+            return null;
+        }
+
+        const queryString = JSON.stringify({
+            selector: {
+                docType: 'class',
+                enrolledStudents: { $elemMatch: { $eq: studentId } },
+            },
+        });
+
+        let classes;
+        try {
+            classes = await this._collectClasses(await ctx.stub.getQueryResult(queryString), () => true);
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            classes = await this._collectClasses(await ctx.stub.getStateByRange('', ''),
+                (record) => record.docType === 'class' && (record.enrolledStudents || []).includes(studentId));
+        }
+
+        const classIds = new Set(classes.map((classData) => classData.id));
+        const changes = (ctx.enrollmentChanges && ctx.enrollmentChanges.get(studentId)) || new Map();
+        for (const [classId, active] of changes) {
+            if (active) {
+                classIds.add(classId);
+            } else {
+                classIds.delete(classId);
+            }
+        }
+
+        if (classIds.size >= maxConcurrentActiveEnrollments) {
+            return `Student ${studentId} has reached the maximum of ${maxConcurrentActiveEnrollments} concurrent active enrollments (${Array.from(classIds).sort().join(', ')})`;
+        }
+        return null;
+    }
+
+    /**
+     * Mémorise une inscription active ajoutée (true) ou retirée (false) dans la transaction en cours
+     * @private
+     */
+    _trackEnrollmentChange(ctx, classId, studentId, active) {
+        ctx.enrollmentChanges = ctx.enrollmentChanges || new Map();
+        const changes = ctx.enrollmentChanges.get(studentId) || new Map();
+        changes.set(classId, active);
+        ctx.enrollmentChanges.set(studentId, changes);
+    }
+
+    /**
+     * Vérifie la période d'inscription de la classe (timestamp de la transaction)
+     * @private
+     * @returns {string|null} Raison du refus, null si la période est ouverte
+     */
+    _checkEnrollmentWindow(ctx, classData) {
+        const now = new Date(this._getTxTimestamp(ctx));
+
+        if (classData.enrollmentOpensAt && now < new Date(classData.enrollmentOpensAt)) {
+            return `Enrollment for class ${classData.id} opens on ${classData.enrollmentOpensAt}`;
+        }
+        if (classData.enrollmentClosesAt && now > new Date(classData.enrollmentClosesAt)) {
+            return `Enrollment for class ${classData.id} closed on ${classData.enrollmentClosesAt}`;
+        }
+        return null;
+    }
+
+    /**
+     * Mode de gestion de la capacité (les classes antérieures sont en "hard")
+     * @private
+     */
+    _getEnrollmentMode(classData) {
+        return classData.enrollmentMode || 'hard';
+    }
+
+    /**
+     * Inscrit un étudiant: liste des inscrits, compteur et enregistrement ENR_
+     * Tous les chemins d'inscription passent par cette fonction pour garder le compteur synchronisé
+     * et appliquer le plafond d'inscriptions simultanées de l'étudiant
+     * La classe modifiée doit être sauvegardée par l'appelant
+     * @private
+     * @throws {Error} Si l'étudiant a atteint le plafond d'inscriptions simultanées
+     */
+    async _addActiveEnrollment(ctx, classData, studentId, extraFields) {
+        await this._checkConcurrentEnrollments(ctx, studentId);
+        this._trackEnrollmentChange(ctx, classData.id, studentId, true);
+
+        const txTimestamp = this._getTxTimestamp(ctx);
+
+        classData.enrolledStudents.push(studentId);
+        classData.enrolledCount = this._getEnrolledCount(classData) + 1;
+        const seatType = (extraFields && extraFields.seatType) || DEFAULT_SEAT_TYPE;
+        if (seatType !== DEFAULT_SEAT_TYPE) {
+            classData.seatCounts = Object.assign({}, classData.seatCounts);
+            classData.seatCounts[seatType] = (classData.seatCounts[seatType] || 0) + 1;
+        }
+        // Un étudiant en liste d'attente qui obtient une place la quitte
+        if (classData.waitlist && classData.waitlist.includes(studentId)) {
+            classData.waitlist = classData.waitlist.filter((id) => id !== studentId);
+        }
+        // Un étudiant qui surveillait la classe (WatchClass) n'a plus à être notifié
+        if (classData.watchers && classData.watchers.includes(studentId)) {
+            classData.watchers = classData.watchers.filter((id) => id !== studentId);
+        }
+        // Sa réservation éventuelle est confirmée: elle ne compte plus à part
+        if (classData.holds && classData.holds.some((hold) => hold.studentId === studentId)) {
+            classData.holds = classData.holds.filter((hold) => hold.studentId !== studentId);
+        }
+        classData.updatedAt = txTimestamp;
+
+        const enrollment = {
+            docType: 'enrollment',
+            id: this._enrollmentKey(classData.id, studentId),
+            classId: classData.id,
+            studentId: studentId,
+            status: 'active',
+            seatType: DEFAULT_SEAT_TYPE,
+            enrolledBy: this._getCallerIdentity(ctx), // Identité ayant inscrit l'étudiant (admin, teacher ou l'étudiant lui-même)
+            enrolledAt: txTimestamp,
+            recordedAt: txTimestamp, // Écriture dans le ledger (enrolledAt peut être antidaté)
+            withdrawnAt: null,
+        };
+        Object.assign(enrollment, extraFields || {});
+
+        await this._putEnrollment(ctx, enrollment);
+        return enrollment;
+    }
+
+    /**
+     * Met à jour l'indicateur d'alerte de remplissage (seuil nearCapacityPercent de la configuration)
+     * L'indicateur est levé au franchissement du seuil et réarmé quand la classe repasse en dessous
+     * @private
+     * @returns {Promise<boolean>} true si le seuil vient d'être franchi
+     */
+    async _updateNearCapacityFlag(ctx, classData) {
+        const config = await getSystemConfig(ctx);
+        const threshold = classData.maxStudents * config.nearCapacityPercent / 100;
+        const aboveThreshold = this._getSeatCount(classData, DEFAULT_SEAT_TYPE) >= threshold;
+        const crossed = aboveThreshold && !classData.nearCapacityNotified;
+
+        classData.nearCapacityNotified = aboveThreshold;
+        return crossed;
+    }
+
+    /**
+     * Vérifie qu'une classe n'est pas archivée (fusionnée dans une autre section)
+     * @throws {Error} Si la classe est archivée
+     */
+    _checkNotArchived(classData) {
+        if (classData.archived) {
+            throw new Error(`Class ${classData.id} is archived (merged into ${classData.mergedInto})`);
+        }
+    }
+
+    /**
+     * Enregistrements rattachés à une classe (CLASS_RECORD_TYPES), avec leur clé
+     * Les enregistrements du contrat principal (legacy) n'ont pas tous de champ id
+     * @private
+     * @returns {Promise<Array>} [{ key, record }]
+     */
+    async _getClassRecords(ctx, classId) {
+        let iterator;
+        try {
+            iterator = await ctx.stub.getQueryResult(JSON.stringify({
+                selector: { docType: { $in: CLASS_RECORD_TYPES }, classId: classId },
+            }));
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            iterator = await ctx.stub.getStateByRange('', '');
+        }
+
+        const records = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if (CLASS_RECORD_TYPES.includes(record.docType) && record.classId === classId) {
+                    records.push({ key: result.value.key, record: record });
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        return records;
+    }
+
+    /**
+     * Taille maximale de la liste d'attente d'une classe
+     * Classes créées avant le champ maxWaitlist: defaultMaxWaitlist de la configuration
+     * @private
+     */
+    async _getMaxWaitlist(ctx, classData) {
+        if (classData.maxWaitlist !== undefined && classData.maxWaitlist !== null) {
+            return classData.maxWaitlist;
+        }
+        return (await getSystemConfig(ctx)).defaultMaxWaitlist;
+    }
+
+    /**
+     * Vérifie qu'une place est disponible en liste d'attente
+     * @throws {Error} Si la classe n'a pas de liste d'attente ou si elle est pleine
+     */
+    async _checkWaitlistCapacity(ctx, classData) {
+        const maxWaitlist = await this._getMaxWaitlist(ctx, classData);
+        if (maxWaitlist === 0) {
+            throw new Error(`Class ${classData.id} does not allow a waitlist (maxWaitlist is 0)`);
+        }
+
+        const waitlistCount = (classData.waitlist || []).length;
+        if (waitlistCount >= maxWaitlist) {
+            throw new Error(`Waitlist of class ${classData.id} is full (maxWaitlist: ${maxWaitlist})`);
+        }
+    }
+
+    /**
+     * Place un étudiant en liste d'attente: liste de la classe + enregistrement ENR_ "waitlisted"
+     * Refusé si la liste d'attente est pleine (maxWaitlist)
+     * La classe modifiée doit être sauvegardée par l'appelant
+     * @private
+     */
+    async _addWaitlistEntry(ctx, classData, studentId, extraFields) {
+        await this._checkWaitlistCapacity(ctx, classData);
+
+        const txTimestamp = this._getTxTimestamp(ctx);
+
+        classData.waitlist = (classData.waitlist || []).concat(studentId);
+        classData.updatedAt = txTimestamp;
+
+        const enrollment = {
+            docType: 'enrollment',
+            id: this._enrollmentKey(classData.id, studentId),
+            classId: classData.id,
+            studentId: studentId,
+            status: 'waitlisted',
+            waitlistedAt: txTimestamp,
+            enrolledAt: null,
+            withdrawnAt: null,
+        };
+        Object.assign(enrollment, extraFields || {});
+
+        await this._putEnrollment(ctx, enrollment);
+        return enrollment;
+    }
+
+    /**
+     * Désinscrit un étudiant: liste des inscrits, compteur et enregistrement ENR_
+     * Tous les chemins de désinscription passent par cette fonction pour garder le compteur synchronisé
+     * La classe modifiée doit être sauvegardée par l'appelant
+     * @private
+     */
+    async _removeActiveEnrollment(ctx, classData, studentId, status, extraFields) {
+        this._trackEnrollmentChange(ctx, classData.id, studentId, false);
+
+        const txTimestamp = this._getTxTimestamp(ctx);
+
+        // Les inscriptions antérieures aux enregistrements ENR_ n'en ont pas: on le crée
+        const existing = await this._getEnrollment(ctx, classData.id, studentId);
+
+        classData.enrolledStudents = classData.enrolledStudents.filter((id) => id !== studentId);
+        classData.enrolledCount = Math.max(this._getEnrolledCount(classData) - 1, 0);
+        const seatType = (existing && existing.seatType) || DEFAULT_SEAT_TYPE;
+        if (seatType !== DEFAULT_SEAT_TYPE && classData.seatCounts && classData.seatCounts[seatType]) {
+            classData.seatCounts = Object.assign({}, classData.seatCounts);
+            classData.seatCounts[seatType] -= 1;
+        }
+        classData.updatedAt = txTimestamp;
+        await this._updateNearCapacityFlag(ctx, classData);
+
+        const enrollment = existing || {
+            docType: 'enrollment',
+            id: this._enrollmentKey(classData.id, studentId),
+            classId: classData.id,
+            studentId: studentId,
+            enrolledAt: null,
+        };
+        enrollment.status = status;
+        enrollment.withdrawnAt = txTimestamp;
+        Object.assign(enrollment, extraFields || {});
+
+        await this._putEnrollment(ctx, enrollment);
+        return enrollment;
+    }
+
+    /**
+     * Liste d'attente dans l'ordre de promotion
+     *
+     * Ordre d'arrivée (waitlistedAt de l'enregistrement ENR_, chaîne vide pour les entrées
+     * antérieures, donc en tête). Deux entrées du même horodatage (même bloc, listes fusionnées
+     * par MergeClasses) sont départagées par studentId: l'ordre ne dépend que du contenu du
+     * ledger et reste identique sur tous les peers. Les entrées antérieures (sans horodatage)
+     * gardent leur ordre dans la liste, qui était leur ordre d'arrivée.
+     * @private
+     * @returns {Promise<Object[]>} [{ studentId, waitlistedAt }]
+     */
+    async _getOrderedWaitlist(ctx, classData) {
+        const entries = [];
+        for (const [index, studentId] of (classData.waitlist || []).entries()) {
+            const enrollment = await this._getEnrollment(ctx, classData.id, studentId);
+            entries.push({
+                index: index,
+                studentId: studentId,
+                waitlistedAt: (enrollment && enrollment.waitlistedAt) || '',
+            });
+        }
+        entries.sort((a, b) => a.waitlistedAt.localeCompare(b.waitlistedAt)
+            || (a.waitlistedAt ? a.studentId.localeCompare(b.studentId) : a.index - b.index));
+        return entries.map(({ studentId, waitlistedAt }) => ({ studentId: studentId, waitlistedAt: waitlistedAt }));
+    }
+
+    /**
+     * Attribue les places libres aux étudiants en liste d'attente (ordre de _getOrderedWaitlist)
+     * Les étudiants au plafond d'inscriptions simultanées sont passés (ils gardent leur rang)
+     * Chaque étudiant promu est notifié. La classe modifiée doit être sauvegardée par l'appelant
+     * @private
+     * @returns {Promise<string[]>} Étudiants promus
+     */
+    async _promoteWaitlist(ctx, classData) {
+        const promoted = [];
+
+        for (const { studentId } of await this._getOrderedWaitlist(ctx, classData)) {
+            if (!this._hasCapacity(classData, 1, studentId)) {
+                break;
+            }
+            // Plafond d'inscriptions simultanées atteint: l'étudiant reste en liste d'attente
+            if (await this._getConcurrentEnrollmentsError(ctx, studentId)) {
+                continue;
+            }
+
+            await this._addActiveEnrollment(ctx, classData, studentId, { promotedFromWaitlistAt: this._getTxTimestamp(ctx) });
+            await createNotification(ctx, studentId, 'WaitlistPromoted', {
+                classId: classData.id,
+                className: classData.name,
+            });
+            promoted.push(studentId);
+        }
+
+        return promoted;
+    }
+
+    /**
+     * Notifie les étudiants qui surveillent une classe pleine (WatchClass) quand une place "lecture" s'ouvre
+     * À appeler après _promoteWaitlist: la liste d'attente est prioritaire, les surveillants ne sont
+     * notifiés que si elle est vide. Les places réservées (HoldSeat) ne comptent pas comme ouvertes
+     * @private
+     * @param {boolean} wasFull - La classe était pleine avant la modification
+     * @returns {Promise<string[]>} Étudiants notifiés
+     */
+    async _notifySeatOpened(ctx, classData, wasFull) {
+        const watchers = classData.watchers || [];
+        if (!wasFull || watchers.length === 0 || (classData.waitlist || []).length > 0 || !this._hasCapacity(classData, 1, '')) {
+            return [];
+        }
+
+        for (const studentId of watchers) {
+            await createNotification(ctx, studentId, 'SeatOpened', {
+                classId: classData.id,
+                className: classData.name,
+            });
+        }
+
+        return watchers.slice();
+    }
+
+    /**
+     * Annule (sans les supprimer) les copies et notes non publiées d'un étudiant dans une classe
+     * Les notes publiées ne sont pas modifiées
+     * @private
+     * @returns {Promise<string[]>} IDs des enregistrements annulés
+     */
+    async _voidStudentWork(ctx, classId, studentId, voidedBy) {
+        const query = {
+            selector: {
+                docType: { $in: ['grade', 'submission'] },
+                classId: classId,
+                studentId: studentId,
+            },
+        };
+
+        let iterator;
+        try {
+            iterator = await ctx.stub.getQueryResult(JSON.stringify(query));
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            iterator = await ctx.stub.getStateByRange('', '');
+        }
+
+        const records = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if ((record.docType === 'grade' || record.docType === 'submission') &&
+                    record.classId === classId && record.studentId === studentId) {
+                    records.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        const txTimestamp = this._getTxTimestamp(ctx);
+        const voided = [];
+        for (const record of records) {
+            // Notes publiées ou figées (FinalizeGrade): dossier conservé
+            if (record.voided || (record.docType === 'grade' && (record.isPublished !== false || record.finalized))) {
+                continue;
+            }
+            record.voided = true;
+            record.voidedAt = txTimestamp;
+            record.voidedBy = voidedBy;
+            record.voidReason = 'withdrawal';
+            await ctx.stub.putState(record.id, serializeRecord(record));
+            voided.push(record.id);
+        }
+        return voided;
+    }
+
+    /**
+     * Récupère tous les enregistrements d'inscription d'une classe
+     * Les clés d'inscription sont hachées (enrollmentKey): sélection sur docType et classId
+     * @private
+     */
+    async _getClassEnrollments(ctx, classId) {
+        const allResults = [];
+        let iterator;
+        try {
+            iterator = await ctx.stub.getQueryResult(JSON.stringify({
+                selector: { docType: 'enrollment', classId: classId },
+            }));
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            iterator = await ctx.stub.getStateByRange('', '');
+        }
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            let record;
+
+            try {
+                record = JSON.parse(strValue);
+
+                if (record.docType === 'enrollment' && record.classId === classId) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
+    }
+
+    /**
+     * Ajouter des modules à une classe (bonus)
+     * Accessible uniquement par SchoolOrg
+     */
+    async AddModuleToClass(ctx, classId, moduleName) {
+        console.info('============= START : AddModuleToClass ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can add modules');
+        }
+
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${classId} does not exist`);
+        }
+
+        const classData = parseRecord(classAsBytes, classId, 'class');
+
+        if (classData.modules.includes(moduleName)) {
+            throw new Error(`Module ${moduleName} already exists in class ${classId}`);
+        }
+
+        classData.modules.push(moduleName);
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        console.info(`✅ Module ${moduleName} added to class ${classId}`);
+        console.info('============= END : AddModuleToClass ===========');
+
+        return JSON.stringify({ success: true, classId: classId, module: moduleName });
+    }
+
+    /**
+     * Définir le mode de capacité d'une classe
+     * Accessible uniquement par SchoolOrg
+     *
+     * - "hard": toute inscription au-delà de maxStudents est refusée (défaut)
+     * - "soft": la sur-inscription est acceptée, signalée et notifiée au teacher
+     */
+    async SetEnrollmentMode(ctx, classId, enrollmentMode) {
+        console.info('============= START : SetEnrollmentMode ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can change the enrollment mode');
+        }
+
+        if (enrollmentMode !== 'hard' && enrollmentMode !== 'soft') {
+            throw new Error('Invalid enrollmentMode: must be "hard" or "soft"');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        classData.enrollmentMode = enrollmentMode;
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        console.info(`✅ Enrollment mode of ${classId} set to ${enrollmentMode}`);
+        console.info('============= END : SetEnrollmentMode ===========');
+
+        return JSON.stringify({ success: true, classId: classId, enrollmentMode: enrollmentMode });
+    }
+
+    /**
+     * Définir la politique de retrait d'une classe
+     * Accessible uniquement par SchoolOrg
+     *
+     * - "keep": au retrait, copies et notes de l'étudiant sont conservées (défaut)
+     * - "void": au retrait, ses copies et notes non publiées sont marquées annulées
+     */
+    async SetWithdrawalPolicy(ctx, classId, withdrawalPolicy) {
+        console.info('============= START : SetWithdrawalPolicy ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can change the withdrawal policy');
+        }
+
+        if (withdrawalPolicy !== 'keep' && withdrawalPolicy !== 'void') {
+            throw new Error('Invalid withdrawalPolicy: must be "keep" or "void"');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        classData.withdrawalPolicy = withdrawalPolicy;
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        console.info(`✅ Withdrawal policy of ${classId} set to ${withdrawalPolicy}`);
+        console.info('============= END : SetWithdrawalPolicy ===========');
+
+        return JSON.stringify({ success: true, classId: classId, withdrawalPolicy: withdrawalPolicy });
+    }
+
+    /**
+     * Définir les prérequis d'une classe
+     * Accessible par: Teacher responsable de la classe ou admin
+     *
+     * Remplace la liste des prérequis. Refusé si l'ajout créerait un cycle
+     * (A requiert B, B requiert A): l'inscription deviendrait impossible.
+     * L'inscription exige un résultat figé "completed" (FinalizeClassOutcome) dans chaque prérequis.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} prerequisitesJSON - JSON array des IDs de classes requises (ex: '["CS101"]')
+     * @returns {string} JSON { classId, prerequisites }
+     */
+    async SetPrerequisites(ctx, classId, prerequisitesJSON) {
+        console.info('============= START : SetPrerequisites ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData, 'prerequisites');
+
+        let prerequisites;
+        try {
+            prerequisites = JSON.parse(prerequisitesJSON);
+        } catch (err) {
+            throw new Error('Invalid prerequisitesJSON: must be a JSON array of class IDs');
+        }
+        if (!Array.isArray(prerequisites) || prerequisites.some((id) => typeof id !== 'string' || !id.trim())) {
+            throw new Error('Invalid prerequisitesJSON: must be a JSON array of class IDs');
+        }
+        prerequisites = Array.from(new Set(prerequisites));
+
+        for (const prerequisiteId of prerequisites) {
+            if (prerequisiteId === classId) {
+                throw new Error(`Invalid prerequisite: class ${classId} cannot require itself`);
+            }
+            if (!(await this._classExists(ctx, prerequisiteId))) {
+                throw new Error(`Class ${prerequisiteId} does not exist`);
+            }
+        }
+
+        // Un cycle apparaît si un prérequis dépend déjà (directement ou non) de cette classe
+        const graph = await this._getPrerequisiteGraph(ctx);
+        graph.set(classId, prerequisites);
+        for (const prerequisiteId of prerequisites) {
+            const path = this._findPrerequisitePath(graph, prerequisiteId, classId);
+            if (path) {
+                throw new Error(`Prerequisite cycle: ${[classId].concat(path).join(' -> ')}`);
+            }
+        }
+
+        classData.prerequisites = prerequisites;
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        console.info(`✅ Prerequisites of ${classId} set to [${prerequisites.join(', ')}]`);
+        console.info('============= END : SetPrerequisites ===========');
+
+        return JSON.stringify({ success: true, classId: classId, prerequisites: prerequisites });
+    }
+
+    /**
+     * Détecter les cycles du graphe des prérequis
+     * Accessible uniquement par SchoolOrg
+     *
+     * Contrôle de cohérence sur toutes les classes (les cycles antérieurs à
+     * la vérification de SetPrerequisites restent possibles)
+     */
+    async DetectPrerequisiteCycles(ctx) {
+        console.info('============= START : DetectPrerequisiteCycles ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can inspect prerequisites');
+        }
+
+        const graph = await this._getPrerequisiteGraph(ctx);
+        const cycles = this._findPrerequisiteCycles(graph);
+
+        console.info(`✅ Prerequisite graph: ${graph.size} classes, ${cycles.length} cycles`);
+        console.info('============= END : DetectPrerequisiteCycles ===========');
+
+        return JSON.stringify({
+            classCount: graph.size,
+            hasCycles: cycles.length > 0,
+            cycles: cycles,
+        });
+    }
+
+    /**
+     * Exiger (ou non) une approbation avant la publication des notes d'une classe
+     * Accessible par: Admins uniquement
+     *
+     * true: les notes ne sont publiées que via RequestGradeRelease (teacher)
+     * puis ApproveGradeRelease (admin ou department-head distinct du demandeur)
+     */
+    async SetReleaseApprovalRequired(ctx, classId, required) {
+        console.info('============= START : SetReleaseApprovalRequired ===========');
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can change the grade release approval policy');
+        }
+
+        if (required !== 'true' && required !== 'false') {
+            throw new Error('Invalid required: must be "true" or "false"');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        classData.releaseApprovalRequired = required === 'true';
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        console.info(`✅ Release approval of ${classId} set to ${classData.releaseApprovalRequired}`);
+        console.info('============= END : SetReleaseApprovalRequired ===========');
+
+        return JSON.stringify({ success: true, classId: classId, releaseApprovalRequired: classData.releaseApprovalRequired });
+    }
+
+    /**
+     * Définir le délai de saisie des notes d'une classe
+     * Accessible par: Teacher responsable de la classe ou admin
+     *
+     * Échéance de saisie: examDate + gradingWindowDays (0 = sans échéance). Au-delà:
+     * - strict (défaut): la saisie des notes d'un examen est close (réouverture ponctuelle: ReopenGrading)
+     * - non strict ("false"): la saisie reste acceptée, la note est marquée en retard
+     */
+    async SetGradingWindow(ctx, classId, gradingWindowDays, strict) {
+        console.info('============= START : SetGradingWindow ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData, 'grading window');
+
+        const days = Number(gradingWindowDays);
+        if (gradingWindowDays === '' || !Number.isInteger(days) || days < 0) {
+            throw new Error('Invalid gradingWindowDays: must be a non-negative integer (0 = no deadline)');
+        }
+        if (strict !== undefined && strict !== '' && strict !== 'true' && strict !== 'false') {
+            throw new Error('Invalid strict: must be "true" or "false"');
+        }
+
+        classData.gradingWindowDays = days;
+        if (strict === 'true' || strict === 'false') {
+            classData.strictGradingDeadline = strict === 'true';
+        }
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        const strictDeadline = classData.strictGradingDeadline !== false;
+        console.info(`✅ Grading window of ${classId} set to ${days} days (${strictDeadline ? 'strict' : 'lenient'})`);
+        console.info('============= END : SetGradingWindow ===========');
+
+        return JSON.stringify({
+            success: true,
+            classId: classId,
+            gradingWindowDays: days,
+            strictGradingDeadline: strictDeadline,
+        });
+    }
+
+    /**
+     * Définir le barème des mentions d'une classe (pourcentage -> lettre et points GPA)
+     * Accessible par: Teacher responsable de la classe ou admin
+     *
+     * scaleJSON: [{ minPercent, letter, points }], un seuil à 0 obligatoire (toute note a une mention);
+     * "" ou "null" rétablit le barème par défaut. Les mentions déjà enregistrées sur les notes
+     * ne sont pas modifiées: les recalculer avec RecomputeLetterGrades.
+     */
+    async SetGradingScale(ctx, classId, scaleJSON) {
+        console.info('============= START : SetGradingScale ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData, 'grading scale');
+
+        const scale = !scaleJSON || scaleJSON === 'null' ? null : this._parseGradingScale(scaleJSON);
+
+        classData.gradingScale = scale;
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        ctx.stub.setEvent('GradingScaleChanged', Buffer.from(JSON.stringify({
+            classId: classId,
+            gradingScale: scale,
+            changedBy: this._getCallerIdentity(ctx),
+        })));
+
+        console.info(`✅ Grading scale of ${classId} set to ${scale ? scale.map((row) => `${row.letter}>=${row.minPercent}`).join(', ') : 'default'}`);
+        console.info('============= END : SetGradingScale ===========');
+
+        return JSON.stringify({ success: true, classId: classId, gradingScale: scale });
+    }
+
+    /**
+     * Définir la capacité d'un pool de places (ex: places de TP "lab")
+     *
+     * Accessible par: Teacher responsable de la classe ou admin
+     *
+     * "lecture" correspond à maxStudents (entre 1 et maxStudentsLimit, comme PatchClass); les autres
+     * types créent ou modifient leur pool (0 = illimitée). La capacité ne peut pas descendre sous les inscriptions actives du pool.
+     * Les places "lecture" ajoutées sont attribuées à la liste d'attente (ordre d'arrivée).
+     */
+    async SetSeatCapacity(ctx, classId, seatType, capacity) {
+        console.info('============= START : SetSeatCapacity ===========');
+
+        const classData = await this._getClass(ctx, classId);
+        await this._checkClassOwner(ctx, classData, 'seat capacities');
+
+        if (!SEAT_TYPES.includes(seatType)) {
+            throw new Error(`Invalid seatType: ${seatType} (supported: ${SEAT_TYPES.join(', ')})`);
+        }
+
+        const capacityNum = Number(capacity);
+        if (seatType === DEFAULT_SEAT_TYPE) {
+            await this._checkMaxStudents(ctx, capacity === '' ? NaN : capacityNum);
+        } else if (capacity === '' || !Number.isInteger(capacityNum) || capacityNum < 0) {
+            throw new Error('Invalid capacity: must be a positive integer (0 = unlimited)');
+        }
+
+        const seatCount = this._getSeatCount(classData, seatType);
+        if (capacityNum > 0 && capacityNum < seatCount) {
+            throw new Error(`Invalid capacity: ${capacityNum} is below the ${seatCount} active ${seatType} enrollments of class ${classId}`);
+        }
+
+        const wasFull = !this._hasCapacity(classData, 1, '');
+        if (seatType === DEFAULT_SEAT_TYPE) {
+            classData.maxStudents = capacityNum;
+        } else {
+            classData.seatCapacities = Object.assign({}, classData.seatCapacities, { [seatType]: capacityNum });
+            classData.seatCounts = Object.assign({ [seatType]: 0 }, classData.seatCounts);
+        }
+        classData.updatedAt = this._getTxTimestamp(ctx);
+        const promoted = await this._promoteWaitlist(ctx, classData);
+        const notifiedWatchers = await this._notifySeatOpened(ctx, classData, wasFull);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        if (promoted.length > 0 || notifiedWatchers.length > 0) {
+            ctx.stub.setEvent('SeatOpened', Buffer.from(JSON.stringify({
+                classId: classId,
+                seatType: seatType,
+                capacity: capacityNum,
+                promoted: promoted,
+                notifiedWatchers: notifiedWatchers,
+            })));
+        }
+
+        console.info(`✅ ${seatType} capacity of ${classId} set to ${capacityNum || 'unlimited'}`);
+        console.info('============= END : SetSeatCapacity ===========');
+
+        return JSON.stringify({
+            success: true,
+            classId: classId,
+            seatType: seatType,
+            capacity: capacityNum,
+            enrolledCount: this._getSeatCount(classData, seatType),
+            promoted: promoted,
+            notifiedWatchers: notifiedWatchers,
+        });
+    }
+
+    /**
+     * Définir le quota de stockage des supports d'une classe
+     * Accessible uniquement par SchoolOrg
+     * Taille totale déclarée des supports en octets, 0 = illimité
+     */
+    async SetStorageQuota(ctx, classId, maxTotalBytes) {
+        console.info('============= START : SetStorageQuota ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can set storage quotas');
+        }
+
+        const maxTotalBytesNum = Number(maxTotalBytes);
+        if (maxTotalBytes === '' || !Number.isInteger(maxTotalBytesNum) || maxTotalBytesNum < 0) {
+            throw new Error('Invalid maxTotalBytes: must be a positive integer (0 = unlimited)');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        classData.maxTotalBytes = maxTotalBytesNum;
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        console.info(`✅ Storage quota of ${classId} set to ${maxTotalBytesNum} bytes`);
+        console.info('============= END : SetStorageQuota ===========');
+
+        return JSON.stringify({ success: true, classId: classId, maxTotalBytes: maxTotalBytesNum });
+    }
+
+    /**
+     * Définir la période d'inscription d'une classe
+     * Accessible uniquement par SchoolOrg
+     * Dates ISO 8601 avec fuseau (stockées en UTC), chaîne vide = pas de limite
+     */
+    async SetEnrollmentWindow(ctx, classId, opensAt, closesAt) {
+        console.info('============= START : SetEnrollmentWindow ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can set the enrollment window');
+        }
+
+        // Dates stockées en UTC, décalage d'origine conservé pour l'affichage
+        const opens = opensAt ? normalizeDate(opensAt, 'opensAt') : null;
+        const closes = closesAt ? normalizeDate(closesAt, 'closesAt') : null;
+        if (opens && closes && new Date(opens.utc) >= new Date(closes.utc)) {
+            throw new Error('Invalid enrollment window: opensAt must be before closesAt');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        classData.enrollmentOpensAt = opens ? opens.utc : null;
+        classData.enrollmentOpensAtOffset = opens ? opens.offset : null;
+        classData.enrollmentClosesAt = closes ? closes.utc : null;
+        classData.enrollmentClosesAtOffset = closes ? closes.offset : null;
+        classData.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(classId, serializeRecord(classData));
+
+        console.info(`✅ Enrollment window of ${classId} set`);
+        console.info('============= END : SetEnrollmentWindow ===========');
+
+        return JSON.stringify({
+            success: true,
+            classId: classId,
+            enrollmentOpensAt: classData.enrollmentOpensAt,
+            enrollmentClosesAt: classData.enrollmentClosesAt,
+        });
+    }
+
+    /**
+     * Obtenir les étudiants inscrits à une classe
+     * Accessible par SchoolOrg uniquement
+     */
+    async GetEnrolledStudents(ctx, classId) {
+        console.info('============= START : GetEnrolledStudents ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view enrolled students');
+        }
+
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${classId} does not exist`);
+        }
+
+        const classData = parseRecord(classAsBytes, classId, 'class');
+
+        console.info(`✅ Retrieved ${classData.enrolledStudents.length} enrolled students`);
+        console.info('============= END : GetEnrolledStudents ===========');
 
         return JSON.stringify({
             classId: classId,
@@ -377,6 +3778,683 @@ class ClassContract extends Contract {
             count: classData.enrolledStudents.length,
         });
     }
+
+    /**
+     * Exporter la liste de classe (roster)
+     * Accessible par SchoolOrg uniquement
+     *
+     * Une ligne par inscription (active, en attente ou terminée), avec le responsable
+     * (sponsorId) le cas échéant et l'identité ayant inscrit l'étudiant (enrolledBy, null avant
+     * son enregistrement). Les inscrits antérieurs aux enregistrements ENR_ sont inclus.
+     */
+    async ExportClassRoster(ctx, classId) {
+        console.info('============= START : ExportClassRoster ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can export class rosters');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const enrollments = await this._getClassEnrollments(ctx, classId);
+
+        const roster = enrollments.map((enrollment) => ({
+            studentId: enrollment.studentId,
+            status: enrollment.status,
+            enrolledAt: enrollment.enrolledAt || null,
+            enrolledBy: enrollment.enrolledBy || null,
+            withdrawnAt: enrollment.withdrawnAt || null,
+            sponsorId: enrollment.sponsorId || null,
+        }));
+        for (const studentId of classData.enrolledStudents) {
+            if (!roster.some((row) => row.studentId === studentId)) {
+                roster.push({ studentId: studentId, status: 'active', enrolledAt: null, enrolledBy: null, withdrawnAt: null, sponsorId: null });
+            }
+        }
+        roster.sort((a, b) => a.studentId.localeCompare(b.studentId));
+
+        console.info(`✅ Roster of ${classId} exported: ${roster.length} rows`);
+        console.info('============= END : ExportClassRoster ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            className: classData.name,
+            semester: classData.semester || null,
+            roster: roster,
+        });
+    }
+
+    /**
+     * Obtenir les changements de la liste de classe depuis une date (synchronisation SIS)
+     * Accessible par SchoolOrg uniquement
+     *
+     * Inscriptions (date d'enregistrement, une inscription antidatée est donc incluse)
+     * et retraits / transferts postérieurs à sinceTimestamp, du plus ancien au plus récent.
+     * asOf (timestamp de la transaction) sert de sinceTimestamp à la synchronisation suivante.
+     */
+    async GetRosterChangesSince(ctx, classId, sinceTimestamp) {
+        console.info('============= START : GetRosterChangesSince ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can export class rosters');
+        }
+
+        const since = normalizeDate(sinceTimestamp, 'sinceTimestamp').utc;
+        await this._getClass(ctx, classId);
+        const enrollments = await this._getClassEnrollments(ctx, classId);
+
+        const changes = [];
+        for (const enrollment of enrollments) {
+            const enrolledAt = enrollment.recordedAt || enrollment.enrolledAt;
+            if (enrolledAt && new Date(enrolledAt) > new Date(since)) {
+                changes.push({
+                    studentId: enrollment.studentId,
+                    change: 'enrolled',
+                    at: enrolledAt,
+                    enrolledAt: enrollment.enrolledAt,
+                    enrolledBy: enrollment.enrolledBy || null,
+                    sponsorId: enrollment.sponsorId || null,
+                });
+            }
+            if (enrollment.withdrawnAt && new Date(enrollment.withdrawnAt) > new Date(since)) {
+                changes.push({
+                    studentId: enrollment.studentId,
+                    change: enrollment.status, // withdrawn ou transferred
+                    at: enrollment.withdrawnAt,
+                    enrolledAt: enrollment.enrolledAt || null,
+                    enrolledBy: enrollment.enrolledBy || null,
+                    sponsorId: enrollment.sponsorId || null,
+                });
+            }
+        }
+        changes.sort((a, b) => a.at.localeCompare(b.at) || a.studentId.localeCompare(b.studentId));
+
+        console.info(`✅ ${changes.length} roster changes of ${classId} since ${since}`);
+        console.info('============= END : GetRosterChangesSince ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            since: since,
+            asOf: this._getTxTimestamp(ctx),
+            changes: changes,
+        });
+    }
+
+    /**
+     * Enregistrer le recensement officiel des inscrits d'une classe à une date
+     * Accessible par les admins uniquement
+     *
+     * Inscrits actifs à censusDate d'après les enregistrements ENR_ (date d'effet enrolledAt,
+     * retrait éventuel postérieur); les inscrits antérieurs aux enregistrements, sans date,
+     * sont comptés s'ils sont toujours inscrits. Date future refusée.
+     * Le recensement est immuable (CENSUS_<classId>_<censusDate>): un seul par classe et par date.
+     */
+    async TakeEnrollmentCensus(ctx, classId, censusDate) {
+        console.info('============= START : TakeEnrollmentCensus ===========');
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can take an enrollment census');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const date = normalizeDate(censusDate, 'censusDate').utc;
+        const takenAt = this._getTxTimestamp(ctx);
+        if (new Date(date) > new Date(takenAt)) {
+            throw new Error(`Invalid censusDate: ${date} is in the future (transaction time ${takenAt})`);
+        }
+
+        const key = this._censusKey(classId, date);
+        const existing = await ctx.stub.getState(key);
+        if (existing && existing.length > 0) {
+            throw new Error(`Enrollment census of class ${classId} at ${date} already exists and cannot be replaced`);
+        }
+
+        const enrollments = await this._getClassEnrollments(ctx, classId);
+        const students = [];
+        for (const enrollment of enrollments) {
+            // Liste d'attente: jamais inscrit; enrolledAt null: inscription antérieure aux enregistrements
+            if (!enrollment.enrolledAt || new Date(enrollment.enrolledAt) > new Date(date)) {
+                continue;
+            }
+            if (enrollment.withdrawnAt && new Date(enrollment.withdrawnAt) <= new Date(date)) {
+                continue;
+            }
+            students.push({
+                studentId: enrollment.studentId,
+                enrolledAt: enrollment.enrolledAt,
+                seatType: enrollment.seatType || DEFAULT_SEAT_TYPE,
+            });
+        }
+
+        // Inscriptions antérieures aux enregistrements ENR_: seule la liste fait foi
+        const recorded = new Set(enrollments.map((enrollment) => enrollment.studentId));
+        for (const studentId of classData.enrolledStudents) {
+            if (!recorded.has(studentId)) {
+                students.push({ studentId: studentId, enrolledAt: null, seatType: DEFAULT_SEAT_TYPE });
+            }
+        }
+        students.sort((a, b) => a.studentId.localeCompare(b.studentId));
+
+        const caller = this._getCallerIdentity(ctx);
+        const census = {
+            docType: 'enrollmentCensus',
+            id: key,
+            classId: classId,
+            className: classData.name,
+            semester: classData.semester || null,
+            censusDate: date,
+            count: students.length,
+            students: students,
+            takenBy: caller,
+            takenAt: takenAt,
+        };
+
+        await ctx.stub.putState(key, serializeRecord(census));
+
+        ctx.stub.setEvent('EnrollmentCensusTaken', Buffer.from(JSON.stringify({
+            classId: classId,
+            censusDate: date,
+            count: students.length,
+            takenBy: caller,
+        })));
+
+        console.info(`✅ Enrollment census of ${classId} at ${date}: ${students.length} students (by ${caller})`);
+        console.info('============= END : TakeEnrollmentCensus ===========');
+
+        return JSON.stringify(census);
+    }
+
+    /**
+     * Obtenir le recensement officiel des inscrits d'une classe à une date
+     * Accessible par SchoolOrg uniquement
+     */
+    async GetEnrollmentCensus(ctx, classId, censusDate) {
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view enrollment censuses');
+        }
+
+        const date = normalizeDate(censusDate, 'censusDate').utc;
+        const key = this._censusKey(classId, date);
+        const censusAsBytes = await ctx.stub.getState(key);
+        if (!censusAsBytes || censusAsBytes.length === 0) {
+            throw new Error(`No enrollment census of class ${classId} at ${date}`);
+        }
+
+        return JSON.stringify(parseRecord(censusAsBytes, key, 'enrollmentCensus'));
+    }
+
+    /**
+     * Purger les données personnelles expirées (politique de conservation)
+     * Accessible par les admins uniquement
+     *
+     * Supprime les copies remises avant beforeDate et les inscriptions retirées (withdrawn)
+     * avant beforeDate. Une copie reste conservée tant qu'elle fonde une note:
+     * non corrigée (FinalizeExamNoShows la compterait absente) ou pénalisée pour retard
+     * (la pénalité est recalculée depuis la copie); les copies annulées sont purgées.
+     * Notes, résultats de classe, certificats, relevés signés et recensements ne sont jamais supprimés.
+     * Le statut "withdrawn" d'une inscription purgée est d'abord figé dans un résultat de classe
+     * (outcomeKey) s'il n'en existe pas: GetStudentClassStatus reste exact.
+     * Date future refusée; le récapitulatif est enregistré dans le journal d'audit.
+     */
+    async PurgeExpiredData(ctx, beforeDate) {
+        console.info('============= START : PurgeExpiredData ===========');
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can purge expired data');
+        }
+
+        const date = normalizeDate(beforeDate, 'beforeDate').utc;
+        const purgedAt = this._getTxTimestamp(ctx);
+        if (new Date(date) > new Date(purgedAt)) {
+            throw new Error(`Invalid beforeDate: ${date} is in the future (transaction time ${purgedAt})`);
+        }
+
+        const purgeTypes = ['submission', 'enrollment', 'grade'];
+        let iterator;
+        try {
+            iterator = await ctx.stub.getQueryResult(JSON.stringify({
+                selector: { docType: { $in: purgeTypes } },
+            }));
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            iterator = await ctx.stub.getStateByRange('', '');
+        }
+
+        const records = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if (purgeTypes.includes(record.docType)) {
+                    records.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        // Copies corrigées: une note non annulée existe pour l'examen et l'étudiant
+        const graded = new Set(records
+            .filter((record) => record.docType === 'grade' && !record.voided)
+            .map((grade) => JSON.stringify([grade.examId, grade.studentId])));
+
+        const purgedSubmissions = [];
+        const purgedEnrollments = [];
+        const finalizedOutcomes = [];
+        const retainedSubmissions = [];
+        const exams = new Map();
+        const caller = this._getCallerIdentity(ctx);
+        const { passPercent } = await getSystemConfig(ctx);
+
+        for (const record of records) {
+            if (record.docType === 'enrollment') {
+                if (record.status === 'withdrawn' && record.withdrawnAt && new Date(record.withdrawnAt) < new Date(date)) {
+                    // Le retrait n'est connu que par l'inscription: le figer avant de la supprimer
+                    const key = outcomeKey(record.classId, record.studentId);
+                    if (!(await getOutcomeRecord(ctx, record.classId, record.studentId))) {
+                        const classAsBytes = await ctx.stub.getState(record.classId);
+                        const classData = classAsBytes && classAsBytes.length > 0 ? parseRecord(classAsBytes, record.classId, 'class') : null;
+                        await ctx.stub.putState(key, serializeRecord({
+                            docType: 'classOutcome',
+                            id: key,
+                            classId: record.classId,
+                            semester: (classData && classData.semester) || null,
+                            studentId: record.studentId,
+                            status: 'withdrawn',
+                            percentage: null,
+                            letterGrade: null,
+                            passPercent: passPercent,
+                            finalizedBy: caller,
+                            finalizedAt: purgedAt,
+                        }));
+                        finalizedOutcomes.push(key);
+                    }
+                    await ctx.stub.deleteState(record.id);
+                    purgedEnrollments.push(record.id);
+                }
+                continue;
+            }
+            if (record.docType !== 'submission' || new Date(record.submittedAt) >= new Date(date)) {
+                continue;
+            }
+
+            if (!record.voided) {
+                if (!graded.has(JSON.stringify([record.examId, record.studentId]))) {
+                    retainedSubmissions.push({ id: record.id, reason: 'ungraded' });
+                    continue;
+                }
+                if (!exams.has(record.examId)) {
+                    const examAsBytes = await ctx.stub.getState(record.examId);
+                    exams.set(record.examId, examAsBytes && examAsBytes.length > 0
+                        ? parseRecord(examAsBytes, record.examId, 'exam')
+                        : null);
+                }
+                const exam = exams.get(record.examId);
+                if (exam && computeLatePenalty(exam, record).penalty > 0) {
+                    retainedSubmissions.push({ id: record.id, reason: 'late-penalty' });
+                    continue;
+                }
+            }
+
+            await ctx.stub.deleteState(record.id);
+            purgedSubmissions.push(record.id);
+        }
+
+        purgedSubmissions.sort();
+        purgedEnrollments.sort();
+        finalizedOutcomes.sort();
+        retainedSubmissions.sort((a, b) => a.id.localeCompare(b.id));
+
+        const audit = await writeAuditEntry(ctx, 'ExpiredDataPurged', 'DATA_RETENTION', `Retention purge of records before ${date}`, {
+            beforeDate: date,
+            purgedSubmissions: purgedSubmissions,
+            purgedEnrollments: purgedEnrollments,
+            finalizedOutcomes: finalizedOutcomes,
+            retainedSubmissions: retainedSubmissions,
+        });
+
+        ctx.stub.setEvent('ExpiredDataPurged', Buffer.from(JSON.stringify({
+            beforeDate: date,
+            purgedSubmissionCount: purgedSubmissions.length,
+            purgedEnrollmentCount: purgedEnrollments.length,
+            purgedBy: caller,
+        })));
+
+        console.info(`✅ Purge before ${date} by ${caller}: ${purgedSubmissions.length} submissions, ${purgedEnrollments.length} enrollments`);
+        console.info('============= END : PurgeExpiredData ===========');
+
+        return JSON.stringify({
+            beforeDate: date,
+            purgedSubmissions: purgedSubmissions,
+            purgedEnrollments: purgedEnrollments,
+            finalizedOutcomes: finalizedOutcomes,
+            retainedSubmissions: retainedSubmissions,
+            auditId: audit.id,
+            purgedBy: caller,
+            purgedAt: purgedAt,
+        });
+    }
+
+    /**
+     * Obtenir le nombre de places restantes d'une classe
+     * Accessible par: Tous les participants authentifiés
+     *
+     * Appel léger destiné au polling: une seule lecture (la classe), compteur utilisé s'il existe
+     * remainingSeats: maxStudents - inscriptions actives - places réservées (0 si sur-inscrite)
+     * seatPools: remainingSeats null pour un pool autre que "lecture" sans limite
+     */
+    async GetRemainingSeats(ctx, classId) {
+        if (!this._isAuthenticated(ctx)) {
+            throw new Error('Access Denied: You must be authenticated to view remaining seats');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const maxStudents = classData.maxStudents;
+        const enrolledCount = this._getEnrolledCount(classData);
+        const heldSeats = this._getHeldSeats(classData);
+        const lectureCount = this._getSeatCount(classData, DEFAULT_SEAT_TYPE);
+
+        // Places restantes par pool ("lecture" inclut les réservations)
+        const seatPools = {};
+        for (const seatType of [DEFAULT_SEAT_TYPE].concat(Object.keys(classData.seatCapacities || {}))) {
+            const capacity = this._getSeatCapacity(classData, seatType);
+            const taken = this._getSeatCount(classData, seatType) + (seatType === DEFAULT_SEAT_TYPE ? heldSeats : 0);
+            seatPools[seatType] = {
+                capacity: capacity,
+                enrolledCount: this._getSeatCount(classData, seatType),
+                remainingSeats: seatType !== DEFAULT_SEAT_TYPE && capacity === 0 ? null : Math.max(capacity - taken, 0),
+            };
+        }
+
+        return JSON.stringify({
+            classId: classId,
+            maxStudents: maxStudents,
+            enrolledCount: enrolledCount,
+            heldSeats: heldSeats,
+            remainingSeats: Math.max(maxStudents - lectureCount - heldSeats, 0),
+            seatPools: seatPools,
+        });
+    }
+
+    /**
+     * Obtenir la position d'un étudiant en liste d'attente
+     *
+     * Accessible par:
+     * - SchoolOrg (teachers/admin) - N'importe quel étudiant
+     * - L'étudiant lui-même
+     *
+     * Position à partir de 1, ordre de promotion (waitlistedAt, puis studentId à horodatage égal).
+     * Un étudiant déjà inscrit obtient status "active" sans position.
+     *
+     * @returns {string} JSON { classId, studentId, status, position, waitlistCount, waitlistedAt }
+     * @throws {Error} Si l'étudiant n'est ni inscrit ni en liste d'attente
+     */
+    async GetWaitlistPosition(ctx, classId, studentId) {
+        const caller = this._getCallerIdentity(ctx);
+        if (!this._isSchoolMember(ctx) && !(this._isStudentMember(ctx) && caller === studentId)) {
+            throw new Error(`Access Denied: Students can only view their own waitlist position. You are ${caller}, requested ${studentId}`);
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const waitlist = classData.waitlist || [];
+
+        if (classData.enrolledStudents.includes(studentId)) {
+            return JSON.stringify({
+                classId: classId,
+                studentId: studentId,
+                status: 'active',
+                position: null,
+                waitlistCount: waitlist.length,
+                waitlistedAt: null,
+            });
+        }
+        if (!waitlist.includes(studentId)) {
+            throw new Error(`Student ${studentId} is not on the waitlist of class ${classId}`);
+        }
+
+        const entries = await this._getOrderedWaitlist(ctx, classData);
+        const position = entries.findIndex((entry) => entry.studentId === studentId) + 1;
+
+        return JSON.stringify({
+            classId: classId,
+            studentId: studentId,
+            status: 'waitlisted',
+            position: position,
+            waitlistCount: waitlist.length,
+            waitlistedAt: entries[position - 1].waitlistedAt || null,
+        });
+    }
+
+    /**
+     * Obtenir le résumé d'une classe (tableaux de bord)
+     * Accessible par: Tous les participants authentifiés
+     *
+     * Vérifie le compteur d'inscriptions en recomptant les inscriptions actives:
+     * un écart est signalé (counterDrift). Réparation uniquement si repair="true"
+     * (SchoolOrg), tracée dans le journal d'audit; sans repair, aucune écriture.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - Identifiant de la classe
+     * @param {string} [repair] - "true" pour corriger un compteur divergent
+     * @returns {string} JSON du résumé
+     */
+    async GetClassSummary(ctx, classId, repair) {
+        console.info('============= START : GetClassSummary ===========');
+
+        if (!this._isAuthenticated(ctx)) {
+            throw new Error('Access Denied: You must be authenticated to view a class summary');
+        }
+        if (repair === 'true' && !(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can repair enrollment counters');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        const stored = classData.enrolledCount;
+        const actual = await this._countActiveEnrollments(ctx, classData);
+        const drifted = stored !== actual;
+        let repaired = false;
+
+        if (drifted && repair === 'true') {
+            classData.enrolledCount = actual;
+            classData.updatedAt = this._getTxTimestamp(ctx);
+            await ctx.stub.putState(classId, serializeRecord(classData));
+
+            await writeAuditEntry(ctx, 'EnrollmentCounterRepaired', classId, 'Enrollment counter drift detected on read', {
+                previous: stored === undefined ? null : stored,
+                recomputed: actual,
+            });
+
+            ctx.stub.setEvent('EnrollmentCounterRecomputed', Buffer.from(JSON.stringify({
+                classId: classId,
+                previous: stored,
+                recomputed: actual,
+            })));
+            repaired = true;
+        } else if (drifted) {
+            console.warn(`⚠️ Enrollment counter drift in ${classId}: stored ${stored}, actual ${actual}`);
+        }
+
+        const maxStudents = classData.maxStudents;
+        const heldSeats = this._getHeldSeats(classData);
+
+        console.info(`✅ Summary of ${classId}: ${actual} active enrollments${repaired ? ' (counter repaired)' : ''}`);
+        console.info('============= END : GetClassSummary ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            name: classData.name,
+            semester: classData.semester || null,
+            teacher: this._getClassTeacher(classData),
+            maxStudents: maxStudents,
+            enrolledCount: actual,
+            heldSeats: heldSeats,
+            waitlistCount: (classData.waitlist || []).length,
+            remainingSeats: Math.max(maxStudents - actual - heldSeats, 0),
+            counterDrift: drifted ? { stored: stored === undefined ? null : stored, actual: actual } : null,
+            repaired: repaired,
+        });
+    }
+
+    /**
+     * Recalculer le compteur d'inscriptions actives d'une classe
+     * Accessible par SchoolOrg uniquement
+     *
+     * Réparation: recompte les inscriptions actives (enregistrements ENR_ actifs,
+     * plus les inscrits antérieurs aux enregistrements) et corrige le compteur
+     */
+    async RecomputeEnrollmentCounter(ctx, classId) {
+        console.info('============= START : RecomputeEnrollmentCounter ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can recompute enrollment counters');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+
+        const previous = classData.enrolledCount;
+        const recomputed = await this._countActiveEnrollments(ctx, classData);
+
+        if (previous !== recomputed) {
+            classData.enrolledCount = recomputed;
+            classData.updatedAt = this._getTxTimestamp(ctx);
+            await ctx.stub.putState(classId, serializeRecord(classData));
+
+            ctx.stub.setEvent('EnrollmentCounterRecomputed', Buffer.from(JSON.stringify({
+                classId: classId,
+                previous: previous,
+                recomputed: recomputed,
+            })));
+        }
+
+        console.info(`✅ Enrollment counter for ${classId}: ${previous} -> ${recomputed}`);
+        console.info('============= END : RecomputeEnrollmentCounter ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            previous: previous === undefined ? null : previous,
+            recomputed: recomputed,
+            corrected: previous !== recomputed,
+        });
+    }
+
+    /**
+     * Étiqueter une inscription (cohorte, filière...) pour les rapports agrégés
+     * Accessible par SchoolOrg uniquement
+     *
+     * Remplace les étiquettes de l'inscription active: objet { catégorie: valeur }
+     * (ex: '{"cohort":"2026","program":"CS"}'), {} pour les retirer
+     */
+    async SetEnrollmentTags(ctx, classId, studentId, tagsJSON) {
+        console.info('============= START : SetEnrollmentTags ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members can tag enrollments');
+        }
+
+        let tags;
+        try {
+            tags = JSON.parse(tagsJSON);
+        } catch (err) {
+            throw new Error('Invalid tagsJSON: must be a JSON object of tag categories to values');
+        }
+        if (!tags || typeof tags !== 'object' || Array.isArray(tags)) {
+            throw new Error('Invalid tagsJSON: must be a JSON object of tag categories to values');
+        }
+        const categories = Object.keys(tags);
+        if (categories.length > MAX_ENROLLMENT_TAGS) {
+            throw new Error(`Invalid tagsJSON: at most ${MAX_ENROLLMENT_TAGS} tags per enrollment`);
+        }
+        for (const category of categories) {
+            if (!category.trim() || typeof tags[category] !== 'string' || !tags[category].trim() || tags[category] === UNTAGGED) {
+                throw new Error(`Invalid tag ${category}: categories and values must be non-empty strings`);
+            }
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        if (!classData.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is not enrolled in class ${classId}`);
+        }
+
+        // Les inscriptions antérieures aux enregistrements ENR_ n'en ont pas: on le crée
+        const enrollment = await this._getEnrollment(ctx, classId, studentId) || {
+            docType: 'enrollment',
+            id: this._enrollmentKey(classId, studentId),
+            classId: classId,
+            studentId: studentId,
+            status: 'active',
+            enrolledAt: null,
+            withdrawnAt: null,
+        };
+        enrollment.tags = tags;
+
+        await this._putEnrollment(ctx, enrollment);
+
+        console.info(`✅ Enrollment ${enrollment.id} tagged with ${categories.join(', ') || 'no tags'}`);
+        console.info('============= END : SetEnrollmentTags ===========');
+
+        return JSON.stringify({ success: true, classId: classId, studentId: studentId, tags: tags });
+    }
+
+    /**
+     * Répartition des inscriptions actives d'une classe par étiquette
+     * Accessible par SchoolOrg uniquement (teachers/admin), lecture seule
+     *
+     * Rapport d'équité: uniquement des effectifs agrégés, jamais d'identités.
+     * Pour chaque catégorie, les inscrits sans valeur sont comptés dans "untagged".
+     */
+    async GetEnrollmentBreakdown(ctx, classId) {
+        console.info('============= START : GetEnrollmentBreakdown ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view enrollment breakdowns');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const enrollments = await this._getClassEnrollments(ctx, classId);
+
+        // Étiquettes des inscriptions actives (inscrits sans ENR_: aucune étiquette)
+        const tagsByStudent = new Map();
+        for (const enrollment of enrollments) {
+            if (enrollment.status === 'active') {
+                tagsByStudent.set(enrollment.studentId, enrollment.tags || {});
+            }
+        }
+        for (const studentId of classData.enrolledStudents) {
+            if (!tagsByStudent.has(studentId)) {
+                tagsByStudent.set(studentId, {});
+            }
+        }
+
+        const activeTags = Array.from(tagsByStudent.values());
+        const categories = Array.from(new Set(activeTags.flatMap((tags) => Object.keys(tags)))).sort();
+
+        const breakdown = {};
+        for (const category of categories) {
+            breakdown[category] = {};
+            for (const tags of activeTags) {
+                const value = tags[category] || UNTAGGED;
+                breakdown[category][value] = (breakdown[category][value] || 0) + 1;
+            }
+        }
+
+        console.info(`✅ Enrollment breakdown of ${classId}: ${activeTags.length} active, ${categories.length} categories`);
+        console.info('============= END : GetEnrollmentBreakdown ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            activeCount: activeTags.length,
+            breakdown: breakdown,
+        });
+    }
 }
 
 module.exports = ClassContract;
+module.exports.enrollmentKey = enrollmentKey;
+module.exports.outcomeKey = outcomeKey;
+module.exports.getEnrollmentRecord = getEnrollmentRecord;
+module.exports.getOutcomeRecord = getOutcomeRecord;
+module.exports.getCreditHours = getCreditHours;
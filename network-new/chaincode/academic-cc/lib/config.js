@@ -0,0 +1,159 @@
+/*
+ * System Configuration Smart Contract
+ *
+ * Paramètres globaux du chaincode (délais, limites...)
+ * stockés sous une clé unique dans le ledger.
+ *
+ * Contrôle d'accès:
+ * - Modification: admins uniquement
+ * - Consultation: Tous les participants authentifiés
+ */
+
+'use strict';
+
+const { Contract } = require('fabric-contract-api');
+const { serializeRecord } = require('./records');
+const { getCallerRole } = require('./role');
+
+const CONFIG_KEY = 'SYSTEM_CONFIG';
+
+// Valeurs par défaut appliquées quand une clé n'a jamais été configurée
+const DEFAULT_CONFIG = {
+    appealWindowDays: 14, // Délai pour contester une note après publication
+    appealEscalationDays: 7, // Délai pour escalader une contestation rejetée (après la décision du teacher)
+    nearCapacityPercent: 90, // Seuil d'alerte de remplissage d'une classe (% de maxStudents)
+    passPercent: 50, // Seuil de réussite d'une classe (% de la note finale, 10/20)
+    defaultMaxStudents: 30, // Capacité appliquée par CreateClass sans maxStudents (entre 1 et maxStudentsLimit)
+    maxStudentsLimit: 500, // Capacité maximale autorisée pour une classe
+    maxConcurrentActiveEnrollments: 0, // Inscriptions actives simultanées d'un étudiant, tous semestres (0 = illimité)
+    defaultMaxWaitlist: 10, // Taille de liste d'attente appliquée par CreateClass (0 = pas de liste d'attente)
+    gradeReleaseDelayHours: 0, // Embargo de publication des notes après examDate, en heures (0 = pas d'embargo)
+};
+
+/**
+ * Lit la configuration du ledger et la fusionne avec les valeurs par défaut
+ *
+ * @param {Context} ctx - Le contexte de transaction
+ * @returns {Promise<Object>} Configuration effective
+ */
+async function getSystemConfig(ctx) {
+    const configAsBytes = await ctx.stub.getState(CONFIG_KEY);
+    if (!configAsBytes || configAsBytes.length === 0) {
+        return Object.assign({}, DEFAULT_CONFIG);
+    }
+
+    const stored = JSON.parse(configAsBytes.toString());
+    return Object.assign({}, DEFAULT_CONFIG, stored.values || {});
+}
+
+class ConfigContract extends Contract {
+
+    // ==================== CONTRÔLES D'ACCÈS ====================
+
+    /**
+     * Récupère l'ID de l'utilisateur appelant
+     * Format: x509::/CN=User1@school.academic.edu/...
+     */
+    _getCallerIdentity(ctx) {
+        const userID = ctx.clientIdentity.getID();
+        // Extraire le CN (Common Name) de l'identité X.509
+        const match = userID.match(/CN=([^,/]+)/);
+        return match ? match[1] : userID;
+    }
+
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
+    // ==================== FONCTIONS MÉTIER ====================
+
+    /**
+     * Mettre à jour la configuration système
+     *
+     * Accessible par: Admins uniquement
+     * Seules les clés connues sont acceptées, les autres restent inchangées
+     * Capacités de classe: entiers, defaultMaxStudents entre 1 et maxStudentsLimit
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} configJSON - Objet JSON des clés à modifier (ex: '{"appealWindowDays":7}')
+     * @returns {string} JSON de la configuration effective
+     */
+    async SetSystemConfig(ctx, configJSON) {
+        console.info('============= START : SetSystemConfig ===========');
+
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can update the system configuration');
+        }
+
+        let updates;
+        try {
+            updates = JSON.parse(configJSON);
+        } catch (err) {
+            throw new Error('Invalid configJSON: must be a JSON object');
+        }
+        if (!updates || typeof updates !== 'object' || Array.isArray(updates)) {
+            throw new Error('Invalid configJSON: must be a JSON object');
+        }
+
+        for (const [key, value] of Object.entries(updates)) {
+            if (!(key in DEFAULT_CONFIG)) {
+                throw new Error(`Invalid config key: ${key}`);
+            }
+            if (typeof value !== 'number' || !Number.isFinite(value) || value < 0) {
+                throw new Error(`Invalid value for ${key}: must be a non-negative number`);
+            }
+        }
+
+        const configAsBytes = await ctx.stub.getState(CONFIG_KEY);
+        const stored = configAsBytes && configAsBytes.length > 0
+            ? JSON.parse(configAsBytes.toString())
+            : { docType: 'config', id: CONFIG_KEY, values: {} };
+
+        stored.values = Object.assign({}, stored.values, updates);
+
+        if ('defaultMaxStudents' in updates || 'maxStudentsLimit' in updates) {
+            const { defaultMaxStudents, maxStudentsLimit } = Object.assign({}, DEFAULT_CONFIG, stored.values);
+            if (!Number.isInteger(maxStudentsLimit) || maxStudentsLimit <= 0) {
+                throw new Error('Invalid value for maxStudentsLimit: must be a positive integer');
+            }
+            if (!Number.isInteger(defaultMaxStudents) || defaultMaxStudents <= 0) {
+                throw new Error('Invalid value for defaultMaxStudents: must be a positive integer');
+            }
+            if (defaultMaxStudents > maxStudentsLimit) {
+                throw new Error(`Invalid value for defaultMaxStudents: ${defaultMaxStudents} exceeds maxStudentsLimit (${maxStudentsLimit})`);
+            }
+        }
+        stored.updatedBy = this._getCallerIdentity(ctx);
+        stored.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(CONFIG_KEY, serializeRecord(stored));
+
+        ctx.stub.setEvent('SystemConfigUpdated', Buffer.from(JSON.stringify({
+            keys: Object.keys(updates),
+            updatedBy: stored.updatedBy,
+        })));
+
+        console.info(`✅ System config updated by ${stored.updatedBy}`);
+        console.info('============= END : SetSystemConfig ===========');
+
+        return JSON.stringify(Object.assign({}, DEFAULT_CONFIG, stored.values));
+    }
+
+    /**
+     * Obtenir la configuration système effective (valeurs par défaut incluses)
+     * Accessible par: Tous les participants authentifiés
+     */
+    async GetSystemConfig(ctx) {
+        const config = await getSystemConfig(ctx);
+        return JSON.stringify(config);
+    }
+}
+
+module.exports = ConfigContract;
+module.exports.getSystemConfig = getSystemConfig;
+module.exports.DEFAULT_CONFIG = DEFAULT_CONFIG;
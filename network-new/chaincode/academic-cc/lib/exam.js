@@ -4,13 +4,178 @@
  * Contrôle d'accès:
  * - Création/Upload: SchoolMSP uniquement (teachers)
  * - Accès aux examens: Étudiants inscrits + Teachers
- * - Accès aux corrections: 24h après examDate + Enrollment
+ * - Accès aux corrections: 48h après examDate (CORRECTION_DELAY_HOURS) + Enrollment
+ *   (assistants "ta" de la classe: même délai que les étudiants, questions visibles)
+ * - Remise des copies: Étudiants inscrits, pénalité par heure de retard
  * - Stockage IPFS off-chain, hash stocké on-chain
  */
 
 'use strict';
 
 const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord, MAX_QUERY_RESULTS } = require('./records');
+const { normalizeDate } = require('./time');
+const { getCallerRole, isTeachingStaff } = require('./role');
+const { generateDeterministicID } = require('./ids');
+
+const MINUTE_MS = 60 * 1000;
+const HOUR_MS = 60 * MINUTE_MS;
+
+// Note maximale par défaut (barème français sur 20)
+const DEFAULT_MAX_SCORE = 20;
+
+// Tolérance sur les sommes de points (grille d'évaluation)
+const POINTS_EPSILON = 1e-6;
+
+// Modes de notation: points (sur maxScore), percentage (sur 100), passfail (réussite/échec, sans score)
+const GRADING_SCHEMES = ['points', 'percentage', 'passfail'];
+const DEFAULT_GRADING_SCHEME = 'points';
+const PERCENTAGE_MAX_SCORE = 100;
+
+// Aménagement d'épreuve: multiplicateur maximal de la durée pour un étudiant (tiers-temps: 1.33)
+const MAX_TIME_MULTIPLIER = 3;
+
+// Délai de mise à disposition de la correction après examDate (étudiants et assistants "ta")
+const CORRECTION_DELAY_HOURS = 48;
+
+/**
+ * Note maximale d'un examen: dénominateur commun à toutes ses notes
+ */
+function getExamMaxScore(exam) {
+    return exam.maxScore || DEFAULT_MAX_SCORE;
+}
+
+/**
+ * Mode de notation d'un examen (examens antérieurs au champ: "points")
+ */
+function getGradingScheme(exam) {
+    return exam.gradingScheme || DEFAULT_GRADING_SCHEME;
+}
+
+// Empreinte du contenu d'une copie: SHA-256 hexadécimal fourni à la remise
+const CONTENT_HASH_PATTERN = /^[0-9a-f]{64}$/;
+
+/**
+ * Empreinte verrouillée d'une copie et son algorithme
+ * Sans empreinte SHA-256 fournie (ou remise antérieure au champ), le hash IPFS fait foi:
+ * il est lui-même dérivé du contenu
+ */
+function getLockedContentHash(submission) {
+    if (submission.contentHash) {
+        return { hash: submission.contentHash, algorithm: submission.contentHashAlgorithm || 'sha256' };
+    }
+    return { hash: submission.answerFileHash, algorithm: 'ipfs-cid' };
+}
+
+/**
+ * Préfixe des incidents signalés pendant un examen
+ */
+function incidentPrefix(examId) {
+    return `INCIDENT_${examId}_`;
+}
+
+/**
+ * Clé du reçu de remise d'une copie (dérivée de l'examen et de l'étudiant)
+ */
+function submissionKey(examId, studentId) {
+    return generateDeterministicID('SUB', examId, studentId);
+}
+
+/**
+ * Ancienne clé du reçu (SUB_<examId>_<studentId>), relue pour les remises existantes
+ */
+function legacySubmissionKey(examId, studentId) {
+    return `SUB_${examId}_${studentId}`;
+}
+
+/**
+ * Récupère le reçu de remise d'une copie (null si absent)
+ * Clé déterministe en priorité, sinon ancienne clé
+ */
+async function getSubmissionRecord(ctx, examId, studentId) {
+    for (const key of [submissionKey(examId, studentId), legacySubmissionKey(examId, studentId)]) {
+        const submissionAsBytes = await ctx.stub.getState(key);
+        if (submissionAsBytes && submissionAsBytes.length > 0) {
+            return parseRecord(submissionAsBytes, key, 'submission');
+        }
+    }
+    return null;
+}
+
+/**
+ * Multiplicateur de durée accordé à un étudiant (SetExamAccommodation), 1 sans aménagement
+ */
+function getTimeMultiplier(exam, studentId) {
+    const accommodation = studentId ? (exam.accommodations || {})[studentId] : null;
+    return accommodation ? accommodation.timeMultiplier : 1;
+}
+
+/**
+ * Échéance de remise des copies (examDate + durationMinutes × multiplicateur de l'étudiant)
+ * null si l'examen n'a pas de durée définie: aucune copie n'est alors en retard
+ * Sans studentId: échéance commune (sans aménagement)
+ */
+function getSubmissionDeadline(exam, studentId) {
+    if (!exam.durationMinutes) {
+        return null;
+    }
+    const durationMs = Math.round(exam.durationMinutes * getTimeMultiplier(exam, studentId) * MINUTE_MS);
+    return new Date(new Date(exam.examDate).getTime() + durationMs);
+}
+
+/**
+ * Fin de la remise des copies (échéance + gracePeriodMinutes), null sans durée définie
+ */
+function getSubmissionClosesAt(exam, studentId) {
+    const deadline = getSubmissionDeadline(exam, studentId);
+    if (!deadline) {
+        return null;
+    }
+    return new Date(deadline.getTime() + (exam.gracePeriodMinutes || 0) * MINUTE_MS);
+}
+
+/**
+ * Révélation des questions: questionsAvailableAt si défini, sinon examDate
+ */
+function getQuestionsAvailableAt(exam) {
+    return new Date(exam.questionsAvailableAt || exam.examDate);
+}
+
+/**
+ * Mise à disposition de la correction: examDate + CORRECTION_DELAY_HOURS
+ */
+function getCorrectionAvailableAt(exam) {
+    return new Date(new Date(exam.examDate).getTime() + CORRECTION_DELAY_HOURS * HOUR_MS);
+}
+
+/**
+ * Calcule le retard d'une copie et la pénalité en points associée
+ *
+ * L'heure de remise provient toujours du reçu stocké (submission.submittedAt),
+ * l'échéance de l'étudiant tient compte de son aménagement d'épreuve
+ *
+ * @param {Object} exam - Examen (durationMinutes, latePenaltyPerHour)
+ * @param {Object} submission - Reçu de remise (peut être null)
+ * @returns {Object} { hoursLate, lateBySeconds, penalty }
+ */
+function computeLatePenalty(exam, submission) {
+    const deadline = submission ? getSubmissionDeadline(exam, submission.studentId) : null;
+    if (!submission || !deadline) {
+        return { hoursLate: 0, lateBySeconds: 0, penalty: 0 };
+    }
+
+    const lateMs = new Date(submission.submittedAt).getTime() - deadline.getTime();
+    if (lateMs <= 0) {
+        return { hoursLate: 0, lateBySeconds: 0, penalty: 0 };
+    }
+
+    const hoursLate = lateMs / HOUR_MS;
+    return {
+        hoursLate: Math.round(hoursLate * 100) / 100,
+        lateBySeconds: Math.ceil(lateMs / 1000),
+        penalty: Math.round(hoursLate * (exam.latePenaltyPerHour || 0) * 100) / 100,
+    };
+}
 
 class ExamContract extends Contract {
 
@@ -43,6 +208,147 @@ class ExamContract extends Contract {
         return match ? match[1] : userID;
     }
 
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
+    /**
+     * Vérifie si l'examen a commencé (examDate atteinte au timestamp de la transaction)
+     * Une fois commencé, les questions et la date sont verrouillées
+     */
+    _hasExamStarted(ctx, exam) {
+        return new Date(this._getTxTimestamp(ctx)) >= new Date(exam.examDate);
+    }
+
+    /**
+     * Valide une grille d'évaluation: critères { id, label, maxPoints } aux IDs uniques,
+     * dont la somme des points vaut la note maximale de l'examen
+     * @throws {Error} Si la grille est invalide
+     */
+    _parseRubric(rubric, maxScore) {
+        if (!Array.isArray(rubric) || rubric.length === 0) {
+            throw new Error('Invalid rubric: must be a non-empty array of criteria { id, label, maxPoints }');
+        }
+
+        const ids = new Set();
+        const criteria = rubric.map((criterion) => {
+            if (!criterion || typeof criterion.id !== 'string' || !criterion.id.trim()) {
+                throw new Error('Invalid rubric: each criterion needs a non-empty id');
+            }
+            if (ids.has(criterion.id)) {
+                throw new Error(`Invalid rubric: duplicate criterion ${criterion.id}`);
+            }
+            ids.add(criterion.id);
+            if (typeof criterion.maxPoints !== 'number' || !Number.isFinite(criterion.maxPoints) || criterion.maxPoints <= 0) {
+                throw new Error(`Invalid rubric: maxPoints of criterion ${criterion.id} must be a strictly positive number`);
+            }
+            return { id: criterion.id, label: criterion.label || criterion.id, maxPoints: criterion.maxPoints };
+        });
+
+        const total = criteria.reduce((sum, criterion) => sum + criterion.maxPoints, 0);
+        if (Math.abs(total - maxScore) > POINTS_EPSILON) {
+            throw new Error(`Invalid rubric: criteria total ${total} points, expected the exam maxScore ${maxScore}`);
+        }
+        return criteria;
+    }
+
+    /**
+     * Vérifie que les questions sont révélées au plus tard à l'échéance de remise
+     * @throws {Error} Si questionsAvailableAt est postérieur à l'échéance
+     */
+    _checkQuestionsAvailableAt(exam) {
+        const deadline = getSubmissionDeadline(exam);
+        if (exam.questionsAvailableAt && deadline && new Date(exam.questionsAvailableAt) > deadline) {
+            throw new Error(`Invalid questionsAvailableAt: ${exam.questionsAvailableAt} is after the submission deadline ${deadline.toISOString()}`);
+        }
+    }
+
+    /**
+     * Valide et convertit un coefficient d'examen (part de la note finale)
+     * @throws {Error} Si le coefficient n'est pas dans ]0, 1]
+     */
+    _parseWeight(weight) {
+        const weightNum = Number(weight);
+        if (weight === null || weight === '' || !Number.isFinite(weightNum) || weightNum <= 0 || weightNum > 1) {
+            throw new Error(`Invalid weight: ${weight} must be greater than 0 and at most 1`);
+        }
+        return weightNum;
+    }
+
+    /**
+     * Avertissement si les coefficients des examens de la classe dépassent 1 au total
+     * Non bloquant: les coefficients peuvent être rééquilibrés ensuite
+     * @private
+     * @returns {Promise<string|null>} Message d'avertissement, null si le total reste au plus 1
+     */
+    async _getWeightWarning(ctx, classId, examId, weight) {
+        if (weight === null) {
+            return null;
+        }
+
+        let exams;
+        try {
+            exams = await this._collectQuery(ctx, JSON.stringify({ selector: { docType: 'exam', classId: classId } }));
+        } catch (err) {
+            // Fallback si CouchDB non disponible
+            console.warn('CouchDB query failed, using fallback method:', err);
+            exams = await this._collectByRange(ctx, (record) => record.docType === 'exam' && record.classId === classId);
+        }
+
+        const total = exams
+            .filter((exam) => exam.id !== examId)
+            .reduce((sum, exam) => sum + (exam.weight || 0), weight);
+        if (total <= 1 + POINTS_EPSILON) {
+            return null;
+        }
+
+        const warning = `Exam weights of class ${classId} total ${Math.round(total * 1000) / 1000}, above 1`;
+        console.warn(`⚠️ ${warning}`);
+        return warning;
+    }
+
+    /**
+     * Valide le mode de notation (absent: "points")
+     */
+    _parseGradingScheme(gradingScheme) {
+        if (gradingScheme === undefined || gradingScheme === '') {
+            return DEFAULT_GRADING_SCHEME;
+        }
+        if (!GRADING_SCHEMES.includes(gradingScheme)) {
+            throw new Error(`Invalid gradingScheme: ${gradingScheme} (supported: ${GRADING_SCHEMES.join(', ')})`);
+        }
+        return gradingScheme;
+    }
+
+    /**
+     * Vérifie qu'une note maximale est compatible avec le mode de notation
+     * percentage: toujours 100, passfail: aucune note maximale
+     */
+    _checkSchemeMaxScore(gradingScheme, maxScoreNum) {
+        if (gradingScheme === 'passfail') {
+            throw new Error('Invalid maxScore: pass/fail exams have no score');
+        }
+        if (gradingScheme === 'percentage' && maxScoreNum !== PERCENTAGE_MAX_SCORE) {
+            throw new Error(`Invalid maxScore: percentage exams are graded out of ${PERCENTAGE_MAX_SCORE}`);
+        }
+    }
+
+    /**
+     * Valide la note maximale d'un examen (nombre strictement positif)
+     */
+    _parseMaxScore(maxScore) {
+        const maxScoreNum = parseFloat(maxScore);
+        if (isNaN(maxScoreNum) || maxScoreNum <= 0) {
+            throw new Error('Invalid maxScore: must be a strictly positive number');
+        }
+        return maxScoreNum;
+    }
+
     /**
      * Vérifie si l'appelant a accès à une classe
      * - Teachers (SchoolMSP) : accès à tout
@@ -74,12 +380,7 @@ class ExamContract extends Contract {
                 throw new Error(`Class ${classId} does not exist`);
             }
 
-            const classData = JSON.parse(classAsBytes.toString());
-
-            // Vérifier que c'est bien une classe
-            if (classData.docType !== 'class') {
-                throw new Error(`${classId} is not a valid class`);
-            }
+            const classData = parseRecord(classAsBytes, classId, 'class');
 
             // Vérifier si l'étudiant est inscrit
             if (!classData.enrolledStudents.includes(caller)) {
@@ -108,13 +409,18 @@ class ExamContract extends Contract {
      * @param {string} title - Titre de l'examen
      * @param {string} examDate - Date de l'examen (ISO 8601: "2024-02-01T10:00:00Z")
      * @param {string} examFileHash - Hash IPFS du fichier d'examen
+     * @param {string} [weight] - Coefficient de l'examen dans la note finale, dans ]0, 1] (optionnel)
+     *                            Un total de classe au-delà de 1 est signalé (weightWarning de ExamCreated)
+     * @param {string} [maxScore] - Note maximale, commune à toutes les notes de l'examen (20 par défaut)
+     * @param {string} [gradingScheme] - Mode de notation: "points" (défaut), "percentage" (sur 100)
+     *                                   ou "passfail" (réussite/échec, sans coefficient ni note maximale)
      * @returns {string} examId
      */
-    async CreateExam(ctx, examId, classId, moduleId, title, examDate, examFileHash) {
+    async CreateExam(ctx, examId, classId, moduleId, title, examDate, examFileHash, weight, maxScore, gradingScheme) {
         console.info('============= START : CreateExam ===========');
 
         // CONTRÔLE D'ACCÈS: Seulement SchoolOrg peut créer des examens
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members (teachers) can create exams');
         }
 
@@ -124,10 +430,7 @@ class ExamContract extends Contract {
             throw new Error(`Class ${classId} does not exist`);
         }
 
-        const classData = JSON.parse(classAsBytes.toString());
-        if (classData.docType !== 'class') {
-            throw new Error(`${classId} is not a valid class`);
-        }
+        const classData = parseRecord(classAsBytes, classId, 'class');
 
         // Vérifier que l'examen n'existe pas déjà
         const exists = await ctx.stub.getState(examId);
@@ -135,10 +438,25 @@ class ExamContract extends Contract {
             throw new Error(`Exam ${examId} already exists`);
         }
 
-        // Valider le format de la date
-        const examDateTime = new Date(examDate);
-        if (isNaN(examDateTime.getTime())) {
-            throw new Error('Invalid examDate format. Use ISO 8601 format (e.g., "2024-02-01T10:00:00Z")');
+        // Valider le format de la date (stockée en UTC, décalage d'origine conservé)
+        const normalizedDate = normalizeDate(examDate, 'examDate');
+
+        const scheme = this._parseGradingScheme(gradingScheme);
+        const hasWeight = weight !== undefined && weight !== '';
+        if (hasWeight && scheme === 'passfail') {
+            throw new Error('Invalid weight: pass/fail exams do not count in the final grade');
+        }
+        const weightNum = hasWeight ? this._parseWeight(weight) : null;
+        const weightWarning = await this._getWeightWarning(ctx, classId, examId, weightNum);
+
+        // Note maximale: imposée par le mode de notation (100 en pourcentage, aucune en réussite/échec)
+        let maxScoreNum = scheme === 'percentage' ? PERCENTAGE_MAX_SCORE : DEFAULT_MAX_SCORE;
+        if (maxScore !== undefined && maxScore !== '') {
+            maxScoreNum = this._parseMaxScore(maxScore);
+            this._checkSchemeMaxScore(scheme, maxScoreNum);
+        }
+        if (scheme === 'passfail') {
+            maxScoreNum = null;
         }
 
         // Récupérer l'identité du créateur
@@ -151,24 +469,36 @@ class ExamContract extends Contract {
             classId: classId,
             moduleId: moduleId,
             title: title,
-            examDate: examDate,
+            examDate: normalizedDate.utc,
+            examDateOffset: normalizedDate.offset, // Décalage horaire saisi (affichage)
             examFileHash: examFileHash,
+            questionsAvailableAt: null, // Révélation des questions aux étudiants, null = examDate
+            weight: weightNum,
+            gradingScheme: scheme, // points, percentage ou passfail (notes sans score, hors moyennes)
+            maxScore: maxScoreNum, // Dénominateur unique des notes de l'examen (null en réussite/échec)
+            rubric: null, // Grille d'évaluation: [{ id, label, maxPoints }], somme = maxScore
+            durationMinutes: null, // Durée de l'épreuve: échéance de remise = examDate + durée
+            gracePeriodMinutes: 0, // Retard toléré après l'échéance
+            latePenaltyPerHour: 0, // Points retirés par heure de retard
+            proctors: [], // Surveillants assignés
+            allowedMaterials: [], // Supports autorisés pendant l'épreuve (IDs de supports de la classe)
             correctionFileHash: null, // Sera uploadé plus tard
             correctionUploadedAt: null,
             createdBy: createdBy,
-            createdAt: new Date().toISOString(),
+            createdAt: this._getTxTimestamp(ctx),
         };
 
         // Stocker dans le ledger
-        await ctx.stub.putState(examId, Buffer.from(JSON.stringify(exam)));
+        await ctx.stub.putState(examId, serializeRecord(exam));
 
         // Émettre un événement
         ctx.stub.setEvent('ExamCreated', Buffer.from(JSON.stringify({
             examId: examId,
             classId: classId,
             title: title,
-            examDate: examDate,
+            examDate: exam.examDate,
             createdBy: createdBy,
+            weightWarning: weightWarning,
         })));
 
         console.info(`✅ Exam created: ${examId} by ${createdBy} for class ${classId}`);
@@ -192,7 +522,7 @@ class ExamContract extends Contract {
         console.info('============= START : UploadCorrection ===========');
 
         // CONTRÔLE D'ACCÈS: Seulement SchoolOrg peut uploader des corrections
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members (teachers) can upload corrections');
         }
 
@@ -202,15 +532,10 @@ class ExamContract extends Contract {
             throw new Error(`Exam ${examId} does not exist`);
         }
 
-        const exam = JSON.parse(examAsBytes.toString());
-
-        // Vérifier que c'est bien un examen
-        if (exam.docType !== 'exam') {
-            throw new Error(`${examId} is not an exam`);
-        }
+        const exam = parseRecord(examAsBytes, examId, 'exam');
 
-        // RÈGLE TEMPORELLE: Ne peut uploader qu'APRÈS examDate
-        const now = new Date();
+        // RÈGLE TEMPORELLE: Ne peut uploader qu'APRÈS examDate (timestamp de la transaction)
+        const now = new Date(this._getTxTimestamp(ctx));
         const examDate = new Date(exam.examDate);
 
         if (now < examDate) {
@@ -220,10 +545,10 @@ class ExamContract extends Contract {
 
         // Mettre à jour la correction
         exam.correctionFileHash = correctionFileHash;
-        exam.correctionUploadedAt = new Date().toISOString();
+        exam.correctionUploadedAt = this._getTxTimestamp(ctx);
 
         // Sauvegarder
-        await ctx.stub.putState(examId, Buffer.from(JSON.stringify(exam)));
+        await ctx.stub.putState(examId, serializeRecord(exam));
 
         const uploadedBy = this._getCallerIdentity(ctx);
 
@@ -248,14 +573,15 @@ class ExamContract extends Contract {
     /**
      * 3. Obtenir tous les examens d'une classe
      *
-     * Calcule si la correction est disponible (24h après examDate + fichier uploadé)
+     * Calcule si la correction est disponible (CORRECTION_DELAY_HOURS après examDate + fichier uploadé)
      * Masque correctionFileHash si non disponible
      *
      * Accessible par: Étudiants inscrits + Teachers
+     * Au plus MAX_QUERY_RESULTS examens: au-delà, truncated=true
      *
      * @param {Context} ctx - Le contexte de transaction
      * @param {string} classId - ID de la classe
-     * @returns {string} JSON array des examens
+     * @returns {string} JSON { exams, count, truncated }
      */
     async GetExams(ctx, classId) {
         console.info('============= START : GetExams ===========');
@@ -264,8 +590,10 @@ class ExamContract extends Contract {
         await this._checkEnrollment(ctx, classId);
 
         const allResults = [];
-        const now = new Date();
-        const isTeacher = this._isSchoolMember(ctx);
+        let truncated = false;
+        const now = new Date(this._getTxTimestamp(ctx));
+        // Les assistants (ta) voient les examens comme les étudiants pour les corrections
+        const isTeacher = this._isSchoolMember(ctx) && !(await this._isTeachingAssistant(ctx, classId));
 
         // Récupérer tous les états du ledger
         const iterator = await ctx.stub.getStateByRange('', '');
@@ -280,8 +608,11 @@ class ExamContract extends Contract {
 
                 // Filtrer les examens de cette classe uniquement
                 if (record.docType === 'exam' && record.classId === classId) {
-                    const examDate = new Date(record.examDate);
-                    const correctionAvailableAt = new Date(examDate.getTime() + 48 * 60 * 60 * 1000); // +48h
+                    if (allResults.length === MAX_QUERY_RESULTS) {
+                        truncated = true;
+                        break;
+                    }
+                    const correctionAvailableAt = getCorrectionAvailableAt(record);
 
                     // Calculer si la correction est disponible
                     const correctionAvailable = now >= correctionAvailableAt && record.correctionFileHash !== null;
@@ -302,7 +633,7 @@ class ExamContract extends Contract {
                         examData.correctionUploadedAt = record.correctionUploadedAt;
                         examData.correctionAvailable = correctionAvailable;
                     } else {
-                        // Si étudiant : masquer selon règle des 24h
+                        // Si étudiant : masquer selon délai de mise à disposition
                         if (correctionAvailable) {
                             examData.correctionAvailable = true;
                             examData.correctionUploadedAt = record.correctionUploadedAt;
@@ -317,6 +648,14 @@ class ExamContract extends Contract {
                             }
                         }
                         // Ne jamais exposer correctionFileHash aux étudiants ici
+
+                        // Échéance de l'étudiant appelant (aménagement d'épreuve compris)
+                        if (this._isStudentMember(ctx)) {
+                            const deadline = getSubmissionDeadline(record, this._getCallerIdentity(ctx));
+                            const closesAt = getSubmissionClosesAt(record, this._getCallerIdentity(ctx));
+                            examData.submissionDeadline = deadline ? deadline.toISOString() : null;
+                            examData.submissionClosesAt = closesAt ? closesAt.toISOString() : null;
+                        }
                     }
 
                     allResults.push(examData);
@@ -331,16 +670,22 @@ class ExamContract extends Contract {
         await iterator.close();
 
         const caller = this._getCallerIdentity(ctx);
+        if (truncated) {
+            console.warn(`GetExams truncated after ${allResults.length} exams for class ${classId}`);
+        }
         console.info(`✅ Retrieved ${allResults.length} exams for class ${classId} by ${caller}`);
         console.info('============= END : GetExams ===========');
 
-        return JSON.stringify(allResults);
+        return JSON.stringify({ exams: allResults, count: allResults.length, truncated: truncated });
     }
 
     /**
      * 4. Obtenir le hash IPFS d'un examen pour téléchargement
      *
      * Vérifie l'enrollment avant de retourner le hash
+     * RÈGLE TEMPORELLE: les étudiants ne reçoivent le hash des questions qu'à partir
+     * de questionsAvailableAt (examDate par défaut), indépendamment de la date d'examen
+     * Les supports autorisés (formulaires...) sont toujours indiqués
      *
      * Accessible par: Étudiants inscrits + Teachers
      *
@@ -357,18 +702,28 @@ class ExamContract extends Contract {
             throw new Error(`Exam ${examId} does not exist`);
         }
 
-        const exam = JSON.parse(examAsBytes.toString());
-
-        // Vérifier que c'est bien un examen
-        if (exam.docType !== 'exam') {
-            throw new Error(`${examId} is not an exam`);
-        }
+        const exam = parseRecord(examAsBytes, examId, 'exam');
 
         // CONTRÔLE D'ACCÈS: Vérifier l'enrollment dans la classe de l'examen
         await this._checkEnrollment(ctx, exam.classId);
 
+        // Questions masquées aux étudiants avant leur révélation
+        const questionsAvailableAt = getQuestionsAvailableAt(exam);
+        const questionsAvailable = this._isSchoolMember(ctx) ||
+            new Date(this._getTxTimestamp(ctx)) >= questionsAvailableAt;
+
+        // Supports autorisés (ceux supprimés depuis sont ignorés)
+        const allowedMaterials = [];
+        for (const materialId of exam.allowedMaterials || []) {
+            const materialAsBytes = await ctx.stub.getState(materialId);
+            if (materialAsBytes && materialAsBytes.length > 0) {
+                const material = parseRecord(materialAsBytes, materialId, 'material');
+                allowedMaterials.push({ id: material.id, title: material.title, type: material.type });
+            }
+        }
+
         const caller = this._getCallerIdentity(ctx);
-        console.info(`✅ Exam file accessed: ${examId} by ${caller}`);
+        console.info(`✅ Exam file accessed: ${examId} by ${caller} (questions ${questionsAvailable ? 'revealed' : 'hidden'})`);
         console.info('============= END : GetExamFile ===========');
 
         // Retourner le hash IPFS et les métadonnées
@@ -376,19 +731,23 @@ class ExamContract extends Contract {
             id: exam.id,
             title: exam.title,
             examDate: exam.examDate,
-            examFileHash: exam.examFileHash,
+            questionsAvailableAt: questionsAvailableAt.toISOString(),
+            questionsAvailable: questionsAvailable,
+            examFileHash: questionsAvailable ? exam.examFileHash : null,
             classId: exam.classId,
             moduleId: exam.moduleId,
+            allowedMaterials: allowedMaterials,
         });
     }
 
     /**
      * 5. Obtenir le hash IPFS de la correction pour téléchargement
      *
-     * RÈGLE DES 24H: Vérifie que (now - examDate) >= 24h
+     * DÉLAI: Vérifie que (now - examDate) >= CORRECTION_DELAY_HOURS (48h)
      * Vérifie l'enrollment
      *
-     * Accessible par: Étudiants inscrits + Teachers (Teachers : pas de limite temporelle)
+     * Accessible par: Étudiants inscrits + Teachers (Teachers : pas de limite temporelle,
+     * sauf les assistants "ta" de la classe, soumis au même délai que les étudiants)
      *
      * @param {Context} ctx - Le contexte de transaction
      * @param {string} examId - ID de l'examen
@@ -403,12 +762,7 @@ class ExamContract extends Contract {
             throw new Error(`Exam ${examId} does not exist`);
         }
 
-        const exam = JSON.parse(examAsBytes.toString());
-
-        // Vérifier que c'est bien un examen
-        if (exam.docType !== 'exam') {
-            throw new Error(`${examId} is not an exam`);
-        }
+        const exam = parseRecord(examAsBytes, examId, 'exam');
 
         // CONTRÔLE D'ACCÈS: Vérifier l'enrollment dans la classe de l'examen
         await this._checkEnrollment(ctx, exam.classId);
@@ -418,15 +772,14 @@ class ExamContract extends Contract {
             throw new Error(`Correction not yet uploaded for exam ${examId}`);
         }
 
-        const isTeacher = this._isSchoolMember(ctx);
-        const now = new Date();
-        const examDate = new Date(exam.examDate);
-        const correctionAvailableAt = new Date(examDate.getTime() + 48 * 60 * 60 * 1000); // +48h
+        const isTeacher = this._isSchoolMember(ctx) && !(await this._isTeachingAssistant(ctx, exam.classId));
+        const now = new Date(this._getTxTimestamp(ctx));
+        const correctionAvailableAt = getCorrectionAvailableAt(exam);
 
-        // RÈGLE DES 24H: Seulement pour les étudiants
+        // Délai de mise à disposition: seulement pour les étudiants (et assistants)
         if (!isTeacher && now < correctionAvailableAt) {
             const hoursRemaining = Math.ceil((correctionAvailableAt - now) / (1000 * 60 * 60));
-            throw new Error(`Correction available in ${hoursRemaining} hours (24h after exam date)`);
+            throw new Error(`Correction available in ${hoursRemaining} hours (${CORRECTION_DELAY_HOURS}h after exam date)`);
         }
 
         const caller = this._getCallerIdentity(ctx);
@@ -450,6 +803,7 @@ class ExamContract extends Contract {
     /**
      * Obtenir les détails complets d'un examen
      * Accessible par: Teachers uniquement
+     * Assistants (ta) de la classe: correctionFileHash masqué jusqu'à examDate + CORRECTION_DELAY_HOURS
      */
     async GetExam(ctx, examId) {
         console.info('============= START : GetExam ===========');
@@ -463,10 +817,15 @@ class ExamContract extends Contract {
             throw new Error(`Exam ${examId} does not exist`);
         }
 
-        const exam = JSON.parse(examAsBytes.toString());
+        const exam = parseRecord(examAsBytes, examId, 'exam');
 
-        if (exam.docType !== 'exam') {
-            throw new Error(`${examId} is not an exam`);
+        if (await this._isTeachingAssistant(ctx, exam.classId)) {
+            const correctionAvailableAt = getCorrectionAvailableAt(exam);
+            if (new Date(this._getTxTimestamp(ctx)) < correctionAvailableAt) {
+                exam.correctionFileHash = null;
+                exam.correctionAvailable = false;
+                exam.correctionAvailableAt = correctionAvailableAt.toISOString();
+            }
         }
 
         console.info(`✅ Exam retrieved: ${examId}`);
@@ -482,7 +841,7 @@ class ExamContract extends Contract {
     async DeleteExam(ctx, examId) {
         console.info('============= START : DeleteExam ===========');
 
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only teachers can delete exams');
         }
 
@@ -491,11 +850,7 @@ class ExamContract extends Contract {
             throw new Error(`Exam ${examId} does not exist`);
         }
 
-        const exam = JSON.parse(examAsBytes.toString());
-
-        if (exam.docType !== 'exam') {
-            throw new Error(`${examId} is not an exam`);
-        }
+        const exam = parseRecord(examAsBytes, examId, 'exam');
 
         // Supprimer du ledger
         await ctx.stub.deleteState(examId);
@@ -526,7 +881,7 @@ class ExamContract extends Contract {
     async UpdateExamDate(ctx, examId, newExamDate) {
         console.info('============= START : UpdateExamDate ===========');
 
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only teachers can update exam dates');
         }
 
@@ -535,34 +890,34 @@ class ExamContract extends Contract {
             throw new Error(`Exam ${examId} does not exist`);
         }
 
-        const exam = JSON.parse(examAsBytes.toString());
-
-        if (exam.docType !== 'exam') {
-            throw new Error(`${examId} is not an exam`);
-        }
+        const exam = parseRecord(examAsBytes, examId, 'exam');
 
         // Ne peut pas modifier la date si la correction a été uploadée
         if (exam.correctionFileHash) {
             throw new Error('Cannot update exam date after correction has been uploaded');
         }
 
-        // Valider le nouveau format de date
-        const newDate = new Date(newExamDate);
-        if (isNaN(newDate.getTime())) {
-            throw new Error('Invalid date format. Use ISO 8601 format (e.g., "2024-02-01T10:00:00Z")');
+        // Ne peut pas modifier la date une fois l'examen commencé
+        if (this._hasExamStarted(ctx, exam)) {
+            throw new Error(`Cannot update exam date: exam ${examId} started on ${exam.examDate}`);
         }
 
+        // Valider le nouveau format de date
+        const newDate = normalizeDate(newExamDate, 'examDate');
+
         const oldDate = exam.examDate;
-        exam.examDate = newExamDate;
+        exam.examDate = newDate.utc;
+        exam.examDateOffset = newDate.offset;
+        this._checkQuestionsAvailableAt(exam);
 
-        await ctx.stub.putState(examId, Buffer.from(JSON.stringify(exam)));
+        await ctx.stub.putState(examId, serializeRecord(exam));
 
         const caller = this._getCallerIdentity(ctx);
 
         ctx.stub.setEvent('ExamDateUpdated', Buffer.from(JSON.stringify({
             examId: examId,
             oldDate: oldDate,
-            newDate: newExamDate,
+            newDate: exam.examDate,
             updatedBy: caller,
         })));
 
@@ -571,9 +926,1020 @@ class ExamContract extends Contract {
 
         return JSON.stringify({
             success: true,
-            message: `Exam date updated from ${oldDate} to ${newExamDate}`,
+            message: `Exam date updated from ${oldDate} to ${exam.examDate}`,
+        });
+    }
+
+    // ==================== SURVEILLANCE ====================
+
+    /**
+     * Assigner un surveillant à un examen
+     * Accessible par: Teachers/admin uniquement
+     */
+    async AssignProctor(ctx, examId, proctorId) {
+        console.info('============= START : AssignProctor ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only teachers can assign proctors');
+        }
+
+        if (!proctorId) {
+            throw new Error('Missing proctorId');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        const proctors = exam.proctors || [];
+
+        if (proctors.includes(proctorId)) {
+            throw new Error(`Proctor ${proctorId} is already assigned to exam ${examId}`);
+        }
+
+        proctors.push(proctorId);
+        exam.proctors = proctors;
+
+        await ctx.stub.putState(examId, serializeRecord(exam));
+
+        const caller = this._getCallerIdentity(ctx);
+
+        ctx.stub.setEvent('ProctorAssigned', Buffer.from(JSON.stringify({
+            examId: examId,
+            proctorId: proctorId,
+            assignedBy: caller,
+        })));
+
+        console.info(`✅ Proctor ${proctorId} assigned to exam ${examId} by ${caller}`);
+        console.info('============= END : AssignProctor ===========');
+
+        return JSON.stringify({ success: true, examId: examId, proctors: proctors });
+    }
+
+    /**
+     * Retirer un surveillant d'un examen
+     * Accessible par: Teachers/admin uniquement
+     */
+    async RemoveProctor(ctx, examId, proctorId) {
+        console.info('============= START : RemoveProctor ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only teachers can remove proctors');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        const proctors = exam.proctors || [];
+
+        if (!proctors.includes(proctorId)) {
+            throw new Error(`Proctor ${proctorId} is not assigned to exam ${examId}`);
+        }
+
+        exam.proctors = proctors.filter((id) => id !== proctorId);
+
+        await ctx.stub.putState(examId, serializeRecord(exam));
+
+        const caller = this._getCallerIdentity(ctx);
+
+        ctx.stub.setEvent('ProctorRemoved', Buffer.from(JSON.stringify({
+            examId: examId,
+            proctorId: proctorId,
+            removedBy: caller,
+        })));
+
+        console.info(`✅ Proctor ${proctorId} removed from exam ${examId} by ${caller}`);
+        console.info('============= END : RemoveProctor ===========');
+
+        return JSON.stringify({ success: true, examId: examId, proctors: exam.proctors });
+    }
+
+    /**
+     * Planning d'un surveillant: examens assignés triés par date
+     * Accessible par: SchoolOrg uniquement
+     */
+    async GetProctorSchedule(ctx, proctorId) {
+        console.info('============= START : GetProctorSchedule ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view proctor schedules');
+        }
+
+        const queryString = JSON.stringify({
+            selector: {
+                docType: 'exam',
+                proctors: { $elemMatch: { $eq: proctorId } }
+            }
         });
+
+        let exams;
+        try {
+            exams = await this._collectQuery(ctx, queryString);
+        } catch (err) {
+            // Fallback si CouchDB non disponible
+            console.warn('CouchDB query failed, using fallback method:', err);
+            exams = await this._collectByRange(ctx, (record) =>
+                record.docType === 'exam' && (record.proctors || []).includes(proctorId));
+        }
+
+        const schedule = exams
+            .map((exam) => ({
+                id: exam.id,
+                classId: exam.classId,
+                title: exam.title,
+                examDate: exam.examDate,
+            }))
+            .sort((a, b) => new Date(a.examDate) - new Date(b.examDate));
+
+        console.info(`✅ Retrieved ${schedule.length} exams for proctor ${proctorId}`);
+        console.info('============= END : GetProctorSchedule ===========');
+
+        return JSON.stringify(schedule);
+    }
+
+    /**
+     * Signaler un incident pendant un examen (fraude suspectée, malaise, retard...)
+     * Accessible par: Surveillants de l'examen, équipe pédagogique de la classe, admins
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} studentId - Étudiant concerné (vide si l'incident ne vise personne)
+     * @param {string} description - Description de l'incident
+     * @returns {string} JSON de l'incident
+     */
+    async ReportIncident(ctx, examId, studentId, description) {
+        console.info('============= START : ReportIncident ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only proctors and teachers can report incidents');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+
+        const classAsBytes = await ctx.stub.getState(exam.classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${exam.classId} does not exist`);
+        }
+        const classData = parseRecord(classAsBytes, exam.classId, 'class');
+
+        const caller = this._getCallerIdentity(ctx);
+        const isProctor = (exam.proctors || []).includes(caller);
+        const isStaff = (classData.teacher || classData.createdBy) === caller ||
+            (classData.staff || []).some((member) => member.identityId === caller);
+        if (!isProctor && !isStaff && await getCallerRole(ctx) !== 'admin') {
+            throw new Error(`Access Denied: Only the proctors of exam ${examId} or the staff of class ${exam.classId} can report incidents`);
+        }
+
+        if (!description || !description.trim()) {
+            throw new Error('Invalid description: must be a non-empty string');
+        }
+        if (studentId && !classData.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is not enrolled in class ${exam.classId}`);
+        }
+
+        const incident = {
+            docType: 'incident',
+            id: `${incidentPrefix(examId)}${ctx.stub.getTxID()}`,
+            examId: examId,
+            classId: exam.classId,
+            studentId: studentId || null,
+            description: description,
+            reportedBy: caller,
+            reportedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(incident.id, serializeRecord(incident));
+
+        ctx.stub.setEvent('IncidentReported', Buffer.from(JSON.stringify({
+            incidentId: incident.id,
+            examId: examId,
+            studentId: incident.studentId,
+            reportedBy: caller,
+        })));
+
+        console.info(`✅ Incident reported on exam ${examId} by ${caller}`);
+        console.info('============= END : ReportIncident ===========');
+
+        return JSON.stringify(incident);
+    }
+
+    /**
+     * Incidents d'un examen, du plus ancien au plus récent
+     * Accessible par: SchoolOrg uniquement (à prendre en compte lors de la correction)
+     */
+    async GetExamIncidents(ctx, examId) {
+        console.info('============= START : GetExamIncidents ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view exam incidents');
+        }
+
+        await this._getExam(ctx, examId);
+
+        const prefix = incidentPrefix(examId);
+        const allResults = [];
+        const iterator = await ctx.stub.getStateByRange(prefix, prefix + '\uffff');
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                // Le préfixe d'un autre examen peut commencer par celui-ci
+                if (record.docType === 'incident' && record.examId === examId) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        allResults.sort((a, b) => a.reportedAt.localeCompare(b.reportedAt) || a.id.localeCompare(b.id));
+
+        console.info(`✅ Retrieved ${allResults.length} incidents for exam ${examId}`);
+        console.info('============= END : GetExamIncidents ===========');
+
+        return JSON.stringify(allResults);
+    }
+
+    /**
+     * Mettre à jour un examen
+     * Accessible par: Teachers uniquement
+     *
+     * Champs modifiables: title, weight, maxScore, rubric (grille, null pour la retirer),
+     * examFileHash (questions), examDate, questionsAvailableAt (révélation des questions, null = examDate),
+     * durationMinutes, gracePeriodMinutes, latePenaltyPerHour (politique de retard)
+     * Contrainte: questions, dates, barème, grille et politique de retard verrouillés une fois l'examen commencé
+     * Contrainte: questionsAvailableAt au plus tard à l'échéance de remise
+     * Contrainte: weight dans ]0, 1]; un total de classe au-delà de 1 est signalé (weightWarning de ExamUpdated)
+     * Contrainte: maxScore reste 100 en pourcentage; ni weight, ni maxScore, ni grille en réussite/échec
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} fieldsJSON - Champs à modifier (ex: '{"title":"Final","weight":0.4}')
+     * @returns {string} JSON de l'examen mis à jour
+     */
+    async UpdateExam(ctx, examId, fieldsJSON) {
+        console.info('============= START : UpdateExam ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only teachers can update exams');
+        }
+
+        const examAsBytes = await ctx.stub.getState(examId);
+        if (!examAsBytes || examAsBytes.length === 0) {
+            throw new Error(`Exam ${examId} does not exist`);
+        }
+
+        const exam = parseRecord(examAsBytes, examId, 'exam');
+
+        let fields;
+        try {
+            fields = JSON.parse(fieldsJSON);
+        } catch (err) {
+            throw new Error('Invalid fieldsJSON: must be a JSON object');
+        }
+        if (!fields || typeof fields !== 'object' || Array.isArray(fields)) {
+            throw new Error('Invalid fieldsJSON: must be a JSON object');
+        }
+
+        const latePolicy = ['durationMinutes', 'gracePeriodMinutes', 'latePenaltyPerHour'];
+        const editable = ['title', 'weight', 'maxScore', 'rubric', 'examFileHash', 'examDate', 'questionsAvailableAt', ...latePolicy];
+        const locked = ['maxScore', 'rubric', 'examFileHash', 'examDate', 'questionsAvailableAt', ...latePolicy];
+
+        for (const key of Object.keys(fields)) {
+            if (!editable.includes(key)) {
+                throw new Error(`Invalid field: ${key} cannot be updated (allowed: ${editable.join(', ')})`);
+            }
+        }
+
+        // INTÉGRITÉ: questions et date verrouillées dès que l'examen a commencé
+        const lockedChanges = Object.keys(fields).filter((key) => locked.includes(key));
+        if (lockedChanges.length > 0 && this._hasExamStarted(ctx, exam)) {
+            throw new Error(`Cannot update ${lockedChanges.join(', ')}: exam ${examId} started on ${exam.examDate}`);
+        }
+
+        if ('title' in fields) {
+            if (typeof fields.title !== 'string' || !fields.title.trim()) {
+                throw new Error('Invalid title: must be a non-empty string');
+            }
+            exam.title = fields.title;
+        }
+
+        const scheme = getGradingScheme(exam);
+        let weightWarning = null;
+        if ('weight' in fields) {
+            if (scheme === 'passfail') {
+                throw new Error('Invalid weight: pass/fail exams do not count in the final grade');
+            }
+            exam.weight = this._parseWeight(fields.weight);
+            weightWarning = await this._getWeightWarning(ctx, exam.classId, examId, exam.weight);
+        }
+
+        if ('maxScore' in fields) {
+            const maxScoreNum = this._parseMaxScore(fields.maxScore);
+            this._checkSchemeMaxScore(scheme, maxScoreNum);
+            exam.maxScore = maxScoreNum;
+        }
+
+        if ('rubric' in fields) {
+            if (fields.rubric !== null && scheme === 'passfail') {
+                throw new Error('Invalid rubric: pass/fail exams have no score');
+            }
+            exam.rubric = fields.rubric === null ? null : fields.rubric;
+        }
+        // Revalidée aussi quand seule la note maximale change
+        if (exam.rubric) {
+            exam.rubric = this._parseRubric(exam.rubric, getExamMaxScore(exam));
+        }
+
+        if ('examFileHash' in fields) {
+            if (typeof fields.examFileHash !== 'string' || !fields.examFileHash.trim()) {
+                throw new Error('Invalid examFileHash: must be a non-empty IPFS hash');
+            }
+            exam.examFileHash = fields.examFileHash;
+        }
+
+        if ('examDate' in fields) {
+            if (exam.correctionFileHash) {
+                throw new Error('Cannot update exam date after correction has been uploaded');
+            }
+            const newDate = normalizeDate(fields.examDate, 'examDate');
+            exam.examDate = newDate.utc;
+            exam.examDateOffset = newDate.offset;
+        }
+
+        for (const key of latePolicy.filter((policyKey) => policyKey in fields)) {
+            if (typeof fields[key] !== 'number' || !Number.isFinite(fields[key]) || fields[key] < 0) {
+                throw new Error(`Invalid ${key}: must be a non-negative number`);
+            }
+            exam[key] = fields[key];
+        }
+
+        if ('questionsAvailableAt' in fields) {
+            exam.questionsAvailableAt = fields.questionsAvailableAt === null
+                ? null
+                : normalizeDate(fields.questionsAvailableAt, 'questionsAvailableAt').utc;
+        }
+
+        // Revalidé après toute modification: la date et la durée déplacent l'échéance
+        this._checkQuestionsAvailableAt(exam);
+
+        const caller = this._getCallerIdentity(ctx);
+        exam.updatedBy = caller;
+        exam.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(examId, serializeRecord(exam));
+
+        ctx.stub.setEvent('ExamUpdated', Buffer.from(JSON.stringify({
+            examId: examId,
+            fields: Object.keys(fields),
+            updatedBy: caller,
+            weightWarning: weightWarning,
+        })));
+
+        console.info(`✅ Exam updated: ${examId} by ${caller}`);
+        console.info('============= END : UpdateExam ===========');
+
+        return JSON.stringify(exam);
+    }
+
+    // ==================== SUPPORTS AUTORISÉS ====================
+
+    /**
+     * Définir les supports autorisés pendant un examen (formulaires, fiches...)
+     * Accessible par: Teachers/admin uniquement
+     *
+     * Remplace la liste: chaque support doit exister et appartenir à la classe de l'examen
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} materialIdsJSON - JSON array des IDs de supports (ex: '["mat-formulas"]'), [] pour vider
+     * @returns {string} JSON { examId, allowedMaterials }
+     */
+    async SetExamAllowedMaterials(ctx, examId, materialIdsJSON) {
+        console.info('============= START : SetExamAllowedMaterials ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only teachers can set allowed materials');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+
+        let materialIds;
+        try {
+            materialIds = JSON.parse(materialIdsJSON);
+        } catch (err) {
+            throw new Error('Invalid materialIdsJSON: must be a JSON array of material IDs');
+        }
+        if (!Array.isArray(materialIds) || materialIds.some((id) => typeof id !== 'string' || !id.trim())) {
+            throw new Error('Invalid materialIdsJSON: must be a JSON array of material IDs');
+        }
+
+        const allowedMaterials = Array.from(new Set(materialIds));
+        for (const materialId of allowedMaterials) {
+            const materialAsBytes = await ctx.stub.getState(materialId);
+            if (!materialAsBytes || materialAsBytes.length === 0) {
+                throw new Error(`Material ${materialId} does not exist`);
+            }
+            const material = parseRecord(materialAsBytes, materialId, 'material');
+            if (material.classId !== exam.classId) {
+                throw new Error(`Material ${materialId} belongs to class ${material.classId}, not to class ${exam.classId} of exam ${examId}`);
+            }
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        exam.allowedMaterials = allowedMaterials;
+        exam.updatedBy = caller;
+        exam.updatedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(examId, serializeRecord(exam));
+
+        ctx.stub.setEvent('ExamAllowedMaterialsSet', Buffer.from(JSON.stringify({
+            examId: examId,
+            allowedMaterials: allowedMaterials,
+            updatedBy: caller,
+        })));
+
+        console.info(`✅ ${allowedMaterials.length} allowed materials set for exam ${examId} by ${caller}`);
+        console.info('============= END : SetExamAllowedMaterials ===========');
+
+        return JSON.stringify({ examId: examId, allowedMaterials: allowedMaterials });
+    }
+
+    /**
+     * Définir l'aménagement d'épreuve d'un étudiant (temps supplémentaire)
+     *
+     * Accessible par: Teacher / co-teacher de la classe + admins
+     * L'échéance de remise de l'étudiant devient examDate + durationMinutes × multiplier
+     * (délai de grâce inchangé); multiplier "1" retire l'aménagement
+     * Refusé une fois l'examen commencé (examDate): l'échéance d'un étudiant ne change pas en cours
+     * d'épreuve, ni après coup (pénalités de retard)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} studentId - Étudiant inscrit dans la classe de l'examen
+     * @param {string} multiplier - Multiplicateur de durée, entre 1 et MAX_TIME_MULTIPLIER (ex: "1.5")
+     * @returns {string} JSON { examId, studentId, timeMultiplier, submissionDeadline, submissionClosesAt }
+     */
+    async SetExamAccommodation(ctx, examId, studentId, multiplier) {
+        console.info('============= START : SetExamAccommodation ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only teachers can set exam accommodations');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+
+        const classAsBytes = await ctx.stub.getState(exam.classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${exam.classId} does not exist`);
+        }
+        const classData = parseRecord(classAsBytes, exam.classId, 'class');
+
+        const caller = this._getCallerIdentity(ctx);
+        const isTeacher = (classData.teacher || classData.createdBy) === caller ||
+            (classData.staff || []).some((member) => member.identityId === caller && member.role === 'co-teacher');
+        if (!isTeacher && await getCallerRole(ctx) !== 'admin') {
+            throw new Error(`Access Denied: Only the teachers of class ${exam.classId} can set accommodations for exam ${examId}`);
+        }
+
+        if (!classData.enrolledStudents.includes(studentId)) {
+            throw new Error(`Student ${studentId} is not enrolled in class ${exam.classId}`);
+        }
+
+        const multiplierNum = Number(multiplier);
+        if (multiplier === '' || !Number.isFinite(multiplierNum) || multiplierNum < 1 || multiplierNum > MAX_TIME_MULTIPLIER) {
+            throw new Error(`Invalid multiplier: ${multiplier} must be between 1 and ${MAX_TIME_MULTIPLIER}`);
+        }
+
+        if (new Date(this._getTxTimestamp(ctx)) >= new Date(exam.examDate)) {
+            throw new Error(`Exam ${examId} started at ${exam.examDate}: accommodations can no longer be changed`);
+        }
+
+        const accommodations = Object.assign({}, exam.accommodations);
+        if (multiplierNum === 1) {
+            delete accommodations[studentId];
+        } else {
+            accommodations[studentId] = {
+                timeMultiplier: multiplierNum,
+                setBy: caller,
+                setAt: this._getTxTimestamp(ctx),
+            };
+        }
+        exam.accommodations = accommodations;
+
+        await ctx.stub.putState(examId, serializeRecord(exam));
+
+        ctx.stub.setEvent('ExamAccommodationSet', Buffer.from(JSON.stringify({
+            examId: examId,
+            studentId: studentId,
+            timeMultiplier: multiplierNum,
+            setBy: caller,
+        })));
+
+        const deadline = getSubmissionDeadline(exam, studentId);
+        const closesAt = getSubmissionClosesAt(exam, studentId);
+
+        console.info(`✅ Accommodation for ${studentId} on exam ${examId}: x${multiplierNum} (by ${caller})`);
+        console.info('============= END : SetExamAccommodation ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            studentId: studentId,
+            timeMultiplier: multiplierNum,
+            submissionDeadline: deadline ? deadline.toISOString() : null,
+            submissionClosesAt: closesAt ? closesAt.toISOString() : null,
+        });
+    }
+
+    // ==================== COPIES ====================
+
+    /**
+     * Remettre sa copie d'examen
+     *
+     * Accessible par: Étudiants inscrits uniquement
+     * RÈGLE TEMPORELLE: après le début de l'examen, au plus tard
+     * échéance (examDate + durationMinutes, prolongée par l'aménagement de l'étudiant) + gracePeriodMinutes
+     * Le reçu stocké conserve l'heure de remise (timestamp de la transaction)
+     * Une copie remise après l'échéance mais dans le délai de grâce est marquée late,
+     * avec lateBySeconds (retard sur l'échéance de l'étudiant); au-delà, la remise est refusée
+     * Copie chiffrée: la clé est déposée en séquestre et libérée aux teachers
+     * après la clôture des remises (GetSubmissionKey)
+     * Empreinte du contenu verrouillée dans le reçu (SHA-256 fourni, sinon le hash IPFS):
+     * une copie remplacée ensuite est détectée par VerifySubmissionIntegrity
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} answerFileHash - Hash IPFS de la copie
+     * @param {string} [encryptedKey] - Clé de déchiffrement de la copie (séquestre)
+     * @param {string} [contentHash] - SHA-256 (hexadécimal) du contenu de la copie
+     * @returns {string} JSON du reçu de remise
+     */
+    async SubmitExamCopy(ctx, examId, answerFileHash, encryptedKey, contentHash) {
+        console.info('============= START : SubmitExamCopy ===========');
+
+        if (!this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: Only students can submit exam copies');
+        }
+
+        if (!answerFileHash || !answerFileHash.trim()) {
+            throw new Error('Invalid answerFileHash: must be a non-empty IPFS hash');
+        }
+        const normalizedContentHash = contentHash ? contentHash.trim().toLowerCase() : null;
+        if (normalizedContentHash && !CONTENT_HASH_PATTERN.test(normalizedContentHash)) {
+            throw new Error('Invalid contentHash: must be a hex-encoded SHA-256 digest (64 characters)');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkEnrollment(ctx, exam.classId);
+
+        if (!this._hasExamStarted(ctx, exam)) {
+            throw new Error(`Exam ${examId} has not started yet (starts on ${exam.examDate})`);
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const key = submissionKey(examId, caller);
+
+        if (await getSubmissionRecord(ctx, examId, caller)) {
+            throw new Error(`Copy already submitted for exam ${examId}`);
+        }
+
+        const submittedAt = this._getTxTimestamp(ctx);
+        const closesAt = getSubmissionClosesAt(exam, caller);
+        if (closesAt && new Date(submittedAt) > closesAt) {
+            throw new Error(`Submission closed: copies for exam ${examId} were accepted until ${closesAt.toISOString()}`);
+        }
+
+        const submission = {
+            docType: 'submission',
+            id: key,
+            examId: examId,
+            classId: exam.classId,
+            studentId: caller,
+            answerFileHash: answerFileHash,
+            contentHash: normalizedContentHash, // SHA-256 du contenu (null: le hash IPFS fait foi)
+            contentHashAlgorithm: normalizedContentHash ? 'sha256' : 'ipfs-cid',
+            hashLockedAt: submittedAt, // Empreinte verrouillée: jamais modifiée après la remise
+            encryptedKey: encryptedKey || null, // Séquestre: jamais renvoyé par GetSubmission
+            submittedAt: submittedAt,
+        };
+        const late = computeLatePenalty(exam, submission);
+        submission.hoursLate = late.hoursLate;
+        submission.late = late.lateBySeconds > 0; // Remise dans le délai de grâce, après l'échéance
+        submission.lateBySeconds = late.lateBySeconds;
+
+        await ctx.stub.putState(key, serializeRecord(submission));
+
+        ctx.stub.setEvent('ExamCopySubmitted', Buffer.from(JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            studentId: caller,
+            hoursLate: late.hoursLate,
+            late: submission.late,
+            lateBySeconds: late.lateBySeconds,
+        })));
+
+        console.info(`✅ Copy submitted for exam ${examId} by ${caller}${late.hoursLate > 0 ? ` (${late.hoursLate}h late)` : ''}`);
+        console.info('============= END : SubmitExamCopy ===========');
+
+        return JSON.stringify(this._toSubmissionReceipt(submission));
+    }
+
+    /**
+     * Obtenir le reçu de remise d'une copie
+     * Accessible par: Teachers + étudiant concerné
+     */
+    async GetSubmission(ctx, examId, studentId) {
+        if (!this._isSchoolMember(ctx) && this._getCallerIdentity(ctx) !== studentId) {
+            throw new Error('Access Denied: You can only view your own submissions');
+        }
+
+        const submission = await getSubmissionRecord(ctx, examId, studentId);
+        if (!submission) {
+            throw new Error(`No submission from ${studentId} for exam ${examId}`);
+        }
+
+        return JSON.stringify(this._toSubmissionReceipt(submission));
+    }
+
+    /**
+     * Vérifier qu'une copie correspond à l'empreinte verrouillée à la remise
+     *
+     * Accessible par: Teachers + étudiant concerné
+     * actualHash: empreinte du contenu effectivement récupéré sur IPFS, calculée avec
+     * l'algorithme du reçu (SHA-256 hexadécimal, ou hash IPFS si aucune empreinte n'a été fournie)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} submissionId - ID du reçu (submissionId renvoyé par SubmitExamCopy)
+     * @param {string} actualHash - Empreinte du contenu récupéré
+     * @returns {string} JSON { valid, submissionId, examId, studentId, algorithm, lockedHash, actualHash, hashLockedAt }
+     */
+    async VerifySubmissionIntegrity(ctx, submissionId, actualHash) {
+        console.info('============= START : VerifySubmissionIntegrity ===========');
+
+        if (!actualHash || !actualHash.trim()) {
+            throw new Error('Invalid actualHash: must be a non-empty hash');
+        }
+
+        const submissionAsBytes = await ctx.stub.getState(submissionId);
+        if (!submissionAsBytes || submissionAsBytes.length === 0) {
+            throw new Error(`Submission ${submissionId} does not exist`);
+        }
+        const submission = parseRecord(submissionAsBytes, submissionId, 'submission');
+
+        if (!this._isSchoolMember(ctx) && this._getCallerIdentity(ctx) !== submission.studentId) {
+            throw new Error('Access Denied: You can only verify your own submissions');
+        }
+
+        const locked = getLockedContentHash(submission);
+        // Les empreintes SHA-256 hexadécimales ne dépendent pas de la casse, contrairement aux hash IPFS
+        const candidate = locked.algorithm === 'sha256' ? actualHash.trim().toLowerCase() : actualHash.trim();
+        const valid = candidate === locked.hash;
+
+        console.info(`✅ Submission ${submissionId} integrity: ${valid ? 'valid' : 'MISMATCH'} (${locked.algorithm})`);
+        console.info('============= END : VerifySubmissionIntegrity ===========');
+
+        return JSON.stringify({
+            valid: valid,
+            submissionId: submissionId,
+            examId: submission.examId,
+            studentId: submission.studentId,
+            algorithm: locked.algorithm,
+            lockedHash: locked.hash,
+            actualHash: candidate,
+            hashLockedAt: submission.hashLockedAt || submission.submittedAt,
+        });
+    }
+
+    /**
+     * Obtenir la clé de déchiffrement d'une copie (séquestre)
+     *
+     * Accessible par: Teacher / co-teacher de la classe + admins
+     * RÈGLE TEMPORELLE: uniquement après la clôture des remises
+     * (échéance + délai de grâce), au timestamp de la transaction
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} submissionId - ID du reçu (submissionId renvoyé par SubmitExamCopy)
+     * @returns {string} JSON { submissionId, encryptedKey }
+     */
+    async GetSubmissionKey(ctx, submissionId) {
+        console.info('============= START : GetSubmissionKey ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only teachers can retrieve submission keys');
+        }
+
+        const submissionAsBytes = await ctx.stub.getState(submissionId);
+        if (!submissionAsBytes || submissionAsBytes.length === 0) {
+            throw new Error(`Submission ${submissionId} does not exist`);
+        }
+
+        const submission = parseRecord(submissionAsBytes, submissionId, 'submission');
+        const exam = await this._getExam(ctx, submission.examId);
+
+        const classAsBytes = await ctx.stub.getState(exam.classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${exam.classId} does not exist`);
+        }
+        const classData = parseRecord(classAsBytes, exam.classId, 'class');
+
+        const caller = this._getCallerIdentity(ctx);
+        const isTeacher = (classData.teacher || classData.createdBy) === caller ||
+            (classData.staff || []).some((member) => member.identityId === caller && member.role === 'co-teacher');
+        if (!isTeacher && await getCallerRole(ctx) !== 'admin') {
+            throw new Error(`Access Denied: Only the teachers of class ${exam.classId} can retrieve submission keys`);
+        }
+
+        const closesAt = getSubmissionClosesAt(exam, submission.studentId);
+        if (!closesAt) {
+            throw new Error(`Exam ${exam.id} has no submission deadline (durationMinutes not set): keys cannot be released`);
+        }
+        if (new Date(this._getTxTimestamp(ctx)) <= closesAt) {
+            throw new Error(`Submission key locked until the submission deadline: ${closesAt.toISOString()}`);
+        }
+
+        if (!submission.encryptedKey) {
+            throw new Error(`Submission ${submissionId} has no escrowed key`);
+        }
+
+        console.info(`✅ Submission key released: ${submissionId} to ${caller}`);
+        console.info('============= END : GetSubmissionKey ===========');
+
+        return JSON.stringify({
+            submissionId: submissionId,
+            examId: submission.examId,
+            studentId: submission.studentId,
+            encryptedKey: submission.encryptedKey,
+        });
+    }
+
+    /**
+     * Nombre de copies remises sur le nombre d'inscrits (affichage "45/50 submitted")
+     *
+     * Accessible par: Surveillants de l'examen, équipe pédagogique de la classe, admins
+     * Seules les copies des étudiants actuellement inscrits sont comptées
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON { examId, classId, submittedCount, enrolledCount, pendingCount, lateCount, closesAt }
+     */
+    async GetSubmissionCount(ctx, examId) {
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only proctors and teachers can view submission counts');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+
+        const classAsBytes = await ctx.stub.getState(exam.classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${exam.classId} does not exist`);
+        }
+        const classData = parseRecord(classAsBytes, exam.classId, 'class');
+
+        const caller = this._getCallerIdentity(ctx);
+        const isProctor = (exam.proctors || []).includes(caller);
+        const isStaff = (classData.teacher || classData.createdBy) === caller ||
+            (classData.staff || []).some((member) => member.identityId === caller);
+        if (!isProctor && !isStaff && await getCallerRole(ctx) !== 'admin') {
+            throw new Error(`Access Denied: Only the proctors of exam ${examId} or the staff of class ${exam.classId} can view submission counts`);
+        }
+
+        // Lecture directe des reçus des inscrits (clé déterministe par examen et étudiant)
+        let submittedCount = 0;
+        let lateCount = 0;
+        for (const studentId of classData.enrolledStudents) {
+            const submission = await getSubmissionRecord(ctx, examId, studentId);
+            if (!submission) {
+                continue;
+            }
+
+            submittedCount += 1;
+            if (submission.hoursLate > 0) {
+                lateCount += 1;
+            }
+        }
+
+        const enrolledCount = classData.enrolledStudents.length;
+        const closesAt = getSubmissionClosesAt(exam);
+
+        return JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            submittedCount: submittedCount,
+            enrolledCount: enrolledCount,
+            pendingCount: enrolledCount - submittedCount,
+            lateCount: lateCount,
+            closesAt: closesAt ? closesAt.toISOString() : null,
+        });
+    }
+
+    /**
+     * Détecter les examens qui se chevauchent pour un étudiant
+     *
+     * Accessible par: SchoolOrg ou l'étudiant lui-même
+     * Couvre les examens de toutes les classes où l'étudiant est inscrit.
+     * Un examen occupe [examDate, examDate + durationMinutes[; sans durée définie,
+     * il est réduit à son heure de début (deux débuts identiques sont en conflit).
+     * Deux examens qui s'enchaînent (fin de l'un = début de l'autre) ne sont pas en conflit.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} studentId - ID de l'étudiant
+     * @returns {string} JSON array [{ examA, examB, classA, classB, overlapStart, overlapEnd }]
+     */
+    async DetectExamConflicts(ctx, studentId) {
+        console.info('============= START : DetectExamConflicts ===========');
+
+        if (!this._isSchoolMember(ctx) && this._getCallerIdentity(ctx) !== studentId) {
+            throw new Error('Access Denied: Students can only check their own exam conflicts');
+        }
+
+        let classes;
+        try {
+            classes = await this._collectQuery(ctx, JSON.stringify({
+                selector: {
+                    docType: 'class',
+                    enrolledStudents: { $elemMatch: { $eq: studentId } }
+                }
+            }));
+        } catch (err) {
+            // Fallback si CouchDB non disponible
+            console.warn('CouchDB query failed, using fallback method:', err);
+            classes = await this._collectByRange(ctx, (record) =>
+                record.docType === 'class' && (record.enrolledStudents || []).includes(studentId));
+        }
+        const classIds = classes.map((classData) => classData.id);
+
+        let exams = [];
+        if (classIds.length > 0) {
+            try {
+                exams = await this._collectQuery(ctx, JSON.stringify({
+                    selector: {
+                        docType: 'exam',
+                        classId: { $in: classIds }
+                    }
+                }));
+            } catch (err) {
+                console.warn('CouchDB query failed, using fallback method:', err);
+                exams = await this._collectByRange(ctx, (record) =>
+                    record.docType === 'exam' && classIds.includes(record.classId));
+            }
+        }
+
+        const slots = exams
+            .filter((exam) => exam.examDate && !isNaN(new Date(exam.examDate).getTime()))
+            .map((exam) => {
+                const start = new Date(exam.examDate).getTime();
+                return {
+                    exam: exam,
+                    start: start,
+                    end: start + (exam.durationMinutes || 0) * MINUTE_MS,
+                };
+            })
+            .sort((a, b) => a.start - b.start || a.exam.id.localeCompare(b.exam.id));
+
+        const conflicts = [];
+        for (let i = 0; i < slots.length; i++) {
+            for (let j = i + 1; j < slots.length; j++) {
+                const a = slots[i];
+                const b = slots[j];
+                // Triés par début: b commence pendant a, ou au même instant que a.
+                // Sinon aucun examen suivant ne peut plus chevaucher a
+                if (b.start >= a.end && b.start !== a.start) {
+                    break;
+                }
+
+                conflicts.push({
+                    examA: a.exam.id,
+                    examB: b.exam.id,
+                    classA: a.exam.classId,
+                    classB: b.exam.classId,
+                    overlapStart: new Date(b.start).toISOString(),
+                    overlapEnd: new Date(Math.min(a.end, b.end)).toISOString(),
+                });
+            }
+        }
+
+        console.info(`✅ ${conflicts.length} exam conflicts for student ${studentId} (${slots.length} exams in ${classIds.length} classes)`);
+        console.info('============= END : DetectExamConflicts ===========');
+
+        return JSON.stringify(conflicts);
+    }
+
+    // ==================== FONCTIONS UTILITAIRES ====================
+
+    /**
+     * Récupère un examen et vérifie son type
+     * @private
+     * @throws {Error} Si l'examen n'existe pas
+     */
+    async _getExam(ctx, examId) {
+        const examAsBytes = await ctx.stub.getState(examId);
+        if (!examAsBytes || examAsBytes.length === 0) {
+            throw new Error(`Exam ${examId} does not exist`);
+        }
+
+        const exam = parseRecord(examAsBytes, examId, 'exam');
+
+        return exam;
+    }
+
+    /**
+     * Indique si l'appelant est assistant (ta) de la classe sans rôle donnant accès aux corrections
+     * (teacher responsable, co-teacher ou admin): ses accès aux corrections suivent ceux des étudiants
+     * @private
+     */
+    async _isTeachingAssistant(ctx, classId) {
+        if (!this._isSchoolMember(ctx)) {
+            return false;
+        }
+
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            return false;
+        }
+        const classData = parseRecord(classAsBytes, classId, 'class');
+
+        const caller = this._getCallerIdentity(ctx);
+        if ((classData.teacher || classData.createdBy) === caller) {
+            return false;
+        }
+        const roles = (classData.staff || []).filter((member) => member.identityId === caller).map((member) => member.role);
+        if (!roles.includes('ta') || roles.includes('co-teacher')) {
+            return false;
+        }
+        return await getCallerRole(ctx) !== 'admin';
+    }
+
+    /**
+     * Reçu de remise renvoyé aux clients: la clé en séquestre est masquée, l'empreinte verrouillée exposée
+     * @private
+     */
+    _toSubmissionReceipt(submission) {
+        const locked = getLockedContentHash(submission);
+        const receipt = Object.assign({}, submission, {
+            hasEncryptedKey: !!submission.encryptedKey,
+            lockedContentHash: locked.hash,
+            contentHashAlgorithm: locked.algorithm,
+        });
+        delete receipt.encryptedKey;
+        return receipt;
+    }
+
+    /**
+     * Exécute une requête CouchDB et retourne les enregistrements
+     * @private
+     */
+    async _collectQuery(ctx, queryString) {
+        const allResults = [];
+        const iterator = await ctx.stub.getQueryResult(queryString);
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                allResults.push(JSON.parse(strValue));
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
+    }
+
+    /**
+     * Parcourt tout le ledger et retourne les enregistrements acceptés par le filtre
+     * (fallback sans CouchDB)
+     * @private
+     */
+    async _collectByRange(ctx, filter) {
+        const allResults = [];
+        const iterator = await ctx.stub.getStateByRange('', '');
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if (filter(record)) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
     }
 }
 
 module.exports = ExamContract;
+module.exports.submissionKey = submissionKey;
+module.exports.getSubmissionRecord = getSubmissionRecord;
+module.exports.computeLatePenalty = computeLatePenalty;
+module.exports.getSubmissionClosesAt = getSubmissionClosesAt;
+module.exports.getExamMaxScore = getExamMaxScore;
+module.exports.getGradingScheme = getGradingScheme;
+module.exports.DEFAULT_MAX_SCORE = DEFAULT_MAX_SCORE;
+module.exports.POINTS_EPSILON = POINTS_EPSILON;
@@ -6,11 +6,68 @@
  * - Consultation: Étudiants voient UNIQUEMENT leurs propres notes
  * - Teachers voient toutes les notes
  * - Utilise CouchDB rich queries pour optimisation
+ * - Intégrité: chaîne de hachage des notes par examen (VerifyExamGradeChain)
+ * - Publication à double validation (classes releaseApprovalRequired):
+ *   RequestGradeRelease (teacher) puis ApproveGradeRelease (admin / department-head)
  */
 
 'use strict';
 
+const crypto = require('crypto');
 const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord, canonicalStringify, MAX_QUERY_RESULTS } = require('./records');
+const { writeAuditEntry } = require('./audit');
+const { getCallerRole, isTeachingStaff } = require('./role');
+const { createNotification } = require('./notification');
+const { getSystemConfig } = require('./config');
+const { outcomeKey, getEnrollmentRecord, getOutcomeRecord, getCreditHours } = require('./class');
+const { gradeChainKey, gradeLinkKey, getGradeChainHead, hashGradeContent, hashGradeLink, appendGradeLink } = require('./gradechain');
+const { generateDeterministicID } = require('./ids');
+const { getSubmissionRecord, computeLatePenalty, getSubmissionClosesAt, getExamMaxScore, getGradingScheme, DEFAULT_MAX_SCORE, POINTS_EPSILON } = require('./exam');
+
+// Tolérance sur la somme des coefficients d'une classe (ValidateClassWeights)
+const WEIGHT_SUM_EPSILON = 1e-6;
+
+// Rôles habilités à approuver une publication de notes
+const RELEASE_APPROVER_ROLES = ['admin', 'department-head'];
+
+// Fil de discussion d'une note: nombre maximal de commentaires et longueur d'un commentaire
+const MAX_GRADE_COMMENTS = 50;
+const MAX_GRADE_COMMENT_LENGTH = 1000;
+
+/*
+ * Barèmes de conversion enregistrés
+ * - Barèmes linéaires: conversion proportionnelle au pourcentage
+ * - Barèmes à table: seuils de pourcentage (du plus haut au plus bas) -> valeur
+ */
+const GRADE_SCALES = {
+    fr20: { label: 'French 0-20', max: 20 },
+    percent: { label: '0-100', max: 100 },
+    gpa4: {
+        label: '4.0 GPA',
+        max: 4,
+        table: [
+            { minPercent: 80, value: 4.0 },
+            { minPercent: 70, value: 3.5 },
+            { minPercent: 60, value: 3.0 },
+            { minPercent: 50, value: 2.0 },
+            { minPercent: 40, value: 1.0 },
+            { minPercent: 0, value: 0.0 },
+        ],
+    },
+};
+
+/*
+ * Barème des mentions (par défaut): seuil minimal en pourcentage -> lettre et points GPA
+ * Une classe peut définir son propre barème (classData.gradingScale, même format)
+ */
+const DEFAULT_LETTER_SCALE = [
+    { minPercent: 80, letter: 'A', points: 4.0 },
+    { minPercent: 70, letter: 'B', points: 3.0 },
+    { minPercent: 60, letter: 'C', points: 2.0 },
+    { minPercent: 50, letter: 'D', points: 1.0 },
+    { minPercent: 0, letter: 'F', points: 0.0 },
+];
 
 class GradeContract extends Contract {
 
@@ -43,6 +100,206 @@ class GradeContract extends Contract {
         return match ? match[1] : userID;
     }
 
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
+    /**
+     * Vérifie si l'appelant est administrateur de SchoolOrg
+     * (registre des rôles, attribut de certificat role=admin ou identité Admin@)
+     */
+    async _isAdmin(ctx) {
+        return await getCallerRole(ctx) === 'admin';
+    }
+
+    /**
+     * Date à partir de laquelle les notes d'un examen peuvent être publiées
+     * (examDate + gradeReleaseDelayHours de la configuration, null si pas d'embargo)
+     */
+    async _getReleaseTime(ctx, exam) {
+        const delayHours = (await getSystemConfig(ctx)).gradeReleaseDelayHours;
+        if (!delayHours) {
+            return null;
+        }
+        return new Date(new Date(exam.examDate).getTime() + delayHours * 60 * 60 * 1000);
+    }
+
+    /**
+     * Vérifie l'embargo de publication d'un examen
+     *
+     * Avant la fenêtre, seule une dérogation admin avec motif est acceptée;
+     * elle est alors tracée dans le journal d'audit
+     *
+     * @returns {Promise<boolean>} true si la publication passe par une dérogation
+     * @throws {Error} Si l'embargo n'est pas levé
+     */
+    async _checkReleaseWindow(ctx, exam, targetId, override, reason) {
+        const releaseTime = await this._getReleaseTime(ctx, exam);
+        const now = new Date(this._getTxTimestamp(ctx));
+
+        if (!releaseTime || now >= releaseTime) {
+            return false;
+        }
+
+        if (override !== 'true' && override !== true) {
+            throw new Error(`Grades for exam ${exam.id} are under embargo until ${releaseTime.toISOString()}`);
+        }
+        if (!(await this._isAdmin(ctx))) {
+            throw new Error('Access Denied: Only an admin can override the grade release embargo');
+        }
+        if (!reason || !reason.trim()) {
+            throw new Error('Missing reason: an embargo override requires a justification');
+        }
+
+        await writeAuditEntry(ctx, 'GradeReleaseOverride', targetId, reason, {
+            examId: exam.id,
+            releaseTime: releaseTime.toISOString(),
+        });
+        return true;
+    }
+
+    /**
+     * Refuse la publication directe si la classe exige une approbation
+     * @throws {Error} Si la classe de l'examen exige RequestGradeRelease / ApproveGradeRelease
+     */
+    async _checkReleaseApproval(ctx, exam) {
+        const classData = await this._getClass(ctx, exam.classId);
+        if (classData.releaseApprovalRequired === true) {
+            throw new Error(`Grades of class ${exam.classId} require release approval: use RequestGradeRelease for exam ${exam.id}`);
+        }
+    }
+
+    /**
+     * Échéance de saisie des notes d'un examen: examDate + gradingWindowDays de la classe
+     * @returns {string|null} Date ISO, null si la classe n'a pas de délai de saisie
+     */
+    _getGradingDeadline(exam, classData) {
+        if (!classData.gradingWindowDays) {
+            return null;
+        }
+        return new Date(new Date(exam.examDate).getTime() + classData.gradingWindowDays * 24 * 60 * 60 * 1000).toISOString();
+    }
+
+    /**
+     * État de la saisie des notes d'un examen
+     * closed: clôturée (CloseGrading) ou échéance stricte dépassée sans réouverture (ReopenGrading)
+     * late: échéance dépassée (saisie acceptée si non stricte ou rouverte)
+     * @returns {Promise<Object>} { open, reason, deadline, late, hoursLate, record }
+     */
+    async _getGradingState(ctx, exam) {
+        const classData = await this._getClass(ctx, exam.classId);
+        const deadline = this._getGradingDeadline(exam, classData);
+
+        const key = this._gradingKey(exam.id);
+        const gradingAsBytes = await ctx.stub.getState(key);
+        const record = gradingAsBytes && gradingAsBytes.length > 0 ? parseRecord(gradingAsBytes, key, 'gradingStatus') : null;
+
+        const msLate = deadline ? new Date(this._getTxTimestamp(ctx)) - new Date(deadline) : 0;
+        const state = {
+            open: true,
+            reason: null,
+            deadline: deadline,
+            late: msLate > 0,
+            hoursLate: msLate > 0 ? Math.round(msLate / 36000) / 100 : 0,
+            record: record,
+        };
+
+        if (record && record.status === 'reopened') {
+            return state;
+        }
+        if (record && record.status === 'closed') {
+            return Object.assign(state, { open: false, reason: `closed by ${record.updatedBy} on ${record.updatedAt}` });
+        }
+        if (state.late && classData.strictGradingDeadline !== false) {
+            return Object.assign(state, { open: false, reason: `grading deadline passed on ${deadline}` });
+        }
+        return state;
+    }
+
+    /**
+     * Vérifie que la saisie des notes de l'examen est ouverte
+     * @returns {Promise<Object>} État de la saisie (retard éventuel)
+     * @throws {Error} Si la saisie est close et n'a pas été rouverte
+     */
+    async _checkGradingOpen(ctx, exam) {
+        const state = await this._getGradingState(ctx, exam);
+        if (!state.open) {
+            throw new Error(`Grading is closed for exam ${exam.id} (${state.reason}): use ReopenGrading to add late grades`);
+        }
+        if (state.late) {
+            console.warn(`⚠️ Grade for exam ${exam.id} entered ${state.hoursLate}h after the grading deadline ${state.deadline}`);
+        }
+        return state;
+    }
+
+    /**
+     * Clé de l'état de saisie des notes d'un examen (clôture / réouverture)
+     */
+    _gradingKey(examId) {
+        return `GRADING_${examId}`;
+    }
+
+    /**
+     * Vérifie qu'une note n'est pas figée (FinalizeGrade)
+     * @throws {Error} Si la note est figée
+     */
+    _checkNotFinalized(grade) {
+        if (grade.finalized) {
+            throw new Error(`Grade ${grade.id} is finalized since ${grade.finalizedAt}: an admin must unfinalize it (UnfinalizeGrade) before any change`);
+        }
+    }
+
+    /**
+     * Clé du verrou de relecture des notes d'un examen (LockExamGrades)
+     */
+    _reviewLockKey(examId) {
+        return `GRADELOCK_${examId}`;
+    }
+
+    /**
+     * Verrou de relecture d'un examen (null si non verrouillé)
+     */
+    async _getReviewLock(ctx, examId) {
+        const key = this._reviewLockKey(examId);
+        const lockAsBytes = await ctx.stub.getState(key);
+        if (!lockAsBytes || lockAsBytes.length === 0) {
+            return null;
+        }
+        return parseRecord(lockAsBytes, key, 'gradeReviewLock');
+    }
+
+    /**
+     * Vérifie que l'appelant peut saisir les notes de l'examen (verrou de relecture)
+     * @throws {Error} Si l'examen est verrouillé par une autre identité
+     */
+    async _checkReviewLock(ctx, examId) {
+        const lock = await this._getReviewLock(ctx, examId);
+        if (lock && lock.lockedBy !== this._getCallerIdentity(ctx)) {
+            throw new Error(`Exam ${examId}: grades locked for review by ${lock.lockedBy} since ${lock.lockedAt}`);
+        }
+    }
+
+    /**
+     * Clé de la demande de publication des notes d'un examen
+     */
+    _releaseKey(examId) {
+        return `RELEASE_${examId}`;
+    }
+
+    /**
+     * Une note est visible par l'étudiant une fois publiée
+     * (les notes antérieures au circuit de brouillon sont considérées publiées)
+     * Une note provisoire n'est jamais visible, même marquée publiée
+     */
+    _isPublished(grade) {
+        return grade.isPublished !== false && grade.provisional !== true;
+    }
+
     /**
      * Vérifie si l'appelant peut accéder aux notes d'un étudiant
      *
@@ -107,12 +364,7 @@ class GradeContract extends Contract {
             throw new Error(`Class ${classId} does not exist`);
         }
 
-        const classData = JSON.parse(classAsBytes.toString());
-
-        // Vérifier que c'est bien une classe
-        if (classData.docType !== 'class') {
-            throw new Error(`${classId} is not a valid class`);
-        }
+        const classData = parseRecord(classAsBytes, classId, 'class');
 
         // Vérifier si l'étudiant est inscrit
         if (!classData.enrolledStudents.includes(studentId)) {
@@ -130,6 +382,8 @@ class GradeContract extends Contract {
      *
      * Accessible par: SchoolOrg uniquement (teachers)
      * Vérifie que l'étudiant est inscrit dans la classe de l'examen
+     * EMBARGO (gradeReleaseDelayHours > 0): publication uniquement après examDate + délai,
+     * sauf dérogation admin motivée
      *
      * @param {Context} ctx - Le contexte de transaction
      * @param {string} gradeId - ID unique de la note (ex: "grade-exam1-student1")
@@ -137,77 +391,310 @@ class GradeContract extends Contract {
      * @param {string} studentId - ID de l'étudiant (ex: "student1@students.academic.edu")
      * @param {number} score - Note obtenue (ex: 15.5)
      * @param {string} comment - Commentaire du professeur
+     * @param {string} [override] - "true" pour publier avant la fin de l'embargo (admin)
+     * @param {string} [reason] - Motif obligatoire de la dérogation
      * @returns {string} gradeId
      */
-    async PublishGrade(ctx, gradeId, examId, studentId, score, comment) {
+    async PublishGrade(ctx, gradeId, examId, studentId, score, comment, override, reason) {
         console.info('============= START : PublishGrade ===========');
 
         // CONTRÔLE D'ACCÈS: Seulement SchoolOrg peut publier des notes
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members (teachers) can publish grades');
         }
 
-        // Vérifier que l'examen existe
-        const examAsBytes = await ctx.stub.getState(examId);
-        if (!examAsBytes || examAsBytes.length === 0) {
-            throw new Error(`Exam ${examId} does not exist`);
+        const grade = await this._createGrade(ctx, gradeId, examId, studentId, score, comment, 'scored', true, override, reason);
+
+        // Émettre un événement
+        ctx.stub.setEvent('GradePublished', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
+            examId: examId,
+            studentId: studentId,
+            score: grade.score,
+            publishedBy: grade.publishedBy,
+        })));
+
+        console.info(`✅ Grade published: ${gradeId} for student ${studentId} by ${grade.publishedBy}`);
+        console.info('============= END : PublishGrade ===========');
+
+        return gradeId;
+    }
+
+    /**
+     * Saisir une note sans la publier (brouillon invisible pour l'étudiant)
+     *
+     * Accessible par: SchoolOrg uniquement (teachers)
+     * La publication se fait ensuite via PublishExamGrades
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID unique de la note
+     * @param {string} examId - ID de l'examen
+     * @param {string} studentId - ID de l'étudiant
+     * @param {number} score - Note obtenue ("pass" ou "fail" pour un examen en réussite/échec)
+     * @param {string} comment - Commentaire du professeur
+     * @param {string} [criteriaJSON] - Points par critère de la grille (ex: '{"c1":8,"c2":4.5}'),
+     *                                  leur somme doit valoir score
+     * @param {string} [provisional] - "true": note provisoire, non publiable avant ConfirmGrade
+     * @returns {string} gradeId
+     */
+    async SubmitGrade(ctx, gradeId, examId, studentId, score, comment, criteriaJSON, provisional) {
+        console.info('============= START : SubmitGrade ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can submit grades');
+        }
+        if (provisional !== undefined && provisional !== '' && provisional !== 'true' && provisional !== 'false') {
+            throw new Error(`Invalid provisional: ${provisional} (expected "true" or "false")`);
+        }
+
+        const grade = await this._createGrade(ctx, gradeId, examId, studentId, score, comment, 'scored', false,
+            undefined, undefined, criteriaJSON, provisional === 'true');
+
+        ctx.stub.setEvent('GradeSubmitted', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
+            examId: examId,
+            studentId: studentId,
+            submittedBy: grade.submittedBy,
+            lateGrading: grade.lateGrading,
+            provisional: grade.provisional,
+        })));
+
+        console.info(`✅ Grade submitted (unpublished${grade.provisional ? ', provisional' : ''}): ${gradeId} for student ${studentId}`);
+        console.info('============= END : SubmitGrade ===========');
+
+        return gradeId;
+    }
+
+    /**
+     * Confirmer une note provisoire (elle devient publiable)
+     *
+     * Accessible par: Teacher / co-teacher de la classe de l'examen + admins
+     * Refusé pour une note annulée ou figée, ou si l'examen est verrouillé par une autre identité.
+     * La confirmation est tracée dans le journal d'audit; la publication reste soumise aux contrôles habituels.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID de la note provisoire
+     * @returns {string} JSON de la note confirmée
+     */
+    async ConfirmGrade(ctx, gradeId) {
+        console.info('============= START : ConfirmGrade ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can confirm grades');
+        }
+
+        const gradeAsBytes = await ctx.stub.getState(gradeId);
+        if (!gradeAsBytes || gradeAsBytes.length === 0) {
+            throw new Error(`Grade ${gradeId} does not exist`);
         }
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
 
-        const exam = JSON.parse(examAsBytes.toString());
+        const exam = await this._getExam(ctx, grade.examId);
+        await this._checkExamOwner(ctx, exam);
 
-        // Vérifier que c'est bien un examen
-        if (exam.docType !== 'exam') {
-            throw new Error(`${examId} is not an exam`);
+        if (grade.provisional !== true) {
+            throw new Error(`Grade ${gradeId} is not provisional`);
+        }
+        if (grade.voided) {
+            throw new Error(`Cannot confirm grade ${gradeId}: it has been voided`);
         }
+        this._checkNotFinalized(grade);
+        await this._checkReviewLock(ctx, grade.examId);
 
-        // Vérifier que l'étudiant est inscrit dans la classe de l'examen
-        await this._checkEnrollment(ctx, exam.classId, studentId);
+        const caller = this._getCallerIdentity(ctx);
+        grade.provisional = false;
+        grade.confirmedBy = caller;
+        grade.confirmedAt = this._getTxTimestamp(ctx);
 
-        // Vérifier que la note n'existe pas déjà
-        const exists = await ctx.stub.getState(gradeId);
-        if (exists && exists.length > 0) {
-            throw new Error(`Grade ${gradeId} already exists. Use UpdateGrade to modify it.`);
+        await ctx.stub.putState(gradeId, serializeRecord(grade));
+
+        await writeAuditEntry(ctx, 'GradeConfirmed', gradeId, 'Provisional grade confirmed', {
+            examId: grade.examId,
+            studentId: grade.studentId,
+            score: grade.score,
+        });
+
+        ctx.stub.setEvent('GradeConfirmed', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
+            examId: grade.examId,
+            studentId: grade.studentId,
+            confirmedBy: caller,
+        })));
+
+        console.info(`✅ Grade ${gradeId} confirmed by ${caller}`);
+        console.info('============= END : ConfirmGrade ===========');
+
+        return JSON.stringify(grade);
+    }
+
+    /**
+     * Déclarer un étudiant absent à un examen (brouillon, score 0)
+     *
+     * Accessible par: SchoolOrg uniquement (teachers)
+     * Une absence se distingue d'un zéro dans les statistiques de l'examen
+     * Refusé si l'étudiant a remis une copie
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID unique de la note
+     * @param {string} examId - ID de l'examen
+     * @param {string} studentId - ID de l'étudiant
+     * @param {string} comment - Commentaire du professeur
+     * @returns {string} gradeId
+     */
+    async MarkAbsent(ctx, gradeId, examId, studentId, comment) {
+        console.info('============= START : MarkAbsent ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can mark absences');
         }
 
-        // Valider le score
-        const scoreNum = parseFloat(score);
-        if (isNaN(scoreNum) || scoreNum < 0) {
-            throw new Error('Invalid score: must be a positive number');
+        if (await this._getSubmission(ctx, examId, studentId)) {
+            throw new Error(`Cannot mark ${studentId} absent: a copy was submitted for exam ${examId}`);
         }
 
-        // Récupérer l'identité du professeur
-        const publishedBy = this._getCallerIdentity(ctx);
-        const publishedAt = new Date().toISOString();
+        const grade = await this._createGrade(ctx, gradeId, examId, studentId, '0', comment, 'absent', false);
 
-        // Créer l'objet note
-        const grade = {
-            docType: 'grade',
-            id: gradeId,
+        ctx.stub.setEvent('GradeSubmitted', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
             examId: examId,
-            classId: exam.classId, // Stocker classId pour requêtes optimisées
             studentId: studentId,
-            score: scoreNum,
-            comment: comment || '',
+            status: grade.status,
+            submittedBy: grade.submittedBy,
+        })));
+
+        console.info(`✅ Absence recorded (unpublished): ${gradeId} for student ${studentId}`);
+        console.info('============= END : MarkAbsent ===========');
+
+        return gradeId;
+    }
+
+    /**
+     * Publier toutes les notes en attente d'un examen
+     *
+     * Accessible par: Teacher / co-teacher de la classe de l'examen + admins
+     * EMBARGO (gradeReleaseDelayHours > 0): avant examDate + délai, force="true" + motif + admin requis (audité)
+     * Les notes provisoires restent non publiées (provisional) jusqu'à ConfirmGrade
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} [force] - "true" pour publier avant la fin de l'embargo (admin)
+     * @param {string} [reason] - Motif obligatoire de la dérogation
+     * @returns {string} JSON récapitulatif
+     */
+    async PublishExamGrades(ctx, examId, force, reason) {
+        console.info('============= START : PublishExamGrades ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can publish grades');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+        await this._checkReleaseApproval(ctx, exam);
+        const overridden = await this._checkReleaseWindow(ctx, exam, examId, force, reason);
+
+        const publishedBy = this._getCallerIdentity(ctx);
+        const publishedAt = this._getTxTimestamp(ctx);
+        const published = await this._publishDrafts(ctx, examId, publishedBy, publishedAt);
+        const provisional = (await this._getExamGradeRecords(ctx, examId))
+            .filter((grade) => grade.provisional === true && !grade.voided)
+            .map((grade) => grade.id);
+
+        ctx.stub.setEvent('ExamGradesPublished', Buffer.from(JSON.stringify({
+            examId: examId,
+            count: published.length,
             publishedBy: publishedBy,
-            publishedAt: publishedAt,
-        };
+            override: overridden,
+        })));
 
-        // Stocker dans le ledger
-        await ctx.stub.putState(gradeId, Buffer.from(JSON.stringify(grade)));
+        console.info(`✅ ${published.length} grades published for exam ${examId} by ${publishedBy}`);
+        console.info('============= END : PublishExamGrades ===========');
 
-        // Émettre un événement
-        ctx.stub.setEvent('GradePublished', Buffer.from(JSON.stringify({
-            gradeId: gradeId,
+        return JSON.stringify({
+            success: true,
             examId: examId,
-            studentId: studentId,
-            score: scoreNum,
+            published: published,
+            count: published.length,
+            provisional: provisional,
+            override: overridden,
+        });
+    }
+
+    /**
+     * Publier les notes de plusieurs examens en une transaction (fin de semestre)
+     *
+     * Accessible par: Teacher / co-teacher de la classe de chaque examen + admins
+     * EMBARGO: les examens encore sous embargo sont ignorés (avec motif),
+     * sans dérogation possible: utiliser PublishExamGrades pour forcer un examen
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examIdsJSON - JSON array des IDs d'examens (ex: '["exam1","exam2"]')
+     * @returns {string} JSON { published: [{ examId, count }], skipped: [{ examId, reason }] }
+     */
+    async PublishExamsBatch(ctx, examIdsJSON) {
+        console.info('============= START : PublishExamsBatch ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can publish grades');
+        }
+
+        let examIds;
+        try {
+            examIds = JSON.parse(examIdsJSON);
+        } catch (err) {
+            throw new Error('Invalid examIdsJSON: must be a JSON array of exam IDs');
+        }
+        if (!Array.isArray(examIds) || examIds.length === 0 || !examIds.every((id) => typeof id === 'string' && id)) {
+            throw new Error('Invalid examIdsJSON: must be a non-empty JSON array of exam IDs');
+        }
+        if (new Set(examIds).size !== examIds.length) {
+            throw new Error('Invalid examIdsJSON: duplicate exam IDs');
+        }
+
+        // Vérifier l'existence et les droits sur tous les examens avant toute écriture
+        const exams = [];
+        for (const examId of examIds) {
+            const exam = await this._getExam(ctx, examId);
+            await this._checkExamOwner(ctx, exam);
+            exams.push(exam);
+        }
+
+        const publishedBy = this._getCallerIdentity(ctx);
+        const publishedAt = this._getTxTimestamp(ctx);
+        const now = new Date(publishedAt);
+        const published = [];
+        const skipped = [];
+
+        for (const exam of exams) {
+            const releaseTime = await this._getReleaseTime(ctx, exam);
+            if (releaseTime && now < releaseTime) {
+                skipped.push({ examId: exam.id, reason: `Under embargo until ${releaseTime.toISOString()}` });
+                continue;
+            }
+            if ((await this._getClass(ctx, exam.classId)).releaseApprovalRequired === true) {
+                skipped.push({ examId: exam.id, reason: 'Release approval required (RequestGradeRelease)' });
+                continue;
+            }
+
+            const gradeIds = await this._publishDrafts(ctx, exam.id, publishedBy, publishedAt);
+            published.push({ examId: exam.id, count: gradeIds.length });
+        }
+
+        ctx.stub.setEvent('ExamsBatchPublished', Buffer.from(JSON.stringify({
+            published: published,
+            skipped: skipped.map((entry) => entry.examId),
             publishedBy: publishedBy,
         })));
 
-        console.info(`✅ Grade published: ${gradeId} for student ${studentId} by ${publishedBy}`);
-        console.info('============= END : PublishGrade ===========');
+        console.info(`✅ Batch publish by ${publishedBy}: ${published.length} exams published, ${skipped.length} skipped`);
+        console.info('============= END : PublishExamsBatch ===========');
 
-        return gradeId;
+        return JSON.stringify({
+            success: true,
+            published: published,
+            skipped: skipped,
+            count: published.reduce((sum, entry) => sum + entry.count, 0),
+        });
     }
 
     /**
@@ -269,10 +756,16 @@ class GradeContract extends Contract {
                 try {
                     record = JSON.parse(strValue);
 
+                    // Étudiants: notes publiées uniquement
+                    if (!isTeacher && !this._isPublished(record)) {
+                        result = await iterator.next();
+                        continue;
+                    }
+
                     // Pour chaque note, récupérer les détails de l'examen
                     const examAsBytes = await ctx.stub.getState(record.examId);
                     if (examAsBytes && examAsBytes.length > 0) {
-                        const exam = JSON.parse(examAsBytes.toString());
+                        const exam = parseRecord(examAsBytes, record.examId, 'exam');
 
                         allResults.push({
                             id: record.id,
@@ -334,6 +827,7 @@ class GradeContract extends Contract {
 
         // CONTRÔLE D'ACCÈS: Vérifier si l'appelant peut accéder aux notes de cet étudiant
         this._canAccessGrade(ctx, studentId);
+        const isTeacher = this._isSchoolMember(ctx);
 
         // Vérifier que la classe existe
         const classAsBytes = await ctx.stub.getState(classId);
@@ -363,10 +857,16 @@ class GradeContract extends Contract {
                 try {
                     record = JSON.parse(strValue);
 
+                    // Étudiants: notes publiées uniquement
+                    if (!isTeacher && !this._isPublished(record)) {
+                        result = await iterator.next();
+                        continue;
+                    }
+
                     // Récupérer les détails de l'examen
                     const examAsBytes = await ctx.stub.getState(record.examId);
                     if (examAsBytes && examAsBytes.length > 0) {
-                        const exam = JSON.parse(examAsBytes.toString());
+                        const exam = parseRecord(examAsBytes, record.examId, 'exam');
 
                         allResults.push({
                             id: record.id,
@@ -405,10 +905,11 @@ class GradeContract extends Contract {
      * 4. Obtenir toutes les notes d'une classe
      *
      * Accessible par: Teachers uniquement
+     * Au plus MAX_QUERY_RESULTS notes: au-delà, truncated=true
      *
      * @param {Context} ctx - Le contexte de transaction
      * @param {string} classId - ID de la classe
-     * @returns {string} JSON array de toutes les notes de la classe
+     * @returns {string} JSON { grades, count, truncated }
      */
     async GetClassGrades(ctx, classId) {
         console.info('============= START : GetClassGrades ===========');
@@ -436,6 +937,7 @@ class GradeContract extends Contract {
         });
 
         const allResults = [];
+        let truncated = false;
 
         try {
             // Utiliser CouchDB rich query avec tri
@@ -452,7 +954,11 @@ class GradeContract extends Contract {
                     // Récupérer les détails de l'examen
                     const examAsBytes = await ctx.stub.getState(record.examId);
                     if (examAsBytes && examAsBytes.length > 0) {
-                        const exam = JSON.parse(examAsBytes.toString());
+                        const exam = parseRecord(examAsBytes, record.examId, 'exam');
+                        if (allResults.length === MAX_QUERY_RESULTS) {
+                            truncated = true;
+                            break;
+                        }
 
                         allResults.push({
                             id: record.id,
@@ -482,10 +988,2637 @@ class GradeContract extends Contract {
         }
 
         const callerId = this._getCallerIdentity(ctx);
+        if (truncated) {
+            console.warn(`GetClassGrades truncated after ${allResults.length} grades for class ${classId}`);
+        }
         console.info(`✅ Retrieved ${allResults.length} grades for class ${classId} by ${callerId}`);
         console.info('============= END : GetClassGrades ===========');
 
-        return JSON.stringify(allResults);
+        return JSON.stringify({ grades: allResults, count: allResults.length, truncated: truncated });
+    }
+
+    /**
+     * 5. Convertir une note dans un autre barème
+     *
+     * Lecture seule: la note stockée n'est jamais modifiée
+     * Accessible par: Teacher + Étudiant concerné
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID de la note
+     * @param {string} targetScale - Barème cible (fr20, percent, gpa4)
+     * @returns {string} JSON de la note convertie
+     */
+    async ConvertGrade(ctx, gradeId, targetScale) {
+        console.info('============= START : ConvertGrade ===========');
+
+        const scale = GRADE_SCALES[targetScale];
+        if (!scale) {
+            throw new Error(`Invalid targetScale: ${targetScale} (known scales: ${Object.keys(GRADE_SCALES).join(', ')})`);
+        }
+
+        const gradeAsBytes = await ctx.stub.getState(gradeId);
+        if (!gradeAsBytes || gradeAsBytes.length === 0) {
+            throw new Error(`Grade ${gradeId} does not exist`);
+        }
+
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+
+        // Vérifier l'accès
+        this._canAccessGrade(ctx, grade.studentId);
+
+        if (this._isStudentMember(ctx) && !this._isPublished(grade)) {
+            throw new Error('Grade not yet published by the teacher');
+        }
+
+        if (this._isPassFailGrade(grade)) {
+            throw new Error(`Grade ${gradeId} is a pass/fail result: it has no score to convert`);
+        }
+
+        const maxScore = grade.maxScore || DEFAULT_MAX_SCORE;
+        const percentage = (grade.score / maxScore) * 100;
+
+        let converted;
+        if (scale.table) {
+            converted = scale.table.find((row) => percentage >= row.minPercent).value;
+        } else {
+            converted = Math.round((percentage / 100) * scale.max * 100) / 100;
+        }
+
+        console.info(`✅ Grade ${gradeId} converted to ${targetScale}`);
+        console.info('============= END : ConvertGrade ===========');
+
+        return JSON.stringify({
+            gradeId: gradeId,
+            score: grade.score,
+            maxScore: maxScore,
+            percentage: Math.round(percentage * 100) / 100,
+            targetScale: targetScale,
+            convertedScore: converted,
+            convertedMax: scale.max,
+        });
+    }
+
+    /**
+     * 6. Calculer la note finale d'un étudiant dans une classe
+     *
+     * Moyenne pondérée (coefficients des examens) des pourcentages obtenus,
+     * convertie en mention et points GPA selon le barème de la classe
+     * Les étudiants ne voient que le calcul sur leurs notes publiées
+     *
+     * Accessible par: Teacher + Étudiant concerné
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @param {string} studentId - ID de l'étudiant
+     * @returns {string} JSON de la note finale
+     */
+    async ComputeFinalGrade(ctx, classId, studentId) {
+        console.info('============= START : ComputeFinalGrade ===========');
+
+        this._canAccessGrade(ctx, studentId);
+
+        const classData = await this._getClass(ctx, classId);
+        const finalGrade = await this._computeFinalGrade(ctx, classData, studentId, this._isSchoolMember(ctx));
+
+        console.info(`✅ Final grade computed for ${studentId} in ${classId}`);
+        console.info('============= END : ComputeFinalGrade ===========');
+
+        return JSON.stringify(finalGrade);
+    }
+
+    /**
+     * 7. Moyenne GPA d'un étudiant sur un semestre
+     *
+     * Seules comptent les classes du semestre dont toutes les notes
+     * de l'étudiant sont publiées (une note publiée par examen).
+     * Chaque classe est pondérée par ses crédits (creditHours).
+     *
+     * Accessible par: Teacher + Étudiant concerné
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} studentId - ID de l'étudiant
+     * @param {string} semester - Semestre (ex: "2026-S1")
+     * @returns {string} JSON { gpa, totalCreditHours, classes: [...] }
+     */
+    async GetStudentSemesterGPA(ctx, studentId, semester) {
+        console.info('============= START : GetStudentSemesterGPA ===========');
+
+        this._canAccessGrade(ctx, studentId);
+
+        const classes = await this._queryRecords(ctx, { docType: 'class', semester: semester });
+        const contributing = [];
+
+        for (const classData of classes) {
+            if (!classData.enrolledStudents.includes(studentId)) {
+                continue;
+            }
+
+            const finalGrade = await this._computeFinalGrade(ctx, classData, studentId, false);
+            if (!finalGrade.complete) {
+                continue;
+            }
+
+            contributing.push({
+                classId: classData.id,
+                className: classData.name,
+                percentage: finalGrade.percentage,
+                letterGrade: finalGrade.letterGrade,
+                gpaPoints: finalGrade.gpaPoints,
+                creditHours: getCreditHours(classData),
+            });
+        }
+
+        const totalCreditHours = contributing.reduce((sum, c) => sum + c.creditHours, 0);
+        const gpa = contributing.length > 0
+            ? Math.round((contributing.reduce((sum, c) => sum + c.gpaPoints * c.creditHours, 0) / totalCreditHours) * 100) / 100
+            : null;
+
+        console.info(`✅ Semester ${semester} GPA for ${studentId}: ${gpa}`);
+        console.info('============= END : GetStudentSemesterGPA ===========');
+
+        return JSON.stringify({
+            studentId: studentId,
+            semester: semester,
+            gpa: gpa,
+            totalCreditHours: totalCreditHours,
+            classes: contributing,
+        });
+    }
+
+    /**
+     * 8. Vérifier la chaîne de hachage des notes d'un examen
+     *
+     * Chaque création, modification ou suppression de note ajoute un maillon
+     * (hash du maillon précédent + hash du contenu de la note). La vérification
+     * recalcule la chaîne et compare chaque note stockée à son dernier maillon:
+     * toute modification hors contrat (édition directe du world state) est signalée.
+     *
+     * Accessible par: SchoolOrg uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON { valid, length, breaks: [{ index, gradeId, reason }] }
+     */
+    async VerifyExamGradeChain(ctx, examId) {
+        console.info('============= START : VerifyExamGradeChain ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can verify grade chains');
+        }
+
+        await this._getExam(ctx, examId);
+
+        const head = await getGradeChainHead(ctx, examId);
+        const breaks = [];
+        const latestLinks = new Map();
+        let prevHash = '';
+
+        // 1. Intégrité des maillons: chaque maillon référence le hash du précédent
+        for (let index = 0; index < head.length; index++) {
+            const linkKey = gradeLinkKey(examId, index);
+            const linkAsBytes = await ctx.stub.getState(linkKey);
+            if (!linkAsBytes || linkAsBytes.length === 0) {
+                breaks.push({ index: index, gradeId: null, reason: 'Missing chain link' });
+                prevHash = null;
+                continue;
+            }
+
+            const link = parseRecord(linkAsBytes, linkKey, 'gradeLink');
+            if (prevHash !== null && link.prevHash !== prevHash) {
+                breaks.push({ index: index, gradeId: link.gradeId, reason: 'Previous hash mismatch' });
+            }
+            if (hashGradeLink(link) !== link.hash) {
+                breaks.push({ index: index, gradeId: link.gradeId, reason: 'Link hash mismatch' });
+            }
+
+            latestLinks.set(link.gradeId, link);
+            prevHash = link.hash;
+        }
+
+        if (prevHash !== null && head.length > 0 && prevHash !== head.lastHash) {
+            breaks.push({ index: head.length - 1, gradeId: null, reason: 'Chain head mismatch' });
+        }
+
+        // 2. Notes stockées: leur contenu doit correspondre à leur dernier maillon
+        const grades = await this._getExamGradeRecords(ctx, examId);
+        for (const grade of grades) {
+            const link = latestLinks.get(grade.id);
+            if (!link || link.action === 'delete') {
+                breaks.push({ index: grade.chainIndex === undefined ? null : grade.chainIndex, gradeId: grade.id, reason: 'Grade not recorded in chain' });
+            } else if (hashGradeContent(grade, link.contentVersion || 1) !== link.contentHash || grade.hash !== link.hash) {
+                breaks.push({ index: link.index, gradeId: grade.id, reason: 'Grade content does not match its chain link' });
+            }
+        }
+
+        // 3. Notes supprimées hors contrat
+        const storedIds = new Set(grades.map((grade) => grade.id));
+        for (const link of latestLinks.values()) {
+            if (link.action !== 'delete' && !storedIds.has(link.gradeId)) {
+                breaks.push({ index: link.index, gradeId: link.gradeId, reason: 'Chained grade is missing from the ledger' });
+            }
+        }
+
+        const valid = breaks.length === 0;
+
+        console.info(`✅ Grade chain of ${examId}: ${valid ? 'valid' : `${breaks.length} break(s)`}`);
+        console.info('============= END : VerifyExamGradeChain ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            valid: valid,
+            length: head.length,
+            breaks: breaks,
+        });
+    }
+
+    /**
+     * 9. Statistiques d'un examen
+     *
+     * Toutes les notes sont exprimées sur la note maximale de l'examen (maxScore)
+     * Distingue les absents (statut absent) des zéros obtenus:
+     * - withoutAbsences: statistiques sur les seules copies notées
+     * - absencesAsZero: les absences comptent comme des zéros
+     * participationRate = notés / (notés + absents)
+     * Une seule note par étudiant: la plus récente
+     * Réussite/échec (gradingScheme "passfail"): aucune statistique numérique,
+     * passFail = { passedCount, failedCount, passRate } (null pour les autres examens)
+     *
+     * Accessible par: SchoolOrg uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON des statistiques
+     */
+    async GetExamStatistics(ctx, examId) {
+        console.info('============= START : GetExamStatistics ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view exam statistics');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        const maxScore = getExamMaxScore(exam);
+
+        const latestByStudent = new Map();
+        const grades = (await this._getExamGradeRecords(ctx, examId))
+            .sort((a, b) => (a.submittedAt || '').localeCompare(b.submittedAt || ''));
+        for (const grade of grades) {
+            latestByStudent.set(grade.studentId, grade);
+        }
+
+        const latest = Array.from(latestByStudent.values());
+        const present = latest.filter((grade) => !this._isAbsent(grade));
+        const absentCount = latest.length - present.length;
+        const participationRate = latest.length > 0
+            ? Math.round((present.length / latest.length) * 10000) / 100
+            : null;
+
+        // Réussite/échec: résultats comptés à part, jamais mêlés aux scores
+        const passFail = getGradingScheme(exam) === 'passfail';
+        const passedCount = present.filter((grade) => grade.result === 'pass').length;
+        // Dénominateur unique: les notes antérieures sur un autre barème sont ramenées à celui de l'examen
+        const scored = present.filter((grade) => !this._isPassFailGrade(grade))
+            .map((grade) => (grade.maxScore && grade.maxScore !== maxScore ? (grade.score / grade.maxScore) * maxScore : grade.score));
+
+        console.info(`✅ Statistics for ${examId}: ${present.length} scored, ${absentCount} absent`);
+        console.info('============= END : GetExamStatistics ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            gradingScheme: getGradingScheme(exam),
+            maxScore: passFail ? null : maxScore,
+            gradedCount: latest.length,
+            scoredCount: present.length,
+            absentCount: absentCount,
+            participationRate: participationRate,
+            withoutAbsences: this._computeStatistics(scored),
+            absencesAsZero: this._computeStatistics(passFail ? scored : scored.concat(new Array(absentCount).fill(0))),
+            passFail: passFail ? {
+                passedCount: passedCount,
+                failedCount: present.length - passedCount,
+                passRate: present.length > 0 ? Math.round((passedCount / present.length) * 10000) / 100 : null,
+            } : null,
+        });
+    }
+
+    /**
+     * 10. Accuser réception d'une note
+     *
+     * Accessible par: L'étudiant concerné uniquement, sur une note publiée
+     * Enregistre acknowledged + acknowledgedAt (timestamp de la transaction)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID de la note
+     * @returns {string} JSON { gradeId, acknowledgedAt }
+     */
+    async AcknowledgeGrade(ctx, gradeId) {
+        console.info('============= START : AcknowledgeGrade ===========');
+
+        if (!this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: Only students can acknowledge grades');
+        }
+
+        const gradeAsBytes = await ctx.stub.getState(gradeId);
+        if (!gradeAsBytes || gradeAsBytes.length === 0) {
+            throw new Error(`Grade ${gradeId} does not exist`);
+        }
+
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+
+        const caller = this._getCallerIdentity(ctx);
+        if (grade.studentId !== caller) {
+            throw new Error('Access Denied: You can only acknowledge your own grades');
+        }
+        if (!this._isPublished(grade)) {
+            throw new Error('Grade not yet published by the teacher');
+        }
+        if (grade.acknowledged) {
+            throw new Error(`Grade ${gradeId} was already acknowledged on ${grade.acknowledgedAt}`);
+        }
+
+        grade.acknowledged = true;
+        grade.acknowledgedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(gradeId, serializeRecord(grade));
+
+        ctx.stub.setEvent('GradeAcknowledged', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
+            examId: grade.examId,
+            studentId: caller,
+            acknowledgedAt: grade.acknowledgedAt,
+        })));
+
+        console.info(`✅ Grade ${gradeId} acknowledged by ${caller}`);
+        console.info('============= END : AcknowledgeGrade ===========');
+
+        return JSON.stringify({
+            gradeId: gradeId,
+            acknowledged: true,
+            acknowledgedAt: grade.acknowledgedAt,
+        });
+    }
+
+    /**
+     * 11. Notes publiées d'un examen dont l'étudiant n'a pas accusé réception
+     *
+     * Accessible par: SchoolOrg uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON { examId, publishedCount, unacknowledged: [...] }
+     */
+    async GetUnacknowledgedGrades(ctx, examId) {
+        console.info('============= START : GetUnacknowledgedGrades ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view acknowledgment reports');
+        }
+
+        await this._getExam(ctx, examId);
+
+        const published = (await this._getExamGradeRecords(ctx, examId))
+            .filter((grade) => this._isPublished(grade));
+        const unacknowledged = published
+            .filter((grade) => !grade.acknowledged)
+            .map((grade) => ({
+                gradeId: grade.id,
+                studentId: grade.studentId,
+                publishedAt: grade.publishedAt || null,
+            }))
+            .sort((a, b) => a.studentId.localeCompare(b.studentId));
+
+        console.info(`✅ ${unacknowledged.length}/${published.length} published grades unacknowledged for ${examId}`);
+        console.info('============= END : GetUnacknowledgedGrades ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            publishedCount: published.length,
+            unacknowledgedCount: unacknowledged.length,
+            unacknowledged: unacknowledged,
+        });
+    }
+
+    /**
+     * 12. Vérifier la configuration des coefficients d'une classe
+     *
+     * Valide si chaque examen a un coefficient et si leur somme vaut 1 (à epsilon près).
+     * Sinon ComputeFinalGrade normalise les coefficients, ou pondère à égalité
+     * si un coefficient manque: le rapport permet de le détecter avant de s'y fier.
+     *
+     * Accessible par: SchoolOrg uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @returns {string} JSON { sum, isValid, exams: [{ examId, title, weight }], missingWeights }
+     */
+    async ValidateClassWeights(ctx, classId) {
+        console.info('============= START : ValidateClassWeights ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can validate exam weights');
+        }
+
+        await this._getClass(ctx, classId);
+
+        const exams = (await this._queryRecords(ctx, { docType: 'exam', classId: classId }))
+            .sort((a, b) => a.id.localeCompare(b.id));
+
+        const weights = exams.map((exam) => ({
+            examId: exam.id,
+            title: exam.title,
+            weight: typeof exam.weight === 'number' ? exam.weight : null,
+        }));
+        const missingWeights = weights.filter((entry) => entry.weight === null).map((entry) => entry.examId);
+        const sum = Math.round(weights.reduce((total, entry) => total + (entry.weight || 0), 0) * 1e9) / 1e9;
+        const isValid = exams.length > 0 && missingWeights.length === 0 && Math.abs(sum - 1) <= WEIGHT_SUM_EPSILON;
+
+        console.info(`✅ Weights of ${classId}: sum=${sum}, valid=${isValid}`);
+        console.info('============= END : ValidateClassWeights ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            sum: sum,
+            isValid: isValid,
+            epsilon: WEIGHT_SUM_EPSILON,
+            exams: weights,
+            missingWeights: missingWeights,
+        });
+    }
+
+    /**
+     * 13. Avancement de la saisie des notes d'un examen
+     *
+     * Série temporelle construite sur l'historique (GetHistoryForKey) de la tête de
+     * chaîne de l'examen: un point par timestamp de transaction distinct, avec le
+     * nombre de notes existantes après cette transaction.
+     * Les têtes antérieures au compteur gradeCount utilisent la longueur de la chaîne.
+     *
+     * Accessible par: SchoolOrg uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON { examId, gradeCount, series: [{ timestamp, gradeCount, txIds, delta }] }
+     */
+    async GetExamGradingProgress(ctx, examId) {
+        console.info('============= START : GetExamGradingProgress ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view grading progress');
+        }
+
+        await this._getExam(ctx, examId);
+
+        const iterator = await ctx.stub.getHistoryForKey(gradeChainKey(examId));
+        const versions = [];
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const modification = result.value;
+            if (!modification.isDelete) {
+                try {
+                    const head = JSON.parse(Buffer.from(modification.value.toString()).toString('utf8'));
+                    const seconds = modification.timestamp.seconds.low || modification.timestamp.seconds;
+                    versions.push({
+                        timestamp: new Date(seconds * 1000).toISOString(),
+                        txId: modification.txId,
+                        gradeCount: typeof head.gradeCount === 'number' ? head.gradeCount : head.length,
+                    });
+                } catch (err) {
+                    console.log('Error parsing record:', err);
+                }
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        // Un point par timestamp distinct (l'état retenu est le dernier de ce timestamp)
+        versions.sort((a, b) => a.timestamp.localeCompare(b.timestamp));
+        const series = [];
+        let previousCount = 0;
+        for (const version of versions) {
+            const last = series[series.length - 1];
+            if (last && last.timestamp === version.timestamp) {
+                last.gradeCount = version.gradeCount;
+                last.txIds.push(version.txId);
+            } else {
+                series.push({ timestamp: version.timestamp, gradeCount: version.gradeCount, txIds: [version.txId] });
+            }
+        }
+        for (const point of series) {
+            point.delta = point.gradeCount - previousCount; // Négatif si des notes ont été supprimées
+            previousCount = point.gradeCount;
+        }
+
+        console.info(`✅ Grading progress of ${examId}: ${series.length} points, ${previousCount} grades`);
+        console.info('============= END : GetExamGradingProgress ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            gradeCount: previousCount,
+            series: series,
+        });
+    }
+
+    /**
+     * 14. Importer les notes d'un examen depuis un CSV
+     *
+     * Une ligne par note: studentId,score,maxScore (ligne d'en-tête facultative)
+     * Chaque ligne est validée (inscription, bornes, maxScore de l'examen, doublons);
+     * les lignes valides sont saisies en brouillon dans cette transaction, sous une clé
+     * déterministe dérivée de l'examen et de l'étudiant (generateDeterministicID),
+     * les autres sont rejetées avec leur numéro de ligne.
+     * Un étudiant ayant déjà une note pour l'examen (quelle que soit sa clé) est rejeté.
+     *
+     * Accessible par: Teachers de la classe de l'examen ou admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} csvData - Contenu CSV
+     * @returns {string} JSON { examId, imported, failed, results: [{ line, studentId, gradeId, status, error }] }
+     */
+    async ImportExamGradesCSV(ctx, examId, csvData) {
+        console.info('============= START : ImportExamGradesCSV ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can import grades');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+
+        if (getGradingScheme(exam) === 'passfail') {
+            throw new Error(`Exam ${examId} is graded pass/fail: CSV import only accepts scores`);
+        }
+
+        if (!csvData || !csvData.trim()) {
+            throw new Error('Invalid csvData: must contain at least one row');
+        }
+
+        const classData = await this._getClass(ctx, exam.classId);
+        const maxScore = getExamMaxScore(exam);
+        const seen = new Set();
+        const results = [];
+
+        // Notes déjà saisies (clé déterministe, ancienne clé ou gradeId libre)
+        const existingGrades = new Map((await this._getExamGradeRecords(ctx, examId))
+            .map((grade) => [grade.studentId, grade.id]));
+
+        const lines = csvData.split(/\r?\n/);
+        for (let index = 0; index < lines.length; index++) {
+            const line = index + 1;
+            if (!lines[index].trim()) {
+                continue;
+            }
+
+            const columns = lines[index].split(',').map((column) => column.trim().replace(/^"(.*)"$/, '$1'));
+            if (index === 0 && columns[0].toLowerCase() === 'studentid') {
+                continue; // En-tête
+            }
+
+            const studentId = columns[0] || null;
+            const gradeId = studentId ? this._gradeKey(examId, studentId) : null;
+            const rowResult = { line: line, studentId: studentId, gradeId: gradeId, status: 'imported', error: null };
+
+            try {
+                if (columns.length !== 3) {
+                    throw new Error(`expected 3 columns (studentId,score,maxScore), got ${columns.length}`);
+                }
+                if (!studentId) {
+                    throw new Error('missing studentId');
+                }
+                if (seen.has(studentId)) {
+                    throw new Error(`duplicate row for student ${studentId}`);
+                }
+                if (existingGrades.has(studentId)) {
+                    throw new Error(`student ${studentId} already has grade ${existingGrades.get(studentId)} for exam ${examId}`);
+                }
+
+                if (!classData.enrolledStudents.includes(studentId)) {
+                    throw new Error(`student ${studentId} is not enrolled in class ${exam.classId}`);
+                }
+                if (columns[2] === '' || parseFloat(columns[2]) !== maxScore) {
+                    throw new Error(`maxScore ${columns[2]} does not match the maxScore ${maxScore} of exam ${examId}`);
+                }
+                if (columns[1] === '' || isNaN(Number(columns[1]))) {
+                    throw new Error(`invalid score "${columns[1]}"`);
+                }
+
+                await this._createGrade(ctx, gradeId, examId, studentId, columns[1], '', 'scored', false);
+                seen.add(studentId);
+            } catch (err) {
+                rowResult.status = 'error';
+                rowResult.error = `Line ${line}: ${err.message}`;
+            }
+
+            results.push(rowResult);
+        }
+
+        const imported = results.filter((row) => row.status === 'imported').length;
+        const failed = results.length - imported;
+        const caller = this._getCallerIdentity(ctx);
+
+        ctx.stub.setEvent('ExamGradesImported', Buffer.from(JSON.stringify({
+            examId: examId,
+            imported: imported,
+            failed: failed,
+            importedBy: caller,
+        })));
+
+        console.info(`✅ CSV import for ${examId}: ${imported} imported, ${failed} rejected by ${caller}`);
+        console.info('============= END : ImportExamGradesCSV ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            imported: imported,
+            failed: failed,
+            results: results,
+        });
+    }
+
+    /**
+     * 15. Demander la publication des notes d'un examen
+     *
+     * Accessible par: Teachers de la classe de l'examen ou admin
+     * Première étape de la double validation: la publication effective
+     * est faite par ApproveGradeRelease, par une autre identité
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON de la demande
+     */
+    async RequestGradeRelease(ctx, examId) {
+        console.info('============= START : RequestGradeRelease ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can request a grade release');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+
+        const key = this._releaseKey(examId);
+        const releaseAsBytes = await ctx.stub.getState(key);
+        if (releaseAsBytes && releaseAsBytes.length > 0 && parseRecord(releaseAsBytes, key, 'gradeRelease').status === 'pending') {
+            throw new Error(`A grade release is already pending for exam ${examId}`);
+        }
+
+        const drafts = (await this._getExamGradeRecords(ctx, examId))
+            .filter((grade) => !this._isPublished(grade) && !grade.voided);
+        if (drafts.length === 0) {
+            throw new Error(`No unpublished grades to release for exam ${examId}`);
+        }
+
+        const requestedBy = this._getCallerIdentity(ctx);
+        const release = {
+            docType: 'gradeRelease',
+            id: key,
+            examId: examId,
+            classId: exam.classId,
+            status: 'pending',
+            requestedBy: requestedBy,
+            requestedAt: this._getTxTimestamp(ctx),
+            approvedBy: null,
+            approvedAt: null,
+        };
+
+        await ctx.stub.putState(key, serializeRecord(release));
+
+        ctx.stub.setEvent('GradeReleaseRequested', Buffer.from(JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            draftCount: drafts.length,
+            requestedBy: requestedBy,
+        })));
+
+        console.info(`✅ Grade release requested for ${examId} by ${requestedBy} (${drafts.length} drafts)`);
+        console.info('============= END : RequestGradeRelease ===========');
+
+        return JSON.stringify(release);
+    }
+
+    /**
+     * 16. Approuver la publication des notes d'un examen
+     *
+     * Accessible par: Admin ou department-head, distinct du demandeur (pas d'auto-approbation)
+     * Publie les brouillons de l'examen; l'embargo (gradeReleaseDelayHours) reste applicable
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON récapitulatif
+     */
+    async ApproveGradeRelease(ctx, examId) {
+        console.info('============= START : ApproveGradeRelease ===========');
+
+        if (!RELEASE_APPROVER_ROLES.includes(await getCallerRole(ctx))) {
+            throw new Error('Access Denied: Only an admin or a department head can approve a grade release');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+
+        const key = this._releaseKey(examId);
+        const releaseAsBytes = await ctx.stub.getState(key);
+        const release = releaseAsBytes && releaseAsBytes.length > 0 ? parseRecord(releaseAsBytes, key, 'gradeRelease') : null;
+        if (!release || release.status !== 'pending') {
+            throw new Error(`No pending grade release for exam ${examId}`);
+        }
+
+        const approvedBy = this._getCallerIdentity(ctx);
+        if (approvedBy === release.requestedBy) {
+            throw new Error(`Self-approval is not allowed: ${approvedBy} requested the release of exam ${examId}`);
+        }
+
+        await this._checkReleaseWindow(ctx, exam, examId);
+
+        const approvedAt = this._getTxTimestamp(ctx);
+        const published = await this._publishDrafts(ctx, examId, approvedBy, approvedAt);
+
+        release.status = 'approved';
+        release.approvedBy = approvedBy;
+        release.approvedAt = approvedAt;
+        release.publishedCount = published.length;
+
+        await ctx.stub.putState(key, serializeRecord(release));
+
+        ctx.stub.setEvent('GradeReleaseApproved', Buffer.from(JSON.stringify({
+            examId: examId,
+            count: published.length,
+            requestedBy: release.requestedBy,
+            approvedBy: approvedBy,
+        })));
+
+        console.info(`✅ Grade release of ${examId} approved by ${approvedBy}: ${published.length} grades published`);
+        console.info('============= END : ApproveGradeRelease ===========');
+
+        return JSON.stringify({
+            success: true,
+            examId: examId,
+            published: published,
+            count: published.length,
+            requestedBy: release.requestedBy,
+            approvedBy: approvedBy,
+        });
+    }
+
+    /**
+     * 17. Rouvrir la saisie des notes d'un examen (note tardive, rattrapage)
+     *
+     * Accessible par: Teachers de la classe de l'examen ou admin
+     * Uniquement si la saisie est close (échéance stricte dépassée ou CloseGrading);
+     * motif obligatoire, tracé dans le journal d'audit. CloseGrading referme la saisie.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @param {string} reason - Motif de la réouverture
+     * @returns {string} JSON de l'état de saisie
+     */
+    async ReopenGrading(ctx, examId, reason) {
+        console.info('============= START : ReopenGrading ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can reopen grading');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+
+        if (!reason || !reason.trim()) {
+            throw new Error('A reason is required to reopen grading');
+        }
+
+        const state = await this._getGradingState(ctx, exam);
+        if (state.open) {
+            throw new Error(`Grading is not closed for exam ${examId}`);
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const grading = {
+            docType: 'gradingStatus',
+            id: this._gradingKey(examId),
+            examId: examId,
+            classId: exam.classId,
+            status: 'reopened',
+            reason: reason,
+            deadline: state.deadline,
+            updatedBy: caller,
+            updatedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(grading.id, serializeRecord(grading));
+
+        await writeAuditEntry(ctx, 'GradingReopened', examId, reason, {
+            classId: exam.classId,
+            deadline: state.deadline,
+            closedReason: state.reason,
+        });
+
+        ctx.stub.setEvent('GradingReopened', Buffer.from(JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            reopenedBy: caller,
+            reason: reason,
+        })));
+
+        console.info(`✅ Grading reopened for ${examId} by ${caller}: ${reason}`);
+        console.info('============= END : ReopenGrading ===========');
+
+        return JSON.stringify(grading);
+    }
+
+    /**
+     * 18. Clôturer la saisie des notes d'un examen
+     *
+     * Accessible par: Teachers de la classe de l'examen ou admin
+     * Finalise la saisie (y compris après ReopenGrading): SubmitGrade est ensuite refusé
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON de l'état de saisie
+     */
+    async CloseGrading(ctx, examId) {
+        console.info('============= START : CloseGrading ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can close grading');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+
+        const state = await this._getGradingState(ctx, exam);
+        if (state.record && state.record.status === 'closed') {
+            throw new Error(`Grading is already closed for exam ${examId}`);
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const grading = {
+            docType: 'gradingStatus',
+            id: this._gradingKey(examId),
+            examId: examId,
+            classId: exam.classId,
+            status: 'closed',
+            reason: null,
+            deadline: state.deadline,
+            updatedBy: caller,
+            updatedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(grading.id, serializeRecord(grading));
+
+        ctx.stub.setEvent('GradingClosed', Buffer.from(JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            closedBy: caller,
+        })));
+
+        console.info(`✅ Grading closed for ${examId} by ${caller}`);
+        console.info('============= END : CloseGrading ===========');
+
+        return JSON.stringify(grading);
+    }
+
+    /**
+     * 19. Tableau de bord des notes d'un teacher sur un semestre
+     *
+     * Pour chaque classe dont il est le teacher responsable: moyenne des notes finales
+     * des inscrits actifs (brouillons inclus), taux de réussite (seuil passPercent
+     * de la configuration) et répartition par mention
+     *
+     * Accessible par: Le teacher concerné ou un admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} teacher - Identité du teacher (ex: "teacher1@school.academic.edu")
+     * @param {string} semester - Semestre (ex: "2026-S1")
+     * @returns {string} JSON { teacher, semester, passPercent, classes }
+     */
+    async GetTeacherGradeAnalytics(ctx, teacher, semester) {
+        console.info('============= START : GetTeacherGradeAnalytics ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view grade analytics');
+        }
+        const caller = this._getCallerIdentity(ctx);
+        if (caller !== teacher && !(await this._isAdmin(ctx))) {
+            throw new Error(`Access Denied: Only ${teacher} or an admin can view these grade analytics`);
+        }
+
+        const { passPercent } = await getSystemConfig(ctx);
+        const classes = (await this._queryRecords(ctx, { docType: 'class', semester: semester }))
+            .filter((classData) => (classData.teacher || classData.createdBy) === teacher)
+            .sort((a, b) => a.id.localeCompare(b.id));
+
+        const analytics = [];
+        for (const classData of classes) {
+            const percentages = [];
+            const distribution = {};
+
+            for (const studentId of classData.enrolledStudents) {
+                const finalGrade = await this._computeFinalGrade(ctx, classData, studentId, true);
+                if (finalGrade.percentage === null) {
+                    continue;
+                }
+                percentages.push(finalGrade.percentage);
+                distribution[finalGrade.letterGrade] = (distribution[finalGrade.letterGrade] || 0) + 1;
+            }
+
+            const passed = percentages.filter((percentage) => percentage >= passPercent).length;
+            analytics.push({
+                classId: classData.id,
+                className: classData.name,
+                enrolledCount: classData.enrolledStudents.length,
+                gradedCount: percentages.length,
+                averagePercentage: this._computeStatistics(percentages).mean,
+                passRate: percentages.length > 0 ? Math.round((passed / percentages.length) * 10000) / 100 : null,
+                distribution: distribution,
+                statistics: this._computeStatistics(percentages),
+            });
+        }
+
+        console.info(`✅ Grade analytics of ${teacher} for ${semester}: ${analytics.length} classes`);
+        console.info('============= END : GetTeacherGradeAnalytics ===========');
+
+        return JSON.stringify({
+            teacher: teacher,
+            semester: semester,
+            passPercent: passPercent,
+            classes: analytics,
+        });
+    }
+
+    /**
+     * 20. Statut d'un étudiant dans une classe
+     *
+     * Résultat finalisé (FinalizeClassOutcome) s'il existe, sinon calculé:
+     * - withdrawn: inscription terminée
+     * - in-progress: au moins un examen sans note publiée
+     * - completed / failed: note finale (notes publiées) au-dessus / en dessous du seuil passPercent
+     *
+     * Accessible par: Teacher + Étudiant concerné
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @param {string} studentId - ID de l'étudiant
+     * @returns {string} JSON { classId, studentId, status, percentage, letterGrade, passPercent, finalized }
+     */
+    async GetStudentClassStatus(ctx, classId, studentId) {
+        console.info('============= START : GetStudentClassStatus ===========');
+
+        this._canAccessGrade(ctx, studentId);
+
+        const classData = await this._getClass(ctx, classId);
+
+        const outcome = await getOutcomeRecord(ctx, classId, studentId)
+            || await this._computeClassOutcome(ctx, classData, studentId);
+
+        console.info(`✅ Status of ${studentId} in ${classId}: ${outcome.status}`);
+        console.info('============= END : GetStudentClassStatus ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            studentId: studentId,
+            status: outcome.status,
+            percentage: outcome.percentage,
+            letterGrade: outcome.letterGrade,
+            passPercent: outcome.passPercent,
+            finalized: Boolean(outcome.finalizedAt),
+            finalizedAt: outcome.finalizedAt || null,
+        });
+    }
+
+    /**
+     * 21. Finaliser les résultats d'une classe (fin de semestre)
+     *
+     * Enregistre le statut de chaque inscrit actif (clé déterministe outcomeKey),
+     * qui prime ensuite sur le calcul de GetStudentClassStatus. Peut être relancé
+     * (après une contestation par exemple): les résultats sont alors remplacés.
+     *
+     * Accessible par: Teacher responsable de la classe ou admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @returns {string} JSON { classId, finalizedAt, counts, outcomes }
+     */
+    async FinalizeClassOutcome(ctx, classId) {
+        console.info('============= START : FinalizeClassOutcome ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can finalize class outcomes');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const caller = this._getCallerIdentity(ctx);
+        if ((classData.teacher || classData.createdBy) !== caller && !(await this._isAdmin(ctx))) {
+            throw new Error(`Access Denied: Only the teacher of class ${classId} or an admin can finalize its outcomes`);
+        }
+
+        const finalizedAt = this._getTxTimestamp(ctx);
+        const counts = { completed: 0, failed: 0, 'in-progress': 0 };
+        const outcomes = [];
+
+        for (const studentId of classData.enrolledStudents) {
+            const computed = await this._computeClassOutcome(ctx, classData, studentId);
+            const outcome = Object.assign({
+                docType: 'classOutcome',
+                id: this._outcomeKey(classId, studentId),
+                classId: classId,
+                semester: classData.semester || null,
+            }, computed, {
+                finalizedBy: caller,
+                finalizedAt: finalizedAt,
+            });
+
+            await ctx.stub.putState(outcome.id, serializeRecord(outcome));
+            counts[outcome.status] += 1;
+            outcomes.push(outcome);
+        }
+
+        ctx.stub.setEvent('ClassOutcomeFinalized', Buffer.from(JSON.stringify({
+            classId: classId,
+            counts: counts,
+            finalizedBy: caller,
+        })));
+
+        console.info(`✅ Outcomes of ${classId} finalized by ${caller}: ${outcomes.length} students`);
+        console.info('============= END : FinalizeClassOutcome ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            finalizedAt: finalizedAt,
+            counts: counts,
+            outcomes: outcomes,
+        });
+    }
+
+    /**
+     * 22. Exporter un relevé de notes signé (registrar)
+     *
+     * Relevé des notes publiées, classe par classe, avec l'organisation du demandeur
+     * (créateur de la transaction: identique sur tous les peers endosseurs);
+     * le digest SHA-256 du document (JSON canonique) est calculé par le contrat et
+     * enregistré dans le ledger (TRANSCRIPT_<digest>) pour être vérifié par VerifyTranscript
+     *
+     * Accessible par: L'étudiant lui-même ou un admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} studentId - ID de l'étudiant
+     * @returns {string} JSON { document, digest, algorithm }
+     */
+    async ExportSignedTranscript(ctx, studentId) {
+        console.info('============= START : ExportSignedTranscript ===========');
+
+        const caller = this._getCallerIdentity(ctx);
+        const isSelf = this._isStudentMember(ctx) && caller === studentId;
+        if (!isSelf && !(this._isSchoolMember(ctx) && await this._isAdmin(ctx))) {
+            throw new Error('Access Denied: Only the student or an admin can export a signed transcript');
+        }
+
+        const grades = (await this._queryRecords(ctx, { docType: 'grade', studentId: studentId }))
+            .filter((grade) => this._isPublished(grade) && !grade.voided);
+        const classIds = Array.from(new Set(grades.map((grade) => grade.classId))).sort();
+
+        const classes = [];
+        for (const classId of classIds) {
+            const classData = await this._getClass(ctx, classId);
+            const finalGrade = await this._computeFinalGrade(ctx, classData, studentId, false);
+            classes.push({
+                classId: classId,
+                className: classData.name,
+                semester: classData.semester || null,
+                percentage: finalGrade.percentage,
+                letterGrade: finalGrade.letterGrade,
+                complete: finalGrade.complete,
+                exams: finalGrade.exams.map((exam) => ({
+                    examId: exam.examId,
+                    score: exam.effectiveScore,
+                    percentage: exam.percentage,
+                    weight: exam.weight,
+                })),
+            });
+        }
+
+        const txId = ctx.stub.getTxID();
+        const document = {
+            studentId: studentId,
+            classes: classes,
+            issuedAt: this._getTxTimestamp(ctx),
+            issuedBy: caller,
+            txId: txId,
+            issuer: {
+                mspId: ctx.clientIdentity.getMSPID(),
+                channelId: ctx.stub.getChannelID(),
+            },
+        };
+        const digest = this._hashTranscript(document);
+
+        await ctx.stub.putState(this._transcriptKey(digest), serializeRecord({
+            docType: 'transcriptDigest',
+            id: this._transcriptKey(digest),
+            digest: digest,
+            studentId: studentId,
+            issuedAt: document.issuedAt,
+            issuedBy: caller,
+            txId: txId,
+        }));
+
+        ctx.stub.setEvent('TranscriptExported', Buffer.from(JSON.stringify({
+            studentId: studentId,
+            digest: digest,
+            issuedBy: caller,
+        })));
+
+        console.info(`✅ Signed transcript exported for ${studentId} by ${caller}: ${digest}`);
+        console.info('============= END : ExportSignedTranscript ===========');
+
+        return JSON.stringify({ document: document, digest: digest, algorithm: 'sha256' });
+    }
+
+    /**
+     * 23. Vérifier un relevé de notes signé
+     *
+     * valid: le digest recalculé correspond (document intact) et a été émis par le contrat
+     *
+     * Accessible par: Tous les participants authentifiés
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} signedTranscriptJSON - JSON renvoyé par ExportSignedTranscript
+     * @returns {string} JSON { valid, digestMatches, registered, studentId, issuedAt }
+     */
+    async VerifyTranscript(ctx, signedTranscriptJSON) {
+        console.info('============= START : VerifyTranscript ===========');
+
+        if (!this._isSchoolMember(ctx) && !this._isStudentMember(ctx)) {
+            throw new Error('Access Denied: You must be authenticated to verify a transcript');
+        }
+
+        let signed;
+        try {
+            signed = JSON.parse(signedTranscriptJSON);
+        } catch (err) {
+            throw new Error('Invalid signedTranscriptJSON: must be the JSON returned by ExportSignedTranscript');
+        }
+        if (!signed || typeof signed.document !== 'object' || signed.document === null || typeof signed.digest !== 'string') {
+            throw new Error('Invalid signedTranscriptJSON: document and digest are required');
+        }
+
+        const digestMatches = this._hashTranscript(signed.document) === signed.digest;
+        const registeredAsBytes = await ctx.stub.getState(this._transcriptKey(signed.digest));
+        const registered = Boolean(registeredAsBytes && registeredAsBytes.length > 0);
+
+        console.info(`✅ Transcript ${signed.digest} verified: ${digestMatches && registered ? 'valid' : 'INVALID'}`);
+        console.info('============= END : VerifyTranscript ===========');
+
+        return JSON.stringify({
+            valid: digestMatches && registered,
+            digestMatches: digestMatches,
+            registered: registered,
+            studentId: signed.document.studentId || null,
+            issuedAt: signed.document.issuedAt || null,
+        });
+    }
+
+    /**
+     * 24. Verrouiller les notes d'un examen pour relecture
+     *
+     * Tant que le verrou est posé, SubmitGrade / UpdateGrade (et les autres saisies)
+     * sont refusés à toute autre identité que son détenteur
+     *
+     * Accessible par: Teachers de la classe de l'examen ou admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON du verrou
+     */
+    async LockExamGrades(ctx, examId) {
+        console.info('============= START : LockExamGrades ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can lock grades');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+
+        const existing = await this._getReviewLock(ctx, examId);
+        if (existing) {
+            throw new Error(`Grades for exam ${examId} are already locked for review by ${existing.lockedBy}`);
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const lock = {
+            docType: 'gradeReviewLock',
+            id: this._reviewLockKey(examId),
+            examId: examId,
+            classId: exam.classId,
+            lockedBy: caller,
+            lockedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(lock.id, serializeRecord(lock));
+
+        ctx.stub.setEvent('ExamGradesLocked', Buffer.from(JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            lockedBy: caller,
+        })));
+
+        console.info(`✅ Grades of exam ${examId} locked for review by ${caller}`);
+        console.info('============= END : LockExamGrades ===========');
+
+        return JSON.stringify(lock);
+    }
+
+    /**
+     * 25. Lever le verrou de relecture des notes d'un examen
+     *
+     * Accessible par: Le détenteur du verrou ou un admin (verrou abandonné)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON { success, examId, unlockedBy }
+     */
+    async UnlockExamGrades(ctx, examId) {
+        console.info('============= START : UnlockExamGrades ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can unlock grades');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        const lock = await this._getReviewLock(ctx, examId);
+        if (!lock) {
+            throw new Error(`Grades for exam ${examId} are not locked`);
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        if (lock.lockedBy !== caller && !(await this._isAdmin(ctx))) {
+            throw new Error(`Access Denied: Only ${lock.lockedBy} or an admin can unlock grades for exam ${examId}`);
+        }
+
+        await ctx.stub.deleteState(lock.id);
+
+        ctx.stub.setEvent('ExamGradesUnlocked', Buffer.from(JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            lockedBy: lock.lockedBy,
+            unlockedBy: caller,
+        })));
+
+        console.info(`✅ Grades of exam ${examId} unlocked by ${caller}`);
+        console.info('============= END : UnlockExamGrades ===========');
+
+        return JSON.stringify({ success: true, examId: examId, lockedBy: lock.lockedBy, unlockedBy: caller });
+    }
+
+    /**
+     * 26. Comparer les résultats de deux examens d'une même classe
+     *
+     * Statistiques de chaque examen en pourcentage (barèmes différents comparables),
+     * copies notées uniquement (absences exclues), puis écarts B - A: moyenne,
+     * taux de réussite (seuil passPercent de la configuration) et répartition par mention.
+     * Un écart de moyenne négatif indique un examen B plus difficile.
+     *
+     * Accessible par: Teachers de la classe des examens ou admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examIdA - Examen de référence
+     * @param {string} examIdB - Examen comparé
+     * @returns {string} JSON { classId, passPercent, examA, examB, delta }
+     */
+    async CompareExamStatistics(ctx, examIdA, examIdB) {
+        console.info('============= START : CompareExamStatistics ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view exam statistics');
+        }
+
+        if (examIdA === examIdB) {
+            throw new Error('Invalid comparison: examIdA and examIdB must be different exams');
+        }
+
+        const examA = await this._getExam(ctx, examIdA);
+        const examB = await this._getExam(ctx, examIdB);
+        if (examA.classId !== examB.classId) {
+            throw new Error(`Invalid comparison: exam ${examIdA} (class ${examA.classId}) and exam ${examIdB} (class ${examB.classId}) belong to different classes`);
+        }
+        await this._checkExamOwner(ctx, examA);
+
+        const classData = await this._getClass(ctx, examA.classId);
+        const { passPercent } = await getSystemConfig(ctx);
+        const performanceA = await this._getExamPerformance(ctx, examA, classData, passPercent);
+        const performanceB = await this._getExamPerformance(ctx, examB, classData, passPercent);
+
+        const round = (value) => Math.round(value * 100) / 100;
+        const difference = (a, b) => (a === null || b === null ? null : round(b - a));
+
+        const distribution = {};
+        const letters = new Set(Object.keys(performanceA.distribution).concat(Object.keys(performanceB.distribution)));
+        for (const letter of letters) {
+            distribution[letter] = (performanceB.distribution[letter] || 0) - (performanceA.distribution[letter] || 0);
+        }
+
+        const delta = {
+            averagePercentage: difference(performanceA.statistics.mean, performanceB.statistics.mean),
+            medianPercentage: difference(performanceA.statistics.median, performanceB.statistics.median),
+            passRate: difference(performanceA.passRate, performanceB.passRate),
+            distribution: distribution,
+        };
+
+        console.info(`✅ Exams ${examIdA} and ${examIdB} compared: average delta ${delta.averagePercentage}`);
+        console.info('============= END : CompareExamStatistics ===========');
+
+        return JSON.stringify({
+            classId: examA.classId,
+            passPercent: passPercent,
+            examA: performanceA,
+            examB: performanceB,
+            delta: delta,
+        });
+    }
+
+    /**
+     * 27. Figer une note (dossier définitif de fin de semestre)
+     *
+     * Une note figée ne peut plus être modifiée, supprimée ni annulée;
+     * une correction passe par UnfinalizeGrade (admin, motif obligatoire)
+     *
+     * Accessible par: Teachers de la classe de l'examen ou admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID de la note
+     * @returns {string} JSON de la note figée
+     */
+    async FinalizeGrade(ctx, gradeId) {
+        console.info('============= START : FinalizeGrade ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can finalize grades');
+        }
+
+        const gradeAsBytes = await ctx.stub.getState(gradeId);
+        if (!gradeAsBytes || gradeAsBytes.length === 0) {
+            throw new Error(`Grade ${gradeId} does not exist`);
+        }
+
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+        const exam = await this._getExam(ctx, grade.examId);
+        await this._checkExamOwner(ctx, exam);
+
+        if (grade.finalized) {
+            throw new Error(`Grade ${gradeId} is already finalized (since ${grade.finalizedAt})`);
+        }
+        if (grade.voided) {
+            throw new Error(`Cannot finalize grade ${gradeId}: it has been voided`);
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        grade.finalized = true;
+        grade.finalizedBy = caller;
+        grade.finalizedAt = this._getTxTimestamp(ctx);
+
+        await ctx.stub.putState(gradeId, serializeRecord(grade));
+
+        ctx.stub.setEvent('GradeFinalized', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
+            examId: grade.examId,
+            studentId: grade.studentId,
+            finalizedBy: caller,
+        })));
+
+        console.info(`✅ Grade ${gradeId} finalized by ${caller}`);
+        console.info('============= END : FinalizeGrade ===========');
+
+        return JSON.stringify(grade);
+    }
+
+    /**
+     * 28. Lever le gel d'une note pour correction
+     *
+     * Accessible par: Admins uniquement
+     * Motif obligatoire, tracé dans le journal d'audit
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID de la note
+     * @param {string} reason - Motif de la correction
+     * @returns {string} JSON de la note
+     */
+    async UnfinalizeGrade(ctx, gradeId, reason) {
+        console.info('============= START : UnfinalizeGrade ===========');
+
+        if (!this._isSchoolMember(ctx) || !(await this._isAdmin(ctx))) {
+            throw new Error('Access Denied: Only admins can unfinalize grades');
+        }
+
+        if (!reason || !reason.trim()) {
+            throw new Error('A reason is required to unfinalize a grade');
+        }
+
+        const gradeAsBytes = await ctx.stub.getState(gradeId);
+        if (!gradeAsBytes || gradeAsBytes.length === 0) {
+            throw new Error(`Grade ${gradeId} does not exist`);
+        }
+
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+        if (!grade.finalized) {
+            throw new Error(`Grade ${gradeId} is not finalized`);
+        }
+
+        await writeAuditEntry(ctx, 'GradeUnfinalized', gradeId, reason, {
+            examId: grade.examId,
+            studentId: grade.studentId,
+            score: grade.score,
+            finalizedBy: grade.finalizedBy,
+            finalizedAt: grade.finalizedAt,
+        });
+
+        const caller = this._getCallerIdentity(ctx);
+        grade.finalized = false;
+        grade.finalizedBy = null;
+        grade.finalizedAt = null;
+
+        await ctx.stub.putState(gradeId, serializeRecord(grade));
+
+        ctx.stub.setEvent('GradeUnfinalized', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
+            examId: grade.examId,
+            studentId: grade.studentId,
+            unfinalizedBy: caller,
+            reason: reason,
+        })));
+
+        console.info(`✅ Grade ${gradeId} unfinalized by ${caller}: ${reason}`);
+        console.info('============= END : UnfinalizeGrade ===========');
+
+        return JSON.stringify(grade);
+    }
+
+    /**
+     * 29. Prévisualiser la publication des notes d'un examen (sans écriture)
+     *
+     * Accessible par: Teacher / co-teacher de la classe de l'examen ou admin
+     * Chaque note non annulée telle que GetGrade la renverra après PublishExamGrades
+     * dans cette même transaction (brouillons publiés par l'appelant), avec le
+     * pourcentage et la mention selon le barème de la classe (null en réussite/échec).
+     * Les contrôles de publication (embargo, approbation) ne sont pas appliqués.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON { examId, previewAt, count, newlyPublishedCount, grades: [{ studentId, newlyPublished, percentage, letterGrade, grade }] }
+     */
+    async PreviewGradeRelease(ctx, examId) {
+        console.info('============= START : PreviewGradeRelease ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can preview grade releases');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+        const classData = await this._getClass(ctx, exam.classId);
+
+        // Mêmes valeurs que _publishDrafts, sans écriture
+        const publishedBy = this._getCallerIdentity(ctx);
+        const previewAt = this._getTxTimestamp(ctx);
+
+        const grades = [];
+        for (const record of await this._getExamGradeRecords(ctx, examId)) {
+            if (record.voided || record.provisional === true) {
+                continue;
+            }
+
+            const newlyPublished = !this._isPublished(record);
+            const grade = newlyPublished
+                ? Object.assign({}, record, { isPublished: true, publishedBy: publishedBy, publishedAt: previewAt })
+                : record;
+            const percentage = this._isPassFailGrade(grade) ? null : Math.round(this._getPercentage(grade) * 100) / 100;
+
+            grades.push({
+                studentId: grade.studentId,
+                newlyPublished: newlyPublished,
+                percentage: percentage,
+                letterGrade: percentage === null ? null : this._getLetterGrade(percentage, classData.gradingScale).letter,
+                grade: grade,
+            });
+        }
+        grades.sort((a, b) => a.studentId.localeCompare(b.studentId) || a.grade.id.localeCompare(b.grade.id));
+
+        const newlyPublishedCount = grades.filter((entry) => entry.newlyPublished).length;
+
+        console.info(`✅ Release preview of ${examId}: ${newlyPublishedCount} of ${grades.length} grades would be published`);
+        console.info('============= END : PreviewGradeRelease ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            previewAt: previewAt,
+            count: grades.length,
+            newlyPublishedCount: newlyPublishedCount,
+            grades: grades,
+        });
+    }
+
+    /**
+     * 30. Ajouter un commentaire au fil de discussion d'une note
+     *
+     * Accessible par: L'étudiant concerné (note publiée) et les teachers / co-teachers de la classe
+     * Au plus MAX_GRADE_COMMENTS commentaires par note, MAX_GRADE_COMMENT_LENGTH caractères chacun
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID de la note
+     * @param {string} text - Texte du commentaire
+     * @returns {string} JSON du commentaire
+     */
+    async AddGradeComment(ctx, gradeId, text) {
+        console.info('============= START : AddGradeComment ===========');
+
+        const grade = await this._getGrade(ctx, gradeId);
+        const authorRole = await this._checkGradeThreadAccess(ctx, grade);
+
+        if (typeof text !== 'string' || !text.trim()) {
+            throw new Error('Invalid text: comment must be a non-empty string');
+        }
+        if (text.length > MAX_GRADE_COMMENT_LENGTH) {
+            throw new Error(`Invalid text: comment exceeds ${MAX_GRADE_COMMENT_LENGTH} characters`);
+        }
+
+        const thread = await this._getGradeComments(ctx, gradeId);
+        if (thread.length >= MAX_GRADE_COMMENTS) {
+            throw new Error(`Grade ${gradeId} already has ${MAX_GRADE_COMMENTS} comments (maximum reached)`);
+        }
+
+        const seq = thread.length + 1;
+        const author = this._getCallerIdentity(ctx);
+        const comment = {
+            docType: 'gradeComment',
+            id: `${this._gradeCommentPrefix(gradeId)}${String(seq).padStart(4, '0')}`,
+            gradeId: gradeId,
+            examId: grade.examId,
+            classId: grade.classId,
+            seq: seq,
+            author: author,
+            authorRole: authorRole, // student ou teacher
+            text: text,
+            createdAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(comment.id, serializeRecord(comment));
+
+        ctx.stub.setEvent('GradeCommentAdded', Buffer.from(JSON.stringify({
+            gradeId: gradeId,
+            studentId: grade.studentId,
+            author: author,
+            authorRole: authorRole,
+            seq: seq,
+        })));
+
+        console.info(`✅ Comment ${seq} added to grade ${gradeId} by ${author} (${authorRole})`);
+        console.info('============= END : AddGradeComment ===========');
+
+        return JSON.stringify(comment);
+    }
+
+    /**
+     * 31. Obtenir le fil de discussion d'une note (ordre chronologique)
+     *
+     * Accessible par: L'étudiant concerné (note publiée) et les teachers / co-teachers de la classe
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} gradeId - ID de la note
+     * @returns {string} JSON { gradeId, studentId, count, comments }
+     */
+    async GetGradeComments(ctx, gradeId) {
+        const grade = await this._getGrade(ctx, gradeId);
+        await this._checkGradeThreadAccess(ctx, grade);
+
+        const comments = await this._getGradeComments(ctx, gradeId);
+
+        return JSON.stringify({
+            gradeId: gradeId,
+            studentId: grade.studentId,
+            count: comments.length,
+            comments: comments,
+        });
+    }
+
+    /**
+     * 32. Recalculer les mentions des notes d'une classe (changement de barème)
+     *
+     * Accessible par: Teacher responsable de la classe ou admin
+     * Réapplique le barème actuel de la classe (SetGradingScale) à la mention enregistrée
+     * sur chaque note: les scores ne sont jamais modifiés. Les notes figées (FinalizeGrade)
+     * sont conservées telles quelles et listées à part. Tracé dans le journal d'audit.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @returns {string} JSON { classId, recomputedAt, gradeCount, changedCount, changes, skippedFinalized }
+     */
+    async RecomputeLetterGrades(ctx, classId) {
+        console.info('============= START : RecomputeLetterGrades ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can recompute letter grades');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const caller = this._getCallerIdentity(ctx);
+        if ((classData.teacher || classData.createdBy) !== caller && !(await this._isAdmin(ctx))) {
+            throw new Error(`Access Denied: Only the teacher of class ${classId} or an admin can recompute its letter grades`);
+        }
+
+        const recomputedAt = this._getTxTimestamp(ctx);
+        const grades = (await this._queryRecords(ctx, { docType: 'grade', classId: classId }))
+            .sort((a, b) => a.id.localeCompare(b.id));
+
+        const changes = [];
+        const skippedFinalized = [];
+        for (const grade of grades) {
+            const letterGrade = this._deriveLetterGrade(grade, classData.gradingScale);
+            if (grade.letterGrade === letterGrade) {
+                continue;
+            }
+            // Notes antérieures au champ letterGrade: la mention est enregistrée pour la première fois
+            const previous = grade.letterGrade === undefined ? null : grade.letterGrade;
+            if (grade.finalized) {
+                if (letterGrade !== previous) {
+                    skippedFinalized.push(grade.id);
+                }
+                continue;
+            }
+
+            grade.letterGrade = letterGrade;
+            grade.letterGradeRecomputedAt = recomputedAt;
+            await ctx.stub.putState(grade.id, serializeRecord(grade));
+
+            if (letterGrade !== previous) {
+                changes.push({ gradeId: grade.id, studentId: grade.studentId, from: previous, to: letterGrade });
+            }
+        }
+
+        await writeAuditEntry(ctx, 'LetterGradesRecomputed', classId, 'Letter grades re-derived from the current grading scale', {
+            gradingScale: classData.gradingScale || null,
+            gradeCount: grades.length,
+            changes: changes,
+            skippedFinalized: skippedFinalized,
+        });
+
+        ctx.stub.setEvent('LetterGradesRecomputed', Buffer.from(JSON.stringify({
+            classId: classId,
+            changedCount: changes.length,
+            recomputedBy: caller,
+        })));
+
+        console.info(`✅ Letter grades of ${classId} recomputed by ${caller}: ${changes.length}/${grades.length} changed`);
+        console.info('============= END : RecomputeLetterGrades ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            recomputedAt: recomputedAt,
+            gradeCount: grades.length,
+            changedCount: changes.length,
+            changes: changes,
+            skippedFinalized: skippedFinalized,
+        });
+    }
+
+    /**
+     * 33. Attribuer un zéro aux inscrits sans copie après l'échéance de remise
+     *
+     * Accessible par: Teachers / co-teachers de la classe de l'examen + admins
+     * Après la fin de la remise (examDate + durationMinutes + gracePeriodMinutes, d'après le
+     * timestamp de la transaction), crée une note brouillon "no-show" (score 0, "fail" en
+     * réussite/échec) pour chaque inscrit actif sans copie ni note. Contrairement à une
+     * absence (MarkAbsent), ce zéro compte dans les statistiques et les moyennes.
+     * Les notes existantes ne sont pas modifiées: l'appel peut être relancé.
+     * Les étudiants bénéficiant d'un aménagement dont la remise est encore ouverte sont
+     * ignorés (stillOpen) jusqu'à un appel ultérieur.
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} examId - ID de l'examen
+     * @returns {string} JSON { examId, closesAt, noShowCount, noShows: [{ studentId, gradeId }], submittedCount, alreadyGradedCount, stillOpen }
+     */
+    async FinalizeExamNoShows(ctx, examId) {
+        console.info('============= START : FinalizeExamNoShows ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can finalize no-shows');
+        }
+
+        const exam = await this._getExam(ctx, examId);
+        await this._checkExamOwner(ctx, exam);
+
+        const closesAt = getSubmissionClosesAt(exam);
+        if (!closesAt) {
+            throw new Error(`Exam ${examId} has no submission deadline (durationMinutes not set): no-shows cannot be finalized`);
+        }
+        const txTimestamp = this._getTxTimestamp(ctx);
+        if (new Date(txTimestamp) < closesAt) {
+            throw new Error(`Submissions for exam ${examId} are open until ${closesAt.toISOString()}: no-shows cannot be finalized yet`);
+        }
+
+        const classData = await this._getClass(ctx, exam.classId);
+        const graded = new Set((await this._getExamGradeRecords(ctx, examId)).map((grade) => grade.studentId));
+        // Réussite/échec: un zéro correspond à un échec
+        const noShowScore = getGradingScheme(exam) === 'passfail' ? 'fail' : '0';
+
+        const noShows = [];
+        const stillOpen = [];
+        let submittedCount = 0;
+        let alreadyGradedCount = 0;
+        for (const studentId of classData.enrolledStudents.slice().sort()) {
+            if (graded.has(studentId)) {
+                alreadyGradedCount += 1;
+                continue;
+            }
+            if (await this._getSubmission(ctx, examId, studentId)) {
+                submittedCount += 1;
+                continue;
+            }
+            // Aménagement d'épreuve: la remise de l'étudiant peut encore être ouverte
+            if (new Date(txTimestamp) < getSubmissionClosesAt(exam, studentId)) {
+                stillOpen.push(studentId);
+                continue;
+            }
+
+            const grade = await this._createGrade(ctx, this._gradeKey(examId, studentId), examId, studentId, noShowScore, '', 'no-show', false);
+            noShows.push({ studentId: studentId, gradeId: grade.id });
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        ctx.stub.setEvent('ExamNoShowsFinalized', Buffer.from(JSON.stringify({
+            examId: examId,
+            classId: exam.classId,
+            noShowCount: noShows.length,
+            finalizedBy: caller,
+        })));
+
+        console.info(`✅ ${noShows.length} no-show grades created for exam ${examId} by ${caller}`);
+        console.info('============= END : FinalizeExamNoShows ===========');
+
+        return JSON.stringify({
+            examId: examId,
+            closesAt: closesAt.toISOString(),
+            noShowCount: noShows.length,
+            noShows: noShows,
+            submittedCount: submittedCount,
+            alreadyGradedCount: alreadyGradedCount,
+            stillOpen: stillOpen,
+        });
+    }
+
+    /**
+     * 34. Prévisualiser la note finale d'un étudiant avant la fin des examens
+     *
+     * Accessible par: Teacher + Étudiant concerné
+     * Seules les notes publiées comptent (mêmes règles que ComputeFinalGrade).
+     * Pour les examens notés restants (hors réussite/échec): poids encore en jeu
+     * et bornes de la note finale (tous réussis à 100%, ou tous à 0%).
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @param {string} studentId - ID de l'étudiant
+     * @returns {string} JSON { classId, studentId, currentPercentage, currentLetterGrade, gradedWeight, outstandingWeight,
+     *                          outstandingWeightPercent, bestPossible, worstPossible, bestLetterGrade, worstLetterGrade, outstandingExams, exams }
+     */
+    async PreviewStudentFinalGrade(ctx, classId, studentId) {
+        console.info('============= START : PreviewStudentFinalGrade ===========');
+
+        this._canAccessGrade(ctx, studentId);
+
+        const classData = await this._getClass(ctx, classId);
+        const exams = await this._queryRecords(ctx, { docType: 'exam', classId: classId });
+        const finalGrade = await this._computeFinalGrade(ctx, classData, studentId, false);
+
+        // Examens notés avec une note publiée (les résultats réussite/échec n'ont pas de pourcentage)
+        const graded = finalGrade.exams.filter((row) => row.percentage !== null);
+        const gradedIds = new Set(graded.map((row) => row.examId));
+        const gradedWeight = graded.reduce((sum, row) => sum + row.weight, 0);
+        const earned = graded.reduce((sum, row) => sum + row.percentage * row.weight, 0);
+
+        const useWeights = this._usesExamWeights(exams);
+        const outstandingExams = exams
+            .filter((exam) => getGradingScheme(exam) !== 'passfail' && !gradedIds.has(exam.id))
+            .map((exam) => ({ examId: exam.id, title: exam.title, examDate: exam.examDate, weight: useWeights ? exam.weight : 1 }))
+            .sort((a, b) => (a.examDate || '').localeCompare(b.examDate || '') || a.examId.localeCompare(b.examId));
+        const outstandingWeight = outstandingExams.reduce((sum, exam) => sum + exam.weight, 0);
+
+        const totalWeight = gradedWeight + outstandingWeight;
+        const round = (value) => Math.round(value * 100) / 100;
+        const bestPossible = totalWeight > 0 ? round((earned + 100 * outstandingWeight) / totalWeight) : null;
+        const worstPossible = totalWeight > 0 ? round(earned / totalWeight) : null;
+
+        console.info(`✅ Final grade preview for ${studentId} in ${classId}: ${outstandingExams.length} exams outstanding`);
+        console.info('============= END : PreviewStudentFinalGrade ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            studentId: studentId,
+            currentPercentage: finalGrade.percentage,
+            currentLetterGrade: finalGrade.letterGrade,
+            gradedWeight: gradedWeight,
+            outstandingWeight: outstandingWeight,
+            outstandingWeightPercent: totalWeight > 0 ? round(outstandingWeight / totalWeight * 100) : null,
+            bestPossible: bestPossible,
+            worstPossible: worstPossible,
+            bestLetterGrade: bestPossible === null ? null : this._getLetterGrade(bestPossible, classData.gradingScale).letter,
+            worstLetterGrade: worstPossible === null ? null : this._getLetterGrade(worstPossible, classData.gradingScale).letter,
+            outstandingExams: outstandingExams,
+            exams: finalGrade.exams,
+        });
+    }
+
+    /**
+     * 35. Délivrer les certificats de réussite d'une classe (fin de semestre)
+     *
+     * Un certificat (clé déterministe, voir _certificateKey) par inscrit actif dont le résultat est
+     * "completed": résultat finalisé (FinalizeClassOutcome) s'il existe, sinon calculé
+     * (note finale complète, au-dessus du seuil passPercent). Les étudiants déjà certifiés
+     * ou non admis sont ignorés avec leur motif. Aucun relevé de présence n'existe dans le
+     * chaincode: seul le seuil de réussite s'applique.
+     *
+     * Accessible par: Teacher responsable de la classe ou admin
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @returns {string} JSON { classId, issuedAt, issuedCount, skippedCount, results: [{ studentId, status, certificateId, reason }] }
+     */
+    async IssueClassCertificates(ctx, classId) {
+        console.info('============= START : IssueClassCertificates ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can issue certificates');
+        }
+
+        const classData = await this._getClass(ctx, classId);
+        const caller = this._getCallerIdentity(ctx);
+        if ((classData.teacher || classData.createdBy) !== caller && !(await this._isAdmin(ctx))) {
+            throw new Error(`Access Denied: Only the teacher of class ${classId} or an admin can issue its certificates`);
+        }
+
+        const issuedAt = this._getTxTimestamp(ctx);
+        const results = [];
+
+        for (const studentId of classData.enrolledStudents.slice().sort()) {
+            const key = this._certificateKey(classId, studentId);
+            const existing = await this._getCertificateRecord(ctx, classId, studentId);
+            if (existing) {
+                results.push({ studentId: studentId, status: 'skipped', certificateId: existing.id, reason: 'Certificate already issued' });
+                continue;
+            }
+
+            const outcome = await getOutcomeRecord(ctx, classId, studentId)
+                || await this._computeClassOutcome(ctx, classData, studentId);
+
+            if (outcome.status === 'in-progress') {
+                results.push({ studentId: studentId, status: 'skipped', certificateId: null, reason: 'Final grade incomplete: some exams have no published grade' });
+                continue;
+            }
+            if (outcome.status !== 'completed') {
+                results.push({
+                    studentId: studentId,
+                    status: 'skipped',
+                    certificateId: null,
+                    reason: `Below the pass threshold (${outcome.percentage}% < ${outcome.passPercent}%)`,
+                });
+                continue;
+            }
+
+            const certificate = {
+                docType: 'certificate',
+                id: key,
+                classId: classId,
+                className: classData.name,
+                semester: classData.semester || null,
+                studentId: studentId,
+                percentage: outcome.percentage,
+                letterGrade: outcome.letterGrade,
+                passPercent: outcome.passPercent,
+                outcomeFinalized: Boolean(outcome.finalizedAt),
+                issuedBy: caller,
+                issuedAt: issuedAt,
+                txId: ctx.stub.getTxID(),
+            };
+            await ctx.stub.putState(key, serializeRecord(certificate));
+            results.push({ studentId: studentId, status: 'issued', certificateId: key, reason: null });
+        }
+
+        const issued = results.filter((result) => result.status === 'issued').map((result) => result.studentId);
+
+        ctx.stub.setEvent('ClassCertificatesIssued', Buffer.from(JSON.stringify({
+            classId: classId,
+            issued: issued,
+            skippedCount: results.length - issued.length,
+            issuedBy: caller,
+        })));
+
+        console.info(`✅ ${issued.length} certificates issued for ${classId} by ${caller} (${results.length - issued.length} skipped)`);
+        console.info('============= END : IssueClassCertificates ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            issuedAt: issuedAt,
+            issuedCount: issued.length,
+            skippedCount: results.length - issued.length,
+            results: results,
+        });
+    }
+
+    /**
+     * 36. Obtenir le certificat de réussite d'un étudiant pour une classe
+     *
+     * Accessible par: Teacher + Étudiant concerné
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @param {string} studentId - ID de l'étudiant
+     * @returns {string} JSON du certificat
+     */
+    async GetCertificate(ctx, classId, studentId) {
+        this._canAccessGrade(ctx, studentId);
+
+        const certificate = await this._getCertificateRecord(ctx, classId, studentId);
+        if (!certificate) {
+            throw new Error(`No certificate for ${studentId} in class ${classId}`);
+        }
+
+        return JSON.stringify(certificate);
+    }
+
+    // ==================== FONCTIONS INTERNES ====================
+
+    /**
+     * Préfixe des commentaires d'une note
+     * @private
+     */
+    _gradeCommentPrefix(gradeId) {
+        return `GRADECOMMENT_${gradeId}_`;
+    }
+
+    /**
+     * Commentaires d'une note, triés par numéro d'ordre
+     * @private
+     */
+    async _getGradeComments(ctx, gradeId) {
+        const prefix = this._gradeCommentPrefix(gradeId);
+        const comments = [];
+        const iterator = await ctx.stub.getStateByRange(prefix, prefix + '\uffff');
+        let result = await iterator.next();
+
+        while (!result.done) {
+            try {
+                const comment = JSON.parse(result.value.value.toString('utf8'));
+                // Le préfixe d'une autre note peut commencer par le nôtre
+                if (comment.docType === 'gradeComment' && comment.gradeId === gradeId) {
+                    comments.push(comment);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        return comments.sort((a, b) => a.seq - b.seq);
+    }
+
+    /**
+     * Vérifie l'accès au fil de discussion d'une note
+     * @private
+     * @returns {Promise<string>} "student" (étudiant concerné) ou "teacher" (teacher / co-teacher de la classe)
+     * @throws {Error} Pour tout autre appelant, ou pour l'étudiant tant que la note n'est pas publiée
+     */
+    async _checkGradeThreadAccess(ctx, grade) {
+        const caller = this._getCallerIdentity(ctx);
+
+        if (this._isStudentMember(ctx) && caller === grade.studentId) {
+            if (!this._isPublished(grade)) {
+                throw new Error('Grade not yet published by the teacher');
+            }
+            return 'student';
+        }
+
+        if (this._isSchoolMember(ctx)) {
+            const classData = await this._getClass(ctx, grade.classId);
+            const isTeacher = (classData.teacher || classData.createdBy) === caller;
+            const isCoTeacher = (classData.staff || []).some((member) => member.identityId === caller && member.role === 'co-teacher');
+            if (isTeacher || isCoTeacher) {
+                return 'teacher';
+            }
+        }
+
+        throw new Error(`Access Denied: Only ${grade.studentId} and the teachers of class ${grade.classId} can access the comments of grade ${grade.id}`);
+    }
+
+    /**
+     * Clé d'enregistrement du digest d'un relevé signé
+     * @private
+     */
+    _transcriptKey(digest) {
+        return `TRANSCRIPT_${digest}`;
+    }
+
+    /**
+     * Digest SHA-256 d'un relevé (JSON canonique: indépendant de l'ordre des clés)
+     * @private
+     */
+    _hashTranscript(document) {
+        return crypto.createHash('sha256').update(canonicalStringify(document)).digest('hex');
+    }
+
+    /**
+     * Clé du résultat finalisé d'un étudiant dans une classe
+     * @private
+     */
+    _outcomeKey(classId, studentId) {
+        return outcomeKey(classId, studentId);
+    }
+
+    /**
+     * Clé du certificat de réussite d'un étudiant pour une classe (dérivée de la classe et de l'étudiant)
+     * @private
+     */
+    _certificateKey(classId, studentId) {
+        return generateDeterministicID('CERT', classId, studentId);
+    }
+
+    /**
+     * Récupère le certificat d'un étudiant pour une classe (null si absent)
+     * Clé déterministe en priorité, sinon ancienne clé CERT_<classId>_<studentId>
+     * @private
+     */
+    async _getCertificateRecord(ctx, classId, studentId) {
+        for (const key of [this._certificateKey(classId, studentId), `CERT_${classId}_${studentId}`]) {
+            const certificateAsBytes = await ctx.stub.getState(key);
+            if (certificateAsBytes && certificateAsBytes.length > 0) {
+                return parseRecord(certificateAsBytes, key, 'certificate');
+            }
+        }
+        return null;
+    }
+
+    /**
+     * Calcule le statut d'un étudiant dans une classe (notes publiées uniquement)
+     * @private
+     * @throws {Error} Si l'étudiant n'a jamais été inscrit à la classe
+     */
+    async _computeClassOutcome(ctx, classData, studentId) {
+        const { passPercent } = await getSystemConfig(ctx);
+        const outcome = { studentId: studentId, status: null, percentage: null, letterGrade: null, passPercent: passPercent };
+
+        if (!classData.enrolledStudents.includes(studentId)) {
+            const enrollment = await getEnrollmentRecord(ctx, classData.id, studentId);
+            if (!enrollment || enrollment.status !== 'withdrawn') {
+                throw new Error(`Student ${studentId} is not enrolled in class ${classData.id}`);
+            }
+            return Object.assign(outcome, { status: 'withdrawn' });
+        }
+
+        const finalGrade = await this._computeFinalGrade(ctx, classData, studentId, false);
+        if (!finalGrade.complete) {
+            return Object.assign(outcome, { status: 'in-progress' });
+        }
+
+        return Object.assign(outcome, {
+            status: finalGrade.percentage >= passPercent ? 'completed' : 'failed',
+            percentage: finalGrade.percentage,
+            letterGrade: finalGrade.letterGrade,
+        });
+    }
+
+    /**
+     * Clé déterministe de la note d'un étudiant à un examen (imports)
+     * @private
+     */
+    _gradeKey(examId, studentId) {
+        return generateDeterministicID('GRADE', examId, studentId);
+    }
+
+    /**
+     * Crée une note (publiée ou brouillon) après toutes les vérifications communes
+     * @private
+     */
+    async _createGrade(ctx, gradeId, examId, studentId, score, comment, status, publish, override, reason, criteriaJSON, provisional) {
+        // Vérifier que l'examen existe
+        const exam = await this._getExam(ctx, examId);
+
+        // Vérifier que l'étudiant est inscrit dans la classe de l'examen
+        await this._checkEnrollment(ctx, exam.classId, studentId);
+        const classData = await this._getClass(ctx, exam.classId);
+
+        // Vérifier que la note n'existe pas déjà
+        const exists = await ctx.stub.getState(gradeId);
+        if (exists && exists.length > 0) {
+            throw new Error(`Grade ${gradeId} already exists. Use UpdateGrade to modify it.`);
+        }
+
+        // Une seule note active par (examen, étudiant): un nouvel identifiant ne doit pas
+        // remplacer une note existante (notamment figée) dans la note finale et les statistiques
+        const existing = (await this._getExamStudentGrades(ctx, examId, studentId)).find((grade) => !grade.voided);
+        if (existing) {
+            const existingId = existing.id || existing.gradeId;
+            throw new Error(`Student ${studentId} already has grade ${existingId} for exam ${examId}${existing.finalized ? ' (finalized)' : ''}. Use UpdateGrade to modify it.`);
+        }
+
+        // Vérifier que la saisie des notes n'est pas close (échéance ou CloseGrading)
+        const gradingState = await this._checkGradingOpen(ctx, exam);
+
+        // Pendant la relecture (LockExamGrades), seul le détenteur du verrou saisit les notes
+        await this._checkReviewLock(ctx, examId);
+
+        // Valider le score (borné par la note maximale de l'examen), ou le résultat en réussite/échec
+        const passFail = getGradingScheme(exam) === 'passfail';
+        const scoreNum = passFail ? null : this._parseScore(score, exam);
+        const result = passFail && status !== 'absent' ? this._parseResult(score, exam) : null;
+        const criteria = criteriaJSON ? this._parseCriteria(criteriaJSON, exam, scoreNum) : null;
+
+        if (publish) {
+            await this._checkReleaseApproval(ctx, exam);
+            await this._checkReleaseWindow(ctx, exam, gradeId, override, reason);
+        }
+
+        // Récupérer l'identité du professeur
+        const caller = this._getCallerIdentity(ctx);
+        // Horodatage de la transaction: sert de point de départ au délai de contestation
+        const txTimestamp = this._getTxTimestamp(ctx);
+
+        // Créer l'objet note
+        const grade = {
+            docType: 'grade',
+            id: gradeId,
+            examId: examId,
+            classId: exam.classId, // Stocker classId pour requêtes optimisées
+            studentId: studentId,
+            score: scoreNum, // null en réussite/échec
+            maxScore: passFail ? null : getExamMaxScore(exam), // Toujours celle de l'examen: dénominateur commun
+            gradingScheme: getGradingScheme(exam), // points, percentage ou passfail
+            result: result, // "pass" ou "fail" en réussite/échec (hors moyennes), null sinon
+            letterGrade: null, // Mention selon le barème de la classe (recalculée par RecomputeLetterGrades)
+            criteria: criteria, // Détail par critère de la grille (null si non saisi)
+            gradingDeadline: gradingState.deadline, // Échéance de saisie (examDate + gradingWindowDays)
+            lateGrading: gradingState.late ? { hoursLate: gradingState.hoursLate } : null, // Saisie après l'échéance
+            // scored, absent (aucune copie rendue: score 0, sans résultat en réussite/échec),
+            // ou no-show (aucune copie à l'échéance, FinalizeExamNoShows: zéro compté dans les moyennes)
+            status: status,
+            comment: comment || '',
+            submittedBy: caller,
+            submittedAt: txTimestamp,
+            isPublished: publish,
+            provisional: provisional === true, // Note provisoire: publiable après ConfirmGrade uniquement
+            publishedBy: publish ? caller : null,
+            publishedAt: publish ? txTimestamp : null,
+        };
+        grade.letterGrade = this._deriveLetterGrade(grade, classData.gradingScale);
+
+        // Chaîner la note aux notes précédentes de l'examen (preuve d'intégrité)
+        await appendGradeLink(ctx, grade, 'create');
+
+        // Stocker dans le ledger
+        await ctx.stub.putState(gradeId, serializeRecord(grade));
+
+        if (publish) {
+            await this._notifyGradePublished(ctx, grade, exam, classData);
+        }
+        return grade;
+    }
+
+    /**
+     * Publie les brouillons d'un examen
+     * @private
+     * @returns {Promise<string[]>} IDs des notes publiées
+     */
+    async _publishDrafts(ctx, examId, publishedBy, publishedAt) {
+        const grades = await this._getExamGradeRecords(ctx, examId);
+        const exam = await this._getExam(ctx, examId);
+        const classData = await this._getClass(ctx, exam.classId);
+
+        const published = [];
+        for (const grade of grades) {
+            // Les notes annulées (retrait de l'étudiant) et provisoires (avant ConfirmGrade) ne sont jamais publiées
+            if (grade.provisional === true || this._isPublished(grade) || grade.voided) {
+                continue;
+            }
+            grade.isPublished = true;
+            grade.publishedBy = publishedBy;
+            grade.publishedAt = publishedAt;
+            await ctx.stub.putState(grade.id, serializeRecord(grade));
+            await this._notifyGradePublished(ctx, grade, exam, classData);
+            published.push(grade.id);
+        }
+        return published;
+    }
+
+    /**
+     * Notifie l'étudiant de la publication de sa note (message rendu dans sa langue)
+     * @private
+     */
+    async _notifyGradePublished(ctx, grade, exam, classData) {
+        await createNotification(ctx, grade.studentId, 'GradePublished', {
+            gradeId: grade.id,
+            examId: exam.id,
+            examTitle: exam.title || exam.id,
+            classId: classData.id,
+            className: classData.name || classData.id,
+        });
+    }
+
+    /**
+     * Vérifie que l'appelant est teacher / co-teacher de la classe de l'examen, ou admin
+     * @private
+     * @throws {Error} Sinon
+     */
+    async _checkExamOwner(ctx, exam) {
+        const classData = await this._getClass(ctx, exam.classId);
+        const caller = this._getCallerIdentity(ctx);
+
+        const isTeacher = (classData.teacher || classData.createdBy) === caller;
+        const isCoTeacher = (classData.staff || []).some((member) => member.identityId === caller && member.role === 'co-teacher');
+
+        if (!isTeacher && !isCoTeacher && !(await this._isAdmin(ctx))) {
+            throw new Error(`Access Denied: Only the teachers of class ${exam.classId} or an admin can manage grades for exam ${exam.id}`);
+        }
+    }
+
+    /**
+     * Récupère un examen et vérifie son type
+     * @private
+     */
+    async _getExam(ctx, examId) {
+        const examAsBytes = await ctx.stub.getState(examId);
+        if (!examAsBytes || examAsBytes.length === 0) {
+            throw new Error(`Exam ${examId} does not exist`);
+        }
+
+        const exam = parseRecord(examAsBytes, examId, 'exam');
+
+        return exam;
+    }
+
+    /**
+     * Récupère une note et vérifie son type
+     * @private
+     */
+    async _getGrade(ctx, gradeId) {
+        const gradeAsBytes = await ctx.stub.getState(gradeId);
+        if (!gradeAsBytes || gradeAsBytes.length === 0) {
+            throw new Error(`Grade ${gradeId} does not exist`);
+        }
+        return parseRecord(gradeAsBytes, gradeId, 'grade');
+    }
+
+    /**
+     * Récupère une classe et vérifie son type
+     * @private
+     */
+    async _getClass(ctx, classId) {
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${classId} does not exist`);
+        }
+
+        const classData = parseRecord(classAsBytes, classId, 'class');
+
+        return classData;
+    }
+
+    /**
+     * Vérifie si une note correspond à une absence
+     * @private
+     */
+    _isAbsent(grade) {
+        return grade.status === 'absent';
+    }
+
+    /**
+     * Vérifie si une note est un résultat réussite/échec (sans score, hors moyennes)
+     * @private
+     */
+    _isPassFailGrade(grade) {
+        return grade.gradingScheme === 'passfail';
+    }
+
+    /**
+     * Statistiques descriptives d'une liste de scores (null si vide)
+     * @private
+     */
+    _computeStatistics(scores) {
+        if (scores.length === 0) {
+            return { count: 0, mean: null, median: null, min: null, max: null, stdDev: null };
+        }
+
+        const round = (value) => Math.round(value * 100) / 100;
+        const sorted = scores.slice().sort((a, b) => a - b);
+        const mean = sorted.reduce((sum, score) => sum + score, 0) / sorted.length;
+        const middle = Math.floor(sorted.length / 2);
+        const median = sorted.length % 2 === 0 ? (sorted[middle - 1] + sorted[middle]) / 2 : sorted[middle];
+        const variance = sorted.reduce((sum, score) => sum + (score - mean) * (score - mean), 0) / sorted.length;
+
+        return {
+            count: sorted.length,
+            mean: round(mean),
+            median: round(median),
+            min: sorted[0],
+            max: sorted[sorted.length - 1],
+            stdDev: round(Math.sqrt(variance)),
+        };
+    }
+
+    /**
+     * Résultats d'un examen en pourcentage: dernière note de chaque étudiant, absences exclues
+     * @private
+     * @returns {Promise<Object>} { examId, title, maxScore, scoredCount, absentCount, statistics, passRate, distribution }
+     */
+    async _getExamPerformance(ctx, exam, classData, passPercent) {
+        const latestByStudent = new Map();
+        const grades = (await this._getExamGradeRecords(ctx, exam.id))
+            .sort((a, b) => (a.submittedAt || '').localeCompare(b.submittedAt || ''));
+        for (const grade of grades) {
+            latestByStudent.set(grade.studentId, grade);
+        }
+
+        const latest = Array.from(latestByStudent.values());
+        const present = latest.filter((grade) => !this._isAbsent(grade));
+        // Les résultats réussite/échec n'ont pas de pourcentage
+        const percentages = present.filter((grade) => !this._isPassFailGrade(grade))
+            .map((grade) => Math.round(this._getPercentage(grade) * 100) / 100);
+
+        const distribution = {};
+        for (const percentage of percentages) {
+            const letter = this._getLetterGrade(percentage, classData.gradingScale).letter;
+            distribution[letter] = (distribution[letter] || 0) + 1;
+        }
+
+        const passed = percentages.filter((percentage) => percentage >= passPercent).length;
+        return {
+            examId: exam.id,
+            title: exam.title,
+            maxScore: getGradingScheme(exam) === 'passfail' ? null : getExamMaxScore(exam),
+            scoredCount: percentages.length,
+            absentCount: latest.length - present.length,
+            statistics: this._computeStatistics(percentages),
+            passRate: percentages.length > 0 ? Math.round((passed / percentages.length) * 10000) / 100 : null,
+            distribution: distribution,
+        };
+    }
+
+    /**
+     * Pourcentage obtenu pour une note
+     * @private
+     */
+    _getPercentage(grade) {
+        return (grade.score / (grade.maxScore || DEFAULT_MAX_SCORE)) * 100;
+    }
+
+    /**
+     * Mention et points GPA correspondant à un pourcentage
+     * @private
+     */
+    _getLetterGrade(percentage, letterScale) {
+        const scale = letterScale || DEFAULT_LETTER_SCALE;
+        return scale.find((row) => percentage >= row.minPercent) || scale[scale.length - 1];
+    }
+
+    /**
+     * Mention enregistrée sur une note (null pour une absence ou un résultat réussite/échec)
+     * Le score n'est jamais modifié
+     * @private
+     */
+    _deriveLetterGrade(grade, letterScale) {
+        if (this._isAbsent(grade) || this._isPassFailGrade(grade) || typeof grade.score !== 'number') {
+            return null;
+        }
+        const percentage = Math.round(this._getPercentage(grade) * 100) / 100;
+        return this._getLetterGrade(percentage, letterScale).letter;
+    }
+
+    /**
+     * Indique si les coefficients des examens s'appliquent: tous les examens notés
+     * (hors réussite/échec) ont un coefficient > 0, sinon ils comptent à égalité
+     * @private
+     */
+    _usesExamWeights(exams) {
+        const scoredExams = exams.filter((exam) => getGradingScheme(exam) !== 'passfail');
+        return scoredExams.length > 0 && scoredExams.every((exam) => typeof exam.weight === 'number' && exam.weight > 0);
+    }
+
+    /**
+     * Calcule la note finale d'un étudiant dans une classe
+     *
+     * Pondération: coefficients des examens s'ils sont tous définis (> 0),
+     * sinon tous les examens comptent à égalité
+     * Examens en réussite/échec: résultat listé, hors moyenne pondérée
+     * complete = chaque examen de la classe a une note publiée
+     * Retard: la pénalité de l'examen est déduite de la note (plancher à 0)
+     * d'après l'heure de remise du reçu de copie
+     *
+     * @private
+     */
+    async _computeFinalGrade(ctx, classData, studentId, includeUnpublished) {
+        const exams = await this._queryRecords(ctx, { docType: 'exam', classId: classData.id });
+        const grades = await this._queryRecords(ctx, { docType: 'grade', classId: classData.id, studentId: studentId });
+
+        const useWeights = this._usesExamWeights(exams);
+        const breakdown = [];
+        let weightedSum = 0;
+        let weightTotal = 0;
+        let complete = exams.length > 0;
+
+        for (const exam of exams) {
+            // Note la plus récente de l'étudiant pour cet examen
+            const examGrades = grades
+                .filter((grade) => grade.examId === exam.id && (includeUnpublished || this._isPublished(grade)))
+                .sort((a, b) => (b.publishedAt || b.submittedAt || '').localeCompare(a.publishedAt || a.submittedAt || ''));
+            const grade = examGrades[0];
+
+            if (!grade || !this._isPublished(grade)) {
+                complete = false;
+            }
+            if (!grade) {
+                continue;
+            }
+
+            if (getGradingScheme(exam) === 'passfail') {
+                breakdown.push({
+                    examId: exam.id,
+                    gradeId: grade.id,
+                    weight: 0,
+                    score: null,
+                    result: grade.result || null,
+                    percentage: null,
+                    isPublished: this._isPublished(grade),
+                });
+                continue;
+            }
+
+            const weight = useWeights ? exam.weight : 1;
+            const submission = await this._getSubmission(ctx, exam.id, studentId);
+            const late = computeLatePenalty(exam, submission);
+            const effectiveScore = Math.max(0, grade.score - late.penalty);
+            const percentage = this._getPercentage(Object.assign({}, grade, { score: effectiveScore }));
+            weightedSum += percentage * weight;
+            weightTotal += weight;
+
+            breakdown.push({
+                examId: exam.id,
+                gradeId: grade.id,
+                weight: weight,
+                score: grade.score,
+                hoursLate: late.hoursLate,
+                latePenalty: late.penalty,
+                effectiveScore: effectiveScore,
+                percentage: Math.round(percentage * 100) / 100,
+                isPublished: this._isPublished(grade),
+            });
+        }
+
+        const percentage = weightTotal > 0 ? Math.round((weightedSum / weightTotal) * 100) / 100 : null;
+        const letter = percentage === null ? null : this._getLetterGrade(percentage, classData.gradingScale);
+
+        return {
+            classId: classData.id,
+            studentId: studentId,
+            percentage: percentage,
+            letterGrade: letter ? letter.letter : null,
+            gpaPoints: letter ? letter.points : null,
+            complete: complete,
+            exams: breakdown,
+        };
+    }
+
+    /**
+     * Valide un score: nombre positif, au plus la note maximale de l'examen
+     * @private
+     */
+    _parseScore(score, exam) {
+        const scoreNum = parseFloat(score);
+        if (isNaN(scoreNum) || scoreNum < 0) {
+            throw new Error('Invalid score: must be a positive number');
+        }
+        const maxScore = getExamMaxScore(exam);
+        if (scoreNum > maxScore) {
+            throw new Error(`Invalid score: ${scoreNum} exceeds the maxScore ${maxScore} of exam ${exam.id}`);
+        }
+        return scoreNum;
+    }
+
+    /**
+     * Valide un résultat réussite/échec ("pass" ou "fail")
+     * @private
+     */
+    _parseResult(result, exam) {
+        if (result !== 'pass' && result !== 'fail') {
+            throw new Error(`Invalid result: exam ${exam.id} is graded pass/fail, expected "pass" or "fail"`);
+        }
+        return result;
+    }
+
+    /**
+     * Valide les points par critère de la grille de l'examen
+     * Chaque critère de la grille est noté entre 0 et ses maxPoints; la somme vaut le score
+     * @private
+     * @returns {Array} [{ id, label, points, maxPoints }] dans l'ordre de la grille
+     */
+    _parseCriteria(criteriaJSON, exam, scoreNum) {
+        if (!exam.rubric) {
+            throw new Error(`Exam ${exam.id} has no rubric: per-criterion scores are not accepted`);
+        }
+
+        let points;
+        try {
+            points = JSON.parse(criteriaJSON);
+        } catch (err) {
+            throw new Error('Invalid criteriaJSON: must be a JSON object of criterion IDs to points');
+        }
+        if (!points || typeof points !== 'object' || Array.isArray(points)) {
+            throw new Error('Invalid criteriaJSON: must be a JSON object of criterion IDs to points');
+        }
+
+        const unknown = Object.keys(points).filter((id) => !exam.rubric.some((criterion) => criterion.id === id));
+        if (unknown.length > 0) {
+            throw new Error(`Invalid criteriaJSON: unknown criteria ${unknown.join(', ')}`);
+        }
+
+        const criteria = exam.rubric.map((criterion) => {
+            const value = points[criterion.id];
+            if (typeof value !== 'number' || !Number.isFinite(value)) {
+                throw new Error(`Invalid criteriaJSON: missing score for criterion ${criterion.id}`);
+            }
+            if (value < 0 || value > criterion.maxPoints) {
+                throw new Error(`Invalid score for criterion ${criterion.id}: ${value} is outside 0-${criterion.maxPoints}`);
+            }
+            return { id: criterion.id, label: criterion.label, points: value, maxPoints: criterion.maxPoints };
+        });
+
+        const total = criteria.reduce((sum, criterion) => sum + criterion.points, 0);
+        if (Math.abs(total - scoreNum) > POINTS_EPSILON) {
+            throw new Error(`Invalid criteriaJSON: criteria total ${total}, expected the score ${scoreNum}`);
+        }
+        return criteria;
+    }
+
+    /**
+     * Récupère le reçu de remise d'une copie (null si absent)
+     * @private
+     */
+    async _getSubmission(ctx, examId, studentId) {
+        return getSubmissionRecord(ctx, examId, studentId);
+    }
+
+    /**
+     * Récupère les enregistrements bruts des notes d'un examen
+     * @private
+     */
+    async _getExamGradeRecords(ctx, examId) {
+        return this._queryRecords(ctx, { docType: 'grade', examId: examId });
+    }
+
+    /**
+     * Notes d'un étudiant pour un examen (clé déterministe GRADE_ comprise), annulées incluses
+     * @private
+     */
+    async _getExamStudentGrades(ctx, examId, studentId) {
+        const records = await this._queryRecords(ctx, { docType: 'grade', examId: examId, studentId: studentId });
+
+        const key = this._gradeKey(examId, studentId);
+        if (!records.some((record) => record.id === key)) {
+            const gradeAsBytes = await ctx.stub.getState(key);
+            if (gradeAsBytes && gradeAsBytes.length > 0) {
+                records.push(parseRecord(gradeAsBytes, key, 'grade'));
+            }
+        }
+        return records;
+    }
+
+    /**
+     * Exécute un sélecteur d'égalité simple
+     * CouchDB si disponible, sinon parcours complet du ledger filtré sur les mêmes champs
+     * @private
+     */
+    async _queryRecords(ctx, selector) {
+        const queryString = JSON.stringify({ selector: selector });
+        const allResults = [];
+        let iterator;
+
+        try {
+            // Utiliser CouchDB rich query pour optimisation
+            iterator = await ctx.stub.getQueryResult(queryString);
+        } catch (err) {
+            // Fallback si CouchDB non disponible: parcours complet, filtré ci-dessous
+            console.warn('CouchDB query failed, using fallback method:', err);
+            iterator = await ctx.stub.getStateByRange('', '');
+        }
+
+        let result = await iterator.next();
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if (Object.keys(selector).every((key) => record[key] === selector[key])) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
     }
 
     // ==================== FONCTIONS FALLBACK (sans CouchDB) ====================
@@ -506,11 +3639,11 @@ class GradeContract extends Contract {
                 record = JSON.parse(strValue);
 
                 if (record.docType === 'grade' && record.classId === classId) {
-                    // Teachers voient tout, étudiants seulement leurs notes
-                    if (isTeacher || record.studentId === callerId) {
+                    // Teachers voient tout, étudiants seulement leurs notes publiées
+                    if (isTeacher || (record.studentId === callerId && this._isPublished(record))) {
                         const examAsBytes = await ctx.stub.getState(record.examId);
                         if (examAsBytes && examAsBytes.length > 0) {
-                            const exam = JSON.parse(examAsBytes.toString());
+                            const exam = parseRecord(examAsBytes, record.examId, 'exam');
                             allResults.push({
                                 id: record.id,
                                 examId: record.examId,
@@ -540,6 +3673,7 @@ class GradeContract extends Contract {
      * Fallback pour GetStudentGrades si CouchDB non disponible
      */
     async _getStudentGradesFallback(ctx, studentId, classId) {
+        const isTeacher = this._isSchoolMember(ctx);
         const allResults = [];
         const iterator = await ctx.stub.getStateByRange('', '');
         let result = await iterator.next();
@@ -553,10 +3687,11 @@ class GradeContract extends Contract {
 
                 if (record.docType === 'grade' &&
                     record.classId === classId &&
-                    record.studentId === studentId) {
+                    record.studentId === studentId &&
+                    (isTeacher || this._isPublished(record))) {
                     const examAsBytes = await ctx.stub.getState(record.examId);
                     if (examAsBytes && examAsBytes.length > 0) {
-                        const exam = JSON.parse(examAsBytes.toString());
+                        const exam = parseRecord(examAsBytes, record.examId, 'exam');
                         allResults.push({
                             id: record.id,
                             examId: record.examId,
@@ -585,6 +3720,7 @@ class GradeContract extends Contract {
      */
     async _getClassGradesFallback(ctx, classId) {
         const allResults = [];
+        let truncated = false;
         const iterator = await ctx.stub.getStateByRange('', '');
         let result = await iterator.next();
 
@@ -598,7 +3734,11 @@ class GradeContract extends Contract {
                 if (record.docType === 'grade' && record.classId === classId) {
                     const examAsBytes = await ctx.stub.getState(record.examId);
                     if (examAsBytes && examAsBytes.length > 0) {
-                        const exam = JSON.parse(examAsBytes.toString());
+                        const exam = parseRecord(examAsBytes, record.examId, 'exam');
+                        if (allResults.length === MAX_QUERY_RESULTS) {
+                            truncated = true;
+                            break;
+                        }
                         allResults.push({
                             id: record.id,
                             examId: record.examId,
@@ -620,7 +3760,7 @@ class GradeContract extends Contract {
         }
 
         await iterator.close();
-        return JSON.stringify(allResults);
+        return JSON.stringify({ grades: allResults, count: allResults.length, truncated: truncated });
     }
 
     // ==================== FONCTIONS UTILITAIRES BONUS ====================
@@ -628,11 +3768,12 @@ class GradeContract extends Contract {
     /**
      * Mettre à jour une note existante
      * Accessible par: Teachers uniquement
+     * Le détail par critère est remplacé par criteriaJSON, ou retiré s'il n'est pas fourni
      */
-    async UpdateGrade(ctx, gradeId, newScore, newComment) {
+    async UpdateGrade(ctx, gradeId, newScore, newComment, criteriaJSON) {
         console.info('============= START : UpdateGrade ===========');
 
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only teachers can update grades');
         }
 
@@ -641,30 +3782,44 @@ class GradeContract extends Contract {
             throw new Error(`Grade ${gradeId} does not exist`);
         }
 
-        const grade = JSON.parse(gradeAsBytes.toString());
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+        this._checkNotFinalized(grade);
+        const exam = await this._getExam(ctx, grade.examId);
 
-        if (grade.docType !== 'grade') {
-            throw new Error(`${gradeId} is not a grade`);
-        }
+        // Pendant la relecture (LockExamGrades), seul le détenteur du verrou modifie les notes
+        await this._checkReviewLock(ctx, grade.examId);
 
-        // Valider le nouveau score
-        const scoreNum = parseFloat(newScore);
-        if (isNaN(scoreNum) || scoreNum < 0) {
-            throw new Error('Invalid score: must be a positive number');
-        }
+        // Valider le nouveau score (borné par la note maximale de l'examen), ou le résultat en réussite/échec
+        const passFail = getGradingScheme(exam) === 'passfail';
+        const scoreNum = passFail ? null : this._parseScore(newScore, exam);
+
+        const criteria = criteriaJSON ? this._parseCriteria(criteriaJSON, exam, scoreNum) : null;
 
         // Mettre à jour
         grade.score = scoreNum;
+        grade.maxScore = passFail ? null : getExamMaxScore(exam);
+        grade.gradingScheme = getGradingScheme(exam);
+        grade.result = passFail ? this._parseResult(newScore, exam) : null;
+        grade.criteria = criteria;
+        grade.status = 'scored'; // Une note saisie remplace une absence
+        // Les notes saisies par le contrat principal (legacy) n'ont pas de classId: classe de l'examen
+        grade.letterGrade = this._deriveLetterGrade(grade, (await this._getClass(ctx, exam.classId)).gradingScale);
         grade.comment = newComment || grade.comment;
+        // L'accusé de réception portait sur l'ancienne note
+        grade.acknowledged = false;
+        grade.acknowledgedAt = null;
         grade.updatedBy = this._getCallerIdentity(ctx);
-        grade.updatedAt = new Date().toISOString();
+        grade.updatedAt = this._getTxTimestamp(ctx);
+
+        await appendGradeLink(ctx, grade, 'update');
 
-        await ctx.stub.putState(gradeId, Buffer.from(JSON.stringify(grade)));
+        await ctx.stub.putState(gradeId, serializeRecord(grade));
 
         ctx.stub.setEvent('GradeUpdated', Buffer.from(JSON.stringify({
             gradeId: gradeId,
             studentId: grade.studentId,
             newScore: scoreNum,
+            result: grade.result,
             updatedBy: grade.updatedBy,
         })));
 
@@ -678,13 +3833,16 @@ class GradeContract extends Contract {
     }
 
     /**
-     * Supprimer une note
-     * Accessible par: Teachers uniquement
+     * Supprimer une note saisie par erreur
+     * Accessible par: Teacher / co-teacher de la classe + admins
+     *
+     * Garde-fous: uniquement une note non publiée, motif obligatoire,
+     * suppression tracée dans le journal d'audit
      */
-    async DeleteGrade(ctx, gradeId) {
+    async DeleteGrade(ctx, gradeId, reason) {
         console.info('============= START : DeleteGrade ===========');
 
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only teachers can delete grades');
         }
 
@@ -693,12 +3851,29 @@ class GradeContract extends Contract {
             throw new Error(`Grade ${gradeId} does not exist`);
         }
 
-        const grade = JSON.parse(gradeAsBytes.toString());
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
+
+        const exam = await this._getExam(ctx, grade.examId);
+        await this._checkExamOwner(ctx, exam);
 
-        if (grade.docType !== 'grade') {
-            throw new Error(`${gradeId} is not a grade`);
+        // Pendant la relecture (LockExamGrades), seul le détenteur du verrou supprime les notes
+        await this._checkReviewLock(ctx, grade.examId);
+
+        this._checkNotFinalized(grade);
+        if (this._isPublished(grade)) {
+            throw new Error(`Cannot delete grade ${gradeId}: it has been published (unpublished grades only)`);
+        }
+        if (!reason || !reason.trim()) {
+            throw new Error('Missing reason: deleting a grade requires a justification');
         }
 
+        await writeAuditEntry(ctx, 'GradeDeleted', gradeId, reason, {
+            examId: grade.examId,
+            studentId: grade.studentId,
+            score: grade.score,
+        });
+
+        await appendGradeLink(ctx, grade, 'delete');
         await ctx.stub.deleteState(gradeId);
 
         const caller = this._getCallerIdentity(ctx);
@@ -730,20 +3905,55 @@ class GradeContract extends Contract {
             throw new Error(`Grade ${gradeId} does not exist`);
         }
 
-        const grade = JSON.parse(gradeAsBytes.toString());
-
-        if (grade.docType !== 'grade') {
-            throw new Error(`${gradeId} is not a grade`);
-        }
+        const grade = parseRecord(gradeAsBytes, gradeId, 'grade');
 
         // Vérifier l'accès
         this._canAccessGrade(ctx, grade.studentId);
 
+        if (this._isStudentMember(ctx) && !this._isPublished(grade)) {
+            throw new Error('Grade not yet published by the teacher');
+        }
+
         console.info(`✅ Grade retrieved: ${gradeId}`);
         console.info('============= END : GetGrade ===========');
 
         return JSON.stringify(grade);
     }
+
+    /**
+     * Obtenir la note d'un étudiant à un examen (sans connaître le gradeId)
+     * Accessible par: Teacher + Étudiant concerné (mêmes règles que GetGrade)
+     *
+     * Clé déterministe (imports CSV) et recherche par examen et étudiant; si plusieurs notes
+     * existent, la note non annulée la plus récente est renvoyée
+     */
+    async GetGradeByExamStudent(ctx, examId, studentId) {
+        console.info('============= START : GetGradeByExamStudent ===========');
+
+        // Vérifier l'accès avant toute recherche
+        this._canAccessGrade(ctx, studentId);
+
+        // Plusieurs notes possibles (notes annulées, doublons antérieurs): la note non annulée
+        // la plus récente (submittedAt, puis identifiant) est renvoyée
+        const records = (await this._getExamStudentGrades(ctx, examId, studentId))
+            .sort((a, b) => Number(Boolean(a.voided)) - Number(Boolean(b.voided))
+                || String(b.submittedAt || '').localeCompare(String(a.submittedAt || ''))
+                || String(b.id || b.gradeId).localeCompare(String(a.id || a.gradeId)));
+        const grade = records.length > 0 ? records[0] : null;
+
+        if (!grade) {
+            throw new Error(`No grade found for student ${studentId} in exam ${examId}`);
+        }
+
+        if (this._isStudentMember(ctx) && !this._isPublished(grade)) {
+            throw new Error('Grade not yet published by the teacher');
+        }
+
+        console.info(`✅ Grade retrieved: ${grade.id} (exam ${examId}, student ${studentId})`);
+        console.info('============= END : GetGradeByExamStudent ===========');
+
+        return JSON.stringify(grade);
+    }
 }
 
 module.exports = GradeContract;
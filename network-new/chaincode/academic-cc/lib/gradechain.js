@@ -0,0 +1,131 @@
+/*
+ * Chaîne de hachage des notes d'un examen
+ *
+ * Chaque création, modification ou suppression de note ajoute un maillon
+ * (GRADELINK_<examId>_<index>) qui référence le hash du maillon précédent;
+ * la tête (GRADECHAIN_<examId>) porte la longueur et le dernier hash.
+ * Partagée par les contrats qui écrivent des notes (GradeContract, fusion de classes).
+ */
+
+'use strict';
+
+const crypto = require('crypto');
+const { parseRecord, serializeRecord, canonicalStringify } = require('./records');
+
+// Champs d'une note couverts par la chaîne de hachage (le statut de publication en est exclu)
+// Version 1: maillons antérieurs aux barèmes; version 2: + résultat pass/fail, note maximale et détail par critère
+const CHAINED_GRADE_FIELDS = {
+    1: ['id', 'examId', 'classId', 'studentId', 'score', 'status', 'comment', 'submittedBy', 'submittedAt'],
+    2: ['id', 'examId', 'classId', 'studentId', 'score', 'status', 'comment', 'submittedBy', 'submittedAt', 'result', 'maxScore', 'criteria'],
+};
+const GRADE_CONTENT_VERSION = 2;
+
+/**
+ * Clés de la chaîne de hachage des notes d'un examen
+ */
+function gradeChainKey(examId) {
+    return `GRADECHAIN_${examId}`;
+}
+
+function gradeLinkKey(examId, index) {
+    return `GRADELINK_${examId}_${index}`;
+}
+
+/**
+ * Tête de la chaîne d'un examen ({ length: 0 } si aucune note)
+ */
+async function getGradeChainHead(ctx, examId) {
+    const key = gradeChainKey(examId);
+
+    // getState ne voit pas les écritures de la transaction en cours:
+    // la tête déjà avancée dans cette transaction est relue depuis le contexte
+    if (ctx.gradeChainHeads && ctx.gradeChainHeads.has(key)) {
+        return ctx.gradeChainHeads.get(key);
+    }
+
+    const headAsBytes = await ctx.stub.getState(key);
+    if (!headAsBytes || headAsBytes.length === 0) {
+        return { docType: 'gradeChain', id: key, examId: examId, length: 0, gradeCount: 0, lastHash: '' };
+    }
+    return parseRecord(headAsBytes, key, 'gradeChain');
+}
+
+/**
+ * Hash du contenu chaîné d'une note
+ * contentVersion: version du maillon (GRADE_CONTENT_VERSION par défaut, 1 si le maillon n'en porte pas)
+ * JSON canonique: le détail par critère est relu avec ses clés triées
+ */
+function hashGradeContent(grade, contentVersion) {
+    const fields = CHAINED_GRADE_FIELDS[contentVersion || GRADE_CONTENT_VERSION];
+    const content = fields.map((field) => (grade[field] === undefined ? null : grade[field]));
+    return crypto.createHash('sha256').update(canonicalStringify(content)).digest('hex');
+}
+
+/**
+ * Hash d'un maillon: maillon précédent + action + contenu de la note
+ */
+function hashGradeLink(link) {
+    const content = [link.prevHash, link.index, link.gradeId, link.action, link.contentHash];
+    return crypto.createHash('sha256').update(JSON.stringify(content)).digest('hex');
+}
+
+/**
+ * Ajoute un maillon à la chaîne de l'examen et met à jour prevHash/hash de la note
+ * L'appelant enregistre la note
+ *
+ * @param {Context} ctx - Le contexte de transaction
+ * @param {Object} grade - Note créée, modifiée ou supprimée
+ * @param {string} action - "create", "update" ou "delete"
+ */
+async function appendGradeLink(ctx, grade, action) {
+    const head = await getGradeChainHead(ctx, grade.examId);
+
+    const timestamp = ctx.stub.getTxTimestamp();
+    const seconds = timestamp.seconds.low || timestamp.seconds;
+
+    const link = {
+        docType: 'gradeLink',
+        id: gradeLinkKey(grade.examId, head.length),
+        examId: grade.examId,
+        index: head.length,
+        gradeId: grade.id,
+        action: action,
+        contentHash: action === 'delete' ? null : hashGradeContent(grade, GRADE_CONTENT_VERSION),
+        contentVersion: GRADE_CONTENT_VERSION,
+        prevHash: head.lastHash,
+        txId: ctx.stub.getTxID(),
+        timestamp: new Date(seconds * 1000).toISOString(),
+    };
+    link.hash = hashGradeLink(link);
+
+    grade.chainIndex = link.index;
+    grade.prevHash = link.prevHash;
+    grade.hash = link.hash;
+
+    head.length += 1;
+    head.lastHash = link.hash;
+    // Compteur des notes existantes: son historique trace l'avancement de la correction
+    const gradeCount = typeof head.gradeCount === 'number' ? head.gradeCount : head.length - 1;
+    if (action === 'create') {
+        head.gradeCount = gradeCount + 1;
+    } else if (action === 'delete') {
+        head.gradeCount = Math.max(gradeCount - 1, 0);
+    } else {
+        head.gradeCount = gradeCount;
+    }
+
+    await ctx.stub.putState(link.id, serializeRecord(link));
+    await ctx.stub.putState(head.id, serializeRecord(head));
+
+    ctx.gradeChainHeads = ctx.gradeChainHeads || new Map();
+    ctx.gradeChainHeads.set(head.id, head);
+}
+
+module.exports = {
+    gradeChainKey,
+    gradeLinkKey,
+    getGradeChainHead,
+    hashGradeContent,
+    hashGradeLink,
+    appendGradeLink,
+};
@@ -0,0 +1,38 @@
+/*
+ * Identifiants déterministes des enregistrements créés automatiquement
+ *
+ * Les identifiants générés par le chaincode (notes importées, reçus de
+ * remise...) sont dérivés uniquement d'entrées stables: aucun compteur,
+ * horodatage ou aléa, chaque peer calcule donc la même clé et une même
+ * entrée redonne toujours la même clé (réessai idempotent).
+ *
+ * Les entrées sont encodées en JSON avant hachage: contrairement à une
+ * simple concaténation ("E_1" + "x" et "E" + "1_x"), deux listes
+ * d'entrées différentes ne produisent jamais la même chaîne hachée.
+ */
+
+'use strict';
+
+const crypto = require('crypto');
+
+// Longueur du condensat conservé dans l'identifiant (caractères hexadécimaux, 160 bits)
+const ID_HASH_LENGTH = 40;
+
+/**
+ * Génère un identifiant déterministe à partir d'entrées stables
+ *
+ * @param {string} prefix - Préfixe lisible du type d'enregistrement (ex: "GRADE")
+ * @param {...string} parts - Entrées stables (ex: examId, studentId)
+ * @returns {string} Identifiant (ex: "GRADE_3f1c...")
+ * @throws {Error} Si une entrée est absente ou vide
+ */
+function generateDeterministicID(prefix, ...parts) {
+    if (parts.length === 0 || parts.some((part) => typeof part !== 'string' || part === '')) {
+        throw new Error(`Invalid ${prefix} id inputs: every part must be a non-empty string`);
+    }
+
+    const hash = crypto.createHash('sha256').update(JSON.stringify(parts)).digest('hex');
+    return `${prefix}_${hash.substring(0, ID_HASH_LENGTH)}`;
+}
+
+module.exports = { generateDeterministicID };
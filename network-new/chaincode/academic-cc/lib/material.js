@@ -5,11 +5,15 @@
  * - Upload: SchoolMSP uniquement (teachers)
  * - Accès aux matériaux: Étudiants inscrits + Teachers
  * - Stockage IPFS off-chain, hash stocké on-chain
+ * - Quota par classe sur la taille déclarée des supports (maxTotalBytes)
+ * - Ordre d'affichage des supports: teacher responsable de la classe + admins
  */
 
 'use strict';
 
 const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord } = require('./records');
+const { getCallerRole, isTeachingStaff } = require('./role');
 
 class MaterialContract extends Contract {
 
@@ -42,6 +46,15 @@ class MaterialContract extends Contract {
         return match ? match[1] : userID;
     }
 
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
     /**
      * Vérifie si l'appelant a accès à une classe
      * - Teachers (SchoolMSP) : accès à tout
@@ -73,12 +86,7 @@ class MaterialContract extends Contract {
                 throw new Error(`Class ${classId} does not exist`);
             }
 
-            const classData = JSON.parse(classAsBytes.toString());
-
-            // Vérifier que c'est bien une classe
-            if (classData.docType !== 'class') {
-                throw new Error(`${classId} is not a valid class`);
-            }
+            const classData = parseRecord(classAsBytes, classId, 'class');
 
             // Vérifier si l'étudiant est inscrit
             if (!classData.enrolledStudents.includes(caller)) {
@@ -107,13 +115,14 @@ class MaterialContract extends Contract {
      * @param {string} title - Titre du support
      * @param {string} type - Type: "COURS" ou "TP"
      * @param {string} ipfsHash - Hash IPFS du fichier
+     * @param {string} [size] - Taille déclarée du fichier en octets (obligatoire si la classe a un quota)
      * @returns {string} materialId
      */
-    async UploadCourseMaterial(ctx, materialId, classId, moduleId, title, type, ipfsHash) {
+    async UploadCourseMaterial(ctx, materialId, classId, moduleId, title, type, ipfsHash, size) {
         console.info('============= START : UploadCourseMaterial ===========');
 
         // CONTRÔLE D'ACCÈS: Seulement SchoolOrg peut uploader
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only SchoolOrg members (teachers) can upload materials');
         }
 
@@ -128,10 +137,7 @@ class MaterialContract extends Contract {
             throw new Error(`Class ${classId} does not exist`);
         }
 
-        const classData = JSON.parse(classAsBytes.toString());
-        if (classData.docType !== 'class') {
-            throw new Error(`${classId} is not a valid class`);
-        }
+        const classData = parseRecord(classAsBytes, classId, 'class');
 
         // Vérifier que le support n'existe pas déjà
         const exists = await ctx.stub.getState(materialId);
@@ -139,6 +145,27 @@ class MaterialContract extends Contract {
             throw new Error(`Material ${materialId} already exists`);
         }
 
+        // Valider la taille déclarée
+        const sizeNum = size !== undefined && size !== '' ? Number(size) : null;
+        if (sizeNum !== null && (!Number.isInteger(sizeNum) || sizeNum < 0)) {
+            throw new Error('Invalid size: must be a positive integer (bytes)');
+        }
+
+        // QUOTA: la taille totale déclarée des supports ne peut dépasser maxTotalBytes
+        if (classData.maxTotalBytes) {
+            if (sizeNum === null) {
+                throw new Error(`Missing size: class ${classId} has a storage quota, the material size is required`);
+            }
+            const usedBytes = await this._getUsedBytes(ctx, classId);
+            if (usedBytes + sizeNum > classData.maxTotalBytes) {
+                throw new Error(`Storage quota exceeded for class ${classId}: ${usedBytes} + ${sizeNum} bytes > ${classData.maxTotalBytes} bytes`);
+            }
+        }
+
+        // Nouveau support affiché après les supports existants de la classe
+        const orders = (await this._getClassMaterialRecords(ctx, classId)).map((record) => record.order || 0);
+        const order = Math.max(0, ...orders) + 1;
+
         // Récupérer l'identité de l'uploader
         const uploadedBy = this._getCallerIdentity(ctx);
 
@@ -151,12 +178,14 @@ class MaterialContract extends Contract {
             title: title,
             type: type,
             ipfsHash: ipfsHash,
+            size: sizeNum, // Taille déclarée en octets (null si non fournie)
+            order: order, // Rang d'affichage dans la classe (ReorderClassMaterials)
             uploadedBy: uploadedBy,
-            uploadedAt: new Date().toISOString(),
+            uploadedAt: this._getTxTimestamp(ctx),
         };
 
         // Stocker dans le ledger
-        await ctx.stub.putState(materialId, Buffer.from(JSON.stringify(material)));
+        await ctx.stub.putState(materialId, serializeRecord(material));
 
         // Émettre un événement
         ctx.stub.setEvent('MaterialUploaded', Buffer.from(JSON.stringify({
@@ -177,6 +206,7 @@ class MaterialContract extends Contract {
      * 2. Obtenir tous les supports d'une classe
      *
      * RÈGLE CRITIQUE: Vérifie que l'appelant est inscrit dans la classe
+     * Supports triés par ordre d'affichage (ReorderClassMaterials)
      *
      * Accessible par: Étudiants inscrits + Teachers
      *
@@ -205,16 +235,7 @@ class MaterialContract extends Contract {
 
                 // Filtrer les matériaux de cette classe uniquement
                 if (record.docType === 'material' && record.classId === classId) {
-                    allResults.push({
-                        id: record.id,
-                        classId: record.classId,
-                        moduleId: record.moduleId,
-                        title: record.title,
-                        type: record.type,
-                        uploadedBy: record.uploadedBy,
-                        uploadedAt: record.uploadedAt,
-                        // ipfsHash exclu pour des raisons de sécurité (utiliser GetMaterialFile)
-                    });
+                    allResults.push(this._toMaterialSummary(record));
                 }
             } catch (err) {
                 console.log('Error parsing record:', err);
@@ -225,6 +246,8 @@ class MaterialContract extends Contract {
 
         await iterator.close();
 
+        allResults.sort((a, b) => this._compareMaterialOrder(a, b));
+
         const caller = this._getCallerIdentity(ctx);
         console.info(`✅ Retrieved ${allResults.length} materials for class ${classId} by ${caller}`);
         console.info('============= END : GetCourseMaterials ===========');
@@ -232,6 +255,120 @@ class MaterialContract extends Contract {
         return JSON.stringify(allResults);
     }
 
+    /**
+     * 2 bis. Rechercher un mot-clé dans les supports d'une classe
+     *
+     * Correspondance par sous-chaîne, insensible à la casse, sur le titre
+     * (et sur le type si matchType="true")
+     *
+     * Accessible par: Étudiants inscrits + Teachers
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @param {string} keyword - Mot-clé recherché
+     * @param {string} [matchType] - "true" pour rechercher aussi dans le type (COURS, TP)
+     * @returns {string} JSON array des supports correspondants
+     */
+    async GetClassMaterialsSearch(ctx, classId, keyword, matchType) {
+        console.info('============= START : GetClassMaterialsSearch ===========');
+
+        // CONTRÔLE D'ACCÈS: Vérifier l'enrollment
+        await this._checkEnrollment(ctx, classId);
+
+        const term = (keyword || '').trim().toLowerCase();
+        if (!term) {
+            throw new Error('Missing keyword: a search term is required');
+        }
+
+        const fields = matchType === 'true' ? ['title', 'type'] : ['title'];
+        const allResults = (await this._getClassMaterialRecords(ctx, classId))
+            .filter((material) => fields.some((field) => typeof material[field] === 'string' && material[field].toLowerCase().includes(term)))
+            .map((material) => this._toMaterialSummary(material));
+
+        allResults.sort((a, b) => this._compareMaterialOrder(a, b));
+
+        const caller = this._getCallerIdentity(ctx);
+        console.info(`✅ Search "${keyword}" in materials of ${classId} by ${caller}: ${allResults.length} results`);
+        console.info('============= END : GetClassMaterialsSearch ===========');
+
+        return JSON.stringify(allResults);
+    }
+
+    /**
+     * 2 ter. Réordonner les supports d'une classe
+     *
+     * Accessible par: Teacher responsable de la classe + admins
+     * orderedIdsJSON doit lister exactement les supports de la classe (chacun une fois);
+     * ils reçoivent les rangs 1, 2, 3... dans cet ordre
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} classId - ID de la classe
+     * @param {string} orderedIdsJSON - JSON array des IDs de supports dans l'ordre d'affichage
+     * @returns {string} JSON { classId, materials: [{ id, order }] }
+     */
+    async ReorderClassMaterials(ctx, classId, orderedIdsJSON) {
+        console.info('============= START : ReorderClassMaterials ===========');
+
+        if (!(await isTeachingStaff(ctx))) {
+            throw new Error('Access Denied: Only SchoolOrg members (teachers) can reorder materials');
+        }
+
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${classId} does not exist`);
+        }
+        const classData = parseRecord(classAsBytes, classId, 'class');
+
+        const caller = this._getCallerIdentity(ctx);
+        if ((classData.teacher || classData.createdBy) !== caller && await getCallerRole(ctx) !== 'admin') {
+            throw new Error(`Access Denied: Only the teacher of class ${classId} or an admin can reorder its materials`);
+        }
+
+        let orderedIds;
+        try {
+            orderedIds = JSON.parse(orderedIdsJSON);
+        } catch (err) {
+            throw new Error('Invalid orderedIdsJSON: must be a JSON array of material IDs');
+        }
+        if (!Array.isArray(orderedIds) || orderedIds.some((id) => typeof id !== 'string')) {
+            throw new Error('Invalid orderedIdsJSON: must be a JSON array of material IDs');
+        }
+        if (new Set(orderedIds).size !== orderedIds.length) {
+            throw new Error('Invalid orderedIdsJSON: each material must appear once');
+        }
+
+        const materials = new Map((await this._getClassMaterialRecords(ctx, classId)).map((material) => [material.id, material]));
+        const unknown = orderedIds.filter((id) => !materials.has(id));
+        if (unknown.length > 0) {
+            throw new Error(`Invalid orderedIdsJSON: not materials of class ${classId}: ${unknown.join(', ')}`);
+        }
+        const missing = Array.from(materials.keys()).filter((id) => !orderedIds.includes(id)).sort();
+        if (missing.length > 0) {
+            throw new Error(`Invalid orderedIdsJSON: missing materials of class ${classId}: ${missing.join(', ')}`);
+        }
+
+        const ordered = [];
+        for (const [index, id] of orderedIds.entries()) {
+            const material = materials.get(id);
+            if (material.order !== index + 1) {
+                material.order = index + 1;
+                await ctx.stub.putState(id, serializeRecord(material));
+            }
+            ordered.push({ id: id, order: material.order });
+        }
+
+        ctx.stub.setEvent('ClassMaterialsReordered', Buffer.from(JSON.stringify({
+            classId: classId,
+            materialIds: orderedIds,
+            reorderedBy: caller,
+        })));
+
+        console.info(`✅ ${ordered.length} materials of ${classId} reordered by ${caller}`);
+        console.info('============= END : ReorderClassMaterials ===========');
+
+        return JSON.stringify({ classId: classId, materials: ordered });
+    }
+
     /**
      * 3. Obtenir le hash IPFS d'un support pour téléchargement
      *
@@ -252,12 +389,7 @@ class MaterialContract extends Contract {
             throw new Error(`Material ${materialId} does not exist`);
         }
 
-        const material = JSON.parse(materialAsBytes.toString());
-
-        // Vérifier que c'est bien un matériel
-        if (material.docType !== 'material') {
-            throw new Error(`${materialId} is not a material`);
-        }
+        const material = parseRecord(materialAsBytes, materialId, 'material');
 
         // CONTRÔLE D'ACCÈS: Vérifier l'enrollment dans la classe du matériel
         await this._checkEnrollment(ctx, material.classId);
@@ -295,11 +427,7 @@ class MaterialContract extends Contract {
             throw new Error(`Material ${materialId} does not exist`);
         }
 
-        const material = JSON.parse(materialAsBytes.toString());
-
-        if (material.docType !== 'material') {
-            throw new Error(`${materialId} is not a material`);
-        }
+        const material = parseRecord(materialAsBytes, materialId, 'material');
 
         console.info(`✅ Material retrieved: ${materialId}`);
         console.info('============= END : GetMaterial ===========');
@@ -307,6 +435,39 @@ class MaterialContract extends Contract {
         return JSON.stringify(material);
     }
 
+    /**
+     * Obtenir l'utilisation du stockage d'une classe (taille déclarée des supports)
+     * Accessible par: Teachers uniquement
+     */
+    async GetClassStorageUsage(ctx, classId) {
+        console.info('============= START : GetClassStorageUsage ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only teachers can view storage usage');
+        }
+
+        const classAsBytes = await ctx.stub.getState(classId);
+        if (!classAsBytes || classAsBytes.length === 0) {
+            throw new Error(`Class ${classId} does not exist`);
+        }
+
+        const classData = parseRecord(classAsBytes, classId, 'class');
+        const materials = await this._getClassMaterialRecords(ctx, classId);
+        const usedBytes = materials.reduce((sum, material) => sum + (material.size || 0), 0);
+        const maxTotalBytes = classData.maxTotalBytes || 0;
+
+        console.info(`✅ Storage usage of ${classId}: ${usedBytes}/${maxTotalBytes || 'unlimited'} bytes`);
+        console.info('============= END : GetClassStorageUsage ===========');
+
+        return JSON.stringify({
+            classId: classId,
+            materialCount: materials.length,
+            usedBytes: usedBytes,
+            maxTotalBytes: maxTotalBytes,
+            remainingBytes: maxTotalBytes ? Math.max(maxTotalBytes - usedBytes, 0) : null,
+        });
+    }
+
     /**
      * Supprimer un matériel
      * Accessible par: Teachers uniquement
@@ -314,7 +475,7 @@ class MaterialContract extends Contract {
     async DeleteMaterial(ctx, materialId) {
         console.info('============= START : DeleteMaterial ===========');
 
-        if (!this._isSchoolMember(ctx)) {
+        if (!(await isTeachingStaff(ctx))) {
             throw new Error('Access Denied: Only teachers can delete materials');
         }
 
@@ -323,11 +484,7 @@ class MaterialContract extends Contract {
             throw new Error(`Material ${materialId} does not exist`);
         }
 
-        const material = JSON.parse(materialAsBytes.toString());
-
-        if (material.docType !== 'material') {
-            throw new Error(`${materialId} is not a material`);
-        }
+        const material = parseRecord(materialAsBytes, materialId, 'material');
 
         // Supprimer du ledger
         await ctx.stub.deleteState(materialId);
@@ -349,6 +506,87 @@ class MaterialContract extends Contract {
             message: `Material ${materialId} successfully deleted`,
         });
     }
+    // ==================== FONCTIONS UTILITAIRES ====================
+
+    /**
+     * Métadonnées d'un support renvoyées dans les listes
+     * ipfsHash exclu pour des raisons de sécurité (utiliser GetMaterialFile)
+     * @private
+     */
+    _toMaterialSummary(record) {
+        return {
+            id: record.id,
+            classId: record.classId,
+            moduleId: record.moduleId,
+            title: record.title,
+            type: record.type,
+            size: record.size || null,
+            order: record.order || null,
+            uploadedBy: record.uploadedBy,
+            uploadedAt: record.uploadedAt,
+        };
+    }
+
+    /**
+     * Ordre d'affichage des supports: rang croissant, supports sans rang
+     * (antérieurs au champ order) à la fin, puis par ID
+     * @private
+     */
+    _compareMaterialOrder(a, b) {
+        const orderA = a.order || Number.MAX_SAFE_INTEGER;
+        const orderB = b.order || Number.MAX_SAFE_INTEGER;
+        return orderA - orderB || a.id.localeCompare(b.id);
+    }
+
+    /**
+     * Taille totale déclarée des supports d'une classe
+     * @private
+     */
+    async _getUsedBytes(ctx, classId) {
+        const materials = await this._getClassMaterialRecords(ctx, classId);
+        return materials.reduce((sum, material) => sum + (material.size || 0), 0);
+    }
+
+    /**
+     * Récupère les enregistrements des supports d'une classe
+     * CouchDB si disponible, sinon parcours complet du ledger
+     * @private
+     */
+    async _getClassMaterialRecords(ctx, classId) {
+        const queryString = JSON.stringify({
+            selector: {
+                docType: 'material',
+                classId: classId,
+            },
+        });
+
+        let iterator;
+        try {
+            iterator = await ctx.stub.getQueryResult(queryString);
+        } catch (err) {
+            console.warn('CouchDB query failed, using fallback method:', err);
+            iterator = await ctx.stub.getStateByRange('', '');
+        }
+
+        const allResults = [];
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                const record = JSON.parse(strValue);
+                if (record.docType === 'material' && record.classId === classId) {
+                    allResults.push(record);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
+    }
 }
 
 module.exports = MaterialContract;
@@ -0,0 +1,342 @@
+/*
+ * Notification Smart Contract
+ *
+ * File de notifications par destinataire (NOTIF_<destinataire>_<txId>_<n>),
+ * alimentée par les autres contrats via createNotification.
+ *
+ * Les types disposant d'un modèle (NOTIFICATION_TEMPLATES) reçoivent un message
+ * rendu dans la langue préférée du destinataire (SetMyNotificationLanguage).
+ *
+ * Contrôle d'accès:
+ * - Consultation / accusé de lecture / langue: le destinataire uniquement
+ */
+
+'use strict';
+
+const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord } = require('./records');
+
+// Langue des messages quand la langue préférée n'a pas de modèle (ou n'est pas définie)
+const DEFAULT_LANGUAGE = 'en';
+
+// Étiquette de langue acceptée: code ISO 639 + sous-étiquettes (ex: "fr", "fr-CA")
+const LANGUAGE_PATTERN = /^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$/;
+
+// Modèles de message par type de notification et par langue ({champ} = valeur de data)
+const NOTIFICATION_TEMPLATES = {
+    GradePublished: {
+        en: 'Your grade for {examTitle} in {className} is available',
+        fr: 'Votre note pour {examTitle} en {className} est disponible',
+    },
+};
+
+/**
+ * Préfixe des notifications d'un destinataire
+ */
+function notificationPrefix(recipientId) {
+    return `NOTIF_${recipientId}_`;
+}
+
+/**
+ * Clé de la langue préférée d'un destinataire
+ */
+function notificationLanguageKey(recipientId) {
+    return `NOTIFLANG_${recipientId}`;
+}
+
+/**
+ * Rend le message d'une notification dans la langue demandée
+ *
+ * Repli: langue exacte (fr-CA), puis langue de base (fr), puis DEFAULT_LANGUAGE.
+ * Un champ absent de data est laissé tel quel ({champ}).
+ *
+ * @param {string} type - Type de notification
+ * @param {Object} data - Contenu de la notification
+ * @param {string|null} language - Langue préférée du destinataire
+ * @returns {Object|null} { message, language } ou null si le type n'a pas de modèle
+ */
+function renderNotificationMessage(type, data, language) {
+    const templates = NOTIFICATION_TEMPLATES[type];
+    if (!templates) {
+        return null;
+    }
+
+    const candidates = language ? [language, language.split('-')[0], DEFAULT_LANGUAGE] : [DEFAULT_LANGUAGE];
+    const rendered = candidates.find((candidate) => templates[candidate]);
+    const message = templates[rendered].replace(/\{(\w+)\}/g, (placeholder, field) =>
+        (data && data[field] !== undefined && data[field] !== null ? String(data[field]) : placeholder));
+
+    return { message: message, language: rendered };
+}
+
+/**
+ * Récupère l'ID de l'utilisateur appelant (CN du certificat X.509)
+ */
+function getCallerIdentity(ctx) {
+    const userID = ctx.clientIdentity.getID();
+    const match = userID.match(/CN=([^,/]+)/);
+    return match ? match[1] : userID;
+}
+
+/**
+ * Ajoute une notification à la file d'un destinataire
+ * Clé déterministe: txId + rang dans la transaction (plusieurs notifications par transaction)
+ * Les types ayant un modèle reçoivent message et language (langue préférée du destinataire)
+ *
+ * @param {Context} ctx - Le contexte de transaction
+ * @param {string} recipientId - Identité du destinataire (ex: studentId)
+ * @param {string} type - Type de notification (ex: "ClassTeacherChanged")
+ * @param {Object} data - Contenu de la notification
+ * @returns {Promise<Object>} Notification enregistrée
+ */
+async function createNotification(ctx, recipientId, type, data) {
+    ctx.notificationSeq = (ctx.notificationSeq || 0) + 1;
+
+    const timestamp = ctx.stub.getTxTimestamp();
+    const seconds = timestamp.seconds.low || timestamp.seconds;
+    const seq = String(ctx.notificationSeq).padStart(4, '0');
+
+    const notification = {
+        docType: 'notification',
+        id: `${notificationPrefix(recipientId)}${ctx.stub.getTxID()}_${seq}`,
+        recipientId: recipientId,
+        type: type,
+        data: data,
+        createdAt: new Date(seconds * 1000).toISOString(),
+        readAt: null,
+    };
+
+    if (NOTIFICATION_TEMPLATES[type]) {
+        const languageAsBytes = await ctx.stub.getState(notificationLanguageKey(recipientId));
+        const preference = languageAsBytes && languageAsBytes.length > 0
+            ? parseRecord(languageAsBytes, notificationLanguageKey(recipientId), 'notificationLanguage')
+            : null;
+        const rendered = renderNotificationMessage(type, data, preference ? preference.language : null);
+        notification.message = rendered.message;
+        notification.language = rendered.language;
+    }
+
+    await ctx.stub.putState(notification.id, serializeRecord(notification));
+    return notification;
+}
+
+class NotificationContract extends Contract {
+
+    // ==================== CONTRÔLES D'ACCÈS ====================
+
+    /**
+     * Vérifie si l'appelant est authentifié (membre de n'importe quelle org)
+     */
+    _isAuthenticated(ctx) {
+        const mspID = ctx.clientIdentity.getMSPID();
+        return mspID === 'SchoolMSP' || mspID === 'StudentsMSP';
+    }
+
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
+    // ==================== FONCTIONS MÉTIER ====================
+
+    /**
+     * 1. Obtenir ses notifications (plus récentes en premier)
+     *
+     * Accessible par: Tous les participants authentifiés (leur propre file uniquement)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} [unreadOnly] - "true" pour ne renvoyer que les non lues
+     * @returns {string} JSON array des notifications
+     */
+    async GetMyNotifications(ctx, unreadOnly) {
+        console.info('============= START : GetMyNotifications ===========');
+
+        if (!this._isAuthenticated(ctx)) {
+            throw new Error('Access Denied: You must be authenticated to view notifications');
+        }
+
+        const caller = getCallerIdentity(ctx);
+        const allResults = (await this._getNotifications(ctx, caller))
+            .filter((notification) => unreadOnly !== 'true' || !notification.readAt);
+
+        allResults.sort((a, b) => b.createdAt.localeCompare(a.createdAt) || b.id.localeCompare(a.id));
+
+        console.info(`✅ ${allResults.length} notifications for ${caller}`);
+        console.info('============= END : GetMyNotifications ===========');
+
+        return JSON.stringify(allResults);
+    }
+
+    /**
+     * 2. Marquer une notification comme lue
+     *
+     * Accessible par: Le destinataire uniquement
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} notificationId - Identifiant de la notification
+     * @returns {string} JSON de la notification
+     */
+    async MarkNotificationRead(ctx, notificationId) {
+        console.info('============= START : MarkNotificationRead ===========');
+
+        const notificationAsBytes = await ctx.stub.getState(notificationId);
+        if (!notificationAsBytes || notificationAsBytes.length === 0) {
+            throw new Error(`Notification ${notificationId} does not exist`);
+        }
+
+        const notification = parseRecord(notificationAsBytes, notificationId, 'notification');
+
+        const caller = getCallerIdentity(ctx);
+        if (!this._isAuthenticated(ctx) || notification.recipientId !== caller) {
+            throw new Error('Access Denied: Only the recipient can mark a notification as read');
+        }
+
+        if (!notification.readAt) {
+            notification.readAt = this._getTxTimestamp(ctx);
+            await ctx.stub.putState(notificationId, serializeRecord(notification));
+        }
+
+        console.info(`✅ Notification ${notificationId} read by ${caller}`);
+        console.info('============= END : MarkNotificationRead ===========');
+
+        return JSON.stringify(notification);
+    }
+
+    /**
+     * 3. Marquer toutes ses notifications comme lues
+     *
+     * Accessible par: Le destinataire uniquement (studentId = appelant)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} studentId - Identité du destinataire
+     * @returns {string} JSON { studentId, marked, unreadCount }
+     */
+    async MarkAllNotificationsRead(ctx, studentId) {
+        console.info('============= START : MarkAllNotificationsRead ===========');
+
+        this._checkRecipient(ctx, studentId);
+
+        const unread = (await this._getNotifications(ctx, studentId)).filter((notification) => !notification.readAt);
+        const readAt = this._getTxTimestamp(ctx);
+
+        for (const notification of unread) {
+            notification.readAt = readAt;
+            await ctx.stub.putState(notification.id, serializeRecord(notification));
+        }
+
+        console.info(`✅ ${unread.length} notifications marked as read for ${studentId}`);
+        console.info('============= END : MarkAllNotificationsRead ===========');
+
+        return JSON.stringify({ studentId: studentId, marked: unread.length, unreadCount: 0 });
+    }
+
+    /**
+     * 4. Nombre de notifications non lues
+     *
+     * Accessible par: Le destinataire uniquement (studentId = appelant)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} studentId - Identité du destinataire
+     * @returns {string} JSON { studentId, unreadCount }
+     */
+    async GetUnreadNotificationCount(ctx, studentId) {
+        this._checkRecipient(ctx, studentId);
+
+        const unreadCount = (await this._getNotifications(ctx, studentId))
+            .filter((notification) => !notification.readAt).length;
+
+        return JSON.stringify({ studentId: studentId, unreadCount: unreadCount });
+    }
+
+    /**
+     * 5. Définir la langue de ses notifications
+     *
+     * Accessible par: Tous les participants authentifiés (leur propre préférence)
+     * S'applique aux notifications créées ensuite; sans modèle dans cette langue,
+     * le message est rendu dans la langue de base puis en DEFAULT_LANGUAGE
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} language - Étiquette de langue (ex: "fr", "en", "fr-CA")
+     * @returns {string} JSON de la préférence
+     */
+    async SetMyNotificationLanguage(ctx, language) {
+        console.info('============= START : SetMyNotificationLanguage ===========');
+
+        if (!this._isAuthenticated(ctx)) {
+            throw new Error('Access Denied: You must be authenticated to set a notification language');
+        }
+
+        if (typeof language !== 'string' || !LANGUAGE_PATTERN.test(language)) {
+            throw new Error(`Invalid language: ${language} (expected a language tag such as "fr" or "en-US")`);
+        }
+
+        const caller = getCallerIdentity(ctx);
+        const preference = {
+            docType: 'notificationLanguage',
+            id: notificationLanguageKey(caller),
+            recipientId: caller,
+            language: language,
+            updatedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(preference.id, serializeRecord(preference));
+
+        ctx.stub.setEvent('NotificationLanguageSet', Buffer.from(JSON.stringify({
+            recipientId: caller,
+            language: language,
+        })));
+
+        console.info(`✅ Notification language of ${caller} set to ${language}`);
+        console.info('============= END : SetMyNotificationLanguage ===========');
+
+        return JSON.stringify(preference);
+    }
+
+    // ==================== FONCTIONS UTILITAIRES ====================
+
+    /**
+     * Vérifie que l'appelant est le destinataire de la file demandée
+     * @throws {Error} Si l'appelant consulte la file d'un autre destinataire
+     */
+    _checkRecipient(ctx, recipientId) {
+        const caller = getCallerIdentity(ctx);
+        if (!this._isAuthenticated(ctx) || caller !== recipientId) {
+            throw new Error(`Access Denied: You can only manage your own notifications (You: ${caller}, Requested: ${recipientId})`);
+        }
+    }
+
+    /**
+     * Notifications d'un destinataire (parcours de son préfixe)
+     * @private
+     */
+    async _getNotifications(ctx, recipientId) {
+        const prefix = notificationPrefix(recipientId);
+        const allResults = [];
+        const iterator = await ctx.stub.getStateByRange(prefix, prefix + '\uffff');
+        let result = await iterator.next();
+
+        while (!result.done) {
+            try {
+                const notification = JSON.parse(result.value.value.toString('utf8'));
+                // Le préfixe d'un autre destinataire peut commencer par le nôtre
+                if (notification.docType === 'notification' && notification.recipientId === recipientId) {
+                    allResults.push(notification);
+                }
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        return allResults;
+    }
+}
+
+module.exports = NotificationContract;
+module.exports.createNotification = createNotification;
+module.exports.renderNotificationMessage = renderNotificationMessage;
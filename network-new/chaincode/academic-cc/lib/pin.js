@@ -0,0 +1,227 @@
+/*
+ * IPFS Pinning Smart Contract
+ *
+ * Suivi de l'épinglage (pinning) des contenus IPFS référencés par les
+ * supports et les examens: un statut par hash (PIN_<hash>), renseigné par
+ * le service de pinning, pour vérifier leur disponibilité avant un examen.
+ *
+ * Contrôle d'accès:
+ * - Enregistrement d'un statut: admins et identités "oracle" (service de pinning)
+ * - Contenus non épinglés: SchoolMSP uniquement (opérateurs)
+ */
+
+'use strict';
+
+const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord } = require('./records');
+const { getCallerRole } = require('./role');
+
+// Statuts d'épinglage: seul "pinned" confirme la disponibilité du contenu
+const PIN_STATUSES = ['pinned', 'unpinned', 'failed'];
+
+// Champs portant un hash IPFS, par type d'asset
+const PINNED_FIELDS = {
+    material: ['ipfsHash'],
+    exam: ['examFileHash', 'correctionFileHash'],
+};
+
+/**
+ * Clé du statut d'épinglage d'un hash IPFS
+ */
+function pinKey(ipfsHash) {
+    return `PIN_${ipfsHash}`;
+}
+
+class PinContract extends Contract {
+
+    // ==================== CONTRÔLES D'ACCÈS ====================
+
+    /**
+     * Vérifie si l'appelant appartient à SchoolOrg (teachers/admin)
+     */
+    _isSchoolMember(ctx) {
+        const mspID = ctx.clientIdentity.getMSPID();
+        return mspID === 'SchoolMSP';
+    }
+
+    /**
+     * Récupère l'ID de l'utilisateur appelant
+     * Format: x509::/CN=User1@school.academic.edu/...
+     */
+    _getCallerIdentity(ctx) {
+        const userID = ctx.clientIdentity.getID();
+        // Extraire le CN (Common Name) de l'identité X.509
+        const match = userID.match(/CN=([^,/]+)/);
+        return match ? match[1] : userID;
+    }
+
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
+    // ==================== FONCTIONS MÉTIER ====================
+
+    /**
+     * 1. Enregistrer le statut d'épinglage d'un contenu IPFS
+     *
+     * Accessible par: Admins et identités "oracle" (service de pinning)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} ipfsHash - Hash IPFS du contenu
+     * @param {string} status - "pinned", "unpinned" ou "failed"
+     * @returns {string} JSON du statut enregistré
+     */
+    async RecordPinStatus(ctx, ipfsHash, status) {
+        console.info('============= START : RecordPinStatus ===========');
+
+        const role = await getCallerRole(ctx);
+        if (role !== 'admin' && role !== 'oracle') {
+            throw new Error('Access Denied: Only admins and the pinning oracle can record pin statuses');
+        }
+
+        if (!ipfsHash || !ipfsHash.trim()) {
+            throw new Error('Invalid ipfsHash: must be a non-empty IPFS hash');
+        }
+        if (!PIN_STATUSES.includes(status)) {
+            throw new Error(`Invalid status: ${status} (supported: ${PIN_STATUSES.join(', ')})`);
+        }
+
+        const caller = this._getCallerIdentity(ctx);
+        const pin = {
+            docType: 'pinStatus',
+            id: pinKey(ipfsHash),
+            ipfsHash: ipfsHash,
+            status: status,
+            recordedBy: caller,
+            recordedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(pin.id, serializeRecord(pin));
+
+        ctx.stub.setEvent('PinStatusRecorded', Buffer.from(JSON.stringify({
+            ipfsHash: ipfsHash,
+            status: status,
+            recordedBy: caller,
+        })));
+
+        console.info(`✅ Pin status of ${ipfsHash}: ${status} (recorded by ${caller})`);
+        console.info('============= END : RecordPinStatus ===========');
+
+        return JSON.stringify(pin);
+    }
+
+    /**
+     * 2. Lister les supports et examens dont un contenu n'est pas confirmé épinglé
+     *
+     * Accessible par: SchoolOrg uniquement (opérateurs)
+     * Une ligne par contenu référencé sans statut "pinned" (pinStatus null: jamais signalé)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @returns {string} JSON array [{ type, id, classId, field, ipfsHash, pinStatus, recordedAt }]
+     */
+    async GetUnpinnedAssets(ctx) {
+        console.info('============= START : GetUnpinnedAssets ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can view pinning status');
+        }
+
+        const types = Object.keys(PINNED_FIELDS);
+        let records;
+        try {
+            // CouchDB: uniquement les supports et examens
+            records = await this._collect(await ctx.stub.getQueryResult(JSON.stringify({
+                selector: { docType: { $in: types } },
+            })));
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            records = await this._collect(await ctx.stub.getStateByRange('', ''));
+        }
+
+        const pins = new Map();
+        const allResults = [];
+        for (const record of records) {
+            if (!types.includes(record.docType)) {
+                continue;
+            }
+
+            for (const field of PINNED_FIELDS[record.docType]) {
+                const ipfsHash = record[field];
+                if (!ipfsHash) {
+                    continue;
+                }
+
+                if (!pins.has(ipfsHash)) {
+                    pins.set(ipfsHash, await this._getPinStatus(ctx, ipfsHash));
+                }
+                const pin = pins.get(ipfsHash);
+                if (pin && pin.status === 'pinned') {
+                    continue;
+                }
+
+                allResults.push({
+                    type: record.docType,
+                    // Les enregistrements du contrat principal (legacy) n'ont pas de champ id
+                    id: record.id || record.materialId || record.examId,
+                    classId: record.classId,
+                    field: field,
+                    ipfsHash: ipfsHash,
+                    pinStatus: pin ? pin.status : null,
+                    recordedAt: pin ? pin.recordedAt : null,
+                });
+            }
+        }
+
+        allResults.sort((a, b) => a.classId.localeCompare(b.classId) || a.id.localeCompare(b.id) || a.field.localeCompare(b.field));
+
+        console.info(`✅ ${allResults.length} unpinned contents referenced by materials and exams`);
+        console.info('============= END : GetUnpinnedAssets ===========');
+
+        return JSON.stringify(allResults);
+    }
+
+    // ==================== FONCTIONS UTILITAIRES ====================
+
+    /**
+     * Statut d'épinglage d'un hash (null si jamais signalé)
+     * @private
+     */
+    async _getPinStatus(ctx, ipfsHash) {
+        const key = pinKey(ipfsHash);
+        const pinAsBytes = await ctx.stub.getState(key);
+        if (!pinAsBytes || pinAsBytes.length === 0) {
+            return null;
+        }
+        return parseRecord(pinAsBytes, key, 'pinStatus');
+    }
+
+    /**
+     * Parcourt un itérateur et retourne les enregistrements
+     * @private
+     */
+    async _collect(iterator) {
+        const allResults = [];
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                allResults.push(JSON.parse(strValue));
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
+    }
+}
+
+module.exports = PinContract;
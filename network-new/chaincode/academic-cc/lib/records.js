@@ -0,0 +1,85 @@
+/*
+ * Lecture typée et écriture canonique des enregistrements du ledger
+ *
+ * Tous les assets partagent le world state: chaque enregistrement porte
+ * un champ docType (class, exam, grade...) vérifié à la lecture pour éviter
+ * de traiter une classe comme un examen en cas de collision de clés.
+ *
+ * Écriture: JSON canonique (clés triées à tous les niveaux) pour que chaque
+ * peer produise exactement les mêmes octets, quel que soit l'ordre dans lequel
+ * les champs ont été ajoutés (sinon: divergence des endorsements).
+ */
+
+'use strict';
+
+// Nombre maximal d'enregistrements renvoyés par une requête de liste (limites de taille des
+// résultats Fabric): au-delà, le résultat est tronqué et signalé (truncated=true)
+const MAX_QUERY_RESULTS = 500;
+
+/**
+ * Désérialise un enregistrement et vérifie son type
+ *
+ * @param {Buffer} recordAsBytes - Valeur lue via getState
+ * @param {string} id - Clé de l'enregistrement (pour les messages d'erreur)
+ * @param {string} expectedType - docType attendu (ex: "exam")
+ * @returns {Object} Enregistrement désérialisé
+ * @throws {Error} Si la valeur n'est pas du JSON ou si le type ne correspond pas
+ */
+function parseRecord(recordAsBytes, id, expectedType) {
+    let record;
+    try {
+        record = JSON.parse(recordAsBytes.toString());
+    } catch (err) {
+        throw new Error(`Corrupted record: ${id} is not valid JSON`);
+    }
+
+    if (!record || typeof record !== 'object' || record.docType !== expectedType) {
+        const storedType = record && record.docType ? record.docType : 'untyped';
+        throw new Error(`Type mismatch: ${id} holds a "${storedType}" record, expected "${expectedType}"`);
+    }
+
+    return record;
+}
+
+/**
+ * Sérialise une valeur en JSON canonique
+ *
+ * Mêmes règles que JSON.stringify (toJSON, undefined et fonctions ignorés),
+ * mais les clés des objets sont triées: l'ordre d'insertion n'a aucune
+ * influence, y compris pour les clés numériques.
+ *
+ * @param {*} value - Valeur à sérialiser
+ * @returns {string} JSON canonique
+ */
+function canonicalStringify(value) {
+    if (value && typeof value.toJSON === 'function') {
+        return canonicalStringify(value.toJSON());
+    }
+
+    if (Array.isArray(value)) {
+        const items = value.map((item) => (item === undefined || typeof item === 'function' ? 'null' : canonicalStringify(item)));
+        return `[${items.join(',')}]`;
+    }
+
+    if (value && typeof value === 'object') {
+        const keys = Object.keys(value)
+            .filter((key) => value[key] !== undefined && typeof value[key] !== 'function')
+            .sort();
+        const entries = keys.map((key) => `${JSON.stringify(key)}:${canonicalStringify(value[key])}`);
+        return `{${entries.join(',')}}`;
+    }
+
+    return JSON.stringify(value);
+}
+
+/**
+ * Sérialise un enregistrement pour putState (JSON canonique)
+ *
+ * @param {Object} record - Enregistrement à écrire
+ * @returns {Buffer} Octets à stocker
+ */
+function serializeRecord(record) {
+    return Buffer.from(canonicalStringify(record));
+}
+
+module.exports = { parseRecord, serializeRecord, canonicalStringify, MAX_QUERY_RESULTS };
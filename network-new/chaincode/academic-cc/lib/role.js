@@ -0,0 +1,243 @@
+/*
+ * Role Registry Smart Contract
+ *
+ * Rôles des identités de SchoolOrg: admin, department-head, teacher, oracle
+ * - Par défaut: attribut de certificat role, ou identité Admin@ => admin
+ * - Registre on-chain: un admin peut promouvoir une identité sans réémettre
+ *   son certificat (le rôle enregistré prime sur le certificat)
+ * - Révocation: enregistrement "revoked" qui retire tout rôle à l'identité
+ *   (certificat et rôle teacher par défaut compris) jusqu'au prochain GrantRole
+ * - Écritures pédagogiques (classes, examens, notes, supports): teacher,
+ *   department-head ou admin; un oracle ou une identité révoquée est refusé
+ *
+ * Contrôle d'accès:
+ * - Attribution/Révocation: admins uniquement
+ * - Consultation de son propre rôle: Tous les participants authentifiés
+ */
+
+'use strict';
+
+const { Contract } = require('fabric-contract-api');
+const { parseRecord, serializeRecord } = require('./records');
+
+// Rôles attribuables via le registre (identités SchoolMSP uniquement)
+// department-head: approbateur des publications de notes (ApproveGradeRelease)
+// oracle: service de pinning IPFS (RecordPinStatus)
+const GRANTABLE_ROLES = ['admin', 'department-head', 'teacher', 'oracle'];
+
+// Rôles habilités aux écritures pédagogiques (classes, examens, notes, supports)
+// oracle exclu: le service de pinning n'enregistre que des statuts d'épinglage
+const TEACHING_ROLES = ['admin', 'department-head', 'teacher'];
+
+/**
+ * Clé du rôle enregistré d'une identité
+ */
+function roleKey(identityId) {
+    return `ROLE_${identityId}`;
+}
+
+/**
+ * Récupère l'ID de l'utilisateur appelant (CN du certificat X.509)
+ */
+function getCallerIdentity(ctx) {
+    const userID = ctx.clientIdentity.getID();
+    const match = userID.match(/CN=([^,/]+)/);
+    return match ? match[1] : userID;
+}
+
+/**
+ * Détermine le rôle effectif de l'appelant
+ *
+ * StudentsMSP => student (le registre ne franchit pas la frontière des organisations)
+ * SchoolMSP   => aucun rôle si révoqué, sinon rôle enregistré, sinon attribut de certificat role,
+ *                sinon admin pour les identités Admin@, sinon teacher
+ *
+ * @param {Context} ctx - Le contexte de transaction
+ * @returns {Promise<string|null>} admin, department-head, teacher, oracle, student ou null (organisation inconnue, rôle révoqué)
+ */
+async function getCallerRole(ctx) {
+    const mspID = ctx.clientIdentity.getMSPID();
+
+    if (mspID === 'StudentsMSP') {
+        return 'student';
+    }
+    if (mspID !== 'SchoolMSP') {
+        return null;
+    }
+
+    const caller = getCallerIdentity(ctx);
+    const key = roleKey(caller);
+    const roleAsBytes = await ctx.stub.getState(key);
+    if (roleAsBytes && roleAsBytes.length > 0) {
+        const roleRecord = parseRecord(roleAsBytes, key, 'role');
+        // Rôle révoqué: ni le certificat ni le rôle par défaut ne s'appliquent
+        return roleRecord.revoked === true ? null : roleRecord.role;
+    }
+
+    const certRole = ctx.clientIdentity.getAttributeValue('role');
+    if (GRANTABLE_ROLES.includes(certRole)) {
+        return certRole;
+    }
+
+    return caller.startsWith('Admin@') ? 'admin' : 'teacher';
+}
+
+/**
+ * Indique si l'appelant peut effectuer les écritures réservées à l'équipe pédagogique
+ * Refusé hors SchoolOrg, pour un oracle et pour une identité révoquée
+ *
+ * @param {Context} ctx - Le contexte de transaction
+ * @returns {Promise<boolean>}
+ */
+async function isTeachingStaff(ctx) {
+    return TEACHING_ROLES.includes(await getCallerRole(ctx));
+}
+
+class RoleContract extends Contract {
+
+    // ==================== CONTRÔLES D'ACCÈS ====================
+
+    /**
+     * Get deterministic timestamp from transaction (same across all peers)
+     */
+    _getTxTimestamp(ctx) {
+        const timestamp = ctx.stub.getTxTimestamp();
+        const seconds = timestamp.seconds.low || timestamp.seconds;
+        return new Date(seconds * 1000).toISOString();
+    }
+
+    /**
+     * Vérifie que l'appelant est admin
+     * @throws {Error} Si l'appelant n'est pas admin
+     */
+    async _checkAdmin(ctx) {
+        if (await getCallerRole(ctx) !== 'admin') {
+            throw new Error('Access Denied: Only admins can manage roles');
+        }
+    }
+
+    // ==================== FONCTIONS MÉTIER ====================
+
+    /**
+     * 1. Attribuer un rôle à une identité de SchoolOrg
+     *
+     * Accessible par: Admins uniquement
+     * Remplace le rôle précédemment enregistré pour cette identité (et lève une révocation)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} identityID - CN de l'identité (ex: "teacher1@school.academic.edu")
+     * @param {string} role - Rôle attribué: "admin", "department-head", "teacher" ou "oracle"
+     * @returns {string} JSON du rôle enregistré
+     */
+    async GrantRole(ctx, identityID, role) {
+        console.info('============= START : GrantRole ===========');
+
+        await this._checkAdmin(ctx);
+
+        if (!identityID || !identityID.trim()) {
+            throw new Error('Invalid identityID: must be a non-empty identity');
+        }
+        if (!GRANTABLE_ROLES.includes(role)) {
+            throw new Error(`Invalid role: must be one of ${GRANTABLE_ROLES.join(', ')}`);
+        }
+
+        const grantedBy = getCallerIdentity(ctx);
+        const roleRecord = {
+            docType: 'role',
+            id: roleKey(identityID),
+            identityId: identityID,
+            role: role,
+            grantedBy: grantedBy,
+            grantedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(roleRecord.id, serializeRecord(roleRecord));
+
+        ctx.stub.setEvent('RoleGranted', Buffer.from(JSON.stringify({
+            identityId: identityID,
+            role: role,
+            grantedBy: grantedBy,
+        })));
+
+        console.info(`✅ Role ${role} granted to ${identityID} by ${grantedBy}`);
+        console.info('============= END : GrantRole ===========');
+
+        return JSON.stringify(roleRecord);
+    }
+
+    /**
+     * 2. Révoquer le rôle d'une identité de SchoolOrg
+     *
+     * Accessible par: Admins uniquement
+     * La révocation est enregistrée (ROLE_<identité>, revoked): l'identité n'a plus aucun rôle,
+     * y compris celui de son certificat ou le rôle teacher par défaut, jusqu'à un nouveau GrantRole
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} identityID - CN de l'identité
+     * @returns {string} Message de confirmation
+     */
+    async RevokeRole(ctx, identityID) {
+        console.info('============= START : RevokeRole ===========');
+
+        await this._checkAdmin(ctx);
+
+        if (!identityID || !identityID.trim()) {
+            throw new Error('Invalid identityID: must be a non-empty identity');
+        }
+
+        const key = roleKey(identityID);
+        const roleAsBytes = await ctx.stub.getState(key);
+        const previous = roleAsBytes && roleAsBytes.length > 0 ? parseRecord(roleAsBytes, key, 'role') : null;
+        if (previous && previous.revoked === true) {
+            throw new Error(`Role of ${identityID} is already revoked (since ${previous.revokedAt})`);
+        }
+
+        // Rôle retiré: enregistré s'il existe, sinon rôle issu du certificat ou par défaut
+        const previousRole = previous ? previous.role : null;
+        const revokedBy = getCallerIdentity(ctx);
+        const roleRecord = {
+            docType: 'role',
+            id: key,
+            identityId: identityID,
+            role: null,
+            revoked: true,
+            previousRole: previousRole,
+            revokedBy: revokedBy,
+            revokedAt: this._getTxTimestamp(ctx),
+        };
+
+        await ctx.stub.putState(key, serializeRecord(roleRecord));
+
+        ctx.stub.setEvent('RoleRevoked', Buffer.from(JSON.stringify({
+            identityId: identityID,
+            role: previousRole,
+            revokedBy: revokedBy,
+        })));
+
+        const revoked = previousRole ? `Role ${previousRole}` : 'Default role';
+        console.info(`✅ ${revoked} revoked from ${identityID} by ${revokedBy}`);
+        console.info('============= END : RevokeRole ===========');
+
+        return JSON.stringify({
+            success: true,
+            message: `${revoked} revoked from ${identityID}`,
+        });
+    }
+
+    /**
+     * 3. Obtenir son rôle effectif
+     * Accessible par: Tous les participants authentifiés
+     */
+    async GetMyRole(ctx) {
+        const role = await getCallerRole(ctx);
+        return JSON.stringify({
+            identityId: getCallerIdentity(ctx),
+            mspID: ctx.clientIdentity.getMSPID(),
+            role: role,
+        });
+    }
+}
+
+module.exports = RoleContract;
+module.exports.getCallerRole = getCallerRole;
+module.exports.isTeachingStaff = isTeachingStaff;
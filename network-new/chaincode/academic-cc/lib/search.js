@@ -0,0 +1,173 @@
+/*
+ * Search Smart Contract
+ *
+ * Recherche textuelle (sous-chaîne, insensible à la casse) sur les
+ * noms, titres et descriptions des classes, supports et examens.
+ *
+ * Contrôle d'accès:
+ * - Recherche: SchoolMSP uniquement (teachers/admin), lecture seule
+ * - Les hash IPFS ne sont jamais renvoyés
+ */
+
+'use strict';
+
+const { Contract } = require('fabric-contract-api');
+
+// Champs textuels recherchés par type d'asset
+const SEARCHABLE_FIELDS = {
+    class: ['name', 'description'],
+    material: ['title'],
+    exam: ['title'],
+};
+
+class SearchContract extends Contract {
+
+    // ==================== CONTRÔLES D'ACCÈS ====================
+
+    /**
+     * Vérifie si l'appelant appartient à SchoolOrg (teachers/admin)
+     */
+    _isSchoolMember(ctx) {
+        const mspID = ctx.clientIdentity.getMSPID();
+        return mspID === 'SchoolMSP';
+    }
+
+    // ==================== FONCTIONS MÉTIER ====================
+
+    /**
+     * Rechercher un terme dans les classes, supports et examens
+     *
+     * Accessible par: SchoolOrg uniquement (teachers/admin)
+     *
+     * @param {Context} ctx - Le contexte de transaction
+     * @param {string} query - Terme recherché (sous-chaîne, insensible à la casse)
+     * @param {string} [typesJSON] - JSON array des types (ex: '["class","exam"]'), tous par défaut
+     * @returns {string} JSON array [{ type, id, label, matchedFields, classId }]
+     */
+    async Search(ctx, query, typesJSON) {
+        console.info('============= START : Search ===========');
+
+        if (!this._isSchoolMember(ctx)) {
+            throw new Error('Access Denied: Only SchoolOrg members can search assets');
+        }
+
+        const term = (query || '').trim().toLowerCase();
+        if (!term) {
+            throw new Error('Missing query: a search term is required');
+        }
+
+        const types = this._parseTypes(typesJSON);
+
+        let records;
+        try {
+            // CouchDB: filtre par expression régulière côté base
+            records = await this._collect(await ctx.stub.getQueryResult(this._buildQuery(term, types)));
+        } catch (err) {
+            // Si CouchDB n'est pas disponible, fallback sur getStateByRange
+            console.warn('CouchDB query failed, using fallback method:', err);
+            records = await this._collect(await ctx.stub.getStateByRange('', ''));
+        }
+
+        // Le filtre est réappliqué dans tous les cas: la correspondance fait foi côté chaincode
+        const allResults = [];
+        for (const record of records) {
+            if (!types.includes(record.docType)) {
+                continue;
+            }
+
+            const matchedFields = SEARCHABLE_FIELDS[record.docType]
+                .filter((field) => typeof record[field] === 'string' && record[field].toLowerCase().includes(term));
+            if (matchedFields.length === 0) {
+                continue;
+            }
+
+            allResults.push({
+                type: record.docType,
+                id: record.id,
+                label: record.docType === 'class' ? record.name : record.title,
+                matchedFields: matchedFields,
+                classId: record.docType === 'class' ? record.id : record.classId,
+            });
+        }
+
+        allResults.sort((a, b) => a.type.localeCompare(b.type) || a.id.localeCompare(b.id));
+
+        console.info(`✅ Search "${query}" in ${types.join(', ')}: ${allResults.length} results`);
+        console.info('============= END : Search ===========');
+
+        return JSON.stringify(allResults);
+    }
+
+    // ==================== FONCTIONS UTILITAIRES ====================
+
+    /**
+     * Valide la liste des types demandés (tous si absente)
+     * @private
+     */
+    _parseTypes(typesJSON) {
+        const supported = Object.keys(SEARCHABLE_FIELDS);
+        if (!typesJSON) {
+            return supported;
+        }
+
+        let types;
+        try {
+            types = JSON.parse(typesJSON);
+        } catch (err) {
+            throw new Error('Invalid typesJSON: must be a JSON array of asset types');
+        }
+        if (!Array.isArray(types) || types.length === 0) {
+            throw new Error('Invalid typesJSON: must be a non-empty JSON array of asset types');
+        }
+
+        for (const type of types) {
+            if (!supported.includes(type)) {
+                throw new Error(`Invalid type: ${type} (supported: ${supported.join(', ')})`);
+            }
+        }
+        return types;
+    }
+
+    /**
+     * Requête CouchDB: docType parmi les types, un des champs textuels correspondant
+     * @private
+     */
+    _buildQuery(term, types) {
+        const pattern = '(?i)' + term.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+        const fields = new Set();
+        for (const type of types) {
+            SEARCHABLE_FIELDS[type].forEach((field) => fields.add(field));
+        }
+
+        return JSON.stringify({
+            selector: {
+                docType: { $in: types },
+                $or: Array.from(fields).map((field) => ({ [field]: { $regex: pattern } })),
+            },
+        });
+    }
+
+    /**
+     * Parcourt un itérateur et retourne les enregistrements
+     * @private
+     */
+    async _collect(iterator) {
+        const allResults = [];
+        let result = await iterator.next();
+
+        while (!result.done) {
+            const strValue = Buffer.from(result.value.value.toString()).toString('utf8');
+            try {
+                allResults.push(JSON.parse(strValue));
+            } catch (err) {
+                console.log('Error parsing record:', err);
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return allResults;
+    }
+}
+
+module.exports = SearchContract;
@@ -0,0 +1,38 @@
+/*
+ * Normalisation des dates du chaincode
+ *
+ * Les dates fournies par les clients (RFC 3339) peuvent porter des décalages
+ * différents: elles sont stockées en UTC pour que comparaisons et calculs
+ * (+24h, +48h...) soient identiques sur tous les peers, le décalage
+ * d'origine étant conservé à part pour l'affichage.
+ */
+
+'use strict';
+
+// Date et heure avec fuseau explicite (Z ou ±hh:mm): sans fuseau, l'heure
+// serait interprétée dans le fuseau local du peer
+const RFC3339_PATTERN = /^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(Z|[+-]\d{2}:\d{2})$/i;
+
+/**
+ * Valide une date RFC 3339 et la convertit en UTC
+ *
+ * @param {string} value - Date fournie (ex: "2024-02-01T10:00:00+02:00")
+ * @param {string} field - Nom du champ (pour les messages d'erreur)
+ * @returns {Object} { utc: "2024-02-01T08:00:00.000Z", offset: "+02:00" }
+ * @throws {Error} Si la date est invalide ou sans fuseau
+ */
+function normalizeDate(value, field) {
+    const match = typeof value === 'string' ? value.trim().match(RFC3339_PATTERN) : null;
+    const date = match ? new Date(value.trim()) : null;
+
+    if (!date || isNaN(date.getTime())) {
+        throw new Error(`Invalid ${field} format. Use ISO 8601 format with a time zone (e.g., "2024-02-01T10:00:00Z" or "2024-02-01T10:00:00+02:00")`);
+    }
+
+    return {
+        utc: date.toISOString(),
+        offset: match[1].toUpperCase() === 'Z' ? '+00:00' : match[1],
+    };
+}
+
+module.exports = { normalizeDate };
@@ -0,0 +1,129 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const AppealContract = require('../lib/appeal');
+const RoleContract = require('../lib/role');
+const { MemoryLedger } = require('./helpers/ledger');
+
+const DAY = 86400;
+
+/**
+ * Classe C1, examen E1 et note G1 (15/20) d'alice publiée au temps initial du ledger
+ */
+async function publishedGrade(ledger) {
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+    await new GradeContract().PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '15', 'Bien');
+}
+
+test('FileGradeAppeal accepts appeals within the window and rejects them after the deadline', async () => {
+    const ledger = new MemoryLedger();
+    await publishedGrade(ledger);
+    const appeals = new AppealContract();
+
+    ledger.advance(3 * DAY);
+    const appeal = JSON.parse(await appeals.FileGradeAppeal(ledger.student('alice'), 'A1', 'G1', 'Barème mal appliqué'));
+    assert.strictEqual(appeal.status, 'pending');
+    assert.strictEqual(appeal.deadline, '2026-01-24T10:00:00.000Z');
+
+    ledger.advance(20 * DAY);
+    await assert.rejects(appeals.FileGradeAppeal(ledger.student('alice'), 'A2', 'G1', 'Barème mal appliqué'),
+        /Appeal deadline passed: appeals for grade G1 closed on 2026-01-24T10:00:00.000Z/);
+});
+
+test('a rejected appeal can be escalated once to a department head within the escalation window', async () => {
+    const ledger = new MemoryLedger();
+    const appeals = new AppealContract();
+    const head = 'head@school.academic.edu';
+    await new RoleContract().GrantRole(ledger.admin(), head, 'department-head');
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of ['s1', 's2']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await new GradeContract().SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '8', '');
+    await new GradeContract().SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '8', '');
+    await new GradeContract().PublishExamGrades(ledger.school(), 'E1');
+    await appeals.FileGradeAppeal(ledger.student('s1'), 'A1', 'G1', 'Question 3');
+    await appeals.FileGradeAppeal(ledger.student('s2'), 'A2', 'G2', 'Question 3');
+
+    await assert.rejects(appeals.EscalateAppeal(ledger.student('s1'), 'A1'), /Appeal A1 is pending: only an appeal rejected by the teacher can be escalated/);
+    await assert.rejects(appeals.ResolveAppeal(ledger.school('x@school.academic.edu'), 'A1', 'rejected', 'no'), /Only the teachers of class C1 or an admin/);
+    await assert.rejects(appeals.ResolveAppeal(ledger.school(), 'A1', 'maybe', 'no'), /Invalid decision: maybe/);
+    await appeals.ResolveAppeal(ledger.school(), 'A1', 'rejected', 'grading is correct');
+    await appeals.ResolveAppeal(ledger.school(), 'A2', 'rejected', 'grading is correct');
+    await assert.rejects(appeals.EscalateAppeal(ledger.student('s2'), 'A1'), /You can only escalate your own appeals/);
+
+    ledger.advance(3 * DAY);
+    const escalated = JSON.parse(await appeals.EscalateAppeal(ledger.student('s1'), 'A1'));
+    assert.strictEqual(escalated.status, 'escalated');
+    assert.strictEqual(escalated.escalationDeadline, '2026-02-12T10:00:00.000Z');
+    await assert.rejects(appeals.EscalateAppeal(ledger.student('s1'), 'A1'), /Appeal A1 is escalated/);
+    await assert.rejects(appeals.ResolveEscalatedAppeal(ledger.school(), 'A1', 'accepted', 'ok'), /Only a department head can resolve escalated appeals/);
+    await assert.rejects(appeals.ResolveEscalatedAppeal(ledger.admin(), 'A1', 'accepted', 'ok'), /Only a department head/);
+    const resolved = JSON.parse(await appeals.ResolveEscalatedAppeal(ledger.school(head), 'A1', 'accepted', 'partial credit'));
+    assert.strictEqual(resolved.status, 'accepted');
+    assert.strictEqual(resolved.escalationResponse, 'partial credit');
+    await assert.rejects(appeals.EscalateAppeal(ledger.student('s1'), 'A1'), /Appeal A1 is accepted/);
+
+    ledger.advance(5 * DAY);
+    await assert.rejects(appeals.EscalateAppeal(ledger.student('s2'), 'A2'),
+        /Escalation deadline passed: appeal A2 could be escalated until 2026-02-12T10:00:00.000Z \(7 days after rejection\)/);
+    await assert.rejects(appeals.ResolveEscalatedAppeal(ledger.school(head), 'A2', 'accepted', 'ok'), /only escalated appeals can be resolved/);
+});
+
+test('GetAppealStatistics counts appeals of a class by status, escalations included', async () => {
+    const ledger = new MemoryLedger();
+    const appeals = new AppealContract();
+    const grades = new GradeContract();
+    await new RoleContract().GrantRole(ledger.admin(), 'head@school.academic.edu', 'department-head');
+    await new ClassContract().CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '10');
+    const students = ['s1', 's2', 's3', 's4', 's5', 's6'];
+    for (const studentId of students) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'A', studentId);
+    }
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'A', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    ledger.setTime('2026-02-05T10:00:00Z');
+    for (const studentId of students) {
+        await grades.SubmitGrade(ledger.school(), `G${studentId}`, 'E1', studentId, '8', '');
+    }
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+
+    const empty = JSON.parse(await appeals.GetAppealStatistics(ledger.school(), 'A'));
+    assert.deepStrictEqual([empty.total, empty.gradeCount, empty.appealRatePercent], [0, 6, 0]);
+
+    for (const studentId of ['s1', 's2', 's3', 's4', 's5']) {
+        await appeals.FileGradeAppeal(ledger.student(studentId), `AP${studentId}`, `G${studentId}`, 'Question 3');
+    }
+    await appeals.ResolveAppeal(ledger.school(), 'APs2', 'accepted', 'ok');
+    for (const studentId of ['s3', 's4', 's5']) {
+        await appeals.ResolveAppeal(ledger.school(), `AP${studentId}`, 'rejected', 'no');
+    }
+    await appeals.EscalateAppeal(ledger.student('s4'), 'APs4');
+    await appeals.EscalateAppeal(ledger.student('s5'), 'APs5');
+    await appeals.ResolveEscalatedAppeal(ledger.school('head@school.academic.edu'), 'APs5', 'accepted', 'ok');
+
+    // APs5, acceptée en appel, compte comme acceptée et dans escalatedTotal
+    assert.deepStrictEqual(JSON.parse(await appeals.GetAppealStatistics(ledger.admin(), 'A')), {
+        classId: 'A',
+        total: 5,
+        byStatus: { pending: 1, accepted: 2, rejected: 1, escalated: 1 },
+        escalatedTotal: 2,
+        gradeCount: 6,
+        appealedGradeCount: 5,
+        appealRatePercent: 83.33,
+    });
+    await assert.rejects(appeals.GetAppealStatistics(ledger.school('x@school.academic.edu'), 'A'),
+        /Only the teachers of class A or an admin can view its appeal statistics/);
+    await assert.rejects(appeals.GetAppealStatistics(ledger.student('s1'), 'A'), /Only teachers can view appeal statistics/);
+    await assert.rejects(appeals.GetAppealStatistics(ledger.school(), 'Z'), /Class Z does not exist/);
+    await assert.rejects(appeals.ResolveAppeal(ledger.school('x@school.academic.edu'), 'APs1', 'rejected', 'no'),
+        /Only the teachers of class A or an admin can resolve this appeal/);
+});
@@ -0,0 +1,28 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const AuditContract = require('../lib/audit');
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('ListKeysByPrefix lists ledger keys for admins, bounded by limit', async () => {
+    const ledger = new MemoryLedger();
+    const audits = new AuditContract();
+    for (const classId of ['A1', 'A2', 'A3', 'B1']) {
+        await new ClassContract().CreateClass(ledger.school(), classId, `Cours ${classId}`, 'Description', '10');
+    }
+
+    const page = JSON.parse(await audits.ListKeysByPrefix(ledger.admin(), 'A', '2'));
+    assert.deepStrictEqual([page.keys, page.count, page.truncated], [['A1', 'A2'], 2, true]);
+    const all = JSON.parse(await audits.ListKeysByPrefix(ledger.admin(), 'A', '5'));
+    assert.deepStrictEqual([all.keys, all.truncated], [['A1', 'A2', 'A3'], false]);
+
+    await assert.rejects(audits.ListKeysByPrefix(ledger.school(), 'A', '5'), /Only admins can list ledger keys/);
+    // \uffff fermerait la plage, \u0000 ouvrirait les clés composites
+    await assert.rejects(audits.ListKeysByPrefix(ledger.admin(), 'A\uffff', '5'), /Invalid prefix: control characters and \\uffff are not allowed/);
+    await assert.rejects(audits.ListKeysByPrefix(ledger.admin(), '\u0000x', '5'), /Invalid prefix: control characters/);
+    await assert.rejects(audits.ListKeysByPrefix(ledger.admin(), '', '5'), /Invalid prefix: must be a non-empty string/);
+    await assert.rejects(audits.ListKeysByPrefix(ledger.admin(), 'A', '501'), /Invalid limit: must be an integer between 1 and 500/);
+});
@@ -0,0 +1,1148 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const AuditContract = require('../lib/audit');
+const NotificationContract = require('../lib/notification');
+const ConfigContract = require('../lib/config');
+const MaterialContract = require('../lib/material');
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('the enrolled count follows enrollments, withdrawals and transfers', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '3');
+    await classes.CreateClass(ledger.school(), 'C1_B', 'Maths B', 'Algèbre');
+
+    await classes.EnrollStudentsBatch(ledger.school(), 'C1', '["a","b"]');
+    await classes.EnrollStudent(ledger.student('c'), 'C1', 'c');
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'C1', 'd'), /Class C1 is full \(3\/3 students\)/);
+
+    await classes.WithdrawStudent(ledger.student('a'), 'C1', 'a');
+    await classes.TransferEnrollment(ledger.school(), 'C1', 'C1_B', 'b');
+    await classes.EnrollStudent(ledger.school(), 'C1_B', 'x');
+    await classes.EnrollStudent(ledger.school(), 'C1', 'd');
+
+    assert.deepStrictEqual(JSON.parse(await classes.RecomputeEnrollmentCounter(ledger.school(), 'C1')),
+        { classId: 'C1', previous: 2, recomputed: 2, corrected: false });
+    assert.deepStrictEqual(JSON.parse(await classes.RecomputeEnrollmentCounter(ledger.school(), 'C1_B')),
+        { classId: 'C1_B', previous: 2, recomputed: 2, corrected: false });
+
+    const details = JSON.parse(await classes.GetClassDetails(ledger.school(), 'C1'));
+    assert.deepStrictEqual(details.enrolledStudents, ['c', 'd']);
+    assert.strictEqual(details.enrolledCount, 2);
+});
+
+test('RecomputeEnrollmentCounter repairs a drifted counter', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '3');
+    await classes.EnrollStudent(ledger.school(), 'C1', 'a');
+    ledger.put('C1', Object.assign(ledger.get('C1'), { enrolledCount: 3 }));
+
+    assert.deepStrictEqual(JSON.parse(await classes.RecomputeEnrollmentCounter(ledger.school(), 'C1')),
+        { classId: 'C1', previous: 3, recomputed: 1, corrected: true });
+    await classes.EnrollStudent(ledger.school(), 'C1', 'b');
+});
+
+test('GetClassByName returns every class with that name, with or without CouchDB', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await classes.CreateClass(ledger.school(), 'C2', 'Maths', 'Analyse');
+    await classes.CreateClass(ledger.school(), 'C3', 'Physique', 'Mécanique');
+
+    assert.deepStrictEqual(JSON.parse(await classes.GetClassByName(ledger.student('alice'), 'Maths')), [
+        { id: 'C1', name: 'Maths', description: 'Algèbre' },
+        { id: 'C2', name: 'Maths', description: 'Analyse' },
+    ]);
+
+    ledger.couchdb = false;
+    assert.deepStrictEqual(JSON.parse(await classes.GetClassByName(ledger.student('alice'), 'Physique')), [
+        { id: 'C3', name: 'Physique', description: 'Mécanique' },
+    ]);
+    await assert.rejects(classes.GetClassByName(ledger.student('alice'), ''), /Missing name/);
+});
+
+test('hard mode rejects enrollments in a full class, soft mode flags them', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '1');
+    await classes.EnrollStudent(ledger.school(), 'C1', 'a');
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'C1', 'b'), /Class C1 is full \(1\/1 students\)/);
+
+    await classes.SetEnrollmentMode(ledger.school(), 'C1', 'soft');
+    const result = JSON.parse(await classes.EnrollStudent(ledger.school(), 'C1', 'b'));
+    assert.strictEqual(result.overCapacity, true);
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('C1', 'b')).overCapacity, true);
+    assert.strictEqual(ledger.lastEvent().name, 'EnrollmentOverCapacity');
+    assert.strictEqual(ledger.lastEvent().payload.enrolledCount, 2);
+});
+
+test('CloneClass copies the configuration for a new semester without enrollments', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '30', '2026-S1');
+    await classes.AddModuleToClass(ledger.school(), 'C1', 'Algebra');
+    await classes.EnrollStudent(ledger.school(), 'C1', 'a');
+
+    await assert.rejects(classes.CloneClass(ledger.school('teacher2@school.academic.edu'), 'C1', 'C1-S2', '2026-S2'),
+        /Only the teacher of class C1 or an admin can manage its clones/);
+    const clone = JSON.parse(await classes.CloneClass(ledger.admin(), 'C1', 'C1-S2', '2026-S2'));
+    assert.strictEqual(clone.semester, '2026-S2');
+    assert.strictEqual(clone.clonedFrom, 'C1');
+    assert.strictEqual(clone.teacher, 'teacher1@school.academic.edu');
+    assert.strictEqual(clone.maxStudents, 30);
+    assert.deepStrictEqual(clone.modules, ['Algebra']);
+    assert.deepStrictEqual(clone.enrolledStudents, []);
+    assert.strictEqual(clone.enrolledCount, 0);
+
+    await assert.rejects(classes.CloneClass(ledger.school(), 'C1', 'C1-S2', '2026-S2'), /Class C1-S2 already exists/);
+});
+
+/**
+ * Résultat figé d'un étudiant dans la classe prérequise P1 (FinalizeClassOutcome)
+ */
+async function finalizedPrerequisite(ledger, studentId, score) {
+    await new ClassContract().CreateClass(ledger.school(), 'P1', 'Prérequis', 'Bases');
+    await new ClassContract().EnrollStudent(ledger.school(), 'P1', studentId);
+    await new ExamContract().CreateExam(ledger.school(), 'PE1', 'P1', 'M1', 'Final', '2026-01-01T10:00:00Z', 'QmExam');
+    await new GradeContract().PublishGrade(ledger.school(), 'PG1', 'PE1', studentId, score, '');
+    await new GradeContract().FinalizeClassOutcome(ledger.school(), 'P1');
+}
+
+test('CheckEnrollmentEligibility reports every gate that EnrollStudent enforces', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await finalizedPrerequisite(ledger, 'alice', '15');
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '1');
+    await classes.EnrollStudent(ledger.school(), 'C1', 'a');
+    await classes.SetPrerequisites(ledger.school(), 'C1', '["P1"]');
+    await classes.SetEnrollmentWindow(ledger.school(), 'C1', '', '2026-01-05T00:00:00Z');
+
+    const report = JSON.parse(await classes.CheckEnrollmentEligibility(ledger.student('b'), 'C1', 'b'));
+    assert.strictEqual(report.eligible, false);
+    const failed = report.gates.filter((gate) => !gate.passed).map((gate) => gate.gate);
+    assert.deepStrictEqual(failed, ['enrollmentWindow', 'prerequisites', 'capacity']);
+
+    // EnrollStudent refuse pour le premier motif de la même liste
+    await assert.rejects(classes.EnrollStudent(ledger.student('b'), 'C1', 'b'), /Enrollment for class C1 closed on 2026-01-05/);
+
+    await classes.SetEnrollmentWindow(ledger.school(), 'C1', '', '');
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'C1', 'b'), /has not completed the prerequisites of class C1: P1/);
+});
+
+test('a finalized completed outcome satisfies a prerequisite', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await finalizedPrerequisite(ledger, 'alice', '15');
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await classes.SetPrerequisites(ledger.school(), 'C1', '["P1"]');
+
+    const report = JSON.parse(await classes.CheckEnrollmentEligibility(ledger.student('alice'), 'C1', 'alice'));
+    assert.strictEqual(report.eligible, true);
+    await classes.EnrollStudent(ledger.student('alice'), 'C1', 'alice');
+});
+
+test('class staff see their classes in GetMyClasses, with or without CouchDB', async () => {
+    for (const couchdb of [true, false]) {
+        const ledger = new MemoryLedger();
+        ledger.couchdb = couchdb;
+        const classes = new ClassContract();
+        await classes.CreateClass(ledger.school('prof'), 'A', 'Maths', 'Algèbre', '', 'S1');
+        await classes.CreateClass(ledger.school('other'), 'B', 'Physique', 'Mécanique', '', 'S1');
+        await classes.CreateClass(ledger.school('other'), 'C', 'Chimie', 'Organique', '', 'S1');
+
+        await assert.rejects(classes.AddClassStaff(ledger.school('prof'), 'B', 'prof', 'ta'),
+            /Only the teacher of class B or an admin can manage its staff/);
+        await classes.AddClassStaff(ledger.school('other'), 'B', 'prof', 'ta');
+        await assert.rejects(classes.AddClassStaff(ledger.school('other'), 'B', 'prof', 'ta'), /prof is already staff of class B/);
+
+        const mine = JSON.parse(await classes.GetMyClasses(ledger.school('prof')));
+        assert.deepStrictEqual(mine.map((entry) => [entry.id, entry.role]), [['A', 'teacher'], ['B', 'ta']]);
+        assert.deepStrictEqual(JSON.parse(await classes.GetMyClasses(ledger.school('nobody'))), []);
+
+        await classes.RemoveClassStaff(ledger.admin(), 'B', 'prof');
+        assert.deepStrictEqual(JSON.parse(await classes.GetMyClasses(ledger.school('prof'))).map((entry) => entry.id), ['A']);
+    }
+});
+
+test('ClassNearCapacity is emitted once the configured fill threshold is reached', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '10');
+    const names = [];
+    for (let i = 0; i < 10; i++) {
+        await classes.EnrollStudent(ledger.school(), 'A', `s${i}`);
+        names.push(ledger.lastEvent().name);
+    }
+    assert.deepStrictEqual(names.slice(7), ['StudentEnrolled', 'ClassNearCapacity', 'StudentEnrolled']);
+
+    await classes.WithdrawStudent(ledger.school(), 'A', 's9');
+    await classes.WithdrawStudent(ledger.school(), 'A', 's8');
+    await classes.EnrollStudent(ledger.school(), 'A', 's8');
+    assert.deepStrictEqual(ledger.lastEvent().payload, {
+        classId: 'A', studentId: 's8', enrolledBy: 'teacher1@school.academic.edu', mspID: 'SchoolMSP', enrolledCount: 9, maxStudents: 10,
+    });
+
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"nearCapacityPercent":50}');
+    await classes.CreateClass(ledger.school(), 'B', 'Physique', 'Mécanique', '4');
+    await classes.EnrollStudent(ledger.school(), 'B', 's0');
+    assert.strictEqual(ledger.lastEvent().name, 'StudentEnrolled');
+    await classes.EnrollStudent(ledger.school(), 'B', 's1');
+    assert.strictEqual(ledger.lastEvent().name, 'ClassNearCapacity');
+});
+
+test('EnrollStudent rejects a full class without writing anything; the committed seat count decides', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '2');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's0');
+    await classes.EnrollStudent(ledger.student('s1'), 'C1', 's1');
+
+    // Transactions validées l'une après l'autre: la seconde inscription lit la classe déjà pleine
+    const before = ledger.get('C1');
+    await assert.rejects(classes.EnrollStudent(ledger.student('s2'), 'C1', 's2'), /Class C1 is full \(2\/2 students\)/);
+    assert.deepStrictEqual(ledger.get('C1'), before);
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('C1', 's2')), null);
+
+    await classes.WithdrawStudent(ledger.student('s1'), 'C1', 's1');
+    assert.strictEqual(JSON.parse(await classes.EnrollStudent(ledger.student('s2'), 'C1', 's2')).success, true);
+    assert.strictEqual(ledger.get('C1').enrolledCount, 2);
+});
+
+test('TransferEnrollment leaves the source untouched when the target is full, unless waitlisting is requested', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '5');
+    await classes.CreateClass(ledger.school(), 'B', 'Maths B', 'Algèbre', '1');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    await classes.EnrollStudent(ledger.school(), 'B', 'sx');
+
+    await assert.rejects(classes.TransferEnrollment(ledger.school(), 'A', 'B', 's1'), /Class B is full \(1\/1 students\)/);
+    assert.deepStrictEqual(ledger.get('A').enrolledStudents, ['s1']);
+
+    const result = JSON.parse(await classes.TransferEnrollment(ledger.school(), 'A', 'B', 's1', 'true'));
+    assert.strictEqual(result.status, 'waitlisted');
+    assert.deepStrictEqual(ledger.get('A').enrolledStudents, []);
+    assert.deepStrictEqual(ledger.get('B').waitlist, ['s1']);
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('B', 's1')).status, 'waitlisted');
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('B', 's1')).transferredFrom, 'A');
+    await assert.rejects(classes.TransferEnrollment(ledger.school(), 'A', 'B', 's1', 'true'), /Student s1 is not enrolled in class A/);
+});
+
+test('the withdrawal policy decides whether pending grades are voided', async () => {
+    for (const policy of ['keep', 'void']) {
+        const ledger = new MemoryLedger();
+        const classes = new ClassContract();
+        const grades = new GradeContract();
+        await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+        await classes.EnrollStudent(ledger.school(), 'C1', 's1');
+        await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+        await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '12', '');
+        await classes.SetWithdrawalPolicy(ledger.school(), 'C1', policy);
+
+        const result = JSON.parse(await classes.WithdrawStudent(ledger.school(), 'C1', 's1'));
+        assert.strictEqual(result.withdrawalPolicy, policy);
+        const published = JSON.parse(await grades.PublishExamGrades(ledger.school(), 'E1')).published;
+        if (policy === 'keep') {
+            assert.deepStrictEqual(result.voided, []);
+            assert.deepStrictEqual(published, ['G1']);
+        } else {
+            assert.deepStrictEqual(result.voided, ['G1']);
+            assert.strictEqual(ledger.get('G1').voidReason, 'withdrawal');
+            assert.deepStrictEqual(published, []);
+        }
+    }
+});
+
+test('GetRemainingSeats follows enrollments and withdrawals', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '3');
+    assert.deepStrictEqual(JSON.parse(await classes.GetRemainingSeats(ledger.student('s1'), 'C1')), {
+        classId: 'C1', maxStudents: 3, enrolledCount: 0, heldSeats: 0, remainingSeats: 3,
+        seatPools: { lecture: { capacity: 3, enrolledCount: 0, remainingSeats: 3 } },
+    });
+    await classes.EnrollStudent(ledger.school(), 'C1', 's1');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's2');
+    assert.strictEqual(JSON.parse(await classes.GetRemainingSeats(ledger.school(), 'C1')).remainingSeats, 1);
+    await classes.WithdrawStudent(ledger.school(), 'C1', 's1');
+    assert.strictEqual(JSON.parse(await classes.GetRemainingSeats(ledger.school(), 'C1')).remainingSeats, 2);
+    await assert.rejects(classes.GetRemainingSeats(ledger.school(), 'Z'), /Class Z does not exist/);
+});
+
+test('EnrollStudentWithSponsor records the sponsor in the enrollment and the roster export', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '3', 'S1');
+    const result = JSON.parse(await classes.EnrollStudentWithSponsor(ledger.school(), 'C1', 's1', 'guardian-1'));
+    assert.strictEqual(result.sponsorId, 'guardian-1');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's2');
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('C1', 's1')).sponsorId, 'guardian-1');
+
+    const roster = JSON.parse(await classes.ExportClassRoster(ledger.school(), 'C1'));
+    assert.deepStrictEqual(roster.roster.map((entry) => [entry.studentId, entry.sponsorId]), [['s1', 'guardian-1'], ['s2', null]]);
+    await assert.rejects(classes.EnrollStudentWithSponsor(ledger.student('s3'), 'C1', 's3', ' '), /Invalid sponsorId/);
+    await assert.rejects(classes.ExportClassRoster(ledger.student('s1'), 'C1'), /Only SchoolOrg members can export class rosters/);
+});
+
+test('SetPrerequisites rejects cycles and DetectPrerequisiteCycles reports older ones', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    for (const classId of ['A', 'B', 'C']) {
+        await classes.CreateClass(ledger.school(), classId, classId, 'Cours');
+    }
+    await classes.SetPrerequisites(ledger.school(), 'C', '["B"]');
+    await classes.SetPrerequisites(ledger.school(), 'B', '["A"]');
+    assert.deepStrictEqual(JSON.parse(await classes.DetectPrerequisiteCycles(ledger.school())), { classCount: 3, hasCycles: false, cycles: [] });
+
+    await assert.rejects(classes.SetPrerequisites(ledger.school(), 'A', '["C"]'), /Prerequisite cycle: A -> C -> B -> A/);
+    await assert.rejects(classes.SetPrerequisites(ledger.school(), 'A', '["A"]'), /class A cannot require itself/);
+    await assert.rejects(classes.SetPrerequisites(ledger.school('teacher2@school.academic.edu'), 'A', '["B"]'),
+        /Only the teacher of class A or an admin can manage its prerequisites/);
+
+    // Cycle écrit avant la validation
+    ledger.put('A', Object.assign(ledger.get('A'), { prerequisites: ['C'] }));
+    const expected = { classCount: 3, hasCycles: true, cycles: [['A', 'C', 'B', 'A']] };
+    assert.deepStrictEqual(JSON.parse(await classes.DetectPrerequisiteCycles(ledger.school())), expected);
+    ledger.couchdb = false;
+    assert.deepStrictEqual(JSON.parse(await classes.DetectPrerequisiteCycles(ledger.school())), expected);
+});
+
+test('held seats count against capacity until they expire or are confirmed', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '2');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    const hold = JSON.parse(await classes.HoldSeat(ledger.student('s2'), 'A', 's2', '600'));
+    assert.strictEqual(hold.expiresAt, '2026-01-10T10:10:00.000Z');
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'A', 's3'), /Class A is full \(1\/2 students, 1 seats held\)/);
+    await assert.rejects(classes.HoldSeat(ledger.school(), 'A', 's3', '600'), /Class A is full/);
+    assert.deepStrictEqual(JSON.parse(await classes.ReleaseExpiredHolds(ledger.school(), 'A')), { classId: 'A', released: [], remainingHolds: 1 });
+
+    // Un hold expiré reste compté tant qu'il n'est pas libéré
+    ledger.advance(601);
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'A', 's3'), /Class A is full/);
+    assert.deepStrictEqual(JSON.parse(await classes.ReleaseExpiredHolds(ledger.student('s9'), 'A')).released, ['s2']);
+    await classes.EnrollStudent(ledger.school(), 'A', 's3');
+
+    // L'inscription de l'étudiant confirme son hold
+    await classes.CreateClass(ledger.school(), 'B', 'Physique', 'Mécanique', '1');
+    await classes.HoldSeat(ledger.school(), 'B', 's1', '60');
+    await classes.EnrollStudent(ledger.student('s1'), 'B', 's1');
+    assert.deepStrictEqual(ledger.get('B').holds, []);
+    await assert.rejects(classes.HoldSeat(ledger.school(), 'B', 's2', '0'), /Invalid ttlSeconds/);
+});
+
+test('GetEnrollmentBreakdown counts active enrollments per tag value', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '9');
+    for (const studentId of ['s1', 's2', 's3', 's4', 's5']) {
+        await classes.EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await classes.SetEnrollmentTags(ledger.school(), 'C1', 's1', '{"cohort":"2026","program":"CS"}');
+    await classes.SetEnrollmentTags(ledger.school(), 'C1', 's2', '{"cohort":"2026","program":"Math"}');
+    await classes.SetEnrollmentTags(ledger.school(), 'C1', 's3', '{"cohort":"2025"}');
+    await classes.SetEnrollmentTags(ledger.school(), 'C1', 's5', '{"cohort":"2025","program":"CS"}');
+    await classes.WithdrawStudent(ledger.school(), 'C1', 's5');
+
+    assert.deepStrictEqual(JSON.parse(await classes.GetEnrollmentBreakdown(ledger.school(), 'C1')), {
+        classId: 'C1',
+        activeCount: 4,
+        breakdown: {
+            cohort: { 2025: 1, 2026: 2, untagged: 1 },
+            program: { CS: 1, Math: 1, untagged: 2 },
+        },
+    });
+    await assert.rejects(classes.GetEnrollmentBreakdown(ledger.student('s1'), 'C1'), /Only SchoolOrg members can view enrollment breakdowns/);
+    await assert.rejects(classes.SetEnrollmentTags(ledger.school(), 'C1', 's5', '{"cohort":"x"}'), /Student s5 is not enrolled in class C1/);
+    await assert.rejects(classes.SetEnrollmentTags(ledger.school(), 'C1', 's1', '["x"]'), /must be a JSON object/);
+});
+
+test('GetAllClasses is capped and GetAllClassesPaginated continues from its bookmark', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    for (let i = 0; i < 503; i++) {
+        const id = `C${String(i).padStart(4, '0')}`;
+        ledger.put(id, { docType: 'class', id: id, name: id, description: '', enrolledStudents: [] });
+    }
+    ledger.put('EXAMX', { docType: 'exam', id: 'EXAMX' });
+
+    const all = JSON.parse(await classes.GetAllClasses(ledger.school()));
+    assert.strictEqual(all.count, 500);
+    assert.strictEqual(all.truncated, true);
+    assert.strictEqual(all.bookmark, 'C0500');
+
+    const rest = JSON.parse(await classes.GetAllClassesPaginated(ledger.school(), '10', all.bookmark));
+    assert.deepStrictEqual(rest.classes.map((classData) => classData.id), ['C0500', 'C0501', 'C0502']);
+    assert.strictEqual(rest.bookmark, '');
+    const first = JSON.parse(await classes.GetAllClassesPaginated(ledger.school(), '2', ''));
+    assert.deepStrictEqual(first.classes.map((classData) => classData.id), ['C0000', 'C0001']);
+    assert.strictEqual(first.bookmark, 'C0002');
+    await assert.rejects(classes.GetAllClassesPaginated(ledger.school(), '501', ''), /Invalid pageSize: must be an integer between 1 and 500/);
+});
+
+test('enrolling an already enrolled student is a no-op unless the sponsor differs', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '5');
+    await classes.EnrollStudent(ledger.student('s1'), 'C1', 's1');
+    const writes = ledger.history.get('C1').length;
+    const events = ledger.events.length;
+
+    const again = JSON.parse(await classes.EnrollStudent(ledger.student('s1'), 'C1', 's1'));
+    assert.strictEqual(again.alreadyEnrolled, true);
+    assert.strictEqual(ledger.history.get('C1').length, writes);
+    assert.strictEqual(ledger.events.length, events);
+
+    await classes.EnrollStudentWithSponsor(ledger.school(), 'C1', 's2', 'p1');
+    assert.strictEqual(JSON.parse(await classes.EnrollStudentWithSponsor(ledger.school(), 'C1', 's2', 'p1')).alreadyEnrolled, true);
+    await assert.rejects(classes.EnrollStudentWithSponsor(ledger.school(), 'C1', 's2', 'p2'), /Student s2 is already enrolled in class C1/);
+    await assert.rejects(classes.EnrollStudentWithSponsor(ledger.school(), 'C1', 's1', 'p2'), /Student s1 is already enrolled in class C1/);
+    assert.strictEqual(ledger.get('C1').enrolledCount, 2);
+});
+
+test('GetClassSummary reports counter drift and repairs it only on request', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '5');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await classes.EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    ledger.put('C1', Object.assign(ledger.get('C1'), { enrolledCount: 7 }));
+    const writes = ledger.history.get('C1').length;
+
+    const summary = JSON.parse(await classes.GetClassSummary(ledger.student('s1'), 'C1'));
+    assert.strictEqual(summary.enrolledCount, 3);
+    assert.strictEqual(summary.remainingSeats, 2);
+    assert.deepStrictEqual(summary.counterDrift, { stored: 7, actual: 3 });
+    assert.strictEqual(summary.repaired, false);
+    assert.strictEqual(ledger.history.get('C1').length, writes);
+
+    await assert.rejects(classes.GetClassSummary(ledger.student('s1'), 'C1', 'true'), /Only SchoolOrg members can repair enrollment counters/);
+    assert.strictEqual(JSON.parse(await classes.GetClassSummary(ledger.school(), 'C1', 'true')).repaired, true);
+    assert.strictEqual(ledger.get('C1').enrolledCount, 3);
+    assert.strictEqual(JSON.parse(await classes.GetClassSummary(ledger.school(), 'C1', 'true')).repaired, false);
+});
+
+test('PatchClass updates only the given fields', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Algo', 'Graphes', '5', 'S1');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await classes.EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+
+    const patched = JSON.parse(await classes.PatchClass(ledger.school(), 'C1', '{"description":""}'));
+    assert.deepStrictEqual([patched.name, patched.description, patched.maxStudents, patched.semester], ['Algo', '', 5, 'S1']);
+    await classes.PatchClass(ledger.school(), 'C1', '{"maxStudents":40,"semester":null}');
+    assert.strictEqual(ledger.get('C1').maxStudents, 40);
+    assert.strictEqual(ledger.get('C1').semester, null);
+
+    await assert.rejects(classes.PatchClass(ledger.school(), 'C1', '{"maxStudents":2}'), /Invalid maxStudents: 2 is below the 3 active enrollments of class C1/);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'C1', '{"teacher":"x"}'), /Invalid field: teacher cannot be patched/);
+    await assert.rejects(classes.PatchClass(ledger.school('other'), 'C1', '{"name":"x"}'), /Only the teacher of class C1 or an admin/);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'C1', '{}'), /at least one field is required/);
+});
+
+test('EnrollStudentBackdated lets an admin record an earlier effective date with a reason', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '5');
+    await assert.rejects(classes.EnrollStudentBackdated(ledger.school(), 'C1', 's1', '2026-01-01T09:00:00Z', 'paper'),
+        /Only admins can backdate enrollments/);
+    await assert.rejects(classes.EnrollStudentBackdated(ledger.admin(), 'C1', 's1', '2026-02-01T09:00:00Z', 'paper'),
+        /must be before the transaction time 2026-01-10T10:00:00.000Z/);
+    await assert.rejects(classes.EnrollStudentBackdated(ledger.admin(), 'C1', 's1', '2026-01-01T09:00:00Z', ' '),
+        /A reason is required to backdate an enrollment/);
+
+    const result = JSON.parse(await classes.EnrollStudentBackdated(ledger.admin(), 'C1', 's1', '2026-01-01T09:00:00+01:00', 'paper form #12'));
+    assert.strictEqual(result.enrolledAt, '2026-01-01T08:00:00.000Z');
+    assert.strictEqual(result.backdated, true);
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('C1', 's1')).enrolledAt, '2026-01-01T08:00:00.000Z');
+
+    const [audit] = JSON.parse(await new AuditContract().GetAuditTrail(ledger.school(), 'C1'));
+    assert.strictEqual(audit.action, 'EnrollmentBackdated');
+    assert.deepStrictEqual(audit.details, { effectiveDate: '2026-01-01T08:00:00.000Z', recordedAt: '2026-01-10T10:00:00.000Z', studentId: 's1' });
+});
+
+test('WithdrawStudentsBatch withdraws each listed student and promotes the waitlist', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '3');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await classes.EnrollStudent(ledger.school(), 'A', studentId);
+    }
+    for (const studentId of ['w1', 'w2']) {
+        await classes.JoinWaitlist(ledger.student(studentId), 'A', studentId);
+    }
+    await classes.WithdrawStudent(ledger.school(), 'A', 's3');
+    assert.deepStrictEqual(ledger.get('A').waitlist, ['w2']);
+
+    await assert.rejects(classes.WithdrawStudentsBatch(ledger.school('x'), 'A', '["s1"]', 'cancelled'), /Only the teacher of class A or an admin/);
+    await assert.rejects(classes.WithdrawStudentsBatch(ledger.school(), 'A', '["s1"]', ''), /A reason is required to withdraw students in batch/);
+    const result = JSON.parse(await classes.WithdrawStudentsBatch(ledger.school(), 'A', '["s1","s3","s1"]', 'section cancelled'));
+    assert.deepStrictEqual(result.results.map((entry) => [entry.studentId, entry.status]), [['s1', 'withdrawn'], ['s3', 'skipped'], ['s1', 'skipped']]);
+    assert.strictEqual(result.results[2].error, 'Duplicate student s1 in batch');
+    assert.deepStrictEqual(result.promoted, ['w2']);
+    assert.deepStrictEqual(ledger.get('A').enrolledStudents, ['s2', 'w1', 'w2']);
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('A', 's1')).withdrawalReason, 'section cancelled');
+    assert.strictEqual(JSON.parse(await new NotificationContract().GetMyNotifications(ledger.student('w1')))[0].type, 'WaitlistPromoted');
+});
+
+test('every path that frees a seat promotes the waitlist in order', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '1');
+    await classes.CreateClass(ledger.school(), 'B', 'Maths B', 'Algèbre', '5');
+    await classes.EnrollStudent(ledger.school(), 'A', 's0');
+    for (const studentId of ['w1', 'w2', 'w3', 'w4']) {
+        ledger.advance(1);
+        await classes.JoinWaitlist(ledger.student(studentId), 'A', studentId);
+    }
+
+    assert.deepStrictEqual(JSON.parse(await classes.WithdrawStudent(ledger.student('s0'), 'A', 's0')).promoted, ['w1']);
+    assert.deepStrictEqual(JSON.parse(await classes.TransferEnrollment(ledger.school(), 'A', 'B', 'w1', '')).promoted, ['w2']);
+    await classes.PatchClass(ledger.school(), 'A', JSON.stringify({ maxStudents: 2 }));
+    assert.deepStrictEqual(ledger.get('A').enrolledStudents, ['w2', 'w3']);
+    assert.deepStrictEqual(JSON.parse(await classes.SetSeatCapacity(ledger.school(), 'A', 'lecture', '3')).promoted, ['w4']);
+    assert.deepStrictEqual(ledger.get('A').waitlist, []);
+});
+
+test('lab seats form a separate pool from lecture seats', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Chimie', 'Organique', '2');
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'C1', 's1', 'lab'), /Class C1 has no lab seats \(see SetSeatCapacity\)/);
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'C1', 's1', 'gym'), /Invalid seatType: gym \(supported: lecture, lab\)/);
+    await classes.SetSeatCapacity(ledger.school(), 'C1', 'lab', '1');
+
+    await classes.EnrollStudent(ledger.school(), 'C1', 's1', 'lab');
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'C1', 's2', 'lab'), /Class C1 has no lab seats left \(1\/1 students\)/);
+    await classes.EnrollStudent(ledger.school(), 'C1', 's2');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's3', 'lecture');
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'C1', 's4'), /Class C1 is full \(2\/2 students\)/);
+    assert.strictEqual(JSON.parse(await classes.EnrollStudent(ledger.school(), 'C1', 's1', 'lab')).alreadyEnrolled, true);
+    assert.deepStrictEqual(JSON.parse(await classes.GetRemainingSeats(ledger.school(), 'C1')).seatPools, {
+        lecture: { capacity: 2, enrolledCount: 2, remainingSeats: 0 },
+        lab: { capacity: 1, enrolledCount: 1, remainingSeats: 0 },
+    });
+
+    await classes.WithdrawStudent(ledger.school(), 'C1', 's1');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's4', 'lab');
+    await assert.rejects(classes.SetSeatCapacity(ledger.school(), 'C1', 'lecture', '1'), /Invalid capacity: 1 is below the 2 active lecture enrollments/);
+    assert.strictEqual(ledger.get('C1').enrolledCount, 3);
+});
+
+test('maxStudents must be a positive integer within the configured system limit', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    for (const maxStudents of ['-5', 'abc']) {
+        await assert.rejects(classes.CreateClass(ledger.school(), 'N', 'N', 'Cours', maxStudents), /Invalid maxStudents: must be a positive integer/);
+    }
+    await assert.rejects(classes.CreateClass(ledger.school(), 'N', 'N', 'Cours', '501'), /Invalid maxStudents: 501 exceeds the system limit of 500/);
+    await classes.CreateClass(ledger.school(), 'A', 'A', 'Cours', '');
+    await classes.CreateClass(ledger.school(), 'B', 'B', 'Cours', '0');
+    await classes.CreateClass(ledger.school(), 'C', 'C', 'Cours', '500');
+    assert.deepStrictEqual(['A', 'B', 'C'].map((classId) => ledger.get(classId).maxStudents), [30, 30, 500]);
+
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"defaultMaxStudents":12,"maxStudentsLimit":100}');
+    await classes.CreateClass(ledger.school(), 'D', 'D', 'Cours', '');
+    assert.strictEqual(ledger.get('D').maxStudents, 12);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'D', '{"maxStudents":101}'), /101 exceeds the system limit of 100/);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'D', '{"maxStudents":0}'), /Invalid maxStudents: must be a positive integer$/);
+    await assert.rejects(classes.SetSeatCapacity(ledger.school(), 'D', 'lecture', '0'), /Invalid maxStudents: must be a positive integer$/);
+    // 0 reste accepté pour les autres pools (pas de places)
+    await classes.SetSeatCapacity(ledger.school(), 'D', 'lab', '0');
+});
+
+test('CloneClass gives a class without capacity the default one and checks the system limit', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'OLD', 'Maths', 'Algèbre', '200', '2025-S2');
+    // Classe antérieure aux capacités obligatoires (maxStudents à 0)
+    ledger.put('OLD', Object.assign(ledger.get('OLD'), { maxStudents: 0 }));
+
+    const clone = JSON.parse(await classes.CloneClass(ledger.school(), 'OLD', 'NEW', '2026-S1'));
+    assert.strictEqual(clone.maxStudents, 30);
+    const seats = JSON.parse(await classes.GetRemainingSeats(ledger.school(), 'NEW'));
+    assert.strictEqual(seats.remainingSeats, 30);
+
+    await classes.PatchClass(ledger.school(), 'NEW', '{"maxStudents":200}');
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"defaultMaxStudents":30,"maxStudentsLimit":100}');
+    await assert.rejects(classes.CloneClass(ledger.school(), 'NEW', 'NEXT', '2026-S2'), /Invalid maxStudents: 200 exceeds the system limit of 100/);
+});
+
+test('SetSystemConfig keeps defaultMaxStudents a positive integer within maxStudentsLimit', async () => {
+    const ledger = new MemoryLedger();
+    const config = new ConfigContract();
+    await assert.rejects(config.SetSystemConfig(ledger.admin(), '{"defaultMaxStudents":2.5}'), /Invalid value for defaultMaxStudents: must be a positive integer/);
+    await assert.rejects(config.SetSystemConfig(ledger.admin(), '{"defaultMaxStudents":600}'), /600 exceeds maxStudentsLimit \(500\)/);
+    await assert.rejects(config.SetSystemConfig(ledger.admin(), '{"maxStudentsLimit":20}'), /defaultMaxStudents: 30 exceeds maxStudentsLimit \(20\)/);
+    await config.SetSystemConfig(ledger.admin(), '{"defaultMaxStudents":20,"maxStudentsLimit":20}');
+});
+
+test('GetRosterChangesSince lists the enrollments and withdrawals recorded after a timestamp', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    ledger.setTime('2026-01-01T00:00:00Z');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's1');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's2');
+    ledger.setTime('2026-01-05T00:00:00Z');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's3');
+    await classes.WithdrawStudent(ledger.school(), 'C1', 's1');
+    // Une inscription antidatée compte à sa date d'enregistrement
+    await classes.EnrollStudentBackdated(ledger.admin(), 'C1', 's4', '2025-12-01T00:00:00Z', 'paper');
+
+    const changes = JSON.parse(await classes.GetRosterChangesSince(ledger.school(), 'C1', '2026-01-02T00:00:00Z'));
+    assert.strictEqual(changes.asOf, '2026-01-05T00:00:00.000Z');
+    assert.deepStrictEqual(changes.changes.map((change) => [change.studentId, change.change]), [['s1', 'withdrawn'], ['s3', 'enrolled'], ['s4', 'enrolled']]);
+    assert.strictEqual(changes.changes[0].enrolledAt, '2026-01-01T00:00:00.000Z');
+    assert.deepStrictEqual(JSON.parse(await classes.GetRosterChangesSince(ledger.school(), 'C1', '2026-01-06T00:00:00+02:00')).changes, []);
+
+    await assert.rejects(classes.GetRosterChangesSince(ledger.school(), 'C1', 'yesterday'), /Invalid sinceTimestamp format/);
+    await assert.rejects(classes.GetRosterChangesSince(ledger.student('s1'), 'C1', '2026-01-02T00:00:00Z'), /Only SchoolOrg members/);
+});
+
+test('GetWaitlistPosition reports the position of a student and follows promotions', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '10');
+    await classes.CreateClass(ledger.school(), 'B', 'Maths B', 'Algèbre', '1');
+    await classes.EnrollStudent(ledger.school(), 'B', 'x');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await classes.EnrollStudent(ledger.school(), 'A', studentId);
+    }
+    for (const studentId of ['s1', 's2', 's3']) {
+        ledger.advance(60);
+        await classes.TransferEnrollment(ledger.school(), 'A', 'B', studentId, 'true');
+    }
+    const position = async (studentId) => JSON.parse(await classes.GetWaitlistPosition(ledger.student(studentId), 'B', studentId));
+    assert.deepStrictEqual([(await position('s1')).position, (await position('s2')).position, (await position('s3')).position], [1, 2, 3]);
+
+    const active = JSON.parse(await classes.GetWaitlistPosition(ledger.school(), 'B', 'x'));
+    assert.strictEqual(active.status, 'active');
+    assert.strictEqual(active.position, null);
+    await assert.rejects(classes.GetWaitlistPosition(ledger.school(), 'B', 'zz'), /Student zz is not on the waitlist of class B/);
+    await assert.rejects(classes.GetWaitlistPosition(ledger.student('s2'), 'B', 's1'), /Students can only view their own waitlist position/);
+
+    await classes.WithdrawStudentsBatch(ledger.school(), 'B', '["x"]', 'moved');
+    assert.strictEqual((await position('s1')).status, 'active');
+    assert.deepStrictEqual(await position('s2'),
+        { classId: 'B', studentId: 's2', status: 'waitlisted', position: 1, waitlistCount: 2, waitlistedAt: '2026-01-10T10:02:00.000Z' });
+});
+
+test('maxConcurrentActiveEnrollments caps the active enrollments of a student', async () => {
+    for (const couchdb of [true, false]) {
+        const ledger = new MemoryLedger();
+        ledger.couchdb = couchdb;
+        const classes = new ClassContract();
+        for (const [classId, semester] of [['A', '2026-S1'], ['B', '2026-S2'], ['C', '2027-S1'], ['D', '2027-S1']]) {
+            await classes.CreateClass(ledger.school(), classId, classId, 'Cours', '5', semester);
+        }
+        for (const classId of ['A', 'B', 'C']) {
+            await classes.EnrollStudent(ledger.school(), classId, 's1');
+        }
+        await new ConfigContract().SetSystemConfig(ledger.admin(), '{"maxConcurrentActiveEnrollments":3}');
+
+        await assert.rejects(classes.EnrollStudent(ledger.student('s1'), 'D', 's1'),
+            /Student s1 has reached the maximum of 3 concurrent active enrollments \(A, B, C\)/);
+        await classes.EnrollStudent(ledger.school(), 'D', 's2');
+        await classes.WithdrawStudent(ledger.school(), 'A', 's1');
+        await classes.EnrollStudent(ledger.student('s1'), 'D', 's1');
+        assert.strictEqual(JSON.parse(await classes.EnrollStudent(ledger.student('s1'), 'D', 's1')).alreadyEnrolled, true);
+        await assert.rejects(classes.EnrollStudent(ledger.student('s1'), 'A', 's1'), /concurrent active enrollments \(B, C, D\)/);
+    }
+});
+
+test('the concurrent enrollment cap applies to batches and waitlists but not to moves', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"maxConcurrentActiveEnrollments":1}');
+    for (const classId of ['A', 'B', 'C', 'D']) {
+        await classes.CreateClass(ledger.school(), classId, classId, 'Cours', '1');
+    }
+    await classes.EnrollStudent(ledger.school(), 'A', 'alice');
+
+    await assert.rejects(classes.EnrollStudentsBatch(ledger.school(), 'B', '["alice"]', ''), /maximum of 1 concurrent active enrollments \(A\)/);
+    assert.deepStrictEqual(JSON.parse(await classes.EnrollStudentsBatch(ledger.school(), 'B', '["alice","bob"]', 'besteffort')).enrolled, ['bob']);
+    // Transfert et fusion: le nombre d'inscriptions actives ne change pas
+    assert.strictEqual(JSON.parse(await classes.TransferEnrollment(ledger.school(), 'A', 'C', 'alice')).status, 'active');
+    await assert.rejects(classes.JoinWaitlist(ledger.school(), 'B', 'alice'), /maximum of 1 concurrent active enrollments \(C\)/);
+    assert.deepStrictEqual(JSON.parse(await classes.MergeClasses(ledger.admin(), 'C', 'D')).enrolled, ['alice']);
+});
+
+test('waitlist promotion skips students who reached the concurrent enrollment cap', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"maxConcurrentActiveEnrollments":2}');
+    for (const classId of ['A', 'B', 'C']) {
+        await classes.CreateClass(ledger.school(), classId, classId, 'Cours', '1');
+    }
+    await classes.EnrollStudent(ledger.school(), 'A', 'alice');
+    await classes.EnrollStudent(ledger.school(), 'B', 'bob');
+    await classes.JoinWaitlist(ledger.school(), 'B', 'alice');
+    ledger.advance(10);
+    await classes.JoinWaitlist(ledger.school(), 'B', 'carol');
+    await classes.EnrollStudent(ledger.school(), 'C', 'alice');
+
+    const result = JSON.parse(await classes.WithdrawStudentsBatch(ledger.school(), 'B', '["bob"]', 'moved'));
+    assert.deepStrictEqual(result.promoted, ['carol']);
+    assert.deepStrictEqual(ledger.get('B').waitlist, ['alice']);
+});
+
+test('JoinWaitlist is bounded by maxWaitlist and only opens once the class is full', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '1');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    assert.strictEqual(ledger.get('A').maxWaitlist, 10);
+    await classes.PatchClass(ledger.school(), 'A', JSON.stringify({ maxWaitlist: 2 }));
+
+    assert.deepStrictEqual(JSON.parse(await classes.JoinWaitlist(ledger.student('s2'), 'A', 's2')),
+        { success: true, classId: 'A', studentId: 's2', position: 1, maxWaitlist: 2 });
+    await assert.rejects(classes.JoinWaitlist(ledger.student('s2'), 'A', 's2'), /Student s2 is already on the waitlist of class A/);
+    await assert.rejects(classes.JoinWaitlist(ledger.student('s2'), 'A', 's3'), /Students can only join a waitlist themselves/);
+    await classes.JoinWaitlist(ledger.school(), 'A', 's3');
+    await assert.rejects(classes.JoinWaitlist(ledger.student('s4'), 'A', 's4'), /Waitlist of class A is full \(maxWaitlist: 2\)/);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'A', JSON.stringify({ maxWaitlist: 1 })), /1 is below the 2 students on the waitlist/);
+
+    await classes.CreateClass(ledger.school(), 'B', 'Maths B', 'Algèbre', '1');
+    await assert.rejects(classes.JoinWaitlist(ledger.student('s4'), 'B', 's4'), /Class B still has available seats: use EnrollStudent/);
+    await classes.EnrollStudent(ledger.school(), 'B', 's5');
+    await classes.PatchClass(ledger.school(), 'B', JSON.stringify({ maxWaitlist: 0 }));
+    await assert.rejects(classes.JoinWaitlist(ledger.student('s4'), 'B', 's4'), /Class B does not allow a waitlist \(maxWaitlist is 0\)/);
+    await classes.CreateClass(ledger.school(), 'C', 'Maths C', 'Algèbre', '5');
+    await classes.EnrollStudent(ledger.school(), 'C', 's4');
+    await assert.rejects(classes.TransferEnrollment(ledger.school(), 'C', 'B', 's4', 'true'), /Class B does not allow a waitlist/);
+
+    await classes.WithdrawStudent(ledger.school(), 'A', 's1');
+    assert.strictEqual(JSON.parse(await classes.GetWaitlistPosition(ledger.school(), 'A', 's3')).position, 1);
+});
+
+test('enrollments record who enrolled the student in the roster export and roster changes', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '5');
+    await classes.EnrollStudent(ledger.admin(), 'A', 's1');
+    await classes.EnrollStudent(ledger.student('s2'), 'A', 's2');
+    await classes.EnrollStudentsBatch(ledger.school(), 'A', '["s3"]', '');
+    ledger.advance(100);
+    await classes.EnrollStudentBackdated(ledger.admin(), 'A', 's4', '2026-01-01T00:00:00Z', 'paper form');
+    // Inscription antérieure au champ enrolledBy
+    const legacy = ledger.get(ClassContract.enrollmentKey('A', 's1'));
+    delete legacy.enrolledBy;
+    ledger.put(ClassContract.enrollmentKey('A', 's1'), legacy);
+
+    const roster = JSON.parse(await classes.ExportClassRoster(ledger.school(), 'A')).roster;
+    assert.deepStrictEqual(roster.map((entry) => [entry.studentId, entry.enrolledBy]), [
+        ['s1', null],
+        ['s2', 's2'],
+        ['s3', 'teacher1@school.academic.edu'],
+        ['s4', 'Admin@school.academic.edu'],
+    ]);
+
+    const changes = JSON.parse(await classes.GetRosterChangesSince(ledger.school(), 'A', '2026-01-10T10:00:30Z')).changes;
+    assert.deepStrictEqual(changes.map((change) => [change.studentId, change.enrolledBy]), [['s4', 'Admin@school.academic.edu']]);
+});
+
+test('MergeClasses moves students within the target capacity and re-homes exams, grades and materials', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const grades = new GradeContract();
+    await classes.CreateClass(ledger.school(), 'S', 'Maths S', 'Algèbre', '5');
+    await classes.CreateClass(ledger.school(), 'T', 'Maths T', 'Algèbre', '3');
+    await classes.AddModuleToClass(ledger.school(), 'S', 'M1');
+    for (const studentId of ['a', 'b', 'c']) {
+        await classes.EnrollStudent(ledger.school(), 'S', studentId);
+    }
+    for (const studentId of ['x', 'c']) {
+        await classes.EnrollStudent(ledger.school(), 'T', studentId);
+    }
+    await new MaterialContract().UploadCourseMaterial(ledger.school(), 'm1', 'S', 'M1', 'Intro', 'COURS', 'QmMaterial', '10');
+    await new ExamContract().CreateExam(ledger.school(), 'E', 'S', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'g1', 'E', 'a', '12', '');
+
+    await classes.PatchClass(ledger.school(), 'T', JSON.stringify({ maxWaitlist: 0 }));
+    await assert.rejects(classes.MergeClasses(ledger.school(), 'S', 'T'), /Only admins can merge classes/);
+    await assert.rejects(classes.MergeClasses(ledger.admin(), 'S', 'T'),
+        /2 incoming students for 1 free seats, and the target does not allow a waitlist/);
+    await classes.PatchClass(ledger.school(), 'T', JSON.stringify({ maxWaitlist: 2 }));
+
+    // Une note finalisée bloque la fusion jusqu'à sa dé-finalisation
+    await grades.FinalizeGrade(ledger.school(), 'g1');
+    await assert.rejects(classes.MergeClasses(ledger.admin(), 'S', 'T'), /Cannot merge S into T: grades g1 are finalized \(UnfinalizeGrade them first\)/);
+    assert.deepStrictEqual([ledger.get('g1').classId, ledger.get('S').enrolledStudents], ['S', ['a', 'b', 'c']]);
+    await grades.UnfinalizeGrade(ledger.admin(), 'g1', 'section merge');
+
+    assert.deepStrictEqual(JSON.parse(await classes.MergeClasses(ledger.admin(), 'S', 'T')), {
+        success: true, sourceClassId: 'S', targetClassId: 'T',
+        enrolled: ['a'], waitlisted: ['b'], moved: { exam: 1, grade: 1, material: 1 },
+    });
+    const target = ledger.get('T');
+    assert.deepStrictEqual([target.enrolledStudents, target.waitlist, target.modules], [['x', 'c', 'a'], ['b'], ['M1']]);
+    const source = ledger.get('S');
+    assert.deepStrictEqual([source.enrolledStudents, source.archived, source.mergedInto], [[], true, 'T']);
+    assert.deepStrictEqual([ledger.get('g1').classId, ledger.get('E').classId], ['T', 'T']);
+    assert.strictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E')).valid, true);
+    assert.strictEqual(JSON.parse(await grades.ComputeFinalGrade(ledger.school(), 'T', 'a')).percentage, 60);
+
+    await assert.rejects(classes.EnrollStudent(ledger.school(), 'S', 'z'), /Class S is archived \(merged into T\)/);
+    await assert.rejects(classes.MergeClasses(ledger.admin(), 'S', 'T'), /Class S is archived/);
+});
+
+test('watchers of a full class are notified when a seat opens, but not when the waitlist takes it', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const notifications = new NotificationContract();
+    const notificationCount = async (studentId) => JSON.parse(await notifications.GetMyNotifications(ledger.student(studentId))).length;
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '2');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    await assert.rejects(classes.WatchClass(ledger.student('w1'), 'A', 'w1'), /Class A still has available seats: use EnrollStudent/);
+    await classes.EnrollStudent(ledger.school(), 'A', 's2');
+
+    await assert.rejects(classes.WatchClass(ledger.student('w1'), 'A', 'w2'), /Students can only watch a class themselves/);
+    assert.deepStrictEqual(JSON.parse(await classes.WatchClass(ledger.student('w1'), 'A', 'w1')),
+        { success: true, classId: 'A', studentId: 'w1', watcherCount: 1 });
+    await classes.WatchClass(ledger.school(), 'A', 'w2');
+    await assert.rejects(classes.WatchClass(ledger.student('w1'), 'A', 'w1'), /Student w1 is already watching class A/);
+    await assert.rejects(classes.WatchClass(ledger.student('s1'), 'A', 's1'), /Student s1 is already enrolled in class A/);
+
+    const withdrawal = JSON.parse(await classes.WithdrawStudent(ledger.school(), 'A', 's1'));
+    assert.deepStrictEqual(withdrawal.notifiedWatchers, ['w1', 'w2']);
+    assert.deepStrictEqual(JSON.parse(await notifications.GetMyNotifications(ledger.student('w1'))).map((notification) => [notification.type, notification.data]),
+        [['SeatOpened', { classId: 'A', className: 'Maths' }]]);
+    await classes.EnrollStudent(ledger.student('w1'), 'A', 'w1');
+    assert.deepStrictEqual(ledger.get('A').watchers, ['w2']);
+
+    // Seule une hausse de capacité rouvre une place
+    await classes.PatchClass(ledger.school(), 'A', JSON.stringify({ description: 'Analyse' }));
+    assert.strictEqual(await notificationCount('w2'), 1);
+    await classes.PatchClass(ledger.school(), 'A', JSON.stringify({ maxStudents: 3 }));
+    assert.strictEqual(await notificationCount('w2'), 2);
+    assert.deepStrictEqual(ledger.lastEvent().payload.notifiedWatchers, ['w2']);
+    await classes.EnrollStudent(ledger.school(), 'A', 's3');
+    await classes.SetSeatCapacity(ledger.school(), 'A', 'lecture', '4');
+    assert.strictEqual(await notificationCount('w2'), 3);
+    await classes.WithdrawStudent(ledger.school(), 'A', 's3');
+    assert.strictEqual(await notificationCount('w2'), 3);
+
+    // Une place reprise aussitôt par la liste d'attente n'est pas annoncée
+    await classes.CreateClass(ledger.school(), 'B', 'Physique', 'Optique', '1');
+    await classes.EnrollStudent(ledger.school(), 'B', 's0');
+    await classes.WatchClass(ledger.student('watch'), 'B', 'watch');
+    await classes.JoinWaitlist(ledger.student('wl'), 'B', 'wl');
+    await classes.WithdrawStudent(ledger.school(), 'B', 's0');
+    assert.deepStrictEqual([ledger.lastEvent().payload.notifiedWatchers, ledger.lastEvent().payload.promoted], [[], ['wl']]);
+});
+
+test('GetCatalogFiltered filters classes by department, level, semester and tags', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const catalog = [
+        ['A', 'S1', { department: 'CS', level: 100, tags: ['intro', 'security'] }],
+        ['B', 'S1', { department: 'CS', level: 200, tags: ['security'] }],
+        ['C', 'S2', { department: 'MATH', level: 100 }],
+        ['D', 'S1', null],
+        ['E', 'S2', { department: 'CS', level: 100, tags: ['intro'] }],
+    ];
+    for (const [classId, semester, patch] of catalog) {
+        await classes.CreateClass(ledger.school(), classId, `Cours ${classId}`, 'Description', '5', semester);
+        if (patch) {
+            await classes.PatchClass(ledger.school(), classId, JSON.stringify(patch));
+        }
+    }
+    const filter = async (criteria) => JSON.parse(await classes.GetCatalogFiltered(ledger.student('x'), JSON.stringify(criteria)))
+        .map((entry) => entry.id);
+
+    // Mêmes résultats avec et sans index CouchDB
+    for (const couchdb of [true, false]) {
+        ledger.couchdb = couchdb;
+        assert.deepStrictEqual(await filter({ department: 'CS', level: 100 }), ['A', 'E']);
+        assert.deepStrictEqual(await filter({ department: 'CS' }), ['A', 'B', 'E']);
+        assert.deepStrictEqual(await filter({ level: 100 }), ['A', 'C', 'E']);
+        assert.deepStrictEqual(await filter({ tags: 'security' }), ['A', 'B']);
+        assert.deepStrictEqual(await filter({ tags: ['intro', 'security'] }), ['A']);
+        assert.deepStrictEqual(await filter({ department: 'CS', level: 100, semester: 'S2' }), ['E']);
+        assert.deepStrictEqual(await filter({ department: 'BIO' }), []);
+    }
+
+    await assert.rejects(classes.GetCatalogFiltered(ledger.student('x'), '{"teacher":"t"}'), /Invalid filter field: teacher/);
+    await assert.rejects(classes.GetCatalogFiltered(ledger.student('x'), '{"level":"100"}'), /Invalid level filter: must be a positive integer/);
+    await assert.rejects(classes.GetCatalogFiltered(ledger.student('x'), '{}'), /at least one criterion is required/);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'A', '{"tags":["a","a"]}'), /Invalid tags: tags must be distinct/);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'A', '{"level":0}'), /Invalid level: must be a positive integer/);
+
+    await classes.CloneClass(ledger.school(), 'A', 'A2', 'S3');
+    const clone = ledger.get('A2');
+    assert.deepStrictEqual([clone.department, clone.level, clone.tags], ['CS', 100, ['intro', 'security']]);
+});
+
+test('GetAllClasses keeps reading past pages of non-class records and only reports dropped classes as truncated', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    // 5001 enregistrements d'autres types triés avant la seule classe
+    for (let index = 0; index < 5001; index++) {
+        const id = `A${String(index).padStart(5, '0')}`;
+        ledger.put(id, { docType: 'exam', id });
+    }
+    ledger.put('ZC1', { docType: 'class', id: 'ZC1', name: 'Maths', description: '', enrolledStudents: [] });
+    ledger.put('ZC2', { docType: 'class', id: 'ZC2', name: 'Physique', description: '', enrolledStudents: [] });
+
+    const all = JSON.parse(await classes.GetAllClasses(ledger.school()));
+    assert.deepStrictEqual([all.classes.map((entry) => entry.id), all.truncated, all.bookmark, all.cancelled], [['ZC1', 'ZC2'], false, '', false]);
+    const first = JSON.parse(await classes.GetAllClassesPaginated(ledger.school(), '1', ''));
+    assert.deepStrictEqual([first.classes.map((entry) => entry.id), first.bookmark], [['ZC1'], 'ZC2']);
+    const next = JSON.parse(await classes.GetAllClassesPaginated(ledger.school(), '1', first.bookmark));
+    assert.deepStrictEqual([next.classes.map((entry) => entry.id), next.bookmark], [['ZC2'], '']);
+});
+
+test('a cancelled context stops the public class scan early with a bookmark to resume from', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    for (const classId of ['C1', 'C2', 'C3']) {
+        await classes.CreateClass(ledger.school(), classId, `Cours ${classId}`, 'Description');
+    }
+
+    const cancelledCtx = ledger.student('s1');
+    const controller = new AbortController();
+    controller.abort();
+    cancelledCtx.signal = controller.signal;
+    const cancelled = JSON.parse(await classes.GetAllClasses(cancelledCtx));
+    assert.deepStrictEqual([cancelled.count, cancelled.truncated, cancelled.bookmark, cancelled.cancelled], [0, true, 'C1', true]);
+
+    // Annulation en cours de lecture: les classes déjà lues sont renvoyées
+    const reads = [];
+    const partialCtx = ledger.student('s1');
+    partialCtx.signal = { get aborted() { reads.push(true); return reads.length > 2; } };
+    const partial = JSON.parse(await classes.GetAllClassesPaginated(partialCtx, '10', ''));
+    assert.deepStrictEqual([partial.classes.map((entry) => entry.id), partial.bookmark, partial.cancelled], [['C1', 'C2'], 'C3', true]);
+    const rest = JSON.parse(await classes.GetAllClassesPaginated(ledger.student('s1'), '10', partial.bookmark));
+    assert.deepStrictEqual([rest.classes.map((entry) => entry.id), rest.bookmark, rest.cancelled], [['C3'], '', false]);
+});
+
+test('TakeEnrollmentCensus freezes the roster effective at the census date', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '10');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    await classes.EnrollStudent(ledger.school(), 'A', 's2');
+    ledger.setTime('2026-01-20T10:00:00Z');
+    await classes.EnrollStudent(ledger.school(), 'A', 's3');
+    await classes.WithdrawStudent(ledger.school(), 'A', 's1');
+    ledger.setTime('2026-02-01T10:00:00Z');
+    await classes.EnrollStudentBackdated(ledger.admin(), 'A', 's4', '2026-01-12T00:00:00Z', 'paper form');
+
+    await assert.rejects(classes.TakeEnrollmentCensus(ledger.school(), 'A', '2026-01-15T00:00:00Z'), /Only admins can take an enrollment census/);
+    await assert.rejects(classes.TakeEnrollmentCensus(ledger.admin(), 'A', '2026-03-01T00:00:00Z'),
+        /Invalid censusDate: 2026-03-01T00:00:00.000Z is in the future/);
+
+    // s1, retiré après la date, y figure; s4, inscrit avec effet rétroactif, aussi
+    const census = JSON.parse(await classes.TakeEnrollmentCensus(ledger.admin(), 'A', '2026-01-15T00:00:00+01:00'));
+    assert.deepStrictEqual([census.censusDate, census.students.map((student) => student.studentId)],
+        ['2026-01-14T23:00:00.000Z', ['s1', 's2', 's4']]);
+    assert.deepStrictEqual(JSON.parse(await classes.TakeEnrollmentCensus(ledger.admin(), 'A', '2026-01-25T00:00:00Z')).students
+        .map((student) => student.studentId), ['s2', 's3', 's4']);
+    await assert.rejects(classes.TakeEnrollmentCensus(ledger.admin(), 'A', '2026-01-14T23:00:00Z'), /already exists and cannot be replaced/);
+
+    await classes.WithdrawStudent(ledger.school(), 'A', 's2');
+    assert.deepStrictEqual(JSON.parse(await classes.GetEnrollmentCensus(ledger.school(), 'A', '2026-01-15T00:00:00+01:00')).students
+        .map((student) => student.studentId), ['s1', 's2', 's4']);
+    await assert.rejects(classes.GetEnrollmentCensus(ledger.student('s2'), 'A', '2026-01-15T00:00:00Z'), /Only SchoolOrg members can view enrollment censuses/);
+    await assert.rejects(classes.GetEnrollmentCensus(ledger.school(), 'A', '2026-01-16T00:00:00Z'), /No enrollment census of class A/);
+});
+
+test('EnrollStudentsBatch is all-or-nothing by default and enrolls what it can in besteffort mode', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '3');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    const batch = JSON.stringify(['s2', 's1', 's3', 's2', 's4', 's5']);
+
+    await assert.rejects(classes.EnrollStudentsBatch(ledger.school(), 'A', batch, ''), /Student s1 is already enrolled in class A/);
+    await assert.rejects(classes.EnrollStudentsBatch(ledger.school(), 'A', batch, 'atomic'), /Student s1 is already enrolled in class A/);
+    await assert.rejects(classes.EnrollStudentsBatch(ledger.school(), 'A', batch, 'lenient'), /Invalid mode: lenient \(supported: atomic, besteffort\)/);
+    await assert.rejects(classes.EnrollStudentsBatch(ledger.school(), 'A', '["s2","s3","s4"]'), /Class A is full \(1\/3 students\)/);
+    assert.deepStrictEqual(ledger.get('A').enrolledStudents, ['s1']);
+
+    const result = JSON.parse(await classes.EnrollStudentsBatch(ledger.school(), 'A', batch, 'besteffort'));
+    assert.deepStrictEqual([result.enrolled, result.count], [['s2', 's3'], 2]);
+    assert.deepStrictEqual(result.failed, [
+        { studentId: 's1', error: 'Student s1 is already enrolled in class A' },
+        { studentId: 's2', error: 'Duplicate student s2 in batch' },
+        { studentId: 's4', error: 'Class A is full (3/3 students)' },
+        { studentId: 's5', error: 'Class A is full (3/3 students)' },
+    ]);
+    assert.deepStrictEqual(ledger.get('A').enrolledStudents, ['s1', 's2', 's3']);
+    assert.deepStrictEqual([ledger.lastEvent().name, ledger.lastEvent().payload.mode], ['StudentsBatchEnrolled', 'besteffort']);
+});
+
+test('EnrollStudentsBatch applies prerequisites and the enrollment window to each student', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await finalizedPrerequisite(ledger, 'a', '15');
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '5');
+    await classes.SetPrerequisites(ledger.school(), 'C1', '["P1"]');
+
+    await assert.rejects(classes.EnrollStudentsBatch(ledger.school(), 'C1', '["a","b"]', ''), /Student b has not completed the prerequisites of class C1: P1/);
+    const result = JSON.parse(await classes.EnrollStudentsBatch(ledger.school(), 'C1', '["a","b"]', 'besteffort'));
+    assert.deepStrictEqual([result.enrolled, result.failed.map((failure) => failure.studentId)], [['a'], ['b']]);
+
+    await classes.SetEnrollmentWindow(ledger.school(), 'C1', '', '2026-01-05T00:00:00Z');
+    const closed = JSON.parse(await classes.EnrollStudentsBatch(ledger.school(), 'C1', '["c"]', 'besteffort'));
+    assert.match(closed.failed[0].error, /closed/);
+});
+
+test('waitlist entries with the same timestamp are promoted by student ID, whatever the join order', async () => {
+    for (const order of [['zed', 'amy'], ['amy', 'zed']]) {
+        const ledger = new MemoryLedger();
+        const classes = new ClassContract();
+        await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '1');
+        await classes.EnrollStudent(ledger.school(), 'A', 's0');
+        ledger.setTime('2026-02-01T10:00:00Z');
+        await classes.JoinWaitlist(ledger.student('early'), 'A', 'early');
+        ledger.advance(60);
+        for (const studentId of order) {
+            await classes.JoinWaitlist(ledger.student(studentId), 'A', studentId);
+        }
+
+        const positions = [];
+        for (const studentId of ['early', 'amy', 'zed']) {
+            positions.push(JSON.parse(await classes.GetWaitlistPosition(ledger.school(), 'A', studentId)).position);
+        }
+        assert.deepStrictEqual(positions, [1, 2, 3]);
+        const promoted = [];
+        for (let index = 0; index < 3; index++) {
+            const [current] = ledger.get('A').enrolledStudents;
+            promoted.push(...JSON.parse(await classes.WithdrawStudentsBatch(ledger.school(), 'A', JSON.stringify([current]), 'moved')).promoted);
+        }
+        assert.deepStrictEqual(promoted, ['early', 'amy', 'zed']);
+    }
+});
+
+test('waitlist entries recorded without a timestamp keep their arrival order', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '1');
+    await classes.EnrollStudent(ledger.school(), 'A', 's0');
+    ledger.put('A', { ...ledger.get('A'), waitlist: ['zed', 'amy'] });
+
+    const result = JSON.parse(await classes.WithdrawStudentsBatch(ledger.school(), 'A', '["s0"]', 'moved'));
+    assert.deepStrictEqual(result.promoted, ['zed']);
+});
+
+test('PurgeExpiredData removes graded submissions and closed enrollments but keeps what grades still depend on', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const exams = new ExamContract();
+    const grades = new GradeContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '10');
+    for (const studentId of ['s1', 's2', 's3', 's4', 's5']) {
+        await classes.EnrollStudent(ledger.school(), 'A', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'A', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ durationMinutes: 60, gracePeriodMinutes: 60, latePenaltyPerHour: 2 }));
+    ledger.setTime('2026-02-01T10:30:00Z');
+    for (const studentId of ['s1', 's2']) {
+        await exams.SubmitExamCopy(ledger.student(studentId), 'E1', `Qm${studentId}`, '');
+    }
+    ledger.setTime('2026-02-01T11:30:00Z');
+    await exams.SubmitExamCopy(ledger.student('s3'), 'E1', 'Qms3', '');
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '12', '');
+    await grades.SubmitGrade(ledger.school(), 'G3', 'E1', 's3', '12', '');
+    await classes.WithdrawStudent(ledger.school(), 'A', 's5');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    ledger.setTime('2026-03-01T10:00:00Z');
+    await classes.WithdrawStudent(ledger.school(), 'A', 's4');
+    ledger.setTime('2026-09-01T10:00:00Z');
+
+    await assert.rejects(classes.PurgeExpiredData(ledger.admin(), '2027-01-01T00:00:00Z'), /Invalid beforeDate: 2027-01-01T00:00:00.000Z is in the future/);
+    await assert.rejects(classes.PurgeExpiredData(ledger.school(), '2026-02-10T00:00:00Z'), /Only admins can purge expired data/);
+
+    const finalGrade = await grades.ComputeFinalGrade(ledger.school(), 'A', 's3');
+    const purge = JSON.parse(await classes.PurgeExpiredData(ledger.admin(), '2026-02-10T00:00:00Z'));
+    // s2 n'est pas encore noté; la pénalité de retard de s3 se recalcule depuis sa copie
+    assert.deepStrictEqual(purge.purgedSubmissions, [ExamContract.submissionKey('E1', 's1')]);
+    assert.deepStrictEqual(Object.fromEntries(purge.retainedSubmissions.map((entry) => [entry.id, entry.reason])), {
+        [ExamContract.submissionKey('E1', 's2')]: 'ungraded',
+        [ExamContract.submissionKey('E1', 's3')]: 'late-penalty',
+    });
+    assert.deepStrictEqual(purge.purgedEnrollments, [ClassContract.enrollmentKey('A', 's5')]);
+    assert.strictEqual(await grades.ComputeFinalGrade(ledger.school(), 'A', 's3'), finalGrade);
+    assert.deepStrictEqual(['G1', ClassContract.enrollmentKey('A', 's4'), ClassContract.enrollmentKey('A', 's5')].map((key) => ledger.state.has(key)),
+        [true, true, false]);
+    const [audit] = JSON.parse(await new AuditContract().GetAuditTrail(ledger.school(), 'DATA_RETENTION'));
+    assert.deepStrictEqual(audit.details.purgedEnrollments, [ClassContract.enrollmentKey('A', 's5')]);
+
+    const again = JSON.parse(await classes.PurgeExpiredData(ledger.admin(), '2026-02-10T00:00:00Z'));
+    assert.deepStrictEqual([again.purgedSubmissions, again.purgedEnrollments], [[], []]);
+});
+
+test('PurgeExpiredData freezes the outcome of a withdrawn student before deleting the enrollment', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '5');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    await classes.WithdrawStudent(ledger.school(), 'A', 's1');
+    ledger.setTime('2027-02-14T10:00:00Z');
+
+    const purge = JSON.parse(await classes.PurgeExpiredData(ledger.admin(), '2027-02-13T10:00:00Z'));
+    assert.deepStrictEqual([purge.finalizedOutcomes, purge.purgedEnrollments], [[ClassContract.outcomeKey('A', 's1')], [ClassContract.enrollmentKey('A', 's1')]]);
+    assert.strictEqual(JSON.parse(await new GradeContract().GetStudentClassStatus(ledger.school(), 'A', 's1')).status, 'withdrawn');
+});
+
+test('AutoAssignSection enrolls in the first section with a seat, falls back down the list and waitlists last', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'C-A', 'Maths A', 'Algèbre', '1');
+    await classes.CreateClass(ledger.school(), 'C-B', 'Maths B', 'Algèbre', '2');
+    const preferences = '["C-A","C-B"]';
+
+    let assignment = JSON.parse(await classes.AutoAssignSection(ledger.student('s1'), 'C', 's1', preferences));
+    assert.deepStrictEqual([assignment.classId, assignment.status, assignment.preferenceRank, assignment.skipped], ['C-A', 'active', 1, []]);
+    assignment = JSON.parse(await classes.AutoAssignSection(ledger.student('s1'), 'C', 's1', preferences));
+    assert.deepStrictEqual([assignment.alreadyAssigned, assignment.classId], [true, 'C-A']);
+
+    assignment = JSON.parse(await classes.AutoAssignSection(ledger.school(), 'C', 's2', preferences));
+    assert.deepStrictEqual([assignment.classId, assignment.preferenceRank, assignment.skipped], ['C-B', 2, [{ classId: 'C-A', reason: 'full' }]]);
+    const enrollment = ledger.get(ClassContract.enrollmentKey('C-B', 's2'));
+    assert.deepStrictEqual([enrollment.courseId, enrollment.preferenceRank, enrollment.status], ['C', 2, 'active']);
+
+    // Toutes les sections pleines: liste d'attente du premier choix
+    await classes.AutoAssignSection(ledger.school(), 'C', 's3', preferences);
+    assignment = JSON.parse(await classes.AutoAssignSection(ledger.school(), 'C', 's4', preferences));
+    assert.deepStrictEqual([assignment.classId, assignment.status, assignment.position, assignment.skipped], ['C-A', 'waitlisted', 1, []]);
+    assert.strictEqual(ledger.lastEvent().name, 'StudentWaitlisted');
+
+    await assert.rejects(classes.AutoAssignSection(ledger.student('s5'), 'C', 's6', preferences), /Students can only enroll themselves/);
+    await assert.rejects(classes.AutoAssignSection(ledger.school(), 'C', 's6', '["C-A","C-A"]'), /Invalid preferencesJSON: duplicate class IDs/);
+    await assert.rejects(classes.AutoAssignSection(ledger.school(), 'C', 's6', '[]'), /must be a non-empty JSON array of class IDs/);
+    await assert.rejects(classes.AutoAssignSection(ledger.school(), 'C', 's6', '["C-Z"]'), /Class C-Z does not exist/);
+});
+
+test('AutoAssignSection skips sections whose prerequisites the student lacks', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    await classes.CreateClass(ledger.school(), 'P', 'Prérequis', 'Bases', '5');
+    await classes.CreateClass(ledger.school(), 'S-A', 'Section A', 'Algèbre', '5');
+    await classes.CreateClass(ledger.school(), 'S-B', 'Section B', 'Algèbre', '5');
+    await classes.CreateClass(ledger.school(), 'S-C', 'Section C', 'Algèbre', '1');
+    await classes.SetPrerequisites(ledger.school(), 'S-A', '["P"]');
+    await classes.EnrollStudent(ledger.school(), 'S-C', 'x');
+
+    const assignment = JSON.parse(await classes.AutoAssignSection(ledger.student('s1'), 'CRS', 's1', '["S-C","S-A","S-B"]'));
+    assert.deepStrictEqual([assignment.classId, assignment.preferenceRank], ['S-B', 3]);
+    assert.deepStrictEqual(assignment.skipped, [
+        { classId: 'S-A', reason: 'Student s1 has not completed the prerequisites of class S-A: P' },
+        { classId: 'S-C', reason: 'full' },
+    ]);
+});
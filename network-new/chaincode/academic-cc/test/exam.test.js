@@ -0,0 +1,468 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const { submissionKey } = ExamContract;
+const GradeContract = require('../lib/grade');
+const ConfigContract = require('../lib/config');
+const MaterialContract = require('../lib/material');
+const AcademicContract = require('../index').contracts[0];
+const { MemoryLedger } = require('./helpers/ledger');
+
+const DAY = 86400;
+
+test('UpdateExam and UpdateExamDate lock the questions and the date once the exam has started', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-12T10:00:00Z', 'QmExam', '0.4');
+
+    const updated = JSON.parse(await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ title: 'Final', examFileHash: 'QmExam2' })));
+    assert.strictEqual(updated.title, 'Final');
+    assert.strictEqual(updated.examFileHash, 'QmExam2');
+
+    ledger.advance(3 * DAY);
+    await assert.rejects(exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ examFileHash: 'QmExam3' })),
+        /Cannot update examFileHash: exam E1 started on 2026-01-12T10:00:00.000Z/);
+    await assert.rejects(exams.UpdateExamDate(ledger.school(), 'E1', '2026-03-01'), /Cannot update exam date: exam E1 started/);
+
+    // Les champs sans effet sur l'épreuve restent modifiables
+    const reweighted = JSON.parse(await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ weight: 0.5 })));
+    assert.strictEqual(reweighted.weight, 0.5);
+    assert.strictEqual(reweighted.examFileHash, 'QmExam2');
+});
+
+test('proctors are assigned per exam and see their schedule in date order', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await exams.CreateExam(ledger.school(), 'E2', 'C1', 'M1', 'Final', '2026-03-01T10:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.AssignProctor(ledger.school(), 'E2', 'p1');
+    await exams.AssignProctor(ledger.school(), 'E1', 'p1');
+    await exams.AssignProctor(ledger.school(), 'E1', 'p2');
+
+    const schedule = JSON.parse(await exams.GetProctorSchedule(ledger.school(), 'p1'));
+    assert.deepStrictEqual(schedule.map((exam) => exam.id), ['E1', 'E2']);
+
+    await exams.RemoveProctor(ledger.school(), 'E1', 'p1');
+    ledger.couchdb = false;
+    assert.deepStrictEqual(JSON.parse(await exams.GetProctorSchedule(ledger.school(), 'p1')).map((exam) => exam.id), ['E2']);
+    await assert.rejects(exams.AssignProctor(ledger.school(), 'NOPE', 'p1'), /Exam NOPE does not exist/);
+});
+
+test('late submissions within the grace period are accepted and penalised per started hour', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'bob');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-10T12:00:00Z', 'QmExam');
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ durationMinutes: 60, gracePeriodMinutes: 180, latePenaltyPerHour: 1.5 }));
+    await assert.rejects(exams.SubmitExamCopy(ledger.student('alice'), 'E1', 'QmA'), /Exam E1 has not started yet/);
+
+    ledger.setTime('2026-01-10T12:30:00Z');
+    await exams.SubmitExamCopy(ledger.student('bob'), 'E1', 'QmB');
+    ledger.setTime('2026-01-10T15:00:00Z');
+    const late = JSON.parse(await exams.SubmitExamCopy(ledger.student('alice'), 'E1', 'QmA'));
+    assert.strictEqual(late.late, true);
+    assert.strictEqual(late.hoursLate, 2);
+    await assert.rejects(exams.UpdateExam(ledger.school(), 'E1', '{"latePenaltyPerHour":3}'), /Cannot update latePenaltyPerHour/);
+
+    ledger.setTime('2026-01-13T16:00:00Z');
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '');
+    await grades.PublishGrade(ledger.school(), 'G2', 'E1', 'bob', '12', '');
+    const alice = JSON.parse(await grades.ComputeFinalGrade(ledger.school(), 'C1', 'alice'));
+    assert.strictEqual(alice.exams[0].latePenalty, 3);
+    assert.strictEqual(alice.exams[0].effectiveScore, 9);
+    assert.strictEqual(JSON.parse(await grades.ComputeFinalGrade(ledger.school(), 'C1', 'bob')).exams[0].effectiveScore, 12);
+});
+
+test('exam dates require a time zone and are compared as instants', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"gradeReleaseDelayHours":48}');
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'a');
+    // 10:30+02:00 précède 09:00Z bien que "10" > "09"
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-05T10:30:00+02:00', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'E2', 'C1', 'M1', 'Partiel', '2026-01-05T09:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'E3', 'C1', 'M1', 'Partiel', '2026-01-05T03:00:00-05:00', 'QmExam');
+    const exam = JSON.parse(await exams.GetExam(ledger.school(), 'E1'));
+    assert.strictEqual(exam.examDate, '2026-01-05T08:30:00.000Z');
+    assert.strictEqual(exam.examDateOffset, '+02:00');
+    await assert.rejects(exams.CreateExam(ledger.school(), 'E4', 'C1', 'M1', 'Partiel', '2026-01-05T10:30:00', 'QmExam'),
+        /Use ISO 8601 format with a time zone/);
+
+    for (const examId of ['E1', 'E2', 'E3']) {
+        await exams.AssignProctor(ledger.school(), examId, 'p1');
+    }
+    assert.deepStrictEqual(JSON.parse(await exams.GetProctorSchedule(ledger.school(), 'p1')).map((entry) => entry.examDate),
+        ['2026-01-05T08:00:00.000Z', '2026-01-05T08:30:00.000Z', '2026-01-05T09:00:00.000Z']);
+
+    ledger.setTime('2026-01-07T08:00:00Z');
+    await assert.rejects(new GradeContract().PublishGrade(ledger.school(), 'G1', 'E1', 'a', '12', ''),
+        /under embargo until 2026-01-07T08:30:00.000Z/);
+    ledger.setTime('2026-01-07T10:20:00+01:00');
+    await new GradeContract().PublishGrade(ledger.school(), 'G1', 'E1', 'a', '12', '');
+});
+
+test('an encrypted submission key is released to the class teachers after the deadline only', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-10T12:00:00Z', 'QmExam');
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ durationMinutes: 60, gracePeriodMinutes: 30 }));
+
+    ledger.setTime('2026-01-10T12:30:00Z');
+    const submission = JSON.parse(await exams.SubmitExamCopy(ledger.student('alice'), 'E1', 'QmA', 'KEY123'));
+    assert.strictEqual(submission.hasEncryptedKey, true);
+    assert.strictEqual(submission.encryptedKey, undefined);
+    const submissionId = submissionKey('E1', 'alice');
+    await assert.rejects(exams.GetSubmissionKey(ledger.school(), submissionId),
+        /Submission key locked until the submission deadline: 2026-01-10T13:30:00.000Z/);
+
+    ledger.setTime('2026-01-10T13:31:00Z');
+    await assert.rejects(exams.GetSubmissionKey(ledger.school('other'), submissionId), /Only the teachers of class C1/);
+    await assert.rejects(exams.GetSubmissionKey(ledger.student('alice'), submissionId), /Only teachers can retrieve submission keys/);
+    assert.strictEqual(JSON.parse(await exams.GetSubmissionKey(ledger.school(), submissionId)).encryptedKey, 'KEY123');
+});
+
+test('GetExamFile hides the questions from students until questionsAvailableAt', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 's1');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-20T10:00:00Z', 'QmExam');
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ durationMinutes: 120 }));
+    await assert.rejects(exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ questionsAvailableAt: '2026-01-20T13:00:00Z' })),
+        /is after the submission deadline 2026-01-20T12:00:00.000Z/);
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ questionsAvailableAt: '2026-01-20T09:00:00+00:00' }));
+
+    ledger.setTime('2026-01-20T08:30:00Z');
+    const hidden = JSON.parse(await exams.GetExamFile(ledger.student('s1'), 'E1'));
+    assert.strictEqual(hidden.questionsAvailable, false);
+    assert.strictEqual(hidden.examFileHash, null);
+    assert.strictEqual(JSON.parse(await exams.GetExamFile(ledger.school(), 'E1')).examFileHash, 'QmExam');
+
+    ledger.setTime('2026-01-20T09:00:00Z');
+    assert.strictEqual(JSON.parse(await exams.GetExamFile(ledger.student('s1'), 'E1')).examFileHash, 'QmExam');
+});
+
+test('exam weights must lie in (0, 1] and a class total above 1 raises a warning', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const weight of ['-0.2', '1.5', '0']) {
+        await assert.rejects(exams.CreateExam(ledger.school(), 'E0', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam', weight),
+            new RegExp(`Invalid weight: ${weight} must be greater than 0 and at most 1`));
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam', '0.6');
+    await exams.CreateExam(ledger.school(), 'E2', 'C1', 'M1', 'Final', '2026-02-01T10:00:00Z', 'QmExam', '0.4');
+    assert.strictEqual(ledger.lastEvent().payload.weightWarning, null);
+    await exams.CreateExam(ledger.school(), 'E3', 'C1', 'M1', 'Projet', '2026-02-01T10:00:00Z', 'QmExam', '0.3');
+    assert.strictEqual(ledger.lastEvent().payload.weightWarning, 'Exam weights of class C1 total 1.3, above 1');
+
+    ledger.couchdb = false;
+    await exams.UpdateExam(ledger.school(), 'E3', '{"weight":0.1}');
+    assert.strictEqual(ledger.lastEvent().payload.weightWarning, 'Exam weights of class C1 total 1.1, above 1');
+    await assert.rejects(exams.UpdateExam(ledger.school(), 'E3', '{"weight":-1}'), /Invalid weight: -1/);
+    await exams.UpdateExam(ledger.school(), 'E1', '{"weight":0.5}');
+    assert.strictEqual(ledger.lastEvent().payload.weightWarning, null);
+});
+
+test('SetExamAllowedMaterials accepts materials of the exam class only', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    const materials = new MaterialContract();
+    for (const classId of ['A', 'B']) {
+        await new ClassContract().CreateClass(ledger.school(), classId, classId, 'Cours');
+        await new ClassContract().AddModuleToClass(ledger.school(), classId, 'M1');
+    }
+    await new ClassContract().EnrollStudent(ledger.school(), 'A', 's1');
+    await materials.UploadCourseMaterial(ledger.school(), 'MA', 'A', 'M1', 'Formulas', 'COURS', 'QmA', '10');
+    await materials.UploadCourseMaterial(ledger.school(), 'MB', 'B', 'M1', 'Other', 'COURS', 'QmB', '10');
+    await exams.CreateExam(ledger.school(), 'E1', 'A', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+
+    await assert.rejects(exams.SetExamAllowedMaterials(ledger.school(), 'E1', '["MB"]'), /Material MB belongs to class B, not to class A of exam E1/);
+    await assert.rejects(exams.SetExamAllowedMaterials(ledger.school(), 'E1', '["nope"]'), /Material nope does not exist/);
+    await assert.rejects(exams.SetExamAllowedMaterials(ledger.student('s1'), 'E1', '["MA"]'), /Only teachers can set allowed materials/);
+    assert.deepStrictEqual(JSON.parse(await exams.SetExamAllowedMaterials(ledger.school(), 'E1', '["MA","MA"]')),
+        { examId: 'E1', allowedMaterials: ['MA'] });
+    assert.deepStrictEqual(JSON.parse(await exams.GetExamFile(ledger.student('s1'), 'E1')).allowedMaterials,
+        [{ id: 'MA', title: 'Formulas', type: 'COURS' }]);
+});
+
+test('proctors and class staff report incidents that SchoolOrg members can review', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 's1');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'E2', 'C1', 'M1', 'Final', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.AssignProctor(ledger.school(), 'E1', 'P1');
+
+    const incident = JSON.parse(await exams.ReportIncident(ledger.school('P1'), 'E1', 's1', 'Phone out during exam'));
+    assert.strictEqual(incident.reportedBy, 'P1');
+    assert.strictEqual(incident.classId, 'C1');
+    await exams.ReportIncident(ledger.school(), 'E2', '', 'Fire alarm');
+    await assert.rejects(exams.ReportIncident(ledger.school('P9'), 'E1', 's1', 'x'), /Only the proctors of exam E1 or the staff of class C1 can report incidents/);
+    await assert.rejects(exams.ReportIncident(ledger.school('P1'), 'NOPE', 's1', 'x'), /Exam NOPE does not exist/);
+    await assert.rejects(exams.ReportIncident(ledger.school('P1'), 'E1', 'zz', 'x'), /Student zz is not enrolled in class C1/);
+
+    assert.deepStrictEqual(JSON.parse(await exams.GetExamIncidents(ledger.school(), 'E1')).map((entry) => entry.description), ['Phone out during exam']);
+    assert.deepStrictEqual(JSON.parse(await exams.GetExamIncidents(ledger.school(), 'E2')).map((entry) => entry.description), ['Fire alarm']);
+    await assert.rejects(exams.GetExamIncidents(ledger.student('s1'), 'E1'), /Only SchoolOrg members can view exam incidents/);
+});
+
+test('GetSubmissionCount counts the submissions of active students for proctors and teachers', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+    await exams.AssignProctor(ledger.school(), 'E1', 'p@school.academic.edu');
+    const count = async (ctx) => {
+        const { submittedCount, enrolledCount, pendingCount } = JSON.parse(await exams.GetSubmissionCount(ctx, 'E1'));
+        return [submittedCount, enrolledCount, pendingCount];
+    };
+    assert.deepStrictEqual(await count(ledger.school('p@school.academic.edu')), [0, 3, 3]);
+
+    ledger.setTime('2026-01-01T11:00:00Z');
+    await exams.SubmitExamCopy(ledger.student('s1'), 'E1', 'QmS1');
+    await exams.SubmitExamCopy(ledger.student('s2'), 'E1', 'QmS2');
+    assert.deepStrictEqual(await count(ledger.school()), [2, 3, 1]);
+    await new ClassContract().WithdrawStudent(ledger.school(), 'C1', 's2');
+    assert.deepStrictEqual(await count(ledger.school()), [1, 2, 1]);
+
+    await assert.rejects(exams.GetSubmissionCount(ledger.school('x@school.academic.edu'), 'E1'), /Only the proctors of exam E1 or the staff of class C1/);
+    await assert.rejects(exams.GetSubmissionCount(ledger.student('s1'), 'E1'), /Only proctors and teachers can view submission counts/);
+});
+
+test('the grading scheme fixes the maxScore of an exam', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    const create = (examId, weight, maxScore, scheme) => exams.CreateExam(ledger.school(), examId, 'C1', 'M1', 'Partiel',
+        '2026-02-01T10:00:00Z', 'QmExam', weight, maxScore, scheme);
+    await assert.rejects(create('X', '', '', 'letters'), /Invalid gradingScheme: letters \(supported: points, percentage, passfail\)/);
+    await assert.rejects(create('X', '0.5', '', 'passfail'), /Invalid weight: pass\/fail exams do not count in the final grade/);
+    await assert.rejects(create('X', '', '20', 'percentage'), /Invalid maxScore: percentage exams are graded out of 100/);
+
+    await create('P', '', '', 'percentage');
+    await create('F', '', '', 'passfail');
+    assert.strictEqual(JSON.parse(await exams.GetExam(ledger.school(), 'P')).maxScore, 100);
+    const passFail = JSON.parse(await exams.GetExam(ledger.school(), 'F'));
+    assert.deepStrictEqual([passFail.gradingScheme, passFail.maxScore], ['passfail', null]);
+    await assert.rejects(exams.UpdateExam(ledger.school(), 'F', JSON.stringify({ maxScore: 10 })), /pass\/fail exams have no score/);
+    await assert.rejects(exams.UpdateExam(ledger.school(), 'P', JSON.stringify({ maxScore: 10 })), /percentage exams are graded out of 100/);
+});
+
+test('DetectExamConflicts reports overlapping exams of a student, including zero-length ones', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const exams = new ExamContract();
+    for (const classId of ['A', 'B', 'D']) {
+        await classes.CreateClass(ledger.school(), classId, `Maths ${classId}`, 'Algèbre', '5');
+    }
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    await classes.EnrollStudent(ledger.school(), 'B', 's1');
+    await classes.EnrollStudent(ledger.school(), 'D', 's2');
+    const schedule = [
+        ['E1', 'A', '2026-03-01T09:00:00Z', 120],
+        ['E2', 'B', '2026-03-01T10:00:00Z', 60],
+        ['E3', 'B', '2026-03-01T11:00:00Z', 60],
+        ['E4', 'A', '2026-03-02T09:00:00Z', 0],
+        ['E5', 'B', '2026-03-02T09:00:00Z', 0],
+        ['E6', 'A', '2026-03-03T09:00:00Z', 60],
+        ['E7', 'B', '2026-03-03T09:30:00Z', 0],
+        ['E8', 'D', '2026-03-01T09:30:00Z', 60],
+    ];
+    for (const [examId, classId, examDate, durationMinutes] of schedule) {
+        await exams.CreateExam(ledger.school(), examId, classId, 'M1', 'Partiel', examDate, 'QmExam');
+        if (durationMinutes) {
+            await exams.UpdateExam(ledger.school(), examId, JSON.stringify({ durationMinutes }));
+        }
+    }
+
+    // E3 commence quand E1 et E2 finissent: des créneaux adjacents ne sont pas en conflit
+    const conflicts = JSON.parse(await exams.DetectExamConflicts(ledger.student('s1'), 's1'));
+    assert.deepStrictEqual(conflicts.map((conflict) => [conflict.examA, conflict.examB, conflict.overlapStart, conflict.overlapEnd]), [
+        ['E1', 'E2', '2026-03-01T10:00:00.000Z', '2026-03-01T11:00:00.000Z'],
+        ['E4', 'E5', '2026-03-02T09:00:00.000Z', '2026-03-02T09:00:00.000Z'],
+        ['E6', 'E7', '2026-03-03T09:30:00.000Z', '2026-03-03T09:30:00.000Z'],
+    ]);
+    await assert.rejects(exams.DetectExamConflicts(ledger.student('s2'), 's1'), /Students can only check their own exam conflicts/);
+    assert.deepStrictEqual(JSON.parse(await exams.DetectExamConflicts(ledger.school(), 's2')), []);
+
+    ledger.couchdb = false;
+    assert.strictEqual(JSON.parse(await exams.DetectExamConflicts(ledger.school(), 's1')).length, 3);
+});
+
+test('a submission locks its content hash and VerifySubmissionIntegrity detects a swapped copy', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const exams = new ExamContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '10');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await classes.EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-10T09:00:00Z', 'QmExam');
+    const hash = 'AB'.repeat(32);
+
+    await assert.rejects(exams.SubmitExamCopy(ledger.student('s1'), 'E1', 'QmCopy1', '', 'xyz'),
+        /Invalid contentHash: must be a hex-encoded SHA-256 digest \(64 characters\)/);
+    const submission = JSON.parse(await exams.SubmitExamCopy(ledger.student('s1'), 'E1', 'QmCopy1', '', hash));
+    assert.deepStrictEqual([submission.lockedContentHash, submission.contentHashAlgorithm], [hash.toLowerCase(), 'sha256']);
+    await exams.SubmitExamCopy(ledger.student('s2'), 'E1', 'QmCopy2', '');
+
+    const first = submissionKey('E1', 's1');
+    assert.strictEqual(JSON.parse(await exams.VerifySubmissionIntegrity(ledger.school(), first, hash)).valid, true);
+    const swapped = JSON.parse(await exams.VerifySubmissionIntegrity(ledger.student('s1'), first, 'cd'.repeat(32)));
+    assert.deepStrictEqual([swapped.valid, swapped.lockedHash, swapped.actualHash], [false, 'ab'.repeat(32), 'cd'.repeat(32)]);
+    await assert.rejects(exams.VerifySubmissionIntegrity(ledger.student('s3'), first, hash), /You can only verify your own submissions/);
+
+    // Sans empreinte fournie, c'est le CID qui est verrouillé
+    const second = submissionKey('E1', 's2');
+    assert.strictEqual(JSON.parse(await exams.VerifySubmissionIntegrity(ledger.school(), second, 'QmCopy2')).valid, true);
+    assert.strictEqual(JSON.parse(await exams.VerifySubmissionIntegrity(ledger.school(), second, 'QmOther')).valid, false);
+    assert.strictEqual(JSON.parse(await exams.GetSubmission(ledger.school(), 'E1', 's2')).lockedContentHash, 'QmCopy2');
+});
+
+test('SetExamAccommodation extends the submission window of a single student until the exam starts', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const exams = new ExamContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '10');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await classes.EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ durationMinutes: 60, latePenaltyPerHour: 2 }));
+
+    await assert.rejects(exams.SetExamAccommodation(ledger.school(), 'E1', 's1', '5'), /Invalid multiplier: 5 must be between 1 and 3/);
+    await assert.rejects(exams.SetExamAccommodation(ledger.school(), 'E1', 'zz', '1.5'), /Student zz is not enrolled in class C1/);
+    await assert.rejects(exams.SetExamAccommodation(ledger.school('o@school.academic.edu'), 'E1', 's1', '1.5'),
+        /Only the teachers of class C1 can set accommodations for exam E1/);
+    assert.strictEqual(JSON.parse(await exams.SetExamAccommodation(ledger.school(), 'E1', 's1', '1.5')).submissionClosesAt,
+        '2026-02-01T11:30:00.000Z');
+    await exams.SetExamAccommodation(ledger.school(), 'E1', 's3', '2');
+    assert.strictEqual(JSON.parse(await exams.GetExams(ledger.student('s1'), 'C1')).exams[0].submissionClosesAt, '2026-02-01T11:30:00.000Z');
+    assert.strictEqual(JSON.parse(await exams.GetExams(ledger.student('s2'), 'C1')).exams[0].submissionClosesAt, '2026-02-01T11:00:00.000Z');
+
+    ledger.setTime('2026-02-01T11:20:00Z');
+    assert.strictEqual(JSON.parse(await exams.SubmitExamCopy(ledger.student('s1'), 'E1', 'QmCopy1', '')).hoursLate, 0);
+    await assert.rejects(exams.SubmitExamCopy(ledger.student('s2'), 'E1', 'QmCopy2', ''),
+        /Submission closed: copies for exam E1 were accepted until 2026-02-01T11:00:00.000Z/);
+    await assert.rejects(exams.SetExamAccommodation(ledger.school(), 'E1', 's3', '2'), /accommodations can no longer be changed/);
+
+    // s3 a encore du temps: pas encore absent
+    const noShows = JSON.parse(await new GradeContract().FinalizeExamNoShows(ledger.school(), 'E1'));
+    assert.deepStrictEqual([noShows.noShows.map((noShow) => noShow.studentId), noShows.stillOpen], [['s2'], ['s3']]);
+    assert.deepStrictEqual(Object.keys(ledger.get('E1').accommodations), ['s1', 's3']);
+});
+
+test('copies submitted within the grace period are flagged late and rejected past it', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const exams = new ExamContract();
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '10');
+    for (const studentId of ['s1', 's2', 's3', 's4']) {
+        await classes.EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ durationMinutes: 60, gracePeriodMinutes: 15 }));
+    const submit = async (studentId, at) => {
+        ledger.setTime(at);
+        return JSON.parse(await exams.SubmitExamCopy(ledger.student(studentId), 'E1', `Qm${studentId}`, ''));
+    };
+
+    let submission = await submit('s1', '2026-02-01T10:59:00Z');
+    assert.deepStrictEqual([submission.late, submission.lateBySeconds, submission.hoursLate], [false, 0, 0]);
+    // Rendue pile à l'échéance: à l'heure
+    submission = await submit('s2', '2026-02-01T11:00:00Z');
+    assert.deepStrictEqual([submission.late, submission.lateBySeconds], [false, 0]);
+    submission = await submit('s3', '2026-02-01T11:15:00Z');
+    assert.deepStrictEqual([submission.late, submission.lateBySeconds, submission.hoursLate], [true, 900, 0.25]);
+    assert.deepStrictEqual(ledger.lastEvent().payload,
+        { examId: 'E1', classId: 'C1', studentId: 's3', hoursLate: 0.25, late: true, lateBySeconds: 900 });
+    assert.strictEqual(JSON.parse(await exams.GetSubmission(ledger.school(), 'E1', 's3')).lateBySeconds, 900);
+
+    ledger.setTime('2026-02-01T11:15:01Z');
+    await assert.rejects(exams.SubmitExamCopy(ledger.student('s4'), 'E1', 'Qms4', ''),
+        /Submission closed: copies for exam E1 were accepted until 2026-02-01T11:15:00.000Z/);
+    await assert.rejects(exams.GetSubmission(ledger.school(), 'E1', 's4'), /No submission from s4 for exam E1/);
+});
+
+test('TAs see the correction only once it is released to students, unlike teachers and co-teachers', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const exams = new ExamContract();
+    ledger.setTime('2026-02-01T08:00:00Z');
+    await classes.CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '10');
+    await classes.AddClassStaff(ledger.school(), 'C1', 'ta@school.academic.edu', 'ta');
+    await classes.AddClassStaff(ledger.school(), 'C1', 'co@school.academic.edu', 'co-teacher');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T09:00:00Z', 'QmExam');
+    // Examen passé d'une heure (horodatage de la transaction)
+    ledger.setTime('2026-02-01T10:00:00Z');
+    await exams.UploadCorrection(ledger.school(), 'E1', 'QmCorrection');
+    const ta = ledger.school('ta@school.academic.edu');
+    const coTeacher = ledger.school('co@school.academic.edu');
+
+    // Correction diffusée 48h après l'examen
+    const exam = JSON.parse(await exams.GetExam(ta, 'E1'));
+    assert.deepStrictEqual([exam.correctionFileHash, exam.correctionAvailable, exam.examFileHash], [null, false, 'QmExam']);
+    assert.strictEqual(JSON.parse(await exams.GetExams(ta, 'C1')).exams[0].correctionAvailable, false);
+    assert.strictEqual(JSON.parse(await exams.GetExams(ta, 'C1')).exams[0].correctionFileHash, undefined);
+    await assert.rejects(exams.GetCorrectionFile(ta, 'E1'), /Correction available in 47 hours \(48h after exam date\)/);
+    assert.strictEqual(JSON.parse(await exams.GetExamFile(ta, 'E1')).examFileHash, 'QmExam');
+    assert.strictEqual(JSON.parse(await exams.GetExam(coTeacher, 'E1')).correctionFileHash, 'QmCorrection');
+    assert.strictEqual(JSON.parse(await exams.GetExams(coTeacher, 'C1')).exams[0].correctionFileHash, 'QmCorrection');
+    assert.strictEqual(JSON.parse(await exams.GetCorrectionFile(coTeacher, 'E1')).correctionFileHash, 'QmCorrection');
+    assert.strictEqual(JSON.parse(await exams.GetExam(ledger.school(), 'E1')).correctionFileHash, 'QmCorrection');
+
+    ledger.advance(3 * DAY);
+    assert.strictEqual(JSON.parse(await exams.GetExam(ta, 'E1')).correctionFileHash, 'QmCorrection');
+    assert.strictEqual(JSON.parse(await exams.GetExams(ta, 'C1')).exams[0].correctionAvailable, true);
+    assert.strictEqual(JSON.parse(await exams.GetCorrectionFile(ta, 'E1')).correctionFileHash, 'QmCorrection');
+});
+
+test('the legacy exam queries hide an unreleased correction from students', async () => {
+    const ledger = new MemoryLedger();
+    const legacy = new AcademicContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '5');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-10T11:00:00Z', 'QmExam');
+    ledger.setTime('2026-01-10T12:00:00Z');
+    await new ExamContract().UploadCorrection(ledger.school(), 'E1', 'QmCorrection');
+
+    const views = [
+        JSON.parse(await legacy.GetExam(ledger.student('s1'), 'E1')),
+        JSON.parse(await legacy.GetAllExams(ledger.student('s1'))).exams[0],
+        JSON.parse(await legacy.GetClassExams(ledger.student('s1'), 'C1'))[0],
+    ];
+    assert.deepStrictEqual(views.map((view) => view.correctionFileHash), [null, null, null]);
+});
+
+test('GetExams returns at most 500 exams of the class and flags the truncation', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (let i = 0; i < 501; i++) {
+        const id = `E${String(i).padStart(4, '0')}`;
+        ledger.put(id, { docType: 'exam', id: id, classId: 'C1', title: id, examDate: '2026-01-01T10:00:00.000Z', correctionFileHash: null });
+    }
+    ledger.put('EX-OTHER', { docType: 'exam', id: 'EX-OTHER', classId: 'C2', examDate: '2026-01-01T10:00:00.000Z' });
+
+    const capped = JSON.parse(await exams.GetExams(ledger.school(), 'C1'));
+    assert.deepStrictEqual([capped.count, capped.truncated, capped.exams[499].id], [500, true, 'E0499']);
+    ledger.state.delete('E0000');
+    const complete = JSON.parse(await exams.GetExams(ledger.school(), 'C1'));
+    assert.deepStrictEqual([complete.count, complete.truncated], [500, false]);
+});
@@ -0,0 +1,1014 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+const crypto = require('node:crypto');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const RoleContract = require('../lib/role');
+const ConfigContract = require('../lib/config');
+const AuditContract = require('../lib/audit');
+const AcademicContract = require('../index').contracts[0];
+const { canonicalStringify } = require('../lib/records');
+const { generateDeterministicID } = require('../lib/ids');
+const { hashGradeLink } = require('../lib/gradechain');
+const { MemoryLedger } = require('./helpers/ledger');
+
+/**
+ * Classe C1 (inscrits: students) et examen E1 passé (1er janvier, barème sur 20)
+ */
+async function classWithExam(ledger, students) {
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of students || ['alice']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+}
+
+test('ConvertGrade converts a grade to the known scales', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger);
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '15', 'Bien');
+
+    const percent = JSON.parse(await grades.ConvertGrade(ledger.student('alice'), 'G1', 'percent'));
+    assert.strictEqual(percent.convertedScore, 75);
+    assert.strictEqual(percent.convertedMax, 100);
+
+    const gpa = JSON.parse(await grades.ConvertGrade(ledger.student('alice'), 'G1', 'gpa4'));
+    assert.strictEqual(gpa.convertedScore, 3.5);
+    assert.strictEqual(gpa.convertedMax, 4);
+
+    await assert.rejects(grades.ConvertGrade(ledger.student('alice'), 'G1', 'x'), /Invalid targetScale: x \(known scales: fr20, percent, gpa4\)/);
+});
+
+test('the release embargo is off by default and, when configured, only an admin can override it with a reason', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'bob');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-10T08:00:00Z', 'QmExam');
+    await new ExamContract().CreateExam(ledger.school(), 'E0', 'C1', 'M1', 'Quiz', '2026-01-10T08:00:00Z', 'QmExam');
+
+    // Sans gradeReleaseDelayHours: publication possible dès examDate
+    await grades.PublishGrade(ledger.school(), 'G0', 'E0', 'alice', '10', '');
+
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"gradeReleaseDelayHours":48}');
+    await assert.rejects(grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '15', ''),
+        /Grades for exam E1 are under embargo until 2026-01-12T08:00:00.000Z/);
+    await assert.rejects(grades.PublishGrade(ledger.admin(), 'G1', 'E1', 'alice', '15', '', 'true', ' '), /Missing reason/);
+    await assert.rejects(grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '15', '', 'true', 'urgent'),
+        /Only an admin can override the grade release embargo/);
+    await grades.PublishGrade(ledger.admin(), 'G1', 'E1', 'alice', '15', '', 'true', 'early release approved');
+
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 'bob', '12', '');
+    assert.deepStrictEqual(JSON.parse(await grades.GetMyGrades(ledger.student('bob'), 'C1')), []);
+    await assert.rejects(grades.PublishExamGrades(ledger.school('x@school.academic.edu'), 'E1', 'true', 'dean approval'),
+        /Only the teachers of class C1 or an admin can manage grades for exam E1/);
+    const batch = JSON.parse(await grades.PublishExamGrades(ledger.admin(), 'E1', 'true', 'dean approval'));
+    assert.deepStrictEqual(batch.published, ['G2']);
+    assert.deepStrictEqual(JSON.parse(await grades.GetMyGrades(ledger.student('bob'), 'C1')).map((grade) => grade.id), ['G2']);
+
+    const audits = new AuditContract();
+    const [gradeOverride] = JSON.parse(await audits.GetAuditTrail(ledger.school(), 'G1'));
+    assert.strictEqual(gradeOverride.action, 'GradeReleaseOverride');
+    assert.strictEqual(gradeOverride.reason, 'early release approved');
+    const [examOverride] = JSON.parse(await audits.GetAuditTrail(ledger.school(), 'E1'));
+    assert.strictEqual(examOverride.reason, 'dean approval');
+});
+
+test('GetStudentSemesterGPA averages the published final grades of a semester by credit hours', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    for (const [classId, semester] of [['A', 'S1'], ['B', 'S1'], ['X', 'S2']]) {
+        await new ClassContract().CreateClass(ledger.school(), classId, classId, 'Cours', '', semester);
+        await new ClassContract().EnrollStudent(ledger.school(), classId, 'alice');
+    }
+    await exams.CreateExam(ledger.school(), 'EA1', 'A', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam', '0.5');
+    await exams.CreateExam(ledger.school(), 'EA2', 'A', 'M1', 'Final', '2026-01-01T10:00:00Z', 'QmExam', '0.5');
+    await exams.CreateExam(ledger.school(), 'EB1', 'B', 'M1', 'Final', '2026-01-01T10:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'EX1', 'X', 'M1', 'Final', '2026-01-01T10:00:00Z', 'QmExam');
+    await grades.PublishGrade(ledger.school(), 'G1', 'EA1', 'alice', '16', '');
+    await grades.PublishGrade(ledger.school(), 'G2', 'EA2', 'alice', '12', '');
+    await grades.PublishGrade(ledger.school(), 'G3', 'EB1', 'alice', '10', '');
+    await grades.SubmitGrade(ledger.school(), 'G4', 'EX1', 'alice', '20', '');
+
+    const s1 = JSON.parse(await grades.GetStudentSemesterGPA(ledger.student('alice'), 'alice', 'S1'));
+    assert.strictEqual(s1.gpa, 2);
+    assert.strictEqual(s1.totalCreditHours, 6);
+    assert.deepStrictEqual(s1.classes.map((entry) => [entry.classId, entry.letterGrade]), [['A', 'B'], ['B', 'D']]);
+
+    // Les notes non publiées ne comptent pas
+    const draft = JSON.parse(await grades.GetStudentSemesterGPA(ledger.student('alice'), 'alice', 'S2'));
+    assert.strictEqual(draft.gpa, null);
+    assert.deepStrictEqual(draft.classes, []);
+    await grades.PublishExamGrades(ledger.school(), 'EX1');
+    assert.strictEqual(JSON.parse(await grades.GetStudentSemesterGPA(ledger.student('alice'), 'alice', 'S2')).gpa, 4);
+});
+
+test('VerifyExamGradeChain detects edited, relinked and missing grades', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['alice', 'bob', 'carl']);
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 'bob', '14', '');
+    await grades.SubmitGrade(ledger.school(), 'G3', 'E1', 'carl', '9', '');
+    await grades.UpdateGrade(ledger.school(), 'G1', '13', 'fix');
+    await grades.DeleteGrade(ledger.school(), 'G3', 'wrong student');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    assert.deepStrictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')),
+        { examId: 'E1', valid: true, length: 5, breaks: [] });
+
+    ledger.put('G2', Object.assign(ledger.get('G2'), { score: 20 }));
+    const edited = JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1'));
+    assert.deepStrictEqual(edited.breaks, [{ index: 1, gradeId: 'G2', reason: 'Grade content does not match its chain link' }]);
+
+    ledger.state.delete('G1');
+    ledger.put('GRADELINK_E1_1', Object.assign(ledger.get('GRADELINK_E1_1'), { contentHash: 'x' }));
+    const broken = JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1'));
+    assert.strictEqual(broken.valid, false);
+    assert.deepStrictEqual(broken.breaks.map((entry) => entry.reason), [
+        'Link hash mismatch',
+        'Grade content does not match its chain link',
+        'Chained grade is missing from the ledger',
+    ]);
+});
+
+test('VerifyExamGradeChain covers pass/fail results and maximum scores, and still accepts older links', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['alice', 'bob', 'carl']);
+    await new ExamContract().CreateExam(ledger.school(), 'P1', 'C1', 'M1', 'Oral', '2026-01-01T10:00:00Z', 'QmExam', '', '', 'passfail');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'P1', 'alice', 'fail', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 'bob', '14', '');
+    assert.strictEqual(ledger.get('GRADELINK_P1_0').contentVersion, 2);
+
+    ledger.put('G1', Object.assign(ledger.get('G1'), { result: 'pass' }));
+    assert.deepStrictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'P1')).breaks,
+        [{ index: 0, gradeId: 'G1', reason: 'Grade content does not match its chain link' }]);
+    ledger.put('G2', Object.assign(ledger.get('G2'), { maxScore: 40 }));
+    assert.deepStrictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')).breaks,
+        [{ index: 0, gradeId: 'G2', reason: 'Grade content does not match its chain link' }]);
+
+    // Maillon écrit avant la version 2: seuls les champs de la version 1 sont vérifiés
+    await grades.SubmitGrade(ledger.school(), 'G3', 'E1', 'carl', '9', '');
+    const link = ledger.get('GRADELINK_E1_1');
+    delete link.contentVersion;
+    link.contentHash = crypto.createHash('sha256').update(JSON.stringify(
+        ['G3', 'E1', 'C1', 'carl', 9, 'scored', '', 'teacher1@school.academic.edu', ledger.get('G3').submittedAt])).digest('hex');
+    link.hash = hashGradeLink(link);
+    ledger.put('GRADELINK_E1_1', link);
+    ledger.put('G3', Object.assign(ledger.get('G3'), { hash: link.hash }));
+    ledger.put('GRADECHAIN_E1', Object.assign(ledger.get('GRADECHAIN_E1'), { lastHash: link.hash }));
+    assert.deepStrictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')).breaks.map((entry) => entry.gradeId), ['G2']);
+});
+
+test('GetExamStatistics reports absences separately and with absences counted as zero', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['a', 'b', 'c', 'd']);
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'a', '12', '');
+    await grades.PublishGrade(ledger.school(), 'G2', 'E1', 'b', '0', '');
+    await grades.PublishGrade(ledger.school(), 'G3', 'E1', 'c', '18', '');
+    await grades.MarkAbsent(ledger.school(), 'G4', 'E1', 'd', 'no show');
+
+    const stats = JSON.parse(await grades.GetExamStatistics(ledger.school(), 'E1'));
+    assert.strictEqual(stats.scoredCount, 3);
+    assert.strictEqual(stats.absentCount, 1);
+    assert.strictEqual(stats.participationRate, 75);
+    assert.deepStrictEqual(stats.withoutAbsences, { count: 3, mean: 10, median: 12, min: 0, max: 18, stdDev: 7.48 });
+    assert.deepStrictEqual(stats.absencesAsZero, { count: 4, mean: 7.5, median: 6, min: 0, max: 18, stdDev: 7.79 });
+
+    // Une absence corrigée en note devient un résultat ordinaire
+    await grades.UpdateGrade(ledger.school(), 'G4', '10', 'late exam');
+    const updated = JSON.parse(await grades.GetExamStatistics(ledger.school(), 'E1'));
+    assert.strictEqual(updated.absentCount, 0);
+    assert.strictEqual(updated.withoutAbsences.median, 11);
+    assert.strictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')).valid, true);
+});
+
+test('PublishExamsBatch publishes several exams at once and checks every class', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre');
+    await new ClassContract().CreateClass(ledger.school('other'), 'B', 'Physique', 'Mécanique');
+    await new ClassContract().EnrollStudent(ledger.school(), 'A', 'a');
+    await exams.CreateExam(ledger.school(), 'E1', 'A', 'M1', 'Partiel', '2026-01-01T09:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'E2', 'A', 'M1', 'Final', '2026-01-09T09:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'E3', 'B', 'M1', 'Final', '2026-01-01T09:00:00Z', 'QmExam');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 'a', '12', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E2', 'a', '12', '');
+
+    const result = JSON.parse(await grades.PublishExamsBatch(ledger.school(), '["E1","E2"]'));
+    assert.deepStrictEqual(result.published, [{ examId: 'E1', count: 1 }, { examId: 'E2', count: 1 }]);
+    assert.strictEqual(result.count, 2);
+    assert.strictEqual(ledger.lastEvent().name, 'ExamsBatchPublished');
+
+    await assert.rejects(grades.PublishExamsBatch(ledger.school(), '["E1","E3"]'),
+        /Only the teachers of class B or an admin can manage grades for exam E3/);
+    await assert.rejects(grades.PublishExamsBatch(ledger.school(), '["E1","E1"]'), /duplicate exam IDs/);
+    assert.strictEqual(JSON.parse(await grades.PublishExamsBatch(ledger.admin(), '["E3"]')).count, 0);
+});
+
+test('students acknowledge their own published grades once', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['a', 'b', 'c']);
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'a', '12', '');
+    await grades.PublishGrade(ledger.school(), 'G2', 'E1', 'b', '12', '');
+    await grades.SubmitGrade(ledger.school(), 'G3', 'E1', 'c', '12', '');
+
+    await assert.rejects(grades.AcknowledgeGrade(ledger.school(), 'G1'), /Only students can acknowledge grades/);
+    await assert.rejects(grades.AcknowledgeGrade(ledger.student('b'), 'G1'), /You can only acknowledge your own grades/);
+    await assert.rejects(grades.AcknowledgeGrade(ledger.student('c'), 'G3'), /Grade not yet published/);
+    assert.deepStrictEqual(JSON.parse(await grades.AcknowledgeGrade(ledger.student('a'), 'G1')),
+        { gradeId: 'G1', acknowledged: true, acknowledgedAt: '2026-01-10T10:00:00.000Z' });
+    await assert.rejects(grades.AcknowledgeGrade(ledger.student('a'), 'G1'), /Grade G1 was already acknowledged/);
+
+    const pending = JSON.parse(await grades.GetUnacknowledgedGrades(ledger.school(), 'E1'));
+    assert.strictEqual(pending.publishedCount, 2);
+    assert.deepStrictEqual(pending.unacknowledged.map((entry) => entry.gradeId), ['G2']);
+    // L'accusé de réception ne modifie pas la chaîne des notes
+    assert.strictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')).valid, true);
+});
+
+test('DeleteGrade removes unpublished grades only, with an audited reason', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['a', 'b']);
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'a', '12', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 'b', '14', '');
+
+    await assert.rejects(grades.DeleteGrade(ledger.school(), 'G1', 'oops'), /Cannot delete grade G1: it has been published/);
+    await assert.rejects(grades.DeleteGrade(ledger.school(), 'G2', ' '), /Missing reason/);
+    await assert.rejects(grades.DeleteGrade(ledger.school('other'), 'G2', 'oops'), /Only the teachers of class C1 or an admin/);
+    await grades.DeleteGrade(ledger.school(), 'G2', 'entered twice');
+    assert.strictEqual(ledger.get('G2'), null);
+
+    const [entry] = JSON.parse(await new AuditContract().GetAuditTrail(ledger.school(), 'G2'));
+    assert.strictEqual(entry.action, 'GradeDeleted');
+    assert.strictEqual(entry.reason, 'entered twice');
+    assert.deepStrictEqual(entry.details, { examId: 'E1', score: 14, studentId: 'b' });
+    assert.strictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')).valid, true);
+});
+
+test('ValidateClassWeights checks that the exam weights of a class sum to 1', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre');
+    await new ClassContract().CreateClass(ledger.school(), 'B', 'Physique', 'Mécanique');
+    for (const [examId, classId, weight] of [['E1', 'A', '0.3'], ['E2', 'A', '0.3'], ['E3', 'A', '0.4'], ['F1', 'B', '0.5'], ['F2', 'B', '0.4']]) {
+        await exams.CreateExam(ledger.school(), examId, classId, 'M1', examId, '2026-01-01T09:00:00Z', 'QmExam', weight);
+    }
+
+    // 0.3 + 0.3 + 0.4 n'est pas exactement 1 en flottant
+    const valid = JSON.parse(await grades.ValidateClassWeights(ledger.school(), 'A'));
+    assert.strictEqual(valid.sum, 1);
+    assert.strictEqual(valid.isValid, true);
+    const invalid = JSON.parse(await grades.ValidateClassWeights(ledger.school(), 'B'));
+    assert.strictEqual(invalid.sum, 0.9);
+    assert.strictEqual(invalid.isValid, false);
+    assert.deepStrictEqual(invalid.missingWeights, []);
+});
+
+test('scores are bounded by the maxScore of their exam and statistics normalise older scales', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 's1');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 's2');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam', '', '100');
+
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '120', ''), /Invalid score: 120 exceeds the maxScore 100 of exam E1/);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '80', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '60', '');
+    assert.strictEqual(ledger.get('G1').maxScore, 100);
+    await assert.rejects(grades.UpdateGrade(ledger.school(), 'G1', '101', ''), /exceeds the maxScore 100/);
+
+    // Note antérieure saisie sur 20: 10/20 compte pour 50/100
+    ledger.put('G2', Object.assign(ledger.get('G2'), { score: 10, maxScore: 20 }));
+    const stats = JSON.parse(await grades.GetExamStatistics(ledger.school(), 'E1'));
+    assert.strictEqual(stats.maxScore, 100);
+    assert.deepStrictEqual(stats.withoutAbsences, { count: 2, mean: 65, median: 65, min: 50, max: 80, stdDev: 15 });
+});
+
+test('GetExamGradingProgress rebuilds the grade count over time from the ledger history', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2', 's3']);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '12', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '12', '');
+    ledger.advance(3600);
+    await grades.UpdateGrade(ledger.school(), 'G1', '13', '');
+    await grades.SubmitGrade(ledger.school(), 'G3', 'E1', 's3', '12', '');
+    ledger.advance(3600);
+    await grades.DeleteGrade(ledger.school(), 'G3', 'erreur');
+
+    const progress = JSON.parse(await grades.GetExamGradingProgress(ledger.school(), 'E1'));
+    assert.strictEqual(progress.gradeCount, 2);
+    assert.deepStrictEqual(progress.series.map((point) => [point.timestamp, point.gradeCount, point.delta]), [
+        ['2026-01-10T10:00:00.000Z', 2, 2],
+        ['2026-01-10T11:00:00.000Z', 3, 1],
+        ['2026-01-10T12:00:00.000Z', 2, -1],
+    ]);
+});
+
+test('ImportExamGradesCSV imports valid rows and reports every invalid one by line', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2', 's3']);
+    const csv = 'studentId,score,maxScore\ns1,12,20\r\n\ns2,25,20\ns9,10,20\ns3,x,20\ns1,3,20\ns3,8,100\ns3,8\ns3,14.5,20\n';
+
+    const result = JSON.parse(await grades.ImportExamGradesCSV(ledger.school(), 'E1', csv));
+    assert.strictEqual(result.imported, 2);
+    assert.strictEqual(result.failed, 6);
+    assert.deepStrictEqual(result.results.map((row) => [row.line, row.status, row.error]), [
+        [2, 'imported', null],
+        [4, 'error', 'Line 4: Invalid score: 25 exceeds the maxScore 20 of exam E1'],
+        [5, 'error', 'Line 5: student s9 is not enrolled in class C1'],
+        [6, 'error', 'Line 6: invalid score "x"'],
+        [7, 'error', 'Line 7: duplicate row for student s1'],
+        [8, 'error', 'Line 8: maxScore 100 does not match the maxScore 20 of exam E1'],
+        [9, 'error', 'Line 9: expected 3 columns (studentId,score,maxScore), got 2'],
+        [10, 'imported', null],
+    ]);
+    assert.strictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')).length, 2);
+
+    await assert.rejects(grades.ImportExamGradesCSV(ledger.school('teacher2@school.academic.edu'), 'E1', 's1,1,20'), /Access Denied/);
+    const again = JSON.parse(await grades.ImportExamGradesCSV(ledger.school(), 'E1', 's1,1,20'));
+    assert.match(again.results[0].error, /Line 1: student s1 already has grade GRADE_\w+ for exam E1/);
+});
+
+test('GetGradeByExamStudent picks the latest non-voided grade among older duplicates', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger);
+    const duplicate = (id, fields) => ledger.put(id, Object.assign({ docType: 'grade', id: id, examId: 'E1', studentId: 'alice', classId: 'C1' }, fields));
+    duplicate('z-old', { score: 5, submittedAt: '2026-01-01T00:00:00.000Z' });
+    duplicate('a-new', { score: 9, submittedAt: '2026-01-05T00:00:00.000Z' });
+    duplicate('b-void', { score: 1, submittedAt: '2026-01-08T00:00:00.000Z', voided: true });
+
+    assert.strictEqual(JSON.parse(await grades.GetGradeByExamStudent(ledger.school(), 'E1', 'alice')).id, 'a-new');
+    ledger.couchdb = false;
+    assert.strictEqual(JSON.parse(await grades.GetGradeByExamStudent(ledger.school(), 'E1', 'alice')).id, 'a-new');
+});
+
+test('classes requiring release approval publish through a request approved by someone else', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2']);
+    await assert.rejects(new ClassContract().SetReleaseApprovalRequired(ledger.school(), 'C1', 'true'),
+        /Only admins can change the grade release approval policy/);
+    await new ClassContract().SetReleaseApprovalRequired(ledger.admin(), 'C1', 'true');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '12', '');
+
+    await assert.rejects(grades.PublishExamGrades(ledger.school(), 'E1'), /require release approval: use RequestGradeRelease for exam E1/);
+    await assert.rejects(grades.PublishGrade(ledger.school(), 'G2', 'E1', 's2', '12', ''), /require release approval/);
+    await assert.rejects(grades.ApproveGradeRelease(ledger.admin(), 'E1'), /No pending grade release for exam E1/);
+
+    assert.strictEqual(JSON.parse(await grades.RequestGradeRelease(ledger.school(), 'E1')).status, 'pending');
+    await assert.rejects(grades.RequestGradeRelease(ledger.school(), 'E1'), /already pending/);
+    await assert.rejects(grades.ApproveGradeRelease(ledger.school(), 'E1'), /Only an admin or a department head can approve/);
+    await new RoleContract().GrantRole(ledger.admin(), 'teacher1@school.academic.edu', 'department-head');
+    await assert.rejects(grades.ApproveGradeRelease(ledger.school(), 'E1'), /Self-approval is not allowed/);
+
+    const approved = JSON.parse(await grades.ApproveGradeRelease(ledger.admin(), 'E1'));
+    assert.deepStrictEqual(approved.published, ['G1']);
+    assert.strictEqual(ledger.get('G1').isPublished, true);
+    assert.strictEqual(ledger.get('RELEASE_E1').status, 'approved');
+});
+
+test('rubric criteria must add up to the score and are kept only while the total matches', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 's1');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Projet', '2026-02-01T10:00:00Z', 'QmExam');
+    await assert.rejects(exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ rubric: [{ id: 'c1', maxPoints: 12 }, { id: 'c2', maxPoints: 6 }] })),
+        /Invalid rubric: criteria total 18 points, expected the exam maxScore 20/);
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({
+        rubric: [{ id: 'c1', label: 'Analyse', maxPoints: 12 }, { id: 'c2', label: 'Code', maxPoints: 8 }],
+    }));
+
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '', '{"c1":13,"c2":2}'), /criterion c1: 13 is outside 0-12/);
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '', '{"c1":10,"c2":4}'), /criteria total 14, expected the score 15/);
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '', '{"c1":10}'), /missing score for criterion c2/);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15.5', '', '{"c1":10,"c2":5.5}');
+
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    assert.deepStrictEqual(JSON.parse(await grades.GetGrade(ledger.student('s1'), 'G1')).criteria, [
+        { id: 'c1', label: 'Analyse', maxPoints: 12, points: 10 },
+        { id: 'c2', label: 'Code', maxPoints: 8, points: 5.5 },
+    ]);
+    // Une note modifiée sans détail ne garde pas un détail devenu faux
+    await grades.UpdateGrade(ledger.school(), 'G1', '16', '');
+    assert.strictEqual(JSON.parse(await grades.GetGrade(ledger.student('s1'), 'G1')).criteria, null);
+});
+
+test('GetGradeByExamStudent hides unpublished grades and other students\' grades from students', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2']);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '');
+
+    await assert.rejects(grades.GetGradeByExamStudent(ledger.student('s1'), 'E1', 's1'), /Grade not yet published/);
+    assert.strictEqual(JSON.parse(await grades.GetGradeByExamStudent(ledger.school(), 'E1', 's1')).id, 'G1');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    assert.strictEqual(JSON.parse(await grades.GetGradeByExamStudent(ledger.student('s1'), 'E1', 's1')).score, 15);
+    await assert.rejects(grades.GetGradeByExamStudent(ledger.student('s2'), 'E1', 's1'), /You can only view your own grades/);
+    await assert.rejects(grades.GetGradeByExamStudent(ledger.school(), 'E1', 's2'), /No grade found for student s2 in exam E1/);
+});
+
+test('grading closes after the class grading window and reopens with an audited reason', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await new ClassContract().SetGradingWindow(ledger.school(), 'C1', '7');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '');
+    await assert.rejects(grades.ReopenGrading(ledger.school(), 'E1', 'makeup'), /Grading is not closed for exam E1/);
+
+    ledger.setTime('2026-02-09T10:00:00Z');
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '12', ''),
+        /Grading is closed for exam E1 \(grading deadline passed on 2026-02-08T10:00:00.000Z\)/);
+    await assert.rejects(grades.ReopenGrading(ledger.school(), 'E1', ''), /A reason is required to reopen grading/);
+    assert.strictEqual(JSON.parse(await grades.ReopenGrading(ledger.school(), 'E1', 'makeup exam')).status, 'reopened');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '12', '');
+    const [audit] = JSON.parse(await new AuditContract().GetAuditTrail(ledger.school(), 'E1'));
+    assert.strictEqual(audit.action, 'GradingReopened');
+    assert.strictEqual(audit.reason, 'makeup exam');
+
+    await grades.CloseGrading(ledger.school(), 'E1');
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G3', 'E1', 's3', '12', ''), /Grading is closed for exam E1 \(closed by teacher1/);
+    await assert.rejects(grades.CloseGrading(ledger.school(), 'E1'), /Grading is already closed for exam E1/);
+});
+
+test('a lenient grading window flags late grades instead of rejecting them', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    for (const [classId, strict] of [['A', 'true'], ['B', 'false']]) {
+        await new ClassContract().CreateClass(ledger.school(), classId, classId, 'Cours');
+        for (const studentId of ['s1', 's2']) {
+            await new ClassContract().EnrollStudent(ledger.school(), classId, studentId);
+        }
+        await new ClassContract().SetGradingWindow(ledger.school(), classId, '7', strict);
+        await new ExamContract().CreateExam(ledger.school(), `E${classId}`, classId, 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    }
+
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G1B', 'EB', 's1', '15', '');
+    assert.strictEqual(ledger.get('G1B').lateGrading, null);
+
+    ledger.setTime('2026-02-09T16:00:00Z');
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G2A', 'EA', 's2', '12', ''), /Grading is closed for exam EA/);
+    await grades.SubmitGrade(ledger.school(), 'G2B', 'EB', 's2', '12', '');
+    assert.strictEqual(ledger.get('G2B').gradingDeadline, '2026-02-08T10:00:00.000Z');
+    assert.deepStrictEqual(ledger.get('G2B').lateGrading, { hoursLate: 30 });
+    assert.deepStrictEqual(ledger.lastEvent().payload.lateGrading, { hoursLate: 30 });
+
+    // Une réouverture n'efface pas le retard de saisie
+    await grades.ReopenGrading(ledger.school(), 'EA', 'makeup');
+    await grades.SubmitGrade(ledger.school(), 'G2A', 'EA', 's2', '12', '');
+    assert.deepStrictEqual(ledger.get('G2A').lateGrading, { hoursLate: 30 });
+    assert.strictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'EB')).valid, true);
+});
+
+test('GetTeacherGradeAnalytics summarises the grades of a teacher\'s classes for a semester', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const scores = { A: [16, 12, 6], B: [8, 9] };
+    for (const classId of ['A', 'B']) {
+        await new ClassContract().CreateClass(ledger.school('T1'), classId, classId, 'Cours', '5', 'S1');
+        for (const studentId of ['s1', 's2', 's3']) {
+            await new ClassContract().EnrollStudent(ledger.school(), classId, studentId);
+        }
+        await new ExamContract().CreateExam(ledger.school('T1'), `E${classId}`, classId, 'M1', 'Final', '2026-02-01T10:00:00Z', 'QmExam');
+        for (let i = 0; i < scores[classId].length; i++) {
+            await grades.SubmitGrade(ledger.school('T1'), `G${classId}${i}`, `E${classId}`, `s${i + 1}`, String(scores[classId][i]), '');
+        }
+    }
+    await new ClassContract().CreateClass(ledger.school('T2'), 'Z', 'Z', 'Cours', '5', 'S1');
+    await new ClassContract().CreateClass(ledger.school('T1'), 'Y', 'Y', 'Cours', '5', 'S2');
+
+    const analytics = JSON.parse(await grades.GetTeacherGradeAnalytics(ledger.school('T1'), 'T1', 'S1'));
+    assert.strictEqual(analytics.passPercent, 50);
+    assert.deepStrictEqual(analytics.classes.map((entry) => [entry.classId, entry.gradedCount, entry.averagePercentage, entry.passRate]),
+        [['A', 3, 56.67, 66.67], ['B', 2, 42.5, 0]]);
+    assert.deepStrictEqual(analytics.classes[0].distribution, { A: 1, C: 1, F: 1 });
+    assert.strictEqual(analytics.classes[0].statistics.median, 60);
+
+    await assert.rejects(grades.GetTeacherGradeAnalytics(ledger.school('T2'), 'T1', 'S1'), /Only T1 or an admin can view these grade analytics/);
+    assert.strictEqual(JSON.parse(await grades.GetTeacherGradeAnalytics(ledger.admin(), 'T1', 'S1')).classes.length, 2);
+});
+
+test('GetStudentClassStatus derives the class status and FinalizeClassOutcome freezes it', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of ['s1', 's2', 's3', 's4']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await new ClassContract().WithdrawStudent(ledger.school(), 'C1', 's4');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Final', '2026-02-01T10:00:00Z', 'QmExam');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '7', '');
+    assert.strictEqual(JSON.parse(await grades.GetStudentClassStatus(ledger.school(), 'C1', 's1')).status, 'in-progress');
+
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    const statuses = [];
+    for (const studentId of ['s1', 's2', 's3', 's4']) {
+        statuses.push(JSON.parse(await grades.GetStudentClassStatus(ledger.student(studentId), 'C1', studentId)).status);
+    }
+    assert.deepStrictEqual(statuses, ['completed', 'failed', 'in-progress', 'withdrawn']);
+    await assert.rejects(grades.GetStudentClassStatus(ledger.school(), 'C1', 'zz'), /Student zz is not enrolled in class C1/);
+
+    await assert.rejects(grades.FinalizeClassOutcome(ledger.school('x'), 'C1'), /Only the teacher of class C1 or an admin can finalize its outcomes/);
+    const finalized = JSON.parse(await grades.FinalizeClassOutcome(ledger.school(), 'C1'));
+    assert.deepStrictEqual(finalized.counts, { completed: 1, failed: 1, 'in-progress': 1 });
+    const status = JSON.parse(await grades.GetStudentClassStatus(ledger.student('s1'), 'C1', 's1'));
+    assert.strictEqual(status.finalized, true);
+    assert.strictEqual(status.finalizedAt, '2026-02-05T10:00:00.000Z');
+});
+
+test('a signed transcript verifies only while its content and registration are intact', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1']);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+
+    await assert.rejects(grades.ExportSignedTranscript(ledger.school(), 's1'), /Only the student or an admin can export a signed transcript/);
+    await assert.rejects(grades.ExportSignedTranscript(ledger.student('s2'), 's1'), /Only the student or an admin/);
+    const signed = JSON.parse(await grades.ExportSignedTranscript(ledger.student('s1'), 's1'));
+    assert.strictEqual(signed.algorithm, 'sha256');
+    assert.strictEqual(signed.document.classes[0].letterGrade, 'B');
+    assert.deepStrictEqual(JSON.parse(await grades.VerifyTranscript(ledger.school(), JSON.stringify(signed))),
+        { valid: true, digestMatches: true, registered: true, studentId: 's1', issuedAt: '2026-01-10T10:00:00.000Z' });
+
+    // L'ordre des clés n'a pas d'influence sur l'empreinte
+    const reordered = { digest: signed.digest, document: Object.fromEntries(Object.entries(signed.document).reverse()) };
+    assert.strictEqual(JSON.parse(await grades.VerifyTranscript(ledger.school(), JSON.stringify(reordered))).valid, true);
+
+    const tampered = JSON.parse(JSON.stringify(signed));
+    tampered.document.classes[0].letterGrade = 'A';
+    const result = JSON.parse(await grades.VerifyTranscript(ledger.school(), JSON.stringify(tampered)));
+    assert.deepStrictEqual([result.valid, result.digestMatches, result.registered], [false, false, true]);
+
+    // Empreinte recalculée: le relevé n'a jamais été enregistré
+    tampered.digest = crypto.createHash('sha256').update(canonicalStringify(tampered.document)).digest('hex');
+    const forged = JSON.parse(await grades.VerifyTranscript(ledger.school(), JSON.stringify(tampered)));
+    assert.deepStrictEqual([forged.valid, forged.digestMatches, forged.registered], [false, true, false]);
+});
+
+test('a review lock freezes grade changes for everyone but the locker and admins', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const coTeacher = 'co@school.academic.edu';
+    await classWithExam(ledger, ['s1', 's2', 's3']);
+    await new ClassContract().AddClassStaff(ledger.school(), 'C1', coTeacher, 'co-teacher');
+    await grades.SubmitGrade(ledger.school(coTeacher), 'G1', 'E1', 's1', '10', '');
+
+    assert.strictEqual(JSON.parse(await grades.LockExamGrades(ledger.school(), 'E1')).lockedBy, 'teacher1@school.academic.edu');
+    await assert.rejects(grades.LockExamGrades(ledger.school(coTeacher), 'E1'), /already locked for review by teacher1/);
+    await assert.rejects(grades.SubmitGrade(ledger.school(coTeacher), 'G2', 'E1', 's2', '10', ''), /Exam E1: grades locked for review/);
+    await assert.rejects(grades.UpdateGrade(ledger.school(coTeacher), 'G1', '12', ''), /grades locked for review/);
+    await grades.UpdateGrade(ledger.school(), 'G1', '12', '');
+    await grades.SubmitGrade(ledger.school(), 'G3', 'E1', 's3', '11', '');
+
+    await assert.rejects(grades.UnlockExamGrades(ledger.school(coTeacher), 'E1'), /Only teacher1@school.academic.edu or an admin can unlock/);
+    await grades.UnlockExamGrades(ledger.school(), 'E1');
+    await grades.SubmitGrade(ledger.school(coTeacher), 'G2', 'E1', 's2', '10', '');
+    await assert.rejects(grades.UnlockExamGrades(ledger.school(coTeacher), 'E1'), /Grades for exam E1 are not locked/);
+});
+
+test('DeleteGrade respects the review lock', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '');
+    await grades.LockExamGrades(ledger.admin(), 'E1');
+
+    await assert.rejects(grades.DeleteGrade(ledger.school(), 'G1', 'dup'), /grades locked for review by Admin@school.academic.edu/);
+    await grades.DeleteGrade(ledger.admin(), 'G1', 'dup');
+    assert.strictEqual(ledger.get('G1'), null);
+});
+
+test('CompareExamStatistics compares two exams of a class on a percentage scale', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre');
+    await new ClassContract().CreateClass(ledger.school(), 'B', 'Physique', 'Mécanique');
+    for (const studentId of ['s1', 's2', 's3', 's4']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'A', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'A', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'E2', 'A', 'M1', 'Final', '2026-02-02T10:00:00Z', 'QmExam', '', '40');
+    await exams.CreateExam(ledger.school(), 'EB', 'B', 'M1', 'Final', '2026-02-02T10:00:00Z', 'QmExam');
+    for (const [studentId, score] of [['s1', '16'], ['s2', '14'], ['s3', '12'], ['s4', '8']]) {
+        await grades.SubmitGrade(ledger.school(), `G1${studentId}`, 'E1', studentId, score, '');
+    }
+    for (const [studentId, score] of [['s1', '28'], ['s2', '20'], ['s3', '16']]) {
+        await grades.SubmitGrade(ledger.school(), `G2${studentId}`, 'E2', studentId, score, '');
+    }
+    await grades.MarkAbsent(ledger.school(), 'G2s4', 'E2', 's4', '');
+
+    const comparison = JSON.parse(await grades.CompareExamStatistics(ledger.school(), 'E1', 'E2'));
+    assert.strictEqual(comparison.examA.statistics.mean, 62.5);
+    assert.strictEqual(comparison.examA.passRate, 75);
+    assert.deepStrictEqual(comparison.examA.distribution, { A: 1, B: 1, C: 1, F: 1 });
+    assert.strictEqual(comparison.examB.maxScore, 40);
+    assert.strictEqual(comparison.examB.absentCount, 1);
+    assert.strictEqual(comparison.examB.statistics.mean, 53.33);
+    assert.strictEqual(comparison.examB.passRate, 66.67);
+
+    await assert.rejects(grades.CompareExamStatistics(ledger.school(), 'E1', 'EB'), /exam E1 \(class A\) and exam EB \(class B\) belong to different classes/);
+    await assert.rejects(grades.CompareExamStatistics(ledger.school('x@school.academic.edu'), 'E1', 'E2'), /Access Denied/);
+    await assert.rejects(grades.CompareExamStatistics(ledger.school(), 'E1', 'E1'), /must be different exams/);
+});
+
+test('a finalized grade can only change after an audited admin unfinalize', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1']);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '10', '');
+
+    assert.strictEqual(JSON.parse(await grades.FinalizeGrade(ledger.school(), 'G1')).finalized, true);
+    await assert.rejects(grades.FinalizeGrade(ledger.school(), 'G1'), /Grade G1 is already finalized/);
+    await assert.rejects(grades.UpdateGrade(ledger.school(), 'G1', '12', ''), /an admin must unfinalize it \(UnfinalizeGrade\) before any change/);
+    await assert.rejects(grades.DeleteGrade(ledger.school(), 'G1', 'oops'), /Grade G1 is finalized/);
+    await assert.rejects(grades.UnfinalizeGrade(ledger.school(), 'G1', 'fix'), /Only admins can unfinalize grades/);
+    await assert.rejects(grades.UnfinalizeGrade(ledger.admin(), 'G1', ' '), /A reason is required to unfinalize a grade/);
+
+    await grades.UnfinalizeGrade(ledger.admin(), 'G1', 'typo in score');
+    await grades.UpdateGrade(ledger.school(), 'G1', '12', '');
+    const [audit] = JSON.parse(await new AuditContract().GetAuditTrail(ledger.school(), 'G1'));
+    assert.deepStrictEqual([audit.action, audit.reason], ['GradeUnfinalized', 'typo in score']);
+
+    // Le retrait avec la politique "void" épargne une note finalisée
+    await grades.FinalizeGrade(ledger.school(), 'G1');
+    await new ClassContract().SetWithdrawalPolicy(ledger.school(), 'C1', 'void');
+    await new ClassContract().WithdrawStudent(ledger.school(), 'C1', 's1');
+    assert.strictEqual(ledger.get('G1').voided, undefined);
+});
+
+test('a finalized grade blocks a second grade for the same student, in every entry point', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '');
+    await grades.FinalizeGrade(ledger.school(), 'G1');
+
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G2', 'E1', 'alice', '20', ''), /Student alice already has grade G1 for exam E1 \(finalized\)/);
+    await assert.rejects(new AcademicContract().SubmitGrade(ledger.school(), 'G3', 'E1', 'alice', '20', '', ''), /Student alice already has grade G1 for exam E1/);
+    ledger.couchdb = false;
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G2', 'E1', 'alice', '20', ''), /already has grade G1 for exam E1 \(finalized\)/);
+});
+
+test('pass/fail exams take a result instead of a score and stay out of the final grade', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam');
+    await exams.CreateExam(ledger.school(), 'F1', 'C1', 'M1', 'TP', '2026-02-01T10:00:00Z', 'QmExam', '', '', 'passfail');
+
+    ledger.setTime('2026-02-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'GE1', 'E1', 's1', '10', '');
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'GF1', 'F1', 's1', '12', ''),
+        /Invalid result: exam F1 is graded pass\/fail, expected "pass" or "fail"/);
+    await grades.SubmitGrade(ledger.school(), 'GF1', 'F1', 's1', 'pass', '');
+    await grades.SubmitGrade(ledger.school(), 'GF2', 'F1', 's2', 'fail', '');
+    await grades.MarkAbsent(ledger.school(), 'GF3', 'F1', 's3', '');
+    await grades.UpdateGrade(ledger.school(), 'GF2', 'pass', 'retake ok');
+    await assert.rejects(grades.ImportExamGradesCSV(ledger.school(), 'F1', 's3,1,1'), /Exam F1 is graded pass\/fail: CSV import only accepts scores/);
+    await assert.rejects(new AcademicContract().SubmitGrade(ledger.school(), 'Z', 'F1', 's1', '1', '', ''), /Exam F1 is graded pass\/fail/);
+
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    await grades.PublishExamGrades(ledger.school(), 'F1');
+    const result = JSON.parse(await grades.GetGrade(ledger.student('s1'), 'GF1'));
+    assert.deepStrictEqual([result.score, result.maxScore, result.result], [null, null, 'pass']);
+    assert.deepStrictEqual(JSON.parse(await grades.GetExamStatistics(ledger.school(), 'F1')).passFail, { passedCount: 2, failedCount: 0, passRate: 100 });
+    await assert.rejects(grades.ConvertGrade(ledger.school(), 'GF1', 'percent'), /Grade GF1 is a pass\/fail result: it has no score to convert/);
+
+    const finalGrade = JSON.parse(await grades.ComputeFinalGrade(ledger.school(), 'C1', 's1'));
+    assert.strictEqual(finalGrade.percentage, 50);
+    assert.deepStrictEqual(finalGrade.exams.find((exam) => exam.examId === 'F1'),
+        { examId: 'F1', gradeId: 'GF1', weight: 0, score: null, result: 'pass', percentage: null, isPublished: true });
+});
+
+test('PreviewGradeRelease shows exactly what publishing will release without writing anything', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2']);
+    ledger.setTime('2026-01-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', 'good work');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '7', 'revise ch.3');
+
+    await assert.rejects(grades.PreviewGradeRelease(ledger.school('x@school.academic.edu'), 'E1'), /Only the teachers of class C1 or an admin/);
+    await assert.rejects(grades.PreviewGradeRelease(ledger.student('s1'), 'E1'), /Only SchoolOrg members \(teachers\) can preview grade releases/);
+
+    const before = JSON.stringify([...ledger.state.entries()]);
+    const preview = JSON.parse(await grades.PreviewGradeRelease(ledger.school(), 'E1'));
+    assert.strictEqual(JSON.stringify([...ledger.state.entries()]), before);
+    assert.deepStrictEqual(preview.grades.map((entry) => [entry.studentId, entry.percentage, entry.letterGrade]),
+        [['s1', 75, 'B'], ['s2', 35, 'F']]);
+
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    for (const entry of preview.grades) {
+        assert.deepStrictEqual(JSON.parse(await grades.GetGrade(ledger.student(entry.studentId), entry.grade.id)), entry.grade);
+    }
+});
+
+test('grade comment threads are limited to the student and the class teachers', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2']);
+    await new ClassContract().AddClassStaff(ledger.school(), 'C1', 'co@school.academic.edu', 'co-teacher');
+    await new ClassContract().AddClassStaff(ledger.school(), 'C1', 'ta@school.academic.edu', 'ta');
+    ledger.setTime('2026-01-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '12', '');
+
+    await assert.rejects(grades.AddGradeComment(ledger.student('s1'), 'G1', 'why?'), /Grade not yet published by the teacher/);
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    await grades.AddGradeComment(ledger.student('s1'), 'G1', 'Why did I lose points on Q2?');
+    ledger.advance(60);
+    await grades.AddGradeComment(ledger.school(), 'G1', 'Missing justification.');
+    ledger.advance(60);
+    await grades.AddGradeComment(ledger.school('co@school.academic.edu'), 'G1', 'See the correction, page 3.');
+
+    const denied = /Only s1 and the teachers of class C1 can access the comments of grade G1/;
+    await assert.rejects(grades.AddGradeComment(ledger.student('s2'), 'G1', 'hi'), denied);
+    await assert.rejects(grades.GetGradeComments(ledger.student('s2'), 'G1'), denied);
+    await assert.rejects(grades.GetGradeComments(ledger.school('ta@school.academic.edu'), 'G1'), denied);
+    await assert.rejects(grades.AddGradeComment(ledger.student('s1'), 'G1', 'x'.repeat(1001)), /comment exceeds 1000 characters/);
+    await assert.rejects(grades.AddGradeComment(ledger.student('s1'), 'G1', '  '), /comment must be a non-empty string/);
+
+    const thread = JSON.parse(await grades.GetGradeComments(ledger.student('s1'), 'G1'));
+    assert.deepStrictEqual(thread.comments.map((comment) => [comment.seq, comment.authorRole, comment.author]), [
+        [1, 'student', 's1'],
+        [2, 'teacher', 'teacher1@school.academic.edu'],
+        [3, 'teacher', 'co@school.academic.edu'],
+    ]);
+
+    for (let index = 0; index < 47; index++) {
+        await grades.AddGradeComment(ledger.student('s1'), 'G1', `c${index}`);
+    }
+    await assert.rejects(grades.AddGradeComment(ledger.student('s1'), 'G1', 'more'), /Grade G1 already has 50 comments \(maximum reached\)/);
+    // Les numéros de séquence sont triés numériquement, pas comme des chaînes
+    assert.deepStrictEqual(JSON.parse(await grades.GetGradeComments(ledger.school(), 'G1')).comments.slice(9, 12).map((comment) => comment.seq), [10, 11, 12]);
+});
+
+test('RecomputeLetterGrades migrates letters to a new grading scale and skips finalized grades', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const classes = new ClassContract();
+    await classWithExam(ledger, ['s1', 's2', 's3', 's4']);
+    ledger.setTime('2026-01-05T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '17', '');
+    await grades.MarkAbsent(ledger.school(), 'G3', 'E1', 's3', '');
+    await grades.SubmitGrade(ledger.school(), 'G4', 'E1', 's4', '11', '');
+    assert.deepStrictEqual(['G1', 'G2', 'G3', 'G4'].map((gradeId) => ledger.get(gradeId).letterGrade), ['B', 'A', null, 'D']);
+
+    await assert.rejects(classes.SetGradingScale(ledger.school(), 'C1', '[{"minPercent":50,"letter":"P","points":1}]'),
+        /Invalid grading scale: a row with minPercent 0 is required/);
+    await assert.rejects(classes.SetGradingScale(ledger.school('x@school.academic.edu'), 'C1', '[]'),
+        /Only the teacher of class C1 or an admin can manage its grading scale/);
+    await classes.SetGradingScale(ledger.school(), 'C1', JSON.stringify([
+        { minPercent: 0, letter: 'F', points: 0 },
+        { minPercent: 90, letter: 'A', points: 4 },
+        { minPercent: 70, letter: 'B', points: 3 },
+    ]));
+    // Le changement de barème ne réécrit rien tant que la migration n'est pas lancée
+    assert.strictEqual(ledger.get('G2').letterGrade, 'A');
+    await grades.FinalizeGrade(ledger.school(), 'G4');
+
+    await assert.rejects(grades.RecomputeLetterGrades(ledger.student('s1'), 'C1'), /Only SchoolOrg members \(teachers\) can recompute letter grades/);
+    const migration = JSON.parse(await grades.RecomputeLetterGrades(ledger.school(), 'C1'));
+    assert.deepStrictEqual([migration.gradeCount, migration.changedCount, migration.changes, migration.skippedFinalized],
+        [4, 1, [{ gradeId: 'G2', studentId: 's2', from: 'A', to: 'B' }], ['G4']]);
+    assert.deepStrictEqual([ledger.get('G2').letterGrade, ledger.get('G2').score, ledger.get('G4').letterGrade], ['B', 17, 'D']);
+    assert.strictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')).valid, true);
+    assert.strictEqual(JSON.parse(await grades.RecomputeLetterGrades(ledger.school(), 'C1')).changedCount, 0);
+});
+
+test('UpdateGrade sets the letter of a grade created by the legacy contract', async () => {
+    const ledger = new MemoryLedger();
+    await classWithExam(ledger);
+    await new AcademicContract().SubmitGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '', '');
+    await new GradeContract().UpdateGrade(ledger.school(), 'G1', '15', 'ok');
+    assert.strictEqual(ledger.get('G1').letterGrade, 'B');
+});
+
+test('FinalizeExamNoShows grades a zero for enrolled students who neither submitted nor were graded', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    ledger.setTime('2025-12-20T10:00:00Z');
+    await classWithExam(ledger, ['s1', 's2', 's3', 's4', 's5']);
+    await assert.rejects(grades.FinalizeExamNoShows(ledger.school(), 'E1'), /Exam E1 has no submission deadline \(durationMinutes not set\)/);
+    await exams.UpdateExam(ledger.school(), 'E1', JSON.stringify({ durationMinutes: 60, gracePeriodMinutes: 10 }));
+
+    ledger.setTime('2026-01-01T10:30:00Z');
+    await exams.SubmitExamCopy(ledger.student('s1'), 'E1', 'QmCopy', '');
+    ledger.setTime('2026-01-01T11:05:00Z');
+    await assert.rejects(grades.FinalizeExamNoShows(ledger.school(), 'E1'), /Submissions for exam E1 are open until 2026-01-01T11:10:00.000Z/);
+
+    ledger.setTime('2026-01-02T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '14', '');
+    await new ClassContract().WithdrawStudent(ledger.school(), 'C1', 's5');
+    await assert.rejects(grades.FinalizeExamNoShows(ledger.school('x@school.academic.edu'), 'E1'), /Only the teachers of class C1 or an admin/);
+
+    const result = JSON.parse(await grades.FinalizeExamNoShows(ledger.school(), 'E1'));
+    assert.deepStrictEqual(result.noShows.map((noShow) => noShow.studentId), ['s3', 's4']);
+    assert.deepStrictEqual([result.closesAt, result.submittedCount, result.alreadyGradedCount, result.stillOpen],
+        ['2026-01-01T11:10:00.000Z', 1, 1, []]);
+    const noShow = ledger.get(result.noShows[0].gradeId);
+    assert.deepStrictEqual([noShow.status, noShow.score, noShow.isPublished, noShow.letterGrade], ['no-show', 0, false, 'F']);
+    assert.strictEqual(ledger.get('G2').score, 14);
+    assert.strictEqual(JSON.parse(await grades.FinalizeExamNoShows(ledger.school(), 'E1')).noShowCount, 0);
+});
+
+test('PreviewStudentFinalGrade bounds the final grade with the weight still outstanding', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre', '10');
+    for (const studentId of ['s1', 's2']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmExam', '0.5');
+    await exams.CreateExam(ledger.school(), 'E2', 'C1', 'M1', 'TP', '2026-02-02T10:00:00Z', 'QmExam', '0.25');
+    await exams.CreateExam(ledger.school(), 'E3', 'C1', 'M1', 'Final', '2026-03-01T10:00:00Z', 'QmExam', '0.25');
+    await exams.CreateExam(ledger.school(), 'P1', 'C1', 'M1', 'Oral', '2026-02-01T10:00:00Z', 'QmExam', '', '', 'passfail');
+    ledger.setTime('2026-02-10T10:00:00Z');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E2', 's1', '10', '');
+    await grades.SubmitGrade(ledger.school(), 'G3', 'P1', 's1', 'pass', '');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    await grades.PublishExamGrades(ledger.school(), 'P1');
+
+    // Une note non publiée compte comme restant à venir; le pass/fail n'a pas de poids
+    let preview = JSON.parse(await grades.PreviewStudentFinalGrade(ledger.student('s1'), 'C1', 's1'));
+    assert.deepStrictEqual([preview.currentPercentage, preview.gradedWeight, preview.outstandingWeight, preview.bestPossible, preview.worstPossible],
+        [75, 0.5, 0.5, 87.5, 37.5]);
+    assert.deepStrictEqual(preview.outstandingExams.map((exam) => exam.examId), ['E2', 'E3']);
+
+    await grades.PublishExamGrades(ledger.school(), 'E2');
+    preview = JSON.parse(await grades.PreviewStudentFinalGrade(ledger.school(), 'C1', 's1'));
+    assert.deepStrictEqual([preview.currentPercentage, preview.currentLetterGrade, preview.outstandingWeightPercent],
+        [66.67, 'C', 25]);
+    assert.deepStrictEqual([preview.bestPossible, preview.bestLetterGrade, preview.worstPossible, preview.worstLetterGrade],
+        [75, 'B', 50, 'D']);
+
+    await assert.rejects(grades.PreviewStudentFinalGrade(ledger.student('s2'), 'C1', 's1'), /You can only view your own grades/);
+    preview = JSON.parse(await grades.PreviewStudentFinalGrade(ledger.student('s2'), 'C1', 's2'));
+    assert.deepStrictEqual([preview.currentPercentage, preview.bestPossible, preview.worstPossible], [null, 100, 0]);
+});
+
+test('GetStudentSemesterGPA weights each class by its credit hours', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const grades = new GradeContract();
+    for (const classId of ['H', 'L']) {
+        await classes.CreateClass(ledger.school(), classId, `Cours ${classId}`, 'Description', '', 'S1');
+        await classes.EnrollStudent(ledger.school(), classId, 'alice');
+        await new ExamContract().CreateExam(ledger.school(), `E${classId}`, classId, 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+    }
+    await grades.PublishGrade(ledger.school(), 'G1', 'EH', 'alice', '19', '');
+    await grades.PublishGrade(ledger.school(), 'G2', 'EL', 'alice', '11', '');
+    const gpa = async (high, low) => {
+        await classes.PatchClass(ledger.school(), 'H', JSON.stringify({ creditHours: high }));
+        await classes.PatchClass(ledger.school(), 'L', JSON.stringify({ creditHours: low }));
+        return JSON.parse(await grades.GetStudentSemesterGPA(ledger.student('alice'), 'alice', 'S1')).gpa;
+    };
+
+    // 3 crédits par défaut
+    assert.strictEqual(ledger.get('H').creditHours, 3);
+    const semester = JSON.parse(await grades.GetStudentSemesterGPA(ledger.student('alice'), 'alice', 'S1'));
+    assert.deepStrictEqual([semester.gpa, semester.totalCreditHours], [2.5, 6]);
+    assert.deepStrictEqual(semester.classes.map((entry) => [entry.classId, entry.gpaPoints, entry.creditHours]), [['H', 4, 3], ['L', 1, 3]]);
+    assert.strictEqual(await gpa(6, 1), 3.57);
+    assert.strictEqual(await gpa(1, 6), 1.43);
+
+    await assert.rejects(classes.PatchClass(ledger.school(), 'H', '{"creditHours":0}'), /Invalid creditHours: must be a strictly positive number/);
+    await assert.rejects(classes.PatchClass(ledger.school(), 'H', '{"creditHours":"3"}'), /Invalid creditHours/);
+});
+
+test('provisional grades are held back at publication until a teacher confirms them', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2']);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '12', '', '', 'true');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '14', '');
+    await assert.rejects(grades.SubmitGrade(ledger.school(), 'G3', 'E1', 's2', '14', '', '', 'maybe'),
+        /Invalid provisional: maybe \(expected "true" or "false"\)/);
+
+    const release = JSON.parse(await grades.PublishExamGrades(ledger.school(), 'E1'));
+    assert.deepStrictEqual([release.published, release.provisional], [['G2'], ['G1']]);
+    assert.deepStrictEqual([ledger.get('G1').provisional, ledger.get('G1').isPublished], [true, false]);
+    await assert.rejects(grades.GetGrade(ledger.student('s1'), 'G1'), /Grade not yet published by the teacher/);
+    // Même avec isPublished forcé, une note provisoire reste invisible pour l'étudiant
+    ledger.put('G1', { ...ledger.get('G1'), isPublished: true });
+    await assert.rejects(grades.GetGrade(ledger.student('s1'), 'G1'), /Grade not yet published by the teacher/);
+    ledger.put('G1', { ...ledger.get('G1'), isPublished: false });
+
+    await assert.rejects(grades.ConfirmGrade(ledger.school('x@school.academic.edu'), 'G1'), /Only the teachers of class C1 or an admin/);
+    await assert.rejects(grades.ConfirmGrade(ledger.school(), 'G2'), /Grade G2 is not provisional/);
+    const confirmed = JSON.parse(await grades.ConfirmGrade(ledger.school(), 'G1'));
+    assert.deepStrictEqual([confirmed.provisional, confirmed.confirmedBy], [false, 'teacher1@school.academic.edu']);
+    const second = JSON.parse(await grades.PublishExamGrades(ledger.school(), 'E1'));
+    assert.deepStrictEqual([second.published, second.provisional], [['G1'], []]);
+    assert.strictEqual(JSON.parse(await grades.GetGrade(ledger.student('s1'), 'G1')).score, 12);
+});
+
+test('ConfirmGrade respects the exam lock and grade finalization', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '', '', 'true');
+
+    await grades.LockExamGrades(ledger.admin(), 'E1');
+    await assert.rejects(grades.ConfirmGrade(ledger.school(), 'G1'), /Exam E1: grades locked for review/);
+    await grades.UnlockExamGrades(ledger.admin(), 'E1');
+    await grades.FinalizeGrade(ledger.school(), 'G1');
+    await assert.rejects(grades.ConfirmGrade(ledger.school(), 'G1'), /Grade G1 is finalized since/);
+    await grades.UnfinalizeGrade(ledger.admin(), 'G1', 'provisional');
+    await grades.ConfirmGrade(ledger.school(), 'G1');
+    const actions = JSON.parse(await new AuditContract().GetAuditTrail(ledger.school(), 'G1')).map((entry) => entry.action);
+    assert.ok(actions.includes('GradeConfirmed'));
+    assert.strictEqual(ledger.get('G1').provisional, false);
+});
+
+test('IssueClassCertificates issues a certificate to each passing student with a complete final grade', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger, ['s1', 's2', 's3', 's4']);
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '');
+    await grades.SubmitGrade(ledger.school(), 'G2', 'E1', 's2', '6', '');
+    await grades.SubmitGrade(ledger.school(), 'G4', 'E1', 's4', '10', '');
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+
+    await assert.rejects(grades.IssueClassCertificates(ledger.school('x@school.academic.edu'), 'C1'),
+        /Only the teacher of class C1 or an admin can issue its certificates/);
+    const issued = JSON.parse(await grades.IssueClassCertificates(ledger.school(), 'C1'));
+    assert.deepStrictEqual([issued.issuedCount, issued.skippedCount], [2, 2]);
+    assert.deepStrictEqual(issued.results.map((result) => [result.studentId, result.status, result.certificateId, result.reason]), [
+        ['s1', 'issued', generateDeterministicID('CERT', 'C1', 's1'), null],
+        ['s2', 'skipped', null, 'Below the pass threshold (30% < 50%)'],
+        ['s3', 'skipped', null, 'Final grade incomplete: some exams have no published grade'],
+        ['s4', 'issued', generateDeterministicID('CERT', 'C1', 's4'), null],
+    ]);
+
+    // Relance idempotente: rien n'est réémis
+    const again = JSON.parse(await grades.IssueClassCertificates(ledger.admin(), 'C1'));
+    assert.deepStrictEqual([again.issuedCount, again.results.map((result) => result.reason)], [0, [
+        'Certificate already issued',
+        'Below the pass threshold (30% < 50%)',
+        'Final grade incomplete: some exams have no published grade',
+        'Certificate already issued',
+    ]]);
+
+    const certificate = JSON.parse(await grades.GetCertificate(ledger.student('s1'), 'C1', 's1'));
+    assert.deepStrictEqual([certificate.percentage, certificate.letterGrade, certificate.passPercent, certificate.issuedBy],
+        [75, 'B', 50, 'teacher1@school.academic.edu']);
+    await assert.rejects(grades.GetCertificate(ledger.student('s1'), 'C1', 's4'), /You can only view your own grades/);
+    await assert.rejects(grades.GetCertificate(ledger.student('s2'), 'C1', 's2'), /No certificate for s2 in class C1/);
+});
+
+test('GetClassGrades returns at most 500 grades and flags the truncation', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await classWithExam(ledger);
+    for (let i = 0; i < 501; i++) {
+        const id = `G${String(i).padStart(4, '0')}`;
+        ledger.put(id, { docType: 'grade', id: id, examId: 'E1', classId: 'C1', studentId: `s${i}`, score: 10 });
+    }
+
+    for (const couchdb of [true, false]) {
+        ledger.couchdb = couchdb;
+        const capped = JSON.parse(await grades.GetClassGrades(ledger.school(), 'C1'));
+        assert.deepStrictEqual([capped.count, capped.grades.length, capped.truncated], [500, 500, true]);
+    }
+    ledger.state.delete('G0500');
+    const complete = JSON.parse(await grades.GetClassGrades(ledger.school(), 'C1'));
+    assert.deepStrictEqual([complete.count, complete.truncated], [500, false]);
+});
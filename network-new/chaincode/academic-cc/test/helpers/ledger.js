@@ -0,0 +1,212 @@
+/*
+ * Ledger en mémoire pour les tests des contrats
+ *
+ * Reproduit la partie de ChaincodeStub / ClientIdentity utilisée par le chaincode:
+ * état, historique des clés, requêtes CouchDB (sélecteurs utilisés par les contrats),
+ * pagination par plage, horodatage de transaction et événements.
+ */
+
+'use strict';
+
+const DEFAULT_TEACHER = 'teacher1@school.academic.edu';
+const ADMIN = 'Admin@school.academic.edu';
+
+function iteratorOf(items) {
+    let index = 0;
+    return {
+        async next() {
+            if (index < items.length) {
+                return { value: items[index++], done: false };
+            }
+            return { done: true };
+        },
+        async close() {},
+    };
+}
+
+/**
+ * Évalue un sélecteur CouchDB (opérateurs utilisés par les contrats) sur un enregistrement
+ */
+function matchesSelector(selector, record) {
+    return Object.entries(selector).every(([field, condition]) => {
+        if (field === '$or') {
+            return condition.some((alternative) => matchesSelector(alternative, record));
+        }
+        const value = record[field];
+        if (condition === null || typeof condition !== 'object' || Array.isArray(condition)) {
+            return value === condition;
+        }
+        return Object.entries(condition).every(([operator, operand]) => {
+            switch (operator) {
+            case '$eq':
+                return value === operand;
+            case '$in':
+                return operand.includes(value);
+            case '$all':
+                return Array.isArray(value) && operand.every((item) => value.includes(item));
+            case '$exists':
+                return (value !== undefined) === operand;
+            case '$regex':
+                return typeof value === 'string' && new RegExp(operand).test(value);
+            case '$elemMatch':
+                return Array.isArray(value) && value.some((item) => ('$eq' in operand
+                    ? item === operand.$eq
+                    : item !== null && typeof item === 'object' && matchesSelector(operand, item)));
+            default:
+                throw new Error(`Unsupported selector operator in tests: ${operator}`);
+            }
+        });
+    });
+}
+
+class MemoryLedger {
+    constructor() {
+        this.state = new Map();
+        this.history = new Map();
+        this.events = [];
+        this.time = Date.parse('2026-01-10T10:00:00Z') / 1000; // Horodatage des transactions (secondes)
+        this.couchdb = true; // false: getQueryResult échoue (LevelDB), les contrats utilisent leur repli
+        this.txCount = 0;
+    }
+
+    setTime(iso) {
+        this.time = Date.parse(iso) / 1000;
+    }
+
+    advance(seconds) {
+        this.time += seconds;
+    }
+
+    /**
+     * Lit un enregistrement JSON (null si absent)
+     */
+    get(key) {
+        return this.state.has(key) ? JSON.parse(this.state.get(key).toString()) : null;
+    }
+
+    /**
+     * Écrit un enregistrement directement (données antérieures, cas limites)
+     */
+    put(key, record) {
+        this.state.set(key, Buffer.from(typeof record === 'string' ? record : JSON.stringify(record)));
+    }
+
+    lastEvent() {
+        return this.events[this.events.length - 1];
+    }
+
+    _record(txId, key, value, isDelete) {
+        const entries = this.history.get(key) || [];
+        entries.push({ txId: txId, timestamp: { seconds: this.time, nanos: 0 }, isDelete: isDelete, value: value });
+        this.history.set(key, entries);
+    }
+
+    _sortedKeys(startKey, endKey) {
+        return Array.from(this.state.keys()).sort()
+            .filter((key) => (!startKey || key >= startKey) && (!endKey || key < endKey));
+    }
+
+    /**
+     * Contexte de transaction pour une identité (mspId, CN et attributs de certificat)
+     */
+    context(mspId, commonName, attributes) {
+        const ledger = this;
+        this.txCount += 1;
+        const txId = `tx${this.txCount}`;
+        const attrs = attributes || {};
+
+        const stub = {
+            async getState(key) {
+                return ledger.state.has(key) ? Buffer.from(ledger.state.get(key)) : Buffer.alloc(0);
+            },
+            async putState(key, value) {
+                ledger.state.set(key, Buffer.from(value));
+                ledger._record(txId, key, Buffer.from(value), false);
+            },
+            async deleteState(key) {
+                ledger.state.delete(key);
+                ledger._record(txId, key, Buffer.alloc(0), true);
+            },
+            async getStateByRange(startKey, endKey) {
+                return iteratorOf(ledger._sortedKeys(startKey, endKey)
+                    .map((key) => ({ key: key, value: ledger.state.get(key) })));
+            },
+            async getStateByRangeWithPagination(startKey, endKey, pageSize, bookmark) {
+                const keys = ledger._sortedKeys(bookmark || startKey, endKey);
+                const page = keys.slice(0, pageSize);
+                return {
+                    iterator: iteratorOf(page.map((key) => ({ key: key, value: ledger.state.get(key) }))),
+                    metadata: { fetchedRecordsCount: page.length, bookmark: keys[pageSize] || '' },
+                };
+            },
+            async getQueryResult(query) {
+                if (!ledger.couchdb) {
+                    throw new Error('Rich queries are not supported by LevelDB');
+                }
+                const { selector } = JSON.parse(query);
+                const results = [];
+                for (const key of ledger._sortedKeys('', '')) {
+                    let record;
+                    try {
+                        record = JSON.parse(ledger.state.get(key).toString());
+                    } catch (err) {
+                        continue;
+                    }
+                    if (record && typeof record === 'object' && matchesSelector(selector, record)) {
+                        results.push({ key: key, value: ledger.state.get(key) });
+                    }
+                }
+                return iteratorOf(results);
+            },
+            async getHistoryForKey(key) {
+                return iteratorOf(ledger.history.get(key) || []);
+            },
+            getTxTimestamp() {
+                return { seconds: ledger.time, nanos: 0 };
+            },
+            getTxID() {
+                return txId;
+            },
+            getChannelID() {
+                return 'academic-channel';
+            },
+            getMspID() {
+                return 'PeerMSP';
+            },
+            setEvent(name, payload) {
+                ledger.events.push({ name: name, payload: JSON.parse(payload.toString()) });
+            },
+        };
+
+        const clientIdentity = {
+            getMSPID() {
+                return mspId;
+            },
+            getID() {
+                return `x509::/CN=${commonName}/OU=client::/CN=ca.academic.edu`;
+            },
+            getAttributeValue(name) {
+                return attrs[name] === undefined ? null : attrs[name];
+            },
+        };
+
+        return { stub: stub, clientIdentity: clientIdentity };
+    }
+
+    /**
+     * Contexte d'un membre de SchoolOrg (teacher par défaut)
+     */
+    school(commonName, attributes) {
+        return this.context('SchoolMSP', commonName || DEFAULT_TEACHER, attributes);
+    }
+
+    admin() {
+        return this.school(ADMIN);
+    }
+
+    student(studentId) {
+        return this.context('StudentsMSP', studentId);
+    }
+}
+
+module.exports = { MemoryLedger, ADMIN, DEFAULT_TEACHER };
@@ -0,0 +1,87 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const { generateDeterministicID } = require('../lib/ids');
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('generateDeterministicID is stable and does not collide on separator-shifted inputs', async () => {
+    const id = generateDeterministicID('GRADE', 'E1', 's1');
+    assert.match(id, /^GRADE_[0-9a-f]{40}$/);
+    assert.strictEqual(generateDeterministicID('GRADE', 'E1', 's1'), id);
+    assert.notStrictEqual(generateDeterministicID('GRADE', 'E1', 's2'), id);
+    assert.notStrictEqual(generateDeterministicID('X', 'E_1', 'x'), generateDeterministicID('X', 'E', '1_x'));
+    assert.throws(() => generateDeterministicID('X', 'a', ''), /Invalid X id inputs: every part must be a non-empty string/);
+});
+
+test('submissions and imported grades use deterministic IDs and still see older records', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+    ledger.setTime('2026-01-01T11:00:00Z');
+
+    const submission = JSON.parse(await exams.SubmitExamCopy(ledger.student('s1'), 'E1', 'QmS1'));
+    assert.strictEqual(submission.id, generateDeterministicID('SUB', 'E1', 's1'));
+    await assert.rejects(exams.SubmitExamCopy(ledger.student('s1'), 'E1', 'QmS1'), /Copy already submitted for exam E1/);
+
+    // Reçu écrit avec l'ancien format de clé
+    ledger.put('SUB_E1_s2', { docType: 'submission', id: 'SUB_E1_s2', examId: 'E1', studentId: 's2', answerFileHash: 'QmS2', submittedAt: '2026-01-01T10:30:00.000Z', hoursLate: 0 });
+    await assert.rejects(exams.SubmitExamCopy(ledger.student('s2'), 'E1', 'QmS2'), /Copy already submitted for exam E1/);
+    assert.strictEqual(JSON.parse(await exams.GetSubmission(ledger.student('s2'), 'E1', 's2')).id, 'SUB_E1_s2');
+    assert.strictEqual(JSON.parse(await exams.GetSubmissionCount(ledger.school(), 'E1')).submittedCount, 2);
+
+    await grades.SubmitGrade(ledger.school(), 'G3', 'E1', 's3', '12', '');
+    const imported = JSON.parse(await grades.ImportExamGradesCSV(ledger.school(), 'E1', 'studentId,score,maxScore\ns1,10,20\ns3,11,20'));
+    assert.deepStrictEqual(imported.results.map((row) => [row.gradeId, row.status]), [
+        [generateDeterministicID('GRADE', 'E1', 's1'), 'imported'],
+        [generateDeterministicID('GRADE', 'E1', 's3'), 'error'],
+    ]);
+    assert.strictEqual(imported.results[1].error, 'Line 3: student s3 already has grade G3 for exam E1');
+});
+
+test('enrollment, outcome and certificate keys do not collide on underscores and still see older records', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const grades = new GradeContract();
+    await classes.CreateClass(ledger.school(), 'A_B', 'Maths', 'Algèbre');
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre');
+    // Anciennes clés: ENR_A_B_s1 pour (A_B, s1) comme pour (A, B_s1)
+    await classes.EnrollStudent(ledger.school(), 'A_B', 's1');
+    await classes.EnrollStudent(ledger.school(), 'A', 'B_s1');
+    assert.notStrictEqual(ClassContract.enrollmentKey('A_B', 's1'), ClassContract.enrollmentKey('A', 'B_s1'));
+    assert.deepStrictEqual([ledger.get(ClassContract.enrollmentKey('A_B', 's1')).classId, ledger.get(ClassContract.enrollmentKey('A', 'B_s1')).classId],
+        ['A_B', 'A']);
+    assert.match(ClassContract.outcomeKey('A', 's1'), /^OUTCOME_[0-9a-f]{40}$/);
+
+    // Inscription, résultat et certificat écrits avec l'ancien format de clé
+    await classes.CreateClass(ledger.school(), 'OLD', 'Maths', 'Algèbre');
+    const classData = ledger.get('OLD');
+    ledger.put('OLD', Object.assign(classData, { enrolledStudents: ['s2', 's3'], enrolledCount: 2 }));
+    ledger.put('ENR_OLD_s2', { docType: 'enrollment', id: 'ENR_OLD_s2', classId: 'OLD', studentId: 's2', status: 'active', enrolledAt: null, withdrawnAt: null });
+    ledger.put('OUTCOME_OLD_s3', { docType: 'classOutcome', id: 'OUTCOME_OLD_s3', classId: 'OLD', studentId: 's3', status: 'completed', percentage: 80,
+        letterGrade: 'A', passPercent: 50, finalizedAt: '2026-01-05T00:00:00.000Z' });
+    ledger.put('CERT_OLD_s3', { docType: 'certificate', id: 'CERT_OLD_s3', classId: 'OLD', studentId: 's3', percentage: 80 });
+
+    await classes.SetEnrollmentTags(ledger.school(), 'OLD', 's2', '{"cohort":"2026"}');
+    assert.strictEqual(ledger.get('ENR_OLD_s2').tags.cohort, '2026');
+    assert.strictEqual(JSON.parse(await grades.GetStudentClassStatus(ledger.school(), 'OLD', 's3')).status, 'completed');
+    assert.strictEqual(JSON.parse(await grades.GetCertificate(ledger.student('s3'), 'OLD', 's3')).id, 'CERT_OLD_s3');
+    const issued = JSON.parse(await grades.IssueClassCertificates(ledger.school(), 'OLD'));
+    assert.deepStrictEqual(issued.results.find((result) => result.studentId === 's3'),
+        { studentId: 's3', status: 'skipped', certificateId: 'CERT_OLD_s3', reason: 'Certificate already issued' });
+
+    // Une nouvelle inscription remplace l'enregistrement de l'ancienne clé
+    await classes.WithdrawStudent(ledger.school(), 'OLD', 's2');
+    await classes.EnrollStudent(ledger.school(), 'OLD', 's2');
+    assert.strictEqual(ledger.get('ENR_OLD_s2'), null);
+    assert.strictEqual(ledger.get(ClassContract.enrollmentKey('OLD', 's2')).status, 'active');
+});
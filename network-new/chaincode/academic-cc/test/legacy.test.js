@@ -0,0 +1,95 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const ConfigContract = require('../lib/config');
+const AcademicContract = require('../index').contracts[0];
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('the legacy CreateExam stores the exam date in UTC and SubmitGrade rejects a maxScore that differs from the exam', async () => {
+    const ledger = new MemoryLedger();
+    const academic = new AcademicContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 's1');
+    await assert.rejects(academic.CreateExam(ledger.school(), 'E1', 'C1', 'Partiel', '2026-01-01', 'Algèbre'), /Invalid examDate format/);
+    const exam = JSON.parse(await academic.CreateExam(ledger.school(), 'E1', 'C1', 'Partiel', '2026-01-01T10:00:00+02:00', 'Algèbre'));
+    assert.deepStrictEqual([exam.examDate, exam.examDateOffset], ['2026-01-01T08:00:00.000Z', '+02:00']);
+
+    await assert.rejects(academic.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '100', ''),
+        /Invalid maxScore: 100 does not match the maxScore 20 of exam E1/);
+    const grade = JSON.parse(await academic.SubmitGrade(ledger.school(), 'G1', 'E1', 's1', '15', '20', ''));
+    assert.strictEqual(grade.maxScore, 20);
+});
+
+test('the legacy SubmitGrade chains the grade and applies the GradeContract entry checks', async () => {
+    const ledger = new MemoryLedger();
+    const academic = new AcademicContract();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    for (const studentId of ['alice', 'bob']) {
+        await new ClassContract().EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-09T09:00:00Z', 'QmExam');
+
+    const grade = JSON.parse(await academic.SubmitGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '', 'ok'));
+    assert.deepStrictEqual([grade.id, grade.classId, grade.isPublished], ['G1', 'C1', false]);
+    assert.deepStrictEqual(JSON.parse(await grades.VerifyExamGradeChain(ledger.school(), 'E1')),
+        { examId: 'E1', valid: true, length: 1, breaks: [] });
+
+    await grades.LockExamGrades(ledger.admin(), 'E1');
+    await assert.rejects(academic.SubmitGrade(ledger.school(), 'G2', 'E1', 'bob', '14', '', ''), /grades locked for review/);
+    await grades.UnlockExamGrades(ledger.admin(), 'E1');
+
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+    await grades.FinalizeGrade(ledger.school(), 'G1');
+    await assert.rejects(academic.SubmitGrade(ledger.school(), 'G3', 'E1', 'alice', '20', '', ''),
+        /Student alice already has grade G1 for exam E1 \(finalized\)/);
+});
+
+test('the legacy PublishGrade enforces the same release rules as GradeContract', async () => {
+    const ledger = new MemoryLedger();
+    const academic = new AcademicContract();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-09T09:00:00Z', 'QmExam');
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '', '', 'true');
+
+    await assert.rejects(academic.PublishGrade(ledger.school(), 'G1'), /Grade G1 is provisional/);
+    await grades.ConfirmGrade(ledger.school(), 'G1');
+    await new ClassContract().SetReleaseApprovalRequired(ledger.admin(), 'C1', 'true');
+    await assert.rejects(academic.PublishGrade(ledger.school(), 'G1'), /require release approval/);
+    await new ClassContract().SetReleaseApprovalRequired(ledger.admin(), 'C1', 'false');
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"gradeReleaseDelayHours":48}');
+    await assert.rejects(academic.PublishGrade(ledger.school(), 'G1'), /under embargo until 2026-01-11T09:00:00.000Z/);
+
+    ledger.advance(3 * 86400);
+    await grades.LockExamGrades(ledger.admin(), 'E1');
+    await assert.rejects(academic.PublishGrade(ledger.school(), 'G1'), /grades locked for review/);
+    await grades.UnlockExamGrades(ledger.admin(), 'E1');
+    assert.strictEqual(JSON.parse(await academic.PublishGrade(ledger.school(), 'G1')).isPublished, true);
+});
+
+test('the legacy GetAllExams and GetAllGrades return at most 500 records and flag the truncation', async () => {
+    const ledger = new MemoryLedger();
+    const academic = new AcademicContract();
+    for (let i = 0; i < 501; i++) {
+        const suffix = String(i).padStart(4, '0');
+        ledger.put(`E${suffix}`, { docType: 'exam', examId: `E${suffix}`, classId: 'C1', correctionFileHash: 'QmCorrection' });
+        ledger.put(`G${suffix}`, { docType: 'grade', gradeId: `G${suffix}`, examId: 'E0000', studentId: 's1', score: 10 });
+    }
+
+    const exams = JSON.parse(await academic.GetAllExams(ledger.school()));
+    assert.deepStrictEqual([exams.count, exams.truncated, exams.exams[0].correctionFileHash], [500, true, null]);
+    const grades = JSON.parse(await academic.GetAllGrades(ledger.school()));
+    assert.deepStrictEqual([grades.count, grades.truncated], [500, true]);
+
+    ledger.state.delete('E0500');
+    ledger.state.delete('G0500');
+    assert.strictEqual(JSON.parse(await academic.GetAllExams(ledger.school())).truncated, false);
+    assert.strictEqual(JSON.parse(await academic.GetAllGrades(ledger.school())).truncated, false);
+});
@@ -0,0 +1,77 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const MaterialContract = require('../lib/material');
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('uploads are checked against the class storage quota, with or without CouchDB', async () => {
+    const ledger = new MemoryLedger();
+    const materials = new MaterialContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await materials.UploadCourseMaterial(ledger.school(), 'M0', 'C1', 'M1', 'Plan', 'COURS', 'QmM0');
+    await new ClassContract().SetStorageQuota(ledger.school(), 'C1', '1000');
+
+    await assert.rejects(materials.UploadCourseMaterial(ledger.school(), 'M1', 'C1', 'M1', 'Cours 1', 'COURS', 'QmM1'),
+        /Missing size: class C1 has a storage quota/);
+    await materials.UploadCourseMaterial(ledger.school(), 'M1', 'C1', 'M1', 'Cours 1', 'COURS', 'QmM1', '600');
+    await assert.rejects(materials.UploadCourseMaterial(ledger.school(), 'M2', 'C1', 'M1', 'Cours 2', 'COURS', 'QmM2', '401'),
+        /Storage quota exceeded for class C1: 600 \+ 401 bytes > 1000 bytes/);
+    await materials.UploadCourseMaterial(ledger.school(), 'M2', 'C1', 'M1', 'Cours 2', 'COURS', 'QmM2', '400');
+
+    const expected = { classId: 'C1', materialCount: 3, usedBytes: 1000, maxTotalBytes: 1000, remainingBytes: 0 };
+    assert.deepStrictEqual(JSON.parse(await materials.GetClassStorageUsage(ledger.school(), 'C1')), expected);
+    ledger.couchdb = false;
+    assert.deepStrictEqual(JSON.parse(await materials.GetClassStorageUsage(ledger.school(), 'C1')), expected);
+});
+
+test('GetClassMaterialsSearch matches titles, or types on request, for enrolled students', async () => {
+    const ledger = new MemoryLedger();
+    const materials = new MaterialContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Algo', 'Graphes');
+    await new ClassContract().AddModuleToClass(ledger.school(), 'C1', 'M1');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 's1');
+    await materials.UploadCourseMaterial(ledger.school(), 'MA1', 'C1', 'M1', 'Intro to Graphs', 'COURS', 'QmA1', '10');
+    await materials.UploadCourseMaterial(ledger.school(), 'MA2', 'C1', 'M1', 'Graph lab', 'TP', 'QmA2', '10');
+    await materials.UploadCourseMaterial(ledger.school(), 'MA3', 'C1', 'M1', 'Sorting', 'TP', 'QmA3', '10');
+
+    const ids = async (keyword, includeType) => JSON.parse(await materials.GetClassMaterialsSearch(ledger.student('s1'), 'C1', keyword, includeType))
+        .map((material) => material.id);
+    assert.deepStrictEqual(await ids('GRAPH'), ['MA1', 'MA2']);
+    assert.deepStrictEqual(await ids('tp', 'true'), ['MA2', 'MA3']);
+    assert.deepStrictEqual(await ids('tp'), []);
+    await assert.rejects(materials.GetClassMaterialsSearch(ledger.student('s2'), 'C1', 'graph'), /You must be enrolled in class C1 to access materials/);
+    await assert.rejects(materials.GetClassMaterialsSearch(ledger.school(), 'C1', ' '), /Missing keyword/);
+});
+
+test('ReorderClassMaterials sets the display order of every material of the class', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const materials = new MaterialContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Maths', 'Algèbre', '10');
+    await classes.CreateClass(ledger.school(), 'B', 'Physique', 'Optique', '10');
+    await classes.EnrollStudent(ledger.school(), 'A', 's1');
+    for (const materialId of ['M3', 'M1', 'M2']) {
+        await materials.UploadCourseMaterial(ledger.school(), materialId, 'A', 'M', `T${materialId}`, 'COURS', `Qm${materialId}`);
+    }
+    await materials.UploadCourseMaterial(ledger.school(), 'X', 'B', 'M', 'TX', 'TP', 'QmX');
+    const order = async () => JSON.parse(await materials.GetCourseMaterials(ledger.student('s1'), 'A'))
+        .map((material) => [material.id, material.order]);
+
+    // Par défaut: ordre de dépôt
+    assert.deepStrictEqual(await order(), [['M3', 1], ['M1', 2], ['M2', 3]]);
+    await assert.rejects(materials.ReorderClassMaterials(ledger.school(), 'A', '["M1","M2"]'), /missing materials of class A: M3/);
+    await assert.rejects(materials.ReorderClassMaterials(ledger.school(), 'A', '["M1","M2","M3","X"]'), /not materials of class A: X/);
+    await assert.rejects(materials.ReorderClassMaterials(ledger.school(), 'A', '["M1","M1","M2","M3"]'), /each material must appear once/);
+    await assert.rejects(materials.ReorderClassMaterials(ledger.school('o@school.academic.edu'), 'A', '["M1","M2","M3"]'),
+        /Only the teacher of class A or an admin can reorder its materials/);
+
+    await materials.ReorderClassMaterials(ledger.school(), 'A', '["M2","M1","M3"]');
+    assert.deepStrictEqual(await order(), [['M2', 1], ['M1', 2], ['M3', 3]]);
+    assert.deepStrictEqual(JSON.parse(await materials.GetClassMaterialsSearch(ledger.student('s1'), 'A', 'tm')).map((material) => material.id),
+        ['M2', 'M1', 'M3']);
+    await materials.ReorderClassMaterials(ledger.admin(), 'A', '["M3","M2","M1"]');
+    assert.deepStrictEqual(await order(), [['M3', 1], ['M2', 2], ['M1', 3]]);
+});
@@ -0,0 +1,87 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const NotificationContract = require('../lib/notification');
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('ReassignTeacher notifies the active students, who mark their own notifications read', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const notifications = new NotificationContract();
+    await classes.CreateClass(ledger.school('T1'), 'C1', 'Maths', 'Algèbre', '5');
+    for (const studentId of ['s1', 's2', 's3']) {
+        await classes.EnrollStudent(ledger.school(), 'C1', studentId);
+    }
+    await classes.WithdrawStudent(ledger.school(), 'C1', 's2');
+
+    await assert.rejects(classes.ReassignTeacher(ledger.school('T9'), 'C1', 'T2'), /Only the teacher of class C1 or an admin can manage its teacher/);
+    assert.deepStrictEqual(JSON.parse(await classes.ReassignTeacher(ledger.school('T1'), 'C1', 'T2')),
+        { classId: 'C1', previousTeacher: 'T1', newTeacher: 'T2', notified: ['s1', 's3'] });
+    assert.strictEqual(ledger.lastEvent().name, 'ClassTeacherChanged');
+    assert.strictEqual(ledger.get('C1').teacher, 'T2');
+
+    const [notification] = JSON.parse(await notifications.GetMyNotifications(ledger.student('s1')));
+    assert.strictEqual(notification.type, 'ClassTeacherChanged');
+    assert.deepStrictEqual(notification.data, { classId: 'C1', className: 'Maths', newTeacher: 'T2', previousTeacher: 'T1' });
+    assert.deepStrictEqual(JSON.parse(await notifications.GetMyNotifications(ledger.student('s2'))), []);
+
+    await assert.rejects(notifications.MarkNotificationRead(ledger.student('s3'), notification.id), /Only the recipient can mark a notification as read/);
+    await notifications.MarkNotificationRead(ledger.student('s1'), notification.id);
+    assert.deepStrictEqual(JSON.parse(await notifications.GetMyNotifications(ledger.student('s1'), 'true')), []);
+});
+
+test('students count and mark all of their own unread notifications', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const notifications = new NotificationContract();
+    await classes.CreateClass(ledger.school('T1'), 'C1', 'Maths', 'Algèbre', '5');
+    await classes.EnrollStudent(ledger.school(), 'C1', 's1');
+    await classes.ReassignTeacher(ledger.school('T1'), 'C1', 'T2');
+    await classes.ReassignTeacher(ledger.school('T2'), 'C1', 'T3');
+
+    assert.deepStrictEqual(JSON.parse(await notifications.GetUnreadNotificationCount(ledger.student('s1'), 's1')), { studentId: 's1', unreadCount: 2 });
+    await assert.rejects(notifications.GetUnreadNotificationCount(ledger.student('s2'), 's1'), /You can only manage your own notifications/);
+    await assert.rejects(notifications.MarkAllNotificationsRead(ledger.student('s2'), 's1'), /You can only manage your own notifications/);
+    assert.deepStrictEqual(JSON.parse(await notifications.MarkAllNotificationsRead(ledger.student('s1'), 's1')),
+        { studentId: 's1', marked: 2, unreadCount: 0 });
+    assert.strictEqual(JSON.parse(await notifications.GetUnreadNotificationCount(ledger.student('s1'), 's1')).unreadCount, 0);
+    assert.strictEqual(JSON.parse(await notifications.GetMyNotifications(ledger.student('s1'))).length, 2);
+});
+
+test('grade notifications are rendered in the language chosen by each student, English by default', async () => {
+    const ledger = new MemoryLedger();
+    const classes = new ClassContract();
+    const grades = new GradeContract();
+    const notifications = new NotificationContract();
+    await classes.CreateClass(ledger.school(), 'A', 'Algèbre', 'Description', '', 'S1');
+    for (const studentId of ['en1', 'fr1', 'de1', 'frca']) {
+        await classes.EnrollStudent(ledger.school(), 'A', studentId);
+    }
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'A', 'M1', 'Partiel 1', '2026-01-01T10:00:00Z', 'QmExam');
+    await notifications.SetMyNotificationLanguage(ledger.student('fr1'), 'fr');
+    await notifications.SetMyNotificationLanguage(ledger.student('de1'), 'de');
+    await notifications.SetMyNotificationLanguage(ledger.student('frca'), 'fr-CA');
+    await assert.rejects(notifications.SetMyNotificationLanguage(ledger.student('x'), 'French!'),
+        /Invalid language: French! \(expected a language tag such as "fr" or "en-US"\)/);
+
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'en1', '12', '');
+    for (const studentId of ['fr1', 'de1', 'frca']) {
+        await grades.SubmitGrade(ledger.school(), `G-${studentId}`, 'E1', studentId, '10', '');
+    }
+    await grades.PublishExamGrades(ledger.school(), 'E1');
+
+    // "de" n'a pas de modèle: repli sur l'anglais; "fr-CA" utilise le modèle "fr"
+    const english = 'Your grade for Partiel 1 in Algèbre is available';
+    const french = 'Votre note pour Partiel 1 en Algèbre est disponible';
+    const expected = { en1: ['en', english, 'G1'], fr1: ['fr', french, 'G-fr1'], de1: ['en', english, 'G-de1'], frca: ['fr', french, 'G-frca'] };
+    for (const [studentId, [language, message, gradeId]] of Object.entries(expected)) {
+        const [notification] = JSON.parse(await notifications.GetMyNotifications(ledger.student(studentId)));
+        assert.deepStrictEqual([notification.type, notification.language, notification.message, notification.data.gradeId],
+            ['GradePublished', language, message, gradeId]);
+    }
+});
@@ -0,0 +1,32 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const MaterialContract = require('../lib/material');
+const PinContract = require('../lib/pin');
+const RoleContract = require('../lib/role');
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('GetUnpinnedAssets lists IPFS hashes until the oracle records them as pinned', async () => {
+    const ledger = new MemoryLedger();
+    const pins = new PinContract();
+    const oracle = 'oracle@school.academic.edu';
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new MaterialContract().UploadCourseMaterial(ledger.school(), 'M1', 'C1', 'M1', 'Intro', 'COURS', 'QmM1', '10');
+    await new MaterialContract().UploadCourseMaterial(ledger.school(), 'M2', 'C1', 'M1', 'Lab', 'TP', 'QmM2', '10');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-02-01T10:00:00Z', 'QmE1');
+    const unpinned = async () => JSON.parse(await pins.GetUnpinnedAssets(ledger.school())).map((asset) => [asset.id, asset.field, asset.pinStatus]);
+    assert.deepStrictEqual(await unpinned(), [['E1', 'examFileHash', null], ['M1', 'ipfsHash', null], ['M2', 'ipfsHash', null]]);
+
+    await assert.rejects(pins.RecordPinStatus(ledger.school(), 'QmM1', 'pinned'), /Only admins and the pinning oracle can record pin statuses/);
+    await new RoleContract().GrantRole(ledger.admin(), oracle, 'oracle');
+    await pins.RecordPinStatus(ledger.school(oracle), 'QmM1', 'pinned');
+    await pins.RecordPinStatus(ledger.admin(), 'QmE1', 'failed');
+    await assert.rejects(pins.RecordPinStatus(ledger.admin(), 'QmE1', 'ok'), /Invalid status: ok \(supported: pinned, unpinned, failed\)/);
+
+    assert.deepStrictEqual(await unpinned(), [['E1', 'examFileHash', 'failed'], ['M2', 'ipfsHash', null]]);
+    await assert.rejects(pins.GetUnpinnedAssets(ledger.student('s1')), /Only SchoolOrg members can view pinning status/);
+});
@@ -0,0 +1,93 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const MaterialContract = require('../lib/material');
+const { serializeRecord, canonicalStringify } = require('../lib/records');
+const AcademicContract = require('../index').contracts[0];
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('reading a record under the wrong type or with invalid JSON fails explicitly', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-03-01T10:00:00Z', 'QmExam');
+
+    await assert.rejects(exams.GetExam(ledger.school(), 'C1'), /Type mismatch: C1 holds a "class" record, expected "exam"/);
+    await assert.rejects(new GradeContract().GetGrade(ledger.school(), 'E1'), /Type mismatch: E1 holds a "exam" record, expected "grade"/);
+    await assert.rejects(new ClassContract().GetEnrolledStudents(ledger.school(), 'E1'), /expected "class"/);
+    await assert.rejects(new AcademicContract().GetExam(ledger.school(), 'C1'), /Type mismatch/);
+
+    ledger.put('E2', '{not json');
+    await assert.rejects(exams.GetExam(ledger.school(), 'E2'), /Corrupted record: E2 is not valid JSON/);
+    assert.strictEqual(JSON.parse(await exams.GetExam(ledger.school(), 'E1')).id, 'E1');
+});
+
+test('grade listings skip a grade whose exam key holds another record type', async () => {
+    const ledger = new MemoryLedger();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '15', '');
+    ledger.put('G2', Object.assign(ledger.get('G1'), { id: 'G2', examId: 'C1' }));
+
+    for (const couchdb of [true, false]) {
+        ledger.couchdb = couchdb;
+        const listings = [
+            await grades.GetMyGrades(ledger.student('alice'), 'C1'),
+            await grades.GetStudentGrades(ledger.school(), 'alice', 'C1'),
+            await grades.GetClassGrades(ledger.school(), 'C1'),
+        ];
+        for (const listing of listings) {
+            const parsed = JSON.parse(listing);
+            const items = Array.isArray(parsed) ? parsed : parsed.grades;
+            assert.deepStrictEqual(items.map((grade) => [grade.id, grade.examTitle]), [['G1', 'Partiel']]);
+        }
+    }
+});
+
+test('serializeRecord produces the same bytes whatever the key insertion order', async () => {
+    const first = { id: 'C1', descriptions: { fr: 'Algèbre', en: 'Algebra' }, modules: [{ name: 'M1', id: 1 }] };
+    const second = { modules: [{ id: 1, name: 'M1' }], descriptions: { en: 'Algebra', fr: 'Algèbre' }, id: 'C1' };
+    assert.ok(serializeRecord(first).equals(serializeRecord(second)));
+    assert.strictEqual(serializeRecord(first).toString(),
+        '{"descriptions":{"en":"Algebra","fr":"Algèbre"},"id":"C1","modules":[{"id":1,"name":"M1"}]}');
+
+    // Mêmes règles que JSON.stringify pour undefined et toJSON
+    assert.strictEqual(canonicalStringify({ b: undefined, a: [undefined], d: new Date(0) }), '{"a":[null],"d":"1970-01-01T00:00:00.000Z"}');
+});
+
+test('contracts write records as canonical JSON', async () => {
+    const ledger = new MemoryLedger();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    const stored = ledger.state.get('C1').toString();
+    assert.strictEqual(stored, canonicalStringify(JSON.parse(stored)));
+});
+
+test('written timestamps come from the transaction, so every peer writes the same bytes', async () => {
+    const ledger = new MemoryLedger();
+    const exams = new ExamContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await exams.CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-10T13:00:00Z', 'QmExam');
+    await new MaterialContract().UploadCourseMaterial(ledger.school(), 'MA1', 'C1', 'M1', 'Intro', 'COURS', 'QmMaterial', '10');
+    assert.strictEqual(ledger.get('E1').createdAt, '2026-01-10T10:00:00.000Z');
+    assert.strictEqual(ledger.get('MA1').uploadedAt, '2026-01-10T10:00:00.000Z');
+
+    await assert.rejects(exams.UploadCorrection(ledger.school(), 'E1', 'QmCorrection'),
+        /Cannot upload correction before exam date. Exam is in 3 hours/);
+    ledger.setTime('2026-01-10T14:00:00Z');
+    await exams.UploadCorrection(ledger.school(), 'E1', 'QmCorrection');
+    assert.strictEqual(ledger.get('E1').correctionUploadedAt, '2026-01-10T14:00:00.000Z');
+
+    const grades = new GradeContract();
+    await grades.SubmitGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '');
+    ledger.advance(60);
+    await grades.UpdateGrade(ledger.school(), 'G1', '13', 'recount');
+    assert.strictEqual(ledger.get('G1').updatedAt, '2026-01-10T14:01:00.000Z');
+});
@@ -0,0 +1,83 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const GradeContract = require('../lib/grade');
+const ConfigContract = require('../lib/config');
+const RoleContract = require('../lib/role');
+const { MemoryLedger, DEFAULT_TEACHER } = require('./helpers/ledger');
+
+test('roles granted on the ledger take effect immediately and are revoked just as fast', async () => {
+    const ledger = new MemoryLedger();
+    const roles = new RoleContract();
+    const grades = new GradeContract();
+    await new ConfigContract().SetSystemConfig(ledger.admin(), '{"gradeReleaseDelayHours":48}');
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'bob');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-10T09:00:00Z', 'QmExam');
+
+    assert.strictEqual(JSON.parse(await roles.GetMyRole(ledger.school())).role, 'teacher');
+    await assert.rejects(roles.GrantRole(ledger.school(), DEFAULT_TEACHER, 'admin'), /Only admins can manage roles/);
+    await assert.rejects(roles.GrantRole(ledger.admin(), 'x', 'student'), /Invalid role/);
+
+    await roles.GrantRole(ledger.admin(), DEFAULT_TEACHER, 'admin');
+    assert.strictEqual(JSON.parse(await roles.GetMyRole(ledger.school())).role, 'admin');
+    await grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '12', '', 'true', 'exam board');
+
+    await roles.RevokeRole(ledger.admin(), DEFAULT_TEACHER);
+    await assert.rejects(grades.PublishGrade(ledger.school(), 'G2', 'E1', 'bob', '12', '', 'true', 'exam board'),
+        /Access Denied: Only SchoolOrg members \(teachers\) can publish grades/);
+    await roles.GrantRole(ledger.admin(), DEFAULT_TEACHER, 'teacher');
+    await assert.rejects(grades.PublishGrade(ledger.school(), 'G2', 'E1', 'bob', '12', '', 'true', 'exam board'),
+        /Only an admin can override the grade release embargo/);
+
+    // Sans rôle sur le ledger, l'attribut de certificat s'applique
+    assert.strictEqual(JSON.parse(await roles.GetMyRole(ledger.school('t2', { role: 'admin' }))).role, 'admin');
+    assert.strictEqual(JSON.parse(await roles.GetMyRole(ledger.student('alice'))).role, 'student');
+});
+
+test('revoking a role also overrides the certificate attribute until a new grant', async () => {
+    const ledger = new MemoryLedger();
+    const roles = new RoleContract();
+
+    await roles.RevokeRole(ledger.admin(), DEFAULT_TEACHER);
+    assert.strictEqual(JSON.parse(await roles.GetMyRole(ledger.school())).role, null);
+    await assert.rejects(roles.RevokeRole(ledger.admin(), DEFAULT_TEACHER), /is already revoked/);
+
+    await roles.RevokeRole(ledger.admin(), 'svc@school.academic.edu');
+    assert.strictEqual(JSON.parse(await roles.GetMyRole(ledger.school('svc@school.academic.edu', { role: 'oracle' }))).role, null);
+
+    await roles.GrantRole(ledger.admin(), DEFAULT_TEACHER, 'oracle');
+    assert.strictEqual(JSON.parse(await roles.GetMyRole(ledger.school())).role, 'oracle');
+});
+
+test('revoked identities and the pinning oracle cannot use teacher write paths', async () => {
+    const ledger = new MemoryLedger();
+    const roles = new RoleContract();
+    const grades = new GradeContract();
+    await new ClassContract().CreateClass(ledger.school(), 'C1', 'Maths', 'Algèbre');
+    await new ClassContract().EnrollStudent(ledger.school(), 'C1', 'alice');
+    await new ExamContract().CreateExam(ledger.school(), 'E1', 'C1', 'M1', 'Partiel', '2026-01-01T10:00:00Z', 'QmExam');
+
+    const oracle = () => ledger.school('pin@school.academic.edu', { role: 'oracle' });
+    await assert.rejects(grades.PublishGrade(oracle(), 'G1', 'E1', 'alice', '12', ''), /Only SchoolOrg members \(teachers\) can publish grades/);
+    await assert.rejects(new ExamContract().CreateExam(oracle(), 'E2', 'C1', 'M1', 'Quiz', '2026-01-02T10:00:00Z', 'QmExam'),
+        /Only SchoolOrg members/);
+    await assert.rejects(new ClassContract().CreateClass(oracle(), 'C2', 'Physique', 'Mécanique'), /Only SchoolOrg members/);
+    await assert.rejects(new ConfigContract().SetSystemConfig(ledger.school(), '{"passPercent":60}'),
+        /Only admins can update the system configuration/);
+
+    // Le teacher révoqué reste responsable de C1 mais perd toute écriture
+    await roles.RevokeRole(ledger.admin(), DEFAULT_TEACHER);
+    await assert.rejects(grades.PublishGrade(ledger.school(), 'G1', 'E1', 'alice', '12', ''), /Only SchoolOrg members \(teachers\) can publish grades/);
+    await assert.rejects(new ClassContract().SetSeatCapacity(ledger.school(), 'C1', 'lab', '10'), /Only SchoolOrg members can manage class seat capacities/);
+    await assert.rejects(new ClassContract().EnrollStudent(ledger.school(), 'C1', 'bob'), /You must be a member of SchoolOrg or StudentsOrg/);
+    assert.strictEqual(ledger.get('G1'), null);
+
+    await roles.GrantRole(ledger.admin(), 'head@school.academic.edu', 'department-head');
+    await grades.PublishGrade(ledger.school('head@school.academic.edu'), 'G1', 'E1', 'alice', '12', '');
+});
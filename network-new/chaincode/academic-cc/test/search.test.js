@@ -0,0 +1,28 @@
+'use strict';
+
+const test = require('node:test');
+const assert = require('node:assert');
+
+const ClassContract = require('../lib/class');
+const ExamContract = require('../lib/exam');
+const MaterialContract = require('../lib/material');
+const SearchContract = require('../lib/search');
+const { MemoryLedger } = require('./helpers/ledger');
+
+test('Search matches classes, exams and materials case-insensitively, with or without CouchDB', async () => {
+    for (const couchdb of [true, false]) {
+        const ledger = new MemoryLedger();
+        ledger.couchdb = couchdb;
+        const search = new SearchContract();
+        await new ClassContract().CreateClass(ledger.school(), 'A', 'Network Security', 'crypto basics');
+        await new ClassContract().CreateClass(ledger.school(), 'B', 'Databases', 'sql');
+        await new ExamContract().CreateExam(ledger.school(), 'E1', 'B', 'M1', 'Security of SQL', '2026-01-01T09:00:00Z', 'QmExam');
+        await new MaterialContract().UploadCourseMaterial(ledger.school(), 'M1', 'A', 'M1', 'Crypto notes', 'COURS', 'QmM1');
+
+        const results = JSON.parse(await search.Search(ledger.school(), 'secur'));
+        assert.deepStrictEqual(results.map((result) => [result.type, result.id, result.matchedFields]),
+            [['class', 'A', ['name']], ['exam', 'E1', ['title']]]);
+        assert.deepStrictEqual(JSON.parse(await search.Search(ledger.school(), 'CRYPTO', '["material"]')).map((result) => result.id), ['M1']);
+        await assert.rejects(search.Search(ledger.student('a'), 'x'), /Only SchoolOrg members can search assets/);
+    }
+});